@@ -94,10 +94,11 @@ type registerNormalizeDeleteVariablesFunc func(walker *astvisitor.Walker) *delet
 
 // OperationNormalizer walks a given AST and applies all registered rules
 type OperationNormalizer struct {
-	operationWalkers     []*astvisitor.Walker
-	variablesExtraction  *variablesExtractionVisitor
-	options              options
-	definitionNormalizer *DefinitionNormalizer
+	operationWalkers           []*astvisitor.Walker
+	variablesExtraction        *variablesExtractionVisitor
+	removeOperationDefinitions *removeOperationDefinitionsVisitor
+	options                    options
+	definitionNormalizer       *DefinitionNormalizer
 }
 
 // NewNormalizer creates a new OperationNormalizer and sets up all default rules
@@ -131,10 +132,13 @@ func NewWithOpts(opts ...Option) *OperationNormalizer {
 }
 
 type options struct {
-	removeFragmentDefinitions bool
-	extractVariables          bool
-	removeUnusedVariables     bool
-	normalizeDefinition       bool
+	removeFragmentDefinitions             bool
+	extractVariables                      bool
+	removeUnusedVariables                 bool
+	normalizeDefinition                   bool
+	removeNotMatchingOperationDefinitions bool
+	injectTypename                        bool
+	removeInjectedTypename                bool
 }
 
 type Option func(options *options)
@@ -163,8 +167,32 @@ func WithNormalizeDefinition() Option {
 	}
 }
 
+// WithRemoveNotMatchingOperationDefinitions removes all operations except the one passed to NormalizeNamedOperation
+func WithRemoveNotMatchingOperationDefinitions() Option {
+	return func(options *options) {
+		options.removeNotMatchingOperationDefinitions = true
+	}
+}
+
+// WithInjectTypename adds a __typename field to all selection sets on interfaces and unions which don't select it
+func WithInjectTypename() Option {
+	return func(options *options) {
+		options.injectTypename = true
+	}
+}
+
+// WithRemoveInjectedTypename removes the __typename fields added by WithInjectTypename, e.g. to print the operation as sent by the client
+func WithRemoveInjectedTypename() Option {
+	return func(options *options) {
+		options.removeInjectedTypename = true
+	}
+}
+
 func (o *OperationNormalizer) setupOperationWalkers() {
 	fragmentInline := astvisitor.NewWalker(48)
+	if o.options.removeNotMatchingOperationDefinitions {
+		o.removeOperationDefinitions = removeOperationDefinitions(&fragmentInline)
+	}
 	fragmentSpreadInline(&fragmentInline)
 	directiveIncludeSkip(&fragmentInline)
 
@@ -173,6 +201,13 @@ func (o *OperationNormalizer) setupOperationWalkers() {
 	mergeInlineFragments(&other)
 	mergeFieldSelections(&other)
 	deduplicateFields(&other)
+	deduplicateDirectives(&other)
+	if o.options.injectTypename {
+		injectTypename(&other)
+	}
+	if o.options.removeInjectedTypename {
+		removeInjectedTypename(&other)
+	}
 	if o.options.extractVariables {
 		o.variablesExtraction = extractVariables(&other)
 	}
@@ -200,6 +235,9 @@ func (o *OperationNormalizer) NormalizeOperation(operation, definition *ast.Docu
 		}
 	}
 
+	if o.removeOperationDefinitions != nil {
+		o.removeOperationDefinitions.operationName = nil
+	}
 	for i := range o.operationWalkers {
 		o.operationWalkers[i].Walk(operation, definition, report)
 		if report.HasErrors() {
@@ -220,6 +258,9 @@ func (o *OperationNormalizer) NormalizeNamedOperation(operation, definition *ast
 	if o.variablesExtraction != nil {
 		o.variablesExtraction.operationName = operationName
 	}
+	if o.removeOperationDefinitions != nil {
+		o.removeOperationDefinitions.operationName = operationName
+	}
 	for i := range o.operationWalkers {
 		o.operationWalkers[i].Walk(operation, definition, report)
 		if report.HasErrors() {
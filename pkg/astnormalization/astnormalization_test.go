@@ -288,6 +288,79 @@ schema {
 	})
 }
 
+func TestOperationNormalizer_RemoveNotMatchingOperationDefinitions(t *testing.T) {
+	schema := `
+scalar String
+
+type Query {
+	country: Country!
+}
+
+type Country {
+	name: String!
+	code: String!
+}
+
+schema {
+    query: Query
+}
+`
+
+	runNormalization := func(t *testing.T, query, operationName, expectedOperation string) {
+		t.Helper()
+
+		definition := unsafeparser.ParseGraphqlDocumentString(schema)
+		operation := unsafeparser.ParseGraphqlDocumentString(query)
+
+		report := operationreport.Report{}
+		normalizer := NewWithOpts(
+			WithRemoveNotMatchingOperationDefinitions(),
+			WithRemoveFragmentDefinitions(),
+		)
+		normalizer.NormalizeNamedOperation(&operation, &definition, []byte(operationName), &report)
+		require.False(t, report.HasErrors(), report.Error())
+
+		assert.Equal(t, expectedOperation, unsafeprinter.Print(&operation, nil))
+	}
+
+	t.Run("should keep the selected operation only", func(t *testing.T) {
+		runNormalization(t,
+			`query A {country {name}} query B {country {...Fields}} query C {country {code}} fragment Fields on Country {name code}`,
+			"B",
+			`query B {country {name code}}`)
+	})
+
+	t.Run("should keep a single anonymous operation", func(t *testing.T) {
+		runNormalization(t,
+			`{country {...Fields}} fragment Fields on Country {name}`,
+			"",
+			`{country {name}}`)
+	})
+
+	t.Run("should keep all operations if none matches", func(t *testing.T) {
+		runNormalization(t,
+			`query A {country {name}} query B {country {code}}`,
+			"C",
+			`query A {country {name}} query B {country {code}}`)
+	})
+}
+
+func TestOperationNormalizer_InjectTypename(t *testing.T) {
+	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	operation := unsafeparser.ParseGraphqlDocumentString(`query Q {catOrDog {...DogFields} pet {__typename name}} fragment DogFields on Dog {name}`)
+	report := operationreport.Report{}
+	NewWithOpts(WithInjectTypename(), WithRemoveFragmentDefinitions()).NormalizeOperation(&operation, &definition, &report)
+	require.False(t, report.HasErrors(), report.Error())
+	assert.Equal(t, `query Q {catOrDog {... on Dog {name} __typename} pet {__typename name}}`, unsafeprinter.Print(&operation, nil))
+
+	report = operationreport.Report{}
+	NewWithOpts(WithRemoveInjectedTypename()).NormalizeOperation(&operation, &definition, &report)
+	require.False(t, report.HasErrors(), report.Error())
+	assert.Equal(t, `query Q {catOrDog {... on Dog {name}} pet {__typename name}}`, unsafeprinter.Print(&operation, nil))
+}
+
 func BenchmarkAstNormalization(b *testing.B) {
 
 	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
@@ -0,0 +1,50 @@
+package astnormalization
+
+import (
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+)
+
+// deduplicateDirectives removes directives which are exact duplicates of a previous directive on the same node
+// directives with the same name but different arguments, e.g. repeatable directives, are kept
+func deduplicateDirectives(walker *astvisitor.Walker) {
+	visitor := deduplicateDirectivesVisitor{}
+	walker.RegisterEnterDocumentVisitor(&visitor)
+	walker.RegisterEnterFieldVisitor(&visitor)
+	walker.RegisterEnterInlineFragmentVisitor(&visitor)
+	walker.RegisterEnterFragmentSpreadVisitor(&visitor)
+}
+
+type deduplicateDirectivesVisitor struct {
+	operation *ast.Document
+}
+
+func (d *deduplicateDirectivesVisitor) EnterDocument(operation, definition *ast.Document) {
+	d.operation = operation
+}
+
+func (d *deduplicateDirectivesVisitor) EnterField(ref int) {
+	d.deduplicate(ast.Node{Kind: ast.NodeKindField, Ref: ref})
+}
+
+func (d *deduplicateDirectivesVisitor) EnterInlineFragment(ref int) {
+	d.deduplicate(ast.Node{Kind: ast.NodeKindInlineFragment, Ref: ref})
+}
+
+func (d *deduplicateDirectivesVisitor) EnterFragmentSpread(ref int) {
+	d.deduplicate(ast.Node{Kind: ast.NodeKindFragmentSpread, Ref: ref})
+}
+
+// deduplicate removes the duplicates before the walker visits the directives of the node
+func (d *deduplicateDirectivesVisitor) deduplicate(node ast.Node) {
+	directives := d.operation.NodeDirectives(node)
+	for i := len(directives) - 1; i > 0; i-- {
+		for j := 0; j < i; j++ {
+			if d.operation.DirectivesAreEqual(directives[i], directives[j]) {
+				d.operation.RemoveDirectiveFromNode(node, directives[i])
+				directives = d.operation.NodeDirectives(node)
+				break
+			}
+		}
+	}
+}
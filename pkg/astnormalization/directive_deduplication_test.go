@@ -0,0 +1,51 @@
+package astnormalization
+
+import "testing"
+
+func TestDeduplicateDirectives(t *testing.T) {
+	t.Run("remove exact duplicates", func(t *testing.T) {
+		run(deduplicateDirectives, testDefinition, `
+				query q($x: Boolean!) {
+					dog @include(if: $x) @include(if: $x) {
+						name @skip(if: $x) @skip(if: $x) @skip(if: $x)
+						... on Dog @include(if: $x) @include(if: $x) {
+							nickname
+						}
+					}
+				}`, `
+				query q($x: Boolean!) {
+					dog @include(if: $x) {
+						name @skip(if: $x)
+						... on Dog @include(if: $x) {
+							nickname
+						}
+					}
+				}`)
+	})
+	t.Run("keep directives with the same name but different arguments", func(t *testing.T) {
+		run(deduplicateDirectives, testDefinition, `
+				query q($x: Boolean!, $y: Boolean!) {
+					dog {
+						name @include(if: $x) @include(if: $y) @include(if: $x)
+					}
+				}`, `
+				query q($x: Boolean!, $y: Boolean!) {
+					dog {
+						name @include(if: $x) @include(if: $y)
+					}
+				}`)
+	})
+	t.Run("keep repeatable directives", func(t *testing.T) {
+		run(deduplicateDirectives, testDefinition, `
+				{
+					dog {
+						name @tag(name: "a") @tag(name: "b")
+					}
+				}`, `
+				{
+					dog {
+						name @tag(name: "a") @tag(name: "b")
+					}
+				}`)
+	})
+}
@@ -33,3 +33,89 @@ func TestDirectiveIncludeVisitor(t *testing.T) {
 				}`)
 	})
 }
+
+func TestDirectiveIncludeSkipVisitor_StaticConditions(t *testing.T) {
+	t.Run("include true removes the directive", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				{
+					dog {
+						name @include(if: true)
+					}
+				}`, `
+				{
+					dog {
+						name
+					}
+				}`)
+	})
+	t.Run("include false removes the field", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				{
+					dog {
+						name @include(if: false)
+						nickname
+					}
+				}`, `
+				{
+					dog {
+						nickname
+					}
+				}`)
+	})
+	t.Run("skip false removes the directive", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				{
+					dog {
+						name @skip(if: false)
+					}
+				}`, `
+				{
+					dog {
+						name
+					}
+				}`)
+	})
+	t.Run("skip true removes the field", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				{
+					dog {
+						name @skip(if: true)
+						nickname
+					}
+				}`, `
+				{
+					dog {
+						nickname
+					}
+				}`)
+	})
+	t.Run("multiple static directives on one field", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				{
+					dog {
+						name @include(if: true) @skip(if: true)
+						nickname @skip(if: false) @include(if: true)
+					}
+				}`, `
+				{
+					dog {
+						nickname
+					}
+				}`)
+	})
+	t.Run("variable conditions are left untouched", func(t *testing.T) {
+		run(directiveIncludeSkip, testDefinition, `
+				query q($yes: Boolean!, $no: Boolean!) {
+					dog {
+						name @include(if: $yes)
+						nickname @skip(if: $no)
+					}
+				}`, `
+				query q($yes: Boolean!, $no: Boolean!) {
+					dog {
+						name @include(if: $yes)
+						nickname @skip(if: $no)
+					}
+				}`)
+	})
+}
@@ -0,0 +1,34 @@
+package astnormalization
+
+import (
+	"bytes"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+)
+
+func removeOperationDefinitions(walker *astvisitor.Walker) *removeOperationDefinitionsVisitor {
+	visitor := &removeOperationDefinitionsVisitor{}
+	walker.RegisterEnterDocumentVisitor(visitor)
+	return visitor
+}
+
+// removeOperationDefinitionsVisitor removes all operation definitions except the one named operationName
+// If no operation matches, e.g. because no operation name is given, the document stays untouched
+type removeOperationDefinitionsVisitor struct {
+	operationName []byte
+}
+
+func (r *removeOperationDefinitionsVisitor) EnterDocument(operation, definition *ast.Document) {
+	if len(r.operationName) == 0 || !operation.OperationNameExists(string(r.operationName)) {
+		return
+	}
+	for i := range operation.RootNodes {
+		if operation.RootNodes[i].Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		if !bytes.Equal(operation.OperationDefinitionNameBytes(operation.RootNodes[i].Ref), r.operationName) {
+			operation.RootNodes[i].Kind = ast.NodeKindUnknown
+		}
+	}
+}
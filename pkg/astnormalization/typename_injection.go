@@ -0,0 +1,88 @@
+package astnormalization
+
+import (
+	"bytes"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/position"
+)
+
+// injectTypename adds a __typename field to each selection set on an interface or union
+// so that the type of the returned objects is known when resolving fragments
+func injectTypename(walker *astvisitor.Walker) {
+	visitor := injectTypenameVisitor{
+		Walker: walker,
+	}
+	walker.RegisterEnterDocumentVisitor(&visitor)
+	walker.RegisterEnterSelectionSetVisitor(&visitor)
+}
+
+type injectTypenameVisitor struct {
+	*astvisitor.Walker
+	operation *ast.Document
+}
+
+func (i *injectTypenameVisitor) EnterDocument(operation, definition *ast.Document) {
+	i.operation = operation
+}
+
+func (i *injectTypenameVisitor) EnterSelectionSet(ref int) {
+	if !isAbstractType(i.EnclosingTypeDefinition) {
+		return
+	}
+	if i.operation.SelectionSetHasFieldSelectionWithNameOrAliasBytes(ref, literal.TYPENAME) {
+		return
+	}
+	// injected fields have no position, this tells them apart from fields selected by the client
+	field := i.operation.AddField(ast.Field{
+		Name: i.operation.Input.AppendInputBytes(literal.TYPENAME),
+	})
+	i.operation.AddSelection(ref, ast.Selection{
+		Kind: ast.SelectionKindField,
+		Ref:  field.Ref,
+	})
+}
+
+// removeInjectedTypename removes the __typename fields added by injectTypename
+// __typename fields selected by the client are kept
+func removeInjectedTypename(walker *astvisitor.Walker) {
+	visitor := removeInjectedTypenameVisitor{
+		Walker: walker,
+	}
+	walker.RegisterEnterDocumentVisitor(&visitor)
+	walker.RegisterEnterSelectionSetVisitor(&visitor)
+}
+
+type removeInjectedTypenameVisitor struct {
+	*astvisitor.Walker
+	operation *ast.Document
+}
+
+func (r *removeInjectedTypenameVisitor) EnterDocument(operation, definition *ast.Document) {
+	r.operation = operation
+}
+
+func (r *removeInjectedTypenameVisitor) EnterSelectionSet(ref int) {
+	if !isAbstractType(r.EnclosingTypeDefinition) {
+		return
+	}
+	for i := len(r.operation.SelectionSets[ref].SelectionRefs) - 1; i >= 0; i-- {
+		selection := r.operation.SelectionSets[ref].SelectionRefs[i]
+		if r.operation.Selections[selection].Kind != ast.SelectionKindField {
+			continue
+		}
+		field := r.operation.Selections[selection].Ref
+		if r.operation.Fields[field].Alias.IsDefined || r.operation.Fields[field].Position != (position.Position{}) {
+			continue
+		}
+		if bytes.Equal(r.operation.FieldNameBytes(field), literal.TYPENAME) {
+			r.operation.RemoveFromSelectionSet(ref, i)
+		}
+	}
+}
+
+func isAbstractType(node ast.Node) bool {
+	return node.Kind == ast.NodeKindInterfaceTypeDefinition || node.Kind == ast.NodeKindUnionTypeDefinition
+}
@@ -0,0 +1,115 @@
+package astnormalization
+
+import (
+	"testing"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+)
+
+func TestInjectTypename(t *testing.T) {
+	t.Run("union field", func(t *testing.T) {
+		run(injectTypename, testDefinition, `
+				{
+					catOrDog {
+						... on Cat {
+							meowVolume
+						}
+					}
+				}`, `
+				{
+					catOrDog {
+						... on Cat {
+							meowVolume
+						}
+						__typename
+					}
+				}`)
+	})
+	t.Run("interface field", func(t *testing.T) {
+		run(injectTypename, testDefinition, `
+				{
+					pet {
+						name
+					}
+				}`, `
+				{
+					pet {
+						name
+						__typename
+					}
+				}`)
+	})
+	t.Run("existing __typename is not duplicated", func(t *testing.T) {
+		run(injectTypename, testDefinition, `
+				{
+					pet {
+						__typename
+						name
+					}
+					humanOrAlien {
+						__typename
+					}
+				}`, `
+				{
+					pet {
+						__typename
+						name
+					}
+					humanOrAlien {
+						__typename
+					}
+				}`)
+	})
+	t.Run("concrete object field is untouched", func(t *testing.T) {
+		run(injectTypename, testDefinition, `
+				{
+					dog {
+						name
+						extra {
+							string
+						}
+					}
+				}`, `
+				{
+					dog {
+						name
+						extra {
+							string
+						}
+					}
+				}`)
+	})
+}
+
+func TestRemoveInjectedTypename(t *testing.T) {
+	injectAndRemove := func(walker *astvisitor.Walker) {
+		injectTypename(walker)
+		removeInjectedTypename(walker)
+	}
+
+	t.Run("remove injected __typename only", func(t *testing.T) {
+		run(injectAndRemove, testDefinition, `
+				{
+					pet {
+						name
+					}
+					catOrDog {
+						__typename
+						... on Dog {
+							name
+						}
+					}
+				}`, `
+				{
+					pet {
+						name
+					}
+					catOrDog {
+						__typename
+						... on Dog {
+							name
+						}
+					}
+				}`)
+	})
+}
@@ -151,7 +151,41 @@ func TestVariablesExtraction(t *testing.T) {
 			  }
 			}`, ``, ``)
 	})
+	t.Run("nested lists of objects", func(t *testing.T) {
+		runWithVariables(t, extractVariables, userFilterSchema, `
+			query FindUsers {
+			  findUsers(filter: {tags: ["a","b"], ranges: [{from: 1, to: 2}]}){
+				name
+			  }
+			}`, "FindUsers", `
+			query FindUsers($a: UserFilter) {
+			  findUsers(filter: $a){
+				name
+			  }
+			}`, ``, `{"a":{"tags":["a","b"],"ranges":[{"from":1,"to":2}]}}`)
+	})
+}
+
+const userFilterSchema = `
+schema {
+	query: Query
 }
+scalar String
+scalar Int
+type Query {
+	findUsers(filter: UserFilter): [User]
+}
+input UserFilter {
+	tags: [String!]
+	ranges: [Range!]
+}
+input Range {
+	from: Int!
+	to: Int!
+}
+type User {
+	name: String
+}`
 
 const forumExampleSchema = `
 schema {
@@ -0,0 +1,56 @@
+package astvisitor
+
+import (
+	"bytes"
+)
+
+// RegisterEnterFieldVisitorForTypeField registers the visitor for the fields named fieldName of the type typeName only, e.g. "Query" and "search"
+// Fields are matched by their name, so aliased fields like "results: search" match as well.
+func (w *Walker) RegisterEnterFieldVisitorForTypeField(typeName, fieldName string, visitor EnterFieldVisitor) {
+	w.RegisterEnterFieldVisitor(&typeFieldVisitor{
+		walker:    w,
+		typeName:  []byte(typeName),
+		fieldName: []byte(fieldName),
+		enter:     visitor,
+	})
+}
+
+// RegisterLeaveFieldVisitorForTypeField registers the visitor for the fields named fieldName of the type typeName only
+func (w *Walker) RegisterLeaveFieldVisitorForTypeField(typeName, fieldName string, visitor LeaveFieldVisitor) {
+	w.RegisterLeaveFieldVisitor(&typeFieldVisitor{
+		walker:    w,
+		typeName:  []byte(typeName),
+		fieldName: []byte(fieldName),
+		leave:     visitor,
+	})
+}
+
+// RegisterFieldVisitorForTypeField registers the visitor for entering and leaving the fields named fieldName of the type typeName only
+func (w *Walker) RegisterFieldVisitorForTypeField(typeName, fieldName string, visitor FieldVisitor) {
+	w.RegisterEnterFieldVisitorForTypeField(typeName, fieldName, visitor)
+	w.RegisterLeaveFieldVisitorForTypeField(typeName, fieldName, visitor)
+}
+
+type typeFieldVisitor struct {
+	walker              *Walker
+	typeName, fieldName []byte
+	enter               EnterFieldVisitor
+	leave               LeaveFieldVisitor
+}
+
+func (t *typeFieldVisitor) EnterField(ref int) {
+	if t.matches(ref) {
+		t.enter.EnterField(ref)
+	}
+}
+
+func (t *typeFieldVisitor) LeaveField(ref int) {
+	if t.matches(ref) {
+		t.leave.LeaveField(ref)
+	}
+}
+
+func (t *typeFieldVisitor) matches(ref int) bool {
+	return bytes.Equal(t.walker.document.FieldNameBytes(ref), t.fieldName) &&
+		bytes.Equal(t.walker.EnclosingTypeDefinition.NameBytes(t.walker.definition), t.typeName)
+}
@@ -0,0 +1,92 @@
+package astvisitor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafeparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+)
+
+func TestWalker_RegisterFieldVisitorForTypeField(t *testing.T) {
+	run := func(t *testing.T, operationDocument string) string {
+		definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
+		operation := unsafeparser.ParseGraphqlDocumentString(operationDocument)
+
+		walker := NewWalker(48)
+		visitor := &recordingFieldVisitor{
+			operation: &operation,
+		}
+		walker.RegisterFieldVisitorForTypeField("User", "posts", visitor)
+
+		report := operationreport.Report{}
+		walker.Walk(&operation, &definition, &report)
+		if report.HasErrors() {
+			t.Fatal(report.Error())
+		}
+		return strings.Join(visitor.calls, " ")
+	}
+
+	t.Run("direct match", func(t *testing.T) {
+		calls := run(t, `
+			query {
+				posts {
+					user {
+						posts {
+							id
+						}
+					}
+				}
+			}`)
+		expected := "EnterField(posts) LeaveField(posts)"
+		if calls != expected {
+			t.Fatalf("want: %s\ngot: %s", expected, calls)
+		}
+	})
+
+	t.Run("aliased match", func(t *testing.T) {
+		calls := run(t, `
+			query {
+				posts {
+					user {
+						userPosts: posts {
+							id
+						}
+					}
+				}
+			}`)
+		expected := "EnterField(userPosts) LeaveField(userPosts)"
+		if calls != expected {
+			t.Fatalf("want: %s\ngot: %s", expected, calls)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		calls := run(t, `
+			query {
+				posts {
+					user {
+						posts: name
+					}
+				}
+			}`)
+		if calls != "" {
+			t.Fatalf("want no calls, got: %s", calls)
+		}
+	})
+}
+
+type recordingFieldVisitor struct {
+	operation *ast.Document
+	calls     []string
+}
+
+func (r *recordingFieldVisitor) EnterField(ref int) {
+	r.calls = append(r.calls, fmt.Sprintf("EnterField(%s)", r.operation.FieldAliasOrNameString(ref)))
+}
+
+func (r *recordingFieldVisitor) LeaveField(ref int) {
+	r.calls = append(r.calls, fmt.Sprintf("LeaveField(%s)", r.operation.FieldAliasOrNameString(ref)))
+}
@@ -1456,10 +1456,12 @@ func (w *Walker) walk() {
 			continue
 		}
 		if w.stop {
+			w.leaveDocument()
 			return
 		}
 		if w.skip {
 			w.skip = false
+			w.leaveDocument()
 			return
 		}
 		i++
@@ -1512,14 +1514,21 @@ func (w *Walker) walk() {
 		}
 
 		if w.stop {
-			return
+			break
 		}
 		if w.skip {
 			w.skip = false
-			return
+			break
 		}
 	}
 
+	w.leaveDocument()
+}
+
+// leaveDocument calls the LeaveDocument visitors exactly once per walk, also if the document was skipped or the walker stopped
+// Stop called by a LeaveDocument visitor prevents the remaining LeaveDocument visitors from being called.
+func (w *Walker) leaveDocument() {
+	w.stop = false
 	for i := 0; i < len(w.visitors.leaveDocument); {
 		if w.filter == nil || w.filter.AllowVisitor(LeaveDocument, 0, w.visitors.leaveDocument[i]) {
 			w.visitors.leaveDocument[i].LeaveDocument(w.document, w.definition)
@@ -1807,6 +1816,9 @@ func (w *Walker) walkField(ref int) {
 
 	if w.document.Fields[ref].HasSelections {
 		w.walkSelectionSet(w.document.Fields[ref].SelectionSet)
+		if w.stop {
+			return
+		}
 	}
 
 	w.removeLastAncestor()
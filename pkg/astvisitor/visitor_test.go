@@ -2,9 +2,11 @@ package astvisitor
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/jensneuse/diffview"
@@ -207,6 +209,205 @@ func (p *pathVisitor) EnterField(ref int) {
 	p.out.Write([]byte(fmt.Sprintf("EnterField: %s, path: %s\n", p.op.FieldNameUnsafeString(ref), p.Path)))
 }
 
+func TestWalker_Ancestors(t *testing.T) {
+	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
+	operation := unsafeparser.ParseGraphqlDocumentString(`
+		query MyQuery {
+			posts {
+				user {
+					name
+				}
+			}
+		}`)
+
+	walker := NewWalker(48)
+	visitor := &ancestorsVisitor{
+		Walker:    &walker,
+		operation: &operation,
+		ancestors: map[string]string{},
+		paths:     map[string]string{},
+	}
+	walker.RegisterEnterFieldVisitor(visitor)
+
+	report := operationreport.Report{}
+	walker.Walk(&operation, &definition, &report)
+	if report.HasErrors() {
+		t.Fatal(report.Error())
+	}
+
+	expected := map[string]string{
+		"posts": "NodeKindOperationDefinition(MyQuery) NodeKindSelectionSet",
+		"user":  "NodeKindOperationDefinition(MyQuery) NodeKindSelectionSet NodeKindField(posts) NodeKindSelectionSet",
+		"name":  "NodeKindOperationDefinition(MyQuery) NodeKindSelectionSet NodeKindField(posts) NodeKindSelectionSet NodeKindField(user) NodeKindSelectionSet",
+	}
+	for field, ancestors := range expected {
+		if visitor.ancestors[field] != ancestors {
+			t.Errorf("ancestors of %s\nwant: %s\ngot: %s", field, ancestors, visitor.ancestors[field])
+		}
+	}
+	if visitor.paths["name"] != "query.posts.user.name" {
+		t.Errorf("want path: query.posts.user.name, got: %s", visitor.paths["name"])
+	}
+}
+
+type ancestorsVisitor struct {
+	*Walker
+	operation *ast.Document
+	ancestors map[string]string
+	paths     map[string]string
+}
+
+func (a *ancestorsVisitor) EnterField(ref int) {
+	ancestors := make([]string, 0, len(a.Ancestors))
+	for _, node := range a.Ancestors {
+		switch node.Kind {
+		case ast.NodeKindOperationDefinition:
+			ancestors = append(ancestors, fmt.Sprintf("%s(%s)", node.Kind, a.operation.OperationDefinitionNameString(node.Ref)))
+		case ast.NodeKindField:
+			ancestors = append(ancestors, fmt.Sprintf("%s(%s)", node.Kind, a.operation.FieldNameString(node.Ref)))
+		default:
+			ancestors = append(ancestors, node.Kind.String())
+		}
+	}
+	fieldName := a.operation.FieldNameString(ref)
+	a.ancestors[fieldName] = strings.Join(ancestors, " ")
+	a.paths[fieldName] = a.Path.DotDelimitedString() + "." + fieldName
+}
+
+func TestWalker_StopWithInternalErr(t *testing.T) {
+	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
+	operation := unsafeparser.ParseGraphqlDocumentString(`
+		query PostsUserQuery {
+			posts {
+				id
+				user {
+					id
+					name
+				}
+				description
+			}
+		}`)
+
+	walker := NewWalker(48)
+	visitor := &stopOnFieldVisitor{
+		Walker:    &walker,
+		operation: &operation,
+		stopOn:    "user",
+		err:       errors.New("user is not allowed"),
+	}
+	walker.RegisterEnterFieldVisitor(visitor)
+	walker.RegisterLeaveFieldVisitor(visitor)
+
+	report := operationreport.Report{}
+	walker.Walk(&operation, &definition, &report)
+
+	if len(report.InternalErrors) != 1 || report.InternalErrors[0] != visitor.err {
+		t.Fatalf("want internal error: %v, got: %v", visitor.err, report.InternalErrors)
+	}
+	expected := "EnterField(posts) EnterField(id) LeaveField(id) EnterField(user)"
+	if actual := strings.Join(visitor.calls, " "); actual != expected {
+		t.Fatalf("want: %s\ngot: %s", expected, actual)
+	}
+}
+
+type stopOnFieldVisitor struct {
+	*Walker
+	operation *ast.Document
+	stopOn    string
+	err       error
+	calls     []string
+}
+
+func (s *stopOnFieldVisitor) EnterField(ref int) {
+	fieldName := s.operation.FieldNameString(ref)
+	s.calls = append(s.calls, fmt.Sprintf("EnterField(%s)", fieldName))
+	if fieldName == s.stopOn {
+		s.StopWithInternalErr(s.err)
+	}
+}
+
+func (s *stopOnFieldVisitor) LeaveField(ref int) {
+	s.calls = append(s.calls, fmt.Sprintf("LeaveField(%s)", s.operation.FieldNameString(ref)))
+}
+
+func TestWalker_WalkDefinition(t *testing.T) {
+	run := func(t *testing.T, schema string) string {
+		definition := unsafeparser.ParseGraphqlDocumentString(schema)
+
+		walker := NewWalker(48)
+		visitor := &definitionVisitor{
+			definition: &definition,
+		}
+		walker.RegisterObjectTypeDefinitionVisitor(visitor)
+		walker.RegisterEnterFieldDefinitionVisitor(visitor)
+		walker.RegisterEnterInputObjectTypeDefinitionVisitor(visitor)
+		walker.RegisterEnterInputValueDefinitionVisitor(visitor)
+		walker.RegisterEnterEnumTypeDefinitionVisitor(visitor)
+
+		report := operationreport.Report{}
+		walker.Walk(&definition, nil, &report)
+		if report.HasErrors() {
+			t.Fatal(report.Error())
+		}
+		return strings.Join(visitor.calls, " ")
+	}
+
+	t.Run("object types and field definitions", func(t *testing.T) {
+		expected := "InputValue(if) EnterObjectType(Query) Field(posts) Field(foo) InputValue(bar) InputValue(baz) LeaveObjectType(Query) " +
+			"EnterObjectType(User) Field(id) Field(name) Field(posts) LeaveObjectType(User) " +
+			"EnterObjectType(Post) Field(id) Field(description) Field(user) LeaveObjectType(Post) " +
+			"EnterObjectType(Foo) Field(fooField) LeaveObjectType(Foo)"
+		if actual := run(t, testDefinition); actual != expected {
+			t.Fatalf("want: %s\ngot: %s", expected, actual)
+		}
+	})
+
+	t.Run("input object and enum types", func(t *testing.T) {
+		expected := "InputObjectType(UserFilter) InputValue(name) InputValue(role) EnumType(Role)"
+		actual := run(t, `
+			input UserFilter {
+				name: String
+				role: Role
+			}
+			enum Role {
+				ADMIN
+				USER
+			}`)
+		if actual != expected {
+			t.Fatalf("want: %s\ngot: %s", expected, actual)
+		}
+	})
+}
+
+type definitionVisitor struct {
+	definition *ast.Document
+	calls      []string
+}
+
+func (d *definitionVisitor) EnterObjectTypeDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("EnterObjectType(%s)", d.definition.ObjectTypeDefinitionNameString(ref)))
+}
+
+func (d *definitionVisitor) LeaveObjectTypeDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("LeaveObjectType(%s)", d.definition.ObjectTypeDefinitionNameString(ref)))
+}
+
+func (d *definitionVisitor) EnterFieldDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("Field(%s)", d.definition.FieldDefinitionNameString(ref)))
+}
+
+func (d *definitionVisitor) EnterInputObjectTypeDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("InputObjectType(%s)", d.definition.InputObjectTypeDefinitionNameString(ref)))
+}
+
+func (d *definitionVisitor) EnterInputValueDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("InputValue(%s)", d.definition.InputValueDefinitionNameString(ref)))
+}
+
+func (d *definitionVisitor) EnterEnumTypeDefinition(ref int) {
+	d.calls = append(d.calls, fmt.Sprintf("EnumType(%s)", d.definition.EnumTypeDefinitionNameString(ref)))
+}
+
 func TestVisitWithSkip(t *testing.T) {
 
 	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
@@ -272,6 +473,104 @@ func (s *skipUserVisitor) EnterField(ref int) {
 	}
 }
 
+func TestWalker_LeaveDocument(t *testing.T) {
+	run := func(t *testing.T, configure func(visitor *documentVisitor)) []string {
+		definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
+		operation := unsafeparser.ParseGraphqlDocumentString(`
+			query PostsQuery {
+				posts {
+					id
+				}
+			}
+			query PostDescriptionsQuery {
+				posts {
+					description
+				}
+			}`)
+
+		walker := NewWalker(48)
+		visitor := &documentVisitor{
+			Walker: &walker,
+		}
+		configure(visitor)
+		walker.RegisterDocumentVisitor(visitor)
+		walker.RegisterEnterOperationVisitor(visitor)
+		walker.RegisterLeaveOperationVisitor(visitor)
+
+		report := operationreport.Report{}
+		walker.Walk(&operation, &definition, &report)
+		if report.HasErrors() {
+			t.Fatal(report.Error())
+		}
+		return visitor.calls
+	}
+
+	assertCalls := func(t *testing.T, expected, actual []string) {
+		if fmt.Sprint(expected) != fmt.Sprint(actual) {
+			t.Fatalf("want: %v\ngot: %v", expected, actual)
+		}
+	}
+
+	t.Run("leave after the full traversal", func(t *testing.T) {
+		calls := run(t, func(visitor *documentVisitor) {})
+		assertCalls(t, []string{"EnterDocument", "EnterOperation", "LeaveOperation", "EnterOperation", "LeaveOperation", "LeaveDocument"}, calls)
+	})
+
+	t.Run("leave when the document is skipped", func(t *testing.T) {
+		calls := run(t, func(visitor *documentVisitor) {
+			visitor.skipDocument = true
+		})
+		assertCalls(t, []string{"EnterDocument", "LeaveDocument"}, calls)
+	})
+
+	t.Run("leave when the walker is stopped in EnterOperationDefinition", func(t *testing.T) {
+		calls := run(t, func(visitor *documentVisitor) {
+			visitor.stopOnOperation = true
+		})
+		assertCalls(t, []string{"EnterDocument", "EnterOperation", "LeaveDocument"}, calls)
+	})
+
+	t.Run("leave when the walker is stopped in EnterDocument", func(t *testing.T) {
+		calls := run(t, func(visitor *documentVisitor) {
+			visitor.stopDocument = true
+		})
+		assertCalls(t, []string{"EnterDocument", "LeaveDocument"}, calls)
+	})
+}
+
+type documentVisitor struct {
+	*Walker
+	calls           []string
+	skipDocument    bool
+	stopDocument    bool
+	stopOnOperation bool
+}
+
+func (d *documentVisitor) EnterDocument(operation, definition *ast.Document) {
+	d.calls = append(d.calls, "EnterDocument")
+	if d.skipDocument {
+		d.SkipNode()
+	}
+	if d.stopDocument {
+		d.Stop()
+	}
+}
+
+func (d *documentVisitor) LeaveDocument(operation, definition *ast.Document) {
+	d.calls = append(d.calls, "LeaveDocument")
+}
+
+func (d *documentVisitor) EnterOperationDefinition(ref int) {
+	d.calls = append(d.calls, "EnterOperation")
+	if d.stopOnOperation {
+		d.Stop()
+	}
+}
+
+func (d *documentVisitor) LeaveOperationDefinition(ref int) {
+	d.calls = append(d.calls, "LeaveOperation")
+}
+
 func BenchmarkVisitor(b *testing.B) {
 
 	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
@@ -1720,6 +1720,65 @@ func TestGraphQLDataSource(t *testing.T) {
 		},
 	}))
 
+	t.Run("subscription with request headers", RunTest(testDefinition, `
+		subscription RemainingJedis {
+			remainingJedis
+		}
+	`, "RemainingJedis", &plan.SubscriptionResponsePlan{
+		Response: &resolve.GraphQLSubscription{
+			Trigger: resolve.GraphQLSubscriptionTrigger{
+				Input: []byte(`{"header":{"Authorization":["$$0$$"]},"url":"wss://swapi.com/graphql","body":{"query":"subscription{remainingJedis}"}}`),
+				Variables: resolve.NewVariables(
+					&resolve.HeaderVariable{
+						Path: []string{"Authorization"},
+					},
+				),
+				Source: &SubscriptionSource{
+					NewWebSocketGraphQLSubscriptionClient(http.DefaultClient, ctx),
+				},
+			},
+			Response: &resolve.GraphQLResponse{
+				Data: &resolve.Object{
+					Fields: []*resolve.Field{
+						{
+							Name: []byte("remainingJedis"),
+							Position: resolve.Position{
+								Line:   3,
+								Column: 4,
+							},
+							Value: &resolve.Integer{
+								Path:     []string{"remainingJedis"},
+								Nullable: false,
+							},
+						},
+					},
+				},
+			},
+		},
+	}, plan.Configuration{
+		DataSources: []plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{
+						TypeName:   "Subscription",
+						FieldNames: []string{"remainingJedis"},
+					},
+				},
+				Custom: ConfigJson(Configuration{
+					Fetch: FetchConfiguration{
+						Header: http.Header{
+							"Authorization": []string{"{{ .request.headers.Authorization }}"},
+						},
+					},
+					Subscription: SubscriptionConfiguration{
+						URL: "wss://swapi.com/graphql",
+					},
+				}),
+				Factory: factory,
+			},
+		},
+	}))
+
 	t.Run("subscription with variables", RunTest(`
 		type Subscription {
 			foo(bar: String): Int!
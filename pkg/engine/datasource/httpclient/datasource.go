@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// DataSource is a resolve.DataSource for plain HTTP upstreams
+// The prepared input is the request envelope built with the SetInput* functions, e.g. {"method":"POST","url":"...","header":{...},"body":{...}}.
+// Responses with a 2xx status code are written as is,
+// other status codes are written as GraphQL error, e.g. {"errors":[{"message":"...","extensions":{"statusCode":500,"body":"..."}}]}
+type DataSource struct {
+	client *http.Client
+}
+
+// NewDataSource returns a DataSource using client, DefaultNetHttpClient is used if client is nil
+func NewDataSource(client *http.Client) *DataSource {
+	if client == nil {
+		client = DefaultNetHttpClient
+	}
+	return &DataSource{
+		client: client,
+	}
+}
+
+func (d *DataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	request, err := newRequest(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		_, err = io.Copy(w, response.Body)
+		return
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	errorResponse, err := json.Marshal(statusCodeErrorResponse{
+		Errors: []statusCodeError{
+			{
+				Message: fmt.Sprintf("upstream responded with status code %d", response.StatusCode),
+				Extensions: statusCodeErrorExtensions{
+					StatusCode: response.StatusCode,
+					Body:       string(body),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(errorResponse)
+	return
+}
+
+type statusCodeErrorResponse struct {
+	Errors []statusCodeError `json:"errors"`
+}
+
+type statusCodeError struct {
+	Message    string                    `json:"message"`
+	Extensions statusCodeErrorExtensions `json:"extensions"`
+}
+
+type statusCodeErrorExtensions struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataSource_Load(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "Bearer 123", r.Header.Get("Authorization"))
+			_, err := w.Write([]byte(`{"id":1}`))
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		var input []byte
+		input = SetInputMethod(input, []byte("GET"))
+		input = SetInputURL(input, []byte(server.URL))
+		input = SetInputHeader(input, []byte(`{"Authorization":["Bearer 123"]}`))
+
+		out := &bytes.Buffer{}
+		err := NewDataSource(http.DefaultClient).Load(context.Background(), input, out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1}`, out.String())
+	})
+
+	t.Run("post with body", func(t *testing.T) {
+		body := []byte(`{"name":"Jens"}`)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			actualBody, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, string(body), string(actualBody))
+			_, err = w.Write([]byte(`{"id":2}`))
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		var input []byte
+		input = SetInputMethod(input, []byte("POST"))
+		input = SetInputBody(input, body)
+		input = SetInputURL(input, []byte(server.URL))
+
+		out := &bytes.Buffer{}
+		err := NewDataSource(http.DefaultClient).Load(context.Background(), input, out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":2}`, out.String())
+	})
+
+	t.Run("status code 500", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, err := w.Write([]byte(`internal error`))
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		var input []byte
+		input = SetInputMethod(input, []byte("GET"))
+		input = SetInputURL(input, []byte(server.URL))
+
+		out := &bytes.Buffer{}
+		err := NewDataSource(http.DefaultClient).Load(context.Background(), input, out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"errors":[{"message":"upstream responded with status code 500","extensions":{"statusCode":500,"body":"internal error"}}]}`, out.String())
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(`{"id":1}`))
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		var input []byte
+		input = SetInputMethod(input, []byte("GET"))
+		input = SetInputURL(input, []byte(server.URL))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		out := &bytes.Buffer{}
+		err := NewDataSource(http.DefaultClient).Load(ctx, input, out)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, out.Len())
+	})
+}
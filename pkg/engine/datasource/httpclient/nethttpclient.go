@@ -28,11 +28,29 @@ var (
 
 func Do(client *http.Client, ctx context.Context, requestInput []byte, out io.Writer) (err error) {
 
+	request, err := newRequest(ctx, requestInput)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	_, err = io.Copy(out, response.Body)
+	return
+}
+
+func newRequest(ctx context.Context, requestInput []byte) (*http.Request, error) {
+
 	url, method, body, headers, queryParams := requestInputParams(requestInput)
 
 	request, err := http.NewRequestWithContext(ctx, string(method), string(url), bytes.NewReader(body))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if headers != nil {
@@ -46,7 +64,7 @@ func Do(client *http.Client, ctx context.Context, requestInput []byte, out io.Wr
 			return err
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -75,7 +93,7 @@ func Do(client *http.Client, ctx context.Context, requestInput []byte, out io.Wr
 			}
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		request.URL.RawQuery = query.Encode()
 	}
@@ -83,13 +101,5 @@ func Do(client *http.Client, ctx context.Context, requestInput []byte, out io.Wr
 	request.Header.Add("accept", "application/json")
 	request.Header.Add("content-type", "application/json")
 
-	response, err := client.Do(request)
-	if err != nil {
-		return err
-	}
-
-	defer response.Body.Close()
-
-	_, err = io.Copy(out, response.Body)
-	return
+	return request, nil
 }
@@ -14,8 +14,8 @@ const (
 )
 
 func TestStaticDataSourcePlanning(t *testing.T) {
-	t.Run("simple", datasourcetesting.RunTest(definition, operation, "",
-		&plan.SynchronousResponsePlan{
+	expectedPlan := func(traceField *resolve.TraceField) plan.Plan {
+		return &plan.SynchronousResponsePlan{
 			Response: &resolve.GraphQLResponse{
 				Data: &resolve.Object{
 					Fields: []*resolve.Field{
@@ -37,11 +37,15 @@ func TestStaticDataSourcePlanning(t *testing.T) {
 						Input:                "world",
 						DataSource:           Source{},
 						DataSourceIdentifier: []byte("staticdatasource.Source"),
+						TraceField:           traceField,
 					},
 				},
 			},
-		},
-		plan.Configuration{
+		}
+	}
+
+	config := func(enableTracing bool) plan.Configuration {
+		return plan.Configuration{
 			DataSources: []plan.DataSourceConfiguration{
 				{
 					RootNodes: []plan.TypeField{
@@ -63,6 +67,17 @@ func TestStaticDataSourcePlanning(t *testing.T) {
 					DisableDefaultMapping: true,
 				},
 			},
-		},
+			EnableTracing: enableTracing,
+		}
+	}
+
+	t.Run("simple", datasourcetesting.RunTest(definition, operation, "",
+		expectedPlan(nil),
+		config(false),
+	))
+
+	t.Run("with tracing", datasourcetesting.RunTest(definition, operation, "",
+		expectedPlan(&resolve.TraceField{ResponseName: "hello", ParentType: "Query", FieldName: "hello", ReturnType: "String"}),
+		config(true),
 	))
 }
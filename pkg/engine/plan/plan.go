@@ -30,6 +30,9 @@ type Configuration struct {
 	DefaultFlushInterval int64
 	DataSources          []DataSourceConfiguration
 	Fields               FieldConfigurations
+	// EnableTracing sets the TraceField of each fetch, it's required for the resolver entries of the Apollo Tracing extension
+	// Fetches without a TraceField are not traced, see resolve.Context.SetTracing
+	EnableTracing bool
 }
 
 type FieldConfigurations []FieldConfiguration
@@ -219,7 +222,9 @@ func (p *Planner) Plan(operation, definition *ast.Document, operationName string
 		isNested := p.planningVisitor.planners[key].isNestedPlanner()
 		err := p.planningVisitor.planners[key].planner.Register(p.planningVisitor, custom, isNested)
 		if err != nil {
-			p.planningWalker.StopWithInternalErr(err)
+			// the walker has no report before walking, so the error can't be reported through it
+			report.AddInternalError(err)
+			return
 		}
 	}
 
@@ -302,6 +307,7 @@ type objectFetchConfiguration struct {
 	bufferID       int
 	isSubscription bool
 	fieldRef       int
+	traceField     *resolve.TraceField
 }
 
 func (v *Visitor) AllowVisitor(kind astvisitor.VisitorKind, ref int, visitor interface{}) bool {
@@ -355,14 +361,21 @@ func (v *Visitor) EnterDirective(ref int) {
 	case ast.NodeKindField:
 		switch directiveName {
 		case "stream":
-			initialBatchSize := 0
-			if value, ok := v.Operation.DirectiveArgumentValueByName(ref, literal.INITIAL_BATCH_SIZE); ok {
-				if value.Kind == ast.ValueKindInteger {
-					initialBatchSize = int(v.Operation.IntValueAsInt(value.Ref))
-				}
+			initialCount := 0
+			value, ok := v.Operation.DirectiveArgumentValueByName(ref, literal.INITIAL_COUNT)
+			if !ok {
+				// initialBatchSize is the legacy name of the initialCount argument
+				value, ok = v.Operation.DirectiveArgumentValueByName(ref, literal.INITIAL_BATCH_SIZE)
+			}
+			if ok && value.Kind == ast.ValueKindInteger {
+				initialCount = int(v.Operation.IntValueAsInt(value.Ref))
+			}
+			if initialCount < 0 {
+				v.Walker.StopWithExternalErr(operationreport.ErrStreamInitialCountMustBeNonNegative(initialCount))
+				return
 			}
 			v.currentField.Stream = &resolve.StreamField{
-				InitialBatchSize: initialBatchSize,
+				InitialCount: initialCount,
 			}
 		case "defer":
 			v.currentField.Defer = &resolve.DeferField{}
@@ -426,6 +439,9 @@ func (v *Visitor) EnterField(ref int) {
 			}
 		} else {
 			v.fetchConfigurations[i].object = v.objects[len(v.objects)-1]
+			if v.Config.EnableTracing {
+				v.fetchConfigurations[i].traceField = v.traceField(ref, fieldDefinition)
+			}
 		}
 	}
 
@@ -447,6 +463,17 @@ func (v *Visitor) EnterField(ref int) {
 	*v.currentFields[len(v.currentFields)-1].fields = append(*v.currentFields[len(v.currentFields)-1].fields, v.currentField)
 }
 
+// traceField describes the root field of a fetch for the Apollo Tracing extension
+func (v *Visitor) traceField(ref, fieldDefinition int) *resolve.TraceField {
+	returnType, _ := v.Definition.PrintTypeBytes(v.Definition.FieldDefinitionType(fieldDefinition), nil)
+	return &resolve.TraceField{
+		ResponseName: v.Operation.FieldAliasOrNameString(ref),
+		ParentType:   v.Walker.EnclosingTypeDefinition.NameString(v.Definition),
+		FieldName:    v.Operation.FieldNameString(ref),
+		ReturnType:   string(returnType),
+	}
+}
+
 func (v *Visitor) resolveOnTypeName() []byte {
 	if len(v.Walker.Ancestors) < 2 {
 		return nil
@@ -733,11 +760,10 @@ func (v *Visitor) resolveInputTemplates(config objectFetchConfiguration, input *
 				Path: []string{variableValue},
 			}, false)
 		case "request":
-			if len(path) != 2 {
-				break
-			}
-			switch path[0] {
-			case "headers":
+			switch {
+			case len(path) == 1 && path[0] == "traceId":
+				variableName, _ = variables.AddVariable(&resolve.TraceVariable{}, false)
+			case len(path) == 2 && path[0] == "headers":
 				key := path[1]
 				variableName, _ = variables.AddVariable(&resolve.HeaderVariable{
 					Path: []string{key},
@@ -790,6 +816,7 @@ func (v *Visitor) configureSingleFetch(internal objectFetchConfiguration, extern
 		DisallowSingleFlight:  external.DisallowSingleFlight,
 		DataSourceIdentifier:  []byte(dataSourceType),
 		ProcessResponseConfig: external.ProcessResponseConfig,
+		TraceField:            internal.traceField,
 	}
 }
 
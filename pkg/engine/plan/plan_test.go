@@ -121,7 +121,7 @@ func TestPlanner_Plan(t *testing.T) {
 								{
 									Name: []byte("friends"),
 									Stream: &resolve.StreamField{
-										InitialBatchSize: 0,
+										InitialCount: 0,
 									},
 									Position: resolve.Position{
 										Line:   6,
@@ -154,7 +154,7 @@ func TestPlanner_Plan(t *testing.T) {
 										Column: 5,
 									},
 									Stream: &resolve.StreamField{
-										InitialBatchSize: 5,
+										InitialCount: 5,
 									},
 									Value: &resolve.Array{
 										Nullable: true,
@@ -208,6 +208,75 @@ func TestPlanner_Plan(t *testing.T) {
 		DefaultFlushInterval: 0,
 	}))
 
+	t.Run("stream with initialCount", test(testDefinition, `
+		query MyQuery($id: ID!) {
+			droid(id: $id){
+				friends @stream(initialCount: 2) {
+					name
+				}
+			}
+		}
+	`, "MyQuery", &SynchronousResponsePlan{
+		Response: &resolve.GraphQLResponse{
+			Data: &resolve.Object{
+				Fields: []*resolve.Field{
+					{
+						Name: []byte("droid"),
+						Position: resolve.Position{
+							Line:   3,
+							Column: 4,
+						},
+						Value: &resolve.Object{
+							Path:     []string{"droid"},
+							Nullable: true,
+							Fields: []*resolve.Field{
+								{
+									Name: []byte("friends"),
+									Stream: &resolve.StreamField{
+										InitialCount: 2,
+									},
+									Position: resolve.Position{
+										Line:   4,
+										Column: 5,
+									},
+									Value: &resolve.Array{
+										Nullable: true,
+										Path:     []string{"friends"},
+										Item: &resolve.Object{
+											Nullable: true,
+											Fields: []*resolve.Field{
+												{
+													Name: []byte("name"),
+													Value: &resolve.String{
+														Path: []string{"name"},
+													},
+													Position: resolve.Position{
+														Line:   5,
+														Column: 6,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, Configuration{}))
+
+	t.Run("stream with negative initialCount", testWithError(testDefinition, `
+		query MyQuery($id: ID!) {
+			droid(id: $id){
+				friends @stream(initialCount: -1) {
+					name
+				}
+			}
+		}
+	`, "MyQuery", Configuration{}))
+
 	t.Run("operation selection", func(t *testing.T) {
 		t.Run("should successfully plan a single named query by providing an operation name", test(testDefinition, `
 				query MyHero {
@@ -362,7 +431,7 @@ directive @defer on FIELD
 
 directive @flushInterval(milliSeconds: Int!) on QUERY | SUBSCRIPTION
 
-directive @stream(initialBatchSize: Int) on FIELD
+directive @stream(initialCount: Int, initialBatchSize: Int) on FIELD
 
 union SearchResult = Human | Droid | Starship
 
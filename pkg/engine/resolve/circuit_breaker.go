@@ -0,0 +1,136 @@
+package resolve
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+var circuitOpenResponse = []byte(`{"errors":[{"message":"circuit breaker is open"}]}`)
+
+// CircuitBreakerState is the state of a CircuitBreakerDataSource
+type CircuitBreakerState int
+
+const (
+	// CircuitBreakerStateClosed calls through to the DataSource
+	CircuitBreakerStateClosed CircuitBreakerState = iota
+	// CircuitBreakerStateOpen fails fast without calling the DataSource until the Cooldown has passed
+	CircuitBreakerStateOpen
+	// CircuitBreakerStateHalfOpen lets a single probe call through, its result closes or re-opens the circuit
+	CircuitBreakerStateHalfOpen
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerStateClosed:
+		return "closed"
+	case CircuitBreakerStateOpen:
+		return "open"
+	case CircuitBreakerStateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed loads which open the circuit, values below 1 are treated as 1
+	FailureThreshold int
+	// Cooldown is the time the circuit stays open before a probe is let through
+	Cooldown time.Duration
+	// Clock defaults to the system time
+	Clock Clock
+}
+
+// CircuitBreakerDataSource protects a failing upstream from being called on every request
+// Loads returning an error count as failures, loads canceled by the caller are not counted.
+// Once the circuit is open, Load doesn't call the DataSource but writes a GraphQL error response,
+// so the fetch fails like an upstream responding with an error.
+// After the Cooldown, a single probe is let through: if it succeeds the circuit closes, otherwise it opens again.
+// The state is shared by all concurrent loads.
+type CircuitBreakerDataSource struct {
+	source   DataSource
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	state    CircuitBreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreakerDataSource(source DataSource, config CircuitBreakerConfig) *CircuitBreakerDataSource {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	if config.Clock == nil {
+		config.Clock = realClock{}
+	}
+	return &CircuitBreakerDataSource{
+		source: source,
+		config: config,
+	}
+}
+
+// State returns the current state of the circuit
+func (c *CircuitBreakerDataSource) State() CircuitBreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *CircuitBreakerDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	probe, ok := c.acquire()
+	if !ok {
+		_, err = w.Write(circuitOpenResponse)
+		return err
+	}
+	err = c.source.Load(ctx, input, w)
+	c.release(probe, err != nil && ctx.Err() == nil, ctx.Err() != nil)
+	return err
+}
+
+// acquire returns false if the load must fail fast, probe is true if the load is the half-open probe
+func (c *CircuitBreakerDataSource) acquire() (probe, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case CircuitBreakerStateOpen:
+		if c.config.Clock.Now().Sub(c.openedAt) < c.config.Cooldown {
+			return false, false
+		}
+		c.state = CircuitBreakerStateHalfOpen
+	case CircuitBreakerStateHalfOpen:
+	default:
+		return false, true
+	}
+	if c.probing {
+		return false, false
+	}
+	c.probing = true
+	return true, true
+}
+
+func (c *CircuitBreakerDataSource) release(probe, failed, canceled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if probe {
+		c.probing = false
+	}
+	switch {
+	case canceled:
+		// the upstream wasn't necessarily at fault, a canceled probe lets the next load probe again
+	case failed:
+		c.failures++
+		// loads which started before the circuit opened don't extend the cooldown
+		if probe || (c.state == CircuitBreakerStateClosed && c.failures >= c.config.FailureThreshold) {
+			c.state = CircuitBreakerStateOpen
+			c.openedAt = c.config.Clock.Now()
+		}
+	default:
+		c.failures = 0
+		if probe {
+			c.state = CircuitBreakerStateClosed
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// switchableDataSource fails while err is set and blocks loads while block is set
+type switchableDataSource struct {
+	mu    sync.Mutex
+	err   error
+	loads int
+	block chan struct{}
+}
+
+func (s *switchableDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	s.mu.Lock()
+	s.loads++
+	err, block := s.err, s.block
+	s.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = w.Write([]byte(`{"data":{"ok":true}}`))
+	return nil
+}
+
+func (s *switchableDataSource) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *switchableDataSource) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+func TestCircuitBreakerDataSource(t *testing.T) {
+	load := func(dataSource DataSource) (string, error) {
+		out := &bytes.Buffer{}
+		err := dataSource.Load(context.Background(), []byte(`{}`), out)
+		return out.String(), err
+	}
+
+	setup := func() (*switchableDataSource, *fakeClock, *CircuitBreakerDataSource) {
+		upstream := &switchableDataSource{err: errors.New("connection refused")}
+		clock := newFakeClock()
+		breaker := NewCircuitBreakerDataSource(upstream, CircuitBreakerConfig{
+			FailureThreshold: 3,
+			Cooldown:         time.Second,
+			Clock:            clock,
+		})
+		return upstream, clock, breaker
+	}
+
+	trip := func(t *testing.T, breaker *CircuitBreakerDataSource) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, CircuitBreakerStateClosed, breaker.State())
+			_, err := load(breaker)
+			assert.EqualError(t, err, "connection refused")
+		}
+		assert.Equal(t, CircuitBreakerStateOpen, breaker.State())
+	}
+
+	t.Run("trip after consecutive failures", func(t *testing.T) {
+		upstream, _, breaker := setup()
+		trip(t, breaker)
+		assert.Equal(t, 3, upstream.loadCount())
+	})
+
+	t.Run("success resets the failures", func(t *testing.T) {
+		upstream, _, breaker := setup()
+		_, _ = load(breaker)
+		_, _ = load(breaker)
+		upstream.setErr(nil)
+		_, err := load(breaker)
+		assert.NoError(t, err)
+		upstream.setErr(errors.New("connection refused"))
+		_, _ = load(breaker)
+		_, _ = load(breaker)
+		assert.Equal(t, CircuitBreakerStateClosed, breaker.State())
+	})
+
+	t.Run("fail fast while open", func(t *testing.T) {
+		upstream, clock, breaker := setup()
+		trip(t, breaker)
+
+		clock.Advance(time.Millisecond * 999)
+		out, err := load(breaker)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"errors":[{"message":"circuit breaker is open"}]}`, out)
+		assert.Equal(t, 3, upstream.loadCount())
+		assert.Equal(t, CircuitBreakerStateOpen, breaker.State())
+	})
+
+	t.Run("recover after a successful half-open probe", func(t *testing.T) {
+		upstream, clock, breaker := setup()
+		trip(t, breaker)
+
+		upstream.setErr(nil)
+		clock.Advance(time.Second)
+		out, err := load(breaker)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"ok":true}}`, out)
+		assert.Equal(t, CircuitBreakerStateClosed, breaker.State())
+		assert.Equal(t, 4, upstream.loadCount())
+
+		_, err = load(breaker)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, upstream.loadCount())
+	})
+
+	t.Run("failed probe opens the circuit again", func(t *testing.T) {
+		upstream, clock, breaker := setup()
+		trip(t, breaker)
+
+		clock.Advance(time.Second)
+		_, err := load(breaker)
+		assert.EqualError(t, err, "connection refused")
+		assert.Equal(t, CircuitBreakerStateOpen, breaker.State())
+
+		clock.Advance(time.Millisecond * 999)
+		out, _ := load(breaker)
+		assert.Equal(t, `{"errors":[{"message":"circuit breaker is open"}]}`, out)
+		assert.Equal(t, 4, upstream.loadCount())
+	})
+
+	t.Run("only one probe while half-open", func(t *testing.T) {
+		upstream, clock, breaker := setup()
+		trip(t, breaker)
+
+		block := make(chan struct{})
+		upstream.mu.Lock()
+		upstream.err = nil
+		upstream.block = block
+		upstream.mu.Unlock()
+		clock.Advance(time.Second)
+
+		probeDone := make(chan error)
+		go func() {
+			_, err := load(breaker)
+			probeDone <- err
+		}()
+		assert.Eventually(t, func() bool {
+			return upstream.loadCount() == 4
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, CircuitBreakerStateHalfOpen, breaker.State())
+
+		out, err := load(breaker)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"errors":[{"message":"circuit breaker is open"}]}`, out)
+
+		close(block)
+		assert.NoError(t, <-probeDone)
+		assert.Equal(t, CircuitBreakerStateClosed, breaker.State())
+	})
+
+	t.Run("canceled loads are not counted", func(t *testing.T) {
+		upstream, _, breaker := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 3; i++ {
+			_ = breaker.Load(ctx, []byte(`{}`), &bytes.Buffer{})
+		}
+		assert.Equal(t, CircuitBreakerStateClosed, breaker.State())
+		assert.Equal(t, 3, upstream.loadCount())
+	})
+}
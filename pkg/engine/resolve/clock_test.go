@@ -0,0 +1,354 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+)
+
+// fakeTimer is a Timer of the fakeClock, it fires once the clock is advanced to its deadline
+// timers with a period are tickers, timers with a fn call it instead of sending to ch
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	period   time.Duration
+	fn       func()
+	active   bool
+	ch       chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.deadline = t.clock.now.Add(d)
+	t.active = true
+	t.clock.fire()
+	return wasActive
+}
+
+// fakeClock is a Clock which only moves forward when Advance is called
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// nowCalls counts the calls of Now
+	nowCalls int
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nowCalls++
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) Timer {
+	return f.addTimer(d, &fakeTimer{ch: make(chan time.Time, 1)})
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	return fakeTicker{f.addTimer(d, &fakeTimer{period: d, ch: make(chan time.Time, 1)})}
+}
+
+func (f *fakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.addTimer(d, &fakeTimer{fn: fn})
+}
+
+func (f *fakeClock) addTimer(d time.Duration, timer *fakeTimer) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	timer.clock = f
+	timer.deadline = f.now.Add(d)
+	timer.active = true
+	f.timers = append(f.timers, timer)
+	f.fire()
+	return timer
+}
+
+// Advance moves the clock forward and fires all timers whose deadline has been reached
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	f.fire()
+}
+
+// fire sends the current time to all active timers whose deadline has been reached, f.mu must be held
+func (f *fakeClock) fire() {
+	for _, timer := range f.timers {
+		for timer.active && !timer.deadline.After(f.now) {
+			if timer.fn != nil {
+				go timer.fn()
+			} else {
+				// like a time.Ticker, ticks are dropped for slow receivers
+				select {
+				case timer.ch <- f.now:
+				default:
+				}
+			}
+			if timer.period <= 0 {
+				timer.active = false
+				break
+			}
+			timer.deadline = timer.deadline.Add(timer.period)
+		}
+	}
+}
+
+// fakeTicker is a Ticker of the fakeClock
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+
+	after := clock.After(time.Second)
+	clock.Advance(time.Millisecond * 999)
+	select {
+	case <-after:
+		t.Fatal("want waiter to not fire before deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case fired := <-after:
+		assert.Equal(t, start.Add(time.Second), fired)
+	default:
+		t.Fatal("want waiter to fire at deadline")
+	}
+
+	timer := clock.NewTimer(time.Second)
+	clock.Advance(time.Millisecond * 500)
+	assert.True(t, timer.Reset(time.Second))
+	clock.Advance(time.Millisecond * 500)
+	select {
+	case <-timer.C():
+		t.Fatal("want reset timer to not fire before the new deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond * 500)
+	select {
+	case fired := <-timer.C():
+		assert.Equal(t, start.Add(time.Millisecond*2500), fired)
+	default:
+		t.Fatal("want reset timer to fire at the new deadline")
+	}
+	assert.False(t, timer.Stop())
+
+	ticker := clock.NewTicker(time.Second)
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Millisecond*3500), <-ticker.C())
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Millisecond*4500), <-ticker.C())
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("want stopped ticker to not fire")
+	default:
+	}
+
+	called := make(chan struct{})
+	clock.AfterFunc(time.Second, func() { close(called) })
+	clock.Advance(time.Second)
+	<-called
+}
+
+type _clockAdvancingDataSource struct {
+	clock *fakeClock
+	step  time.Duration
+}
+
+func (c *_clockAdvancingDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	c.clock.Advance(c.step)
+	_, err = fmt.Fprintf(w, `{"name":"%s"}`, input)
+	return
+}
+
+func TestResolver_ResolveGraphQLStreamingResponse_WithClock(t *testing.T) {
+	streamingResponse := func(patchFetch Fetch) *GraphQLStreamingResponse {
+		return &GraphQLStreamingResponse{
+			FlushInterval: 1000,
+			InitialResponse: &GraphQLResponse{
+				Data: &Object{
+					Fetch: &SingleFetch{
+						DataSource: FakeDataSource(`[{"id":1},{"id":2},{"id":3}]`),
+						BufferId:   0,
+					},
+					Fields: []*Field{
+						{
+							HasBuffer: true,
+							BufferID:  0,
+							Name:      []byte("users"),
+							Value: &Array{
+								Stream: Stream{
+									Enabled:      true,
+									InitialCount: 0,
+									PatchIndex:   0,
+								},
+							},
+						},
+					},
+				},
+			},
+			Patches: []*GraphQLResponsePatch{
+				{
+					Operation: literal.ADD,
+					Fetch:     patchFetch,
+					Value: &Object{
+						Fields: []*Field{
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("patches are batched while the clock does not advance", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := newFakeClock()
+		resolver := New(c)
+		resolver.SetClock(clock)
+
+		res := streamingResponse(&SingleFetch{
+			BufferId:   0,
+			DataSource: &_clockAdvancingDataSource{clock: clock, step: 0},
+			InputTemplate: InputTemplate{
+				Segments: []TemplateSegment{
+					{
+						SegmentType:        VariableSegmentType,
+						VariableSource:     VariableSourceObject,
+						VariableSourcePath: []string{"id"},
+					},
+				},
+			},
+		})
+
+		ctx := NewContext(context.Background())
+		writer := &TestFlushWriter{}
+
+		err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`{"data":{"users":[]}}`,
+			`[{"op":"add","path":"/data/users/0","value":{"name":"1"}},{"op":"add","path":"/data/users/1","value":{"name":"2"}},{"op":"add","path":"/data/users/2","value":{"name":"3"}}]`,
+		}, writer.flushed)
+	})
+
+	t.Run("patches are flushed once the flush interval elapsed", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := newFakeClock()
+		resolver := New(c)
+		resolver.SetClock(clock)
+
+		res := streamingResponse(&SingleFetch{
+			BufferId:   0,
+			DataSource: &_clockAdvancingDataSource{clock: clock, step: time.Millisecond * 600},
+			InputTemplate: InputTemplate{
+				Segments: []TemplateSegment{
+					{
+						SegmentType:        VariableSegmentType,
+						VariableSource:     VariableSourceObject,
+						VariableSourcePath: []string{"id"},
+					},
+				},
+			},
+		})
+
+		ctx := NewContext(context.Background())
+		writer := &TestFlushWriter{}
+
+		err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`{"data":{"users":[]}}`,
+			`[{"op":"add","path":"/data/users/0","value":{"name":"1"}},{"op":"add","path":"/data/users/1","value":{"name":"2"}}]`,
+			`[{"op":"add","path":"/data/users/2","value":{"name":"3"}}]`,
+		}, writer.flushed)
+	})
+
+	t.Run("each patch is flushed without consulting the clock if the flush interval is 0", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := newFakeClock()
+		resolver := New(c)
+		resolver.SetClock(clock)
+
+		res := streamingResponse(&SingleFetch{
+			BufferId:   0,
+			DataSource: &_clockAdvancingDataSource{clock: clock, step: 0},
+			InputTemplate: InputTemplate{
+				Segments: []TemplateSegment{
+					{
+						SegmentType:        VariableSegmentType,
+						VariableSource:     VariableSourceObject,
+						VariableSourcePath: []string{"id"},
+					},
+				},
+			},
+		})
+		res.FlushInterval = 0
+
+		ctx := NewContext(context.Background())
+		writer := &TestFlushWriter{}
+
+		err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`{"data":{"users":[]}}`,
+			`[{"op":"add","path":"/data/users/0","value":{"name":"1"}}]`,
+			`[{"op":"add","path":"/data/users/1","value":{"name":"2"}}]`,
+			`[{"op":"add","path":"/data/users/2","value":{"name":"3"}}]`,
+		}, writer.flushed)
+		assert.Equal(t, 0, clock.nowCalls)
+	})
+}
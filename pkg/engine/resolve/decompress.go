@@ -0,0 +1,73 @@
+package resolve
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	ContentEncodingGzip    = "gzip"
+	ContentEncodingDeflate = "deflate"
+)
+
+// DefaultMaxDecompressedResponseBytes is the limit of decompressed upstream responses if Resolver.MaxDecompressedResponseBytes isn't set
+const DefaultMaxDecompressedResponseBytes = 64 << 20
+
+var errDecompressResponse = errors.New("unable to decompress upstream response")
+
+// decompressResponse decompresses a response with the ContentEncoding of the ProcessResponseConfig
+// Responses which aren't compressed are returned as is, e.g. if the http client already decompressed the body.
+// Unknown encodings are passed through untouched. Decompressing fails once the response exceeds maxBytes.
+func decompressResponse(responseData []byte, contentEncoding string, maxBytes int) ([]byte, error) {
+	switch contentEncoding {
+	case ContentEncodingGzip:
+		if !isGzip(responseData) {
+			return responseData, nil
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(responseData))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errDecompressResponse, err)
+		}
+		return readDecompressed(reader, maxBytes)
+	case ContentEncodingDeflate:
+		if !isZlib(responseData) {
+			return responseData, nil
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(responseData))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errDecompressResponse, err)
+		}
+		return readDecompressed(reader, maxBytes)
+	default:
+		return responseData, nil
+	}
+}
+
+// readDecompressed reads at most maxBytes, so that a small compressed response can't exhaust the memory
+func readDecompressed(reader io.ReadCloser, maxBytes int) ([]byte, error) {
+	defer reader.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(reader, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecompressResponse, err)
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("%w: decompressed response exceeds %d bytes", errDecompressResponse, maxBytes)
+	}
+	return data, nil
+}
+
+// isGzip checks for the magic header of gzip
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isZlib checks for the header of zlib, which is what "deflate" means in HTTP (RFC 1950)
+// The compression method must be deflate and the header must be a multiple of 31
+func isZlib(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
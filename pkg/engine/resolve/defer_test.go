@@ -437,3 +437,210 @@ func fakeService(t *testing.T, controller *gomock.Controller, serviceName, respo
 	}
 	return service
 }
+
+func TestDefer_Nested(t *testing.T) {
+	res := &GraphQLStreamingResponse{
+		InitialResponse: &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"users":[{"id":1},{"id":2}]}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Item: &Object{
+								Fields: []*Field{
+									{
+										Name: []byte("id"),
+										Value: &Integer{
+											Path: []string{"id"},
+										},
+									},
+									{
+										Name: []byte("posts"),
+										Value: &Null{
+											Defer: Defer{
+												Enabled:    true,
+												PatchIndex: 0,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Patches: []*GraphQLResponsePatch{
+			{
+				Operation: literal.REPLACE,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`[{"title":"foo"},{"title":"bar"}]`),
+				},
+				Value: &Array{
+					Item: &Object{
+						Fields: []*Field{
+							{
+								Name: []byte("title"),
+								Value: &String{
+									Path: []string{"title"},
+								},
+							},
+							{
+								Name: []byte("comments"),
+								Value: &Null{
+									Defer: Defer{
+										Enabled:    true,
+										PatchIndex: 1,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Operation: literal.REPLACE,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`[{"text":"baz"}]`),
+				},
+				Value: &Array{
+					Item: &Object{
+						Fields: []*Field{
+							{
+								Name: []byte("text"),
+								Value: &String{
+									Path: []string{"text"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resolver := New(c)
+
+	ctx := NewContext(context.Background())
+
+	writer := &TestWriter{}
+
+	err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+	assert.NoError(t, err)
+
+	// patches of deferred fields inside a patch are resolved after their parent patch, their path includes the path of the parent
+	assert.Equal(t, []string{
+		`{"data":{"users":[{"id":1,"posts":null},{"id":2,"posts":null}]}}`,
+		`[{"op":"replace","path":"/data/users/0/posts","value":[{"title":"foo","comments":null},{"title":"bar","comments":null}]}]`,
+		`[{"op":"replace","path":"/data/users/1/posts","value":[{"title":"foo","comments":null},{"title":"bar","comments":null}]}]`,
+		`[{"op":"replace","path":"/data/users/0/posts/0/comments","value":[{"text":"baz"}]}]`,
+		`[{"op":"replace","path":"/data/users/0/posts/1/comments","value":[{"text":"baz"}]}]`,
+		`[{"op":"replace","path":"/data/users/1/posts/0/comments","value":[{"text":"baz"}]}]`,
+		`[{"op":"replace","path":"/data/users/1/posts/1/comments","value":[{"text":"baz"}]}]`,
+	}, writer.flushed)
+
+	patched := []byte(writer.flushed[0])
+	for _, flushed := range writer.flushed[1:] {
+		patch, err := jsonpatch.DecodePatch([]byte(flushed))
+		require.NoError(t, err)
+		patched, err = patch.Apply(patched)
+		require.NoError(t, err)
+	}
+	posts := `[{"title":"foo","comments":[{"text":"baz"}]},{"title":"bar","comments":[{"text":"baz"}]}]`
+	assert.JSONEq(t, `{"data":{"users":[{"id":1,"posts":`+posts+`},{"id":2,"posts":`+posts+`}]}}`, string(patched))
+}
+
+func TestStream_Cursor(t *testing.T) {
+	res := &GraphQLStreamingResponse{
+		InitialResponse: &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"edges":[{"cursor":"YTE=","node":{"name":"a"}},{"cursor":"YTI=","node":{"name":"b"}},{"node":{"name":"c"}}]}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("edges"),
+						Value: &Array{
+							Path: []string{"edges"},
+							Stream: Stream{
+								Enabled:      true,
+								InitialCount: 1,
+								PatchIndex:   0,
+							},
+							Item: &Object{
+								Fields: []*Field{
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Path: []string{"node", "name"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Patches: []*GraphQLResponsePatch{
+			{
+				Operation:  literal.ADD,
+				CursorPath: []string{"cursor"},
+				Value: &Object{
+					Fields: []*Field{
+						{
+							Name: []byte("name"),
+							Value: &String{
+								Path: []string{"node", "name"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resolver := New(c)
+
+	ctx := NewContext(context.Background())
+
+	writer := &TestWriter{}
+
+	err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+	assert.NoError(t, err)
+
+	// items without a cursor get a null cursor
+	assert.Equal(t, []string{
+		`{"data":{"edges":[{"name":"a"}]}}`,
+		`[{"op":"add","path":"/data/edges/1","value":{"name":"b"},"cursor":"YTI="}]`,
+		`[{"op":"add","path":"/data/edges/2","value":{"name":"c"},"cursor":null}]`,
+	}, writer.flushed)
+
+	// the cursor is ignored when applying the patches
+	patched := []byte(writer.flushed[0])
+	for _, flushed := range writer.flushed[1:] {
+		patch, err := jsonpatch.DecodePatch([]byte(flushed))
+		require.NoError(t, err)
+		patched, err = patch.Apply(patched)
+		require.NoError(t, err)
+	}
+	assert.JSONEq(t, `{"data":{"edges":[{"name":"a"},{"name":"b"},{"name":"c"}]}}`, string(patched))
+}
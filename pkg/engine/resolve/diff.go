@@ -0,0 +1,187 @@
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type PlanDiffKind int
+
+const (
+	PlanDiffKindFetchAdded PlanDiffKind = iota + 1
+	PlanDiffKindFetchRemoved
+	PlanDiffKindFetchInputChanged
+	PlanDiffKindFieldAdded
+	PlanDiffKindFieldRemoved
+	PlanDiffKindFieldChanged
+)
+
+func (k PlanDiffKind) String() string {
+	switch k {
+	case PlanDiffKindFetchAdded:
+		return "fetch added"
+	case PlanDiffKindFetchRemoved:
+		return "fetch removed"
+	case PlanDiffKindFetchInputChanged:
+		return "fetch input changed"
+	case PlanDiffKindFieldAdded:
+		return "field added"
+	case PlanDiffKindFieldRemoved:
+		return "field removed"
+	case PlanDiffKindFieldChanged:
+		return "field changed"
+	default:
+		return "unknown"
+	}
+}
+
+// PlanDiff is a structural difference between two GraphQLResponse plans
+type PlanDiff struct {
+	Kind PlanDiffKind
+	// Path is the path of the field or of the object owning the fetch, e.g. "data.users[].name"
+	// Fields which are only resolved for a type are suffixed with the type name, e.g. "data.pets[].woof on Dog"
+	Path string
+	// BufferID is the buffer of the fetch, it's only set for fetch differences
+	BufferID int
+}
+
+func (d PlanDiff) String() string {
+	switch d.Kind {
+	case PlanDiffKindFetchAdded, PlanDiffKindFetchRemoved, PlanDiffKindFetchInputChanged:
+		return fmt.Sprintf("%s: %s (buffer %d)", d.Kind, d.Path, d.BufferID)
+	default:
+		return fmt.Sprintf("%s: %s", d.Kind, d.Path)
+	}
+}
+
+// DiffGraphQLResponses walks both plans and returns the differences of next compared to prev, e.g. to detect
+// unintended planning changes after editing the data source configuration.
+//
+// Fetches are matched by their BufferId, a fetch changed if its input or data source identifier differs.
+// Fields are matched by their name and OnTypeName, a field changed if its own configuration or the kind of its value differs.
+// The fields of changed objects and arrays are compared recursively, fields of values of a different kind are not.
+func DiffGraphQLResponses(prev, next *GraphQLResponse) []PlanDiff {
+	return diffNodes(nil, "data", prev.Data, next.Data)
+}
+
+func diffNodes(diffs []PlanDiff, path string, prev, next Node) []PlanDiff {
+	if prev == nil || next == nil || prev.NodeKind() != next.NodeKind() {
+		if prev != next {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldChanged, Path: path})
+		}
+		return diffs
+	}
+	switch p := prev.(type) {
+	case *Object:
+		n := next.(*Object)
+		if !reflect.DeepEqual(p.Path, n.Path) || p.Nullable != n.Nullable || p.OptionalChaining != n.OptionalChaining {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldChanged, Path: path})
+		}
+		diffs = diffFetches(diffs, path, p.Fetch, n.Fetch)
+		return diffFields(diffs, path, p.Fields, n.Fields)
+	case *Array:
+		n := next.(*Array)
+		if !reflect.DeepEqual(p.Path, n.Path) || p.Nullable != n.Nullable || p.ResolveAsynchronous != n.ResolveAsynchronous || p.Stream != n.Stream {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldChanged, Path: path})
+		}
+		return diffNodes(diffs, path+"[]", p.Item, n.Item)
+	default:
+		if !reflect.DeepEqual(prev, next) {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldChanged, Path: path})
+		}
+		return diffs
+	}
+}
+
+func diffFields(diffs []PlanDiff, path string, prev, next []*Field) []PlanDiff {
+	for _, prevField := range prev {
+		fieldPath := diffFieldPath(path, prevField)
+		nextField := findDiffField(next, prevField)
+		if nextField == nil {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldRemoved, Path: fieldPath})
+			continue
+		}
+		if !equalFieldConfiguration(prevField, nextField) {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldChanged, Path: fieldPath})
+		}
+		diffs = diffNodes(diffs, fieldPath, prevField.Value, nextField.Value)
+	}
+	for _, nextField := range next {
+		if findDiffField(prev, nextField) == nil {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFieldAdded, Path: diffFieldPath(path, nextField)})
+		}
+	}
+	return diffs
+}
+
+func diffFieldPath(path string, field *Field) string {
+	if field.OnTypeName != nil {
+		return path + "." + string(field.Name) + " on " + string(field.OnTypeName)
+	}
+	return path + "." + string(field.Name)
+}
+
+func findDiffField(fields []*Field, field *Field) *Field {
+	for i := range fields {
+		if string(fields[i].Name) == string(field.Name) && string(fields[i].OnTypeName) == string(field.OnTypeName) {
+			return fields[i]
+		}
+	}
+	return nil
+}
+
+// equalFieldConfiguration compares everything of the fields except the value and the position in the operation
+func equalFieldConfiguration(prev, next *Field) bool {
+	return prev.HasBuffer == next.HasBuffer &&
+		prev.BufferID == next.BufferID &&
+		reflect.DeepEqual(prev.FallbackBufferIDs, next.FallbackBufferIDs) &&
+		reflect.DeepEqual(prev.Defer, next.Defer) &&
+		reflect.DeepEqual(prev.Stream, next.Stream) &&
+		reflect.DeepEqual(prev.Condition, next.Condition) &&
+		reflect.DeepEqual(prev.TimeoutDefault, next.TimeoutDefault)
+}
+
+func diffFetches(diffs []PlanDiff, path string, prev, next Fetch) []PlanDiff {
+	prevFetches, nextFetches := singleFetches(prev), singleFetches(next)
+	for _, prevFetch := range prevFetches {
+		nextFetch := findDiffFetch(nextFetches, prevFetch.BufferId)
+		switch {
+		case nextFetch == nil:
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFetchRemoved, Path: path, BufferID: prevFetch.BufferId})
+		case !equalFetchInput(prevFetch, nextFetch):
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFetchInputChanged, Path: path, BufferID: prevFetch.BufferId})
+		}
+	}
+	for _, nextFetch := range nextFetches {
+		if findDiffFetch(prevFetches, nextFetch.BufferId) == nil {
+			diffs = append(diffs, PlanDiff{Kind: PlanDiffKindFetchAdded, Path: path, BufferID: nextFetch.BufferId})
+		}
+	}
+	return diffs
+}
+
+func singleFetches(fetch Fetch) []*SingleFetch {
+	switch f := fetch.(type) {
+	case *SingleFetch:
+		return []*SingleFetch{f}
+	case *ParallelFetch:
+		return f.Fetches
+	default:
+		return nil
+	}
+}
+
+func findDiffFetch(fetches []*SingleFetch, bufferID int) *SingleFetch {
+	for i := range fetches {
+		if fetches[i].BufferId == bufferID {
+			return fetches[i]
+		}
+	}
+	return nil
+}
+
+func equalFetchInput(prev, next *SingleFetch) bool {
+	return prev.Input == next.Input &&
+		string(prev.DataSourceIdentifier) == string(next.DataSourceIdentifier) &&
+		reflect.DeepEqual(prev.InputTemplate, next.InputTemplate)
+}
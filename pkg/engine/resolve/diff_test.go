@@ -0,0 +1,147 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffGraphQLResponses(t *testing.T) {
+	// plan returns a fresh plan on each call, so that test cases can modify it
+	plan := func() *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:             0,
+					DataSourceIdentifier: []byte("users"),
+					Input:                `{"url":"https://users.service/graphql"}`,
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Item: &Object{
+								Fetch: &ParallelFetch{
+									Fetches: []*SingleFetch{
+										{
+											BufferId:             1,
+											DataSourceIdentifier: []byte("reviews"),
+											InputTemplate: InputTemplate{
+												Segments: []TemplateSegment{
+													(&ObjectVariable{Path: []string{"id"}}).TemplateSegment(),
+												},
+											},
+										},
+										{
+											BufferId:             2,
+											DataSourceIdentifier: []byte("pets"),
+										},
+									},
+								},
+								Fields: []*Field{
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Path: []string{"name"},
+										},
+									},
+									{
+										HasBuffer: true,
+										BufferID:  1,
+										Name:      []byte("reviews"),
+										Value: &Integer{
+											Path:     []string{"reviewCount"},
+											Nullable: true,
+										},
+									},
+									{
+										HasBuffer:  true,
+										BufferID:   2,
+										Name:       []byte("woof"),
+										OnTypeName: []byte("Dog"),
+										Value: &String{
+											Path: []string{"woof"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	user := func(response *GraphQLResponse) *Object {
+		return response.Data.(*Object).Fields[0].Value.(*Array).Item.(*Object)
+	}
+
+	t.Run("equal plans", func(t *testing.T) {
+		assert.Len(t, DiffGraphQLResponses(plan(), plan()), 0)
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		next := plan()
+		user(next).Fields = append(user(next).Fields[:1], &Field{
+			Name: []byte("email"),
+			Value: &String{
+				Path:     []string{"email"},
+				Nullable: true,
+			},
+		}, user(next).Fields[2])
+		user(next).Fields[0].Value.(*String).Nullable = true
+
+		assert.Equal(t, []PlanDiff{
+			{Kind: PlanDiffKindFieldChanged, Path: "data.users[].name"},
+			{Kind: PlanDiffKindFieldRemoved, Path: "data.users[].reviews"},
+			{Kind: PlanDiffKindFieldAdded, Path: "data.users[].email"},
+		}, DiffGraphQLResponses(plan(), next))
+	})
+
+	t.Run("fields of a different kind", func(t *testing.T) {
+		next := plan()
+		user(next).Fields[1].Value = &Float{
+			Path:     []string{"reviewCount"},
+			Nullable: true,
+		}
+		user(next).Fields[2].BufferID = 1
+
+		assert.Equal(t, []PlanDiff{
+			{Kind: PlanDiffKindFieldChanged, Path: "data.users[].reviews"},
+			{Kind: PlanDiffKindFieldChanged, Path: "data.users[].woof on Dog"},
+		}, DiffGraphQLResponses(plan(), next))
+	})
+
+	t.Run("fetches", func(t *testing.T) {
+		next := plan()
+		next.Data.(*Object).Fetch.(*SingleFetch).Input = `{"url":"https://users.service/v2/graphql"}`
+		parallel := user(next).Fetch.(*ParallelFetch)
+		parallel.Fetches[0].InputTemplate.Segments[0].VariableSourcePath = []string{"userId"}
+		parallel.Fetches[1] = &SingleFetch{
+			BufferId:             3,
+			DataSourceIdentifier: []byte("pets"),
+		}
+
+		diffs := DiffGraphQLResponses(plan(), next)
+		assert.Equal(t, []PlanDiff{
+			{Kind: PlanDiffKindFetchInputChanged, Path: "data", BufferID: 0},
+			{Kind: PlanDiffKindFetchInputChanged, Path: "data.users[]", BufferID: 1},
+			{Kind: PlanDiffKindFetchRemoved, Path: "data.users[]", BufferID: 2},
+			{Kind: PlanDiffKindFetchAdded, Path: "data.users[]", BufferID: 3},
+		}, diffs)
+		assert.Equal(t, "fetch removed: data.users[] (buffer 2)", diffs[2].String())
+	})
+
+	t.Run("removed fetch", func(t *testing.T) {
+		next := plan()
+		user(next).Fetch = nil
+
+		diffs := DiffGraphQLResponses(plan(), next)
+		assert.Equal(t, []PlanDiff{
+			{Kind: PlanDiffKindFetchRemoved, Path: "data.users[]", BufferID: 1},
+			{Kind: PlanDiffKindFetchRemoved, Path: "data.users[]", BufferID: 2},
+		}, diffs)
+	})
+}
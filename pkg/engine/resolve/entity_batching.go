@@ -0,0 +1,250 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+var (
+	representationsPath = []string{"body", "variables", "representations"}
+	entitiesArrayPath   = []string{"_entities"}
+)
+
+// EntityBatchingDataSource batches federation _entities fetches to avoid one upstream request per parent object
+// Loads with the same input apart from the representations, e.g. the same url, headers and query,
+// are collected for Window or until MaxBatchSize representations are collected.
+// The representations are sent to the upstream in one request and the entities of the response are routed back by index,
+// so every load receives an _entities response for its own representations and ExtractFederationEntities works as usual.
+// Errors with a path into _entities are routed to the load of the entity, all other errors are added to every load.
+// Inputs without representations are loaded without batching.
+//
+// The upstream request is canceled once the contexts of all loads of the batch are done.
+type EntityBatchingDataSource struct {
+	source       DataSource
+	window       time.Duration
+	maxBatchSize int
+	clock        Clock
+	mu           sync.Mutex
+	batches      map[string]*entityBatch
+}
+
+type entityBatch struct {
+	key             string
+	input           []byte
+	representations [][]byte
+	loads           []*entityLoad
+	timer           Timer
+}
+
+type entityLoad struct {
+	ctx context.Context
+	// offset and count are the position of the representations of the load in the batch
+	offset, count int
+	response      []byte
+	err           error
+	done          chan struct{}
+}
+
+// NewEntityBatchingDataSource returns an EntityBatchingDataSource collecting loads for window
+// A batch is sent early once it contains maxBatchSize representations, if maxBatchSize is greater than 0.
+func NewEntityBatchingDataSource(source DataSource, window time.Duration, maxBatchSize int) *EntityBatchingDataSource {
+	return &EntityBatchingDataSource{
+		source:       source,
+		window:       window,
+		maxBatchSize: maxBatchSize,
+		clock:        realClock{},
+		batches:      map[string]*entityBatch{},
+	}
+}
+
+// SetClock replaces the Clock used for the batching window
+func (e *EntityBatchingDataSource) SetClock(clock Clock) {
+	e.clock = clock
+}
+
+func (e *EntityBatchingDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	representations, dataType, _, err := jsonparser.Get(input, representationsPath...)
+	if err != nil || dataType != jsonparser.Array {
+		return e.source.Load(ctx, input, w)
+	}
+
+	load := &entityLoad{
+		ctx:  ctx,
+		done: make(chan struct{}),
+	}
+	batchInput := jsonparser.Delete(append([]byte(nil), input...), representationsPath...)
+	key := string(batchInput)
+
+	e.mu.Lock()
+	batch, ok := e.batches[key]
+	if !ok {
+		batch = &entityBatch{
+			key:   key,
+			input: batchInput,
+		}
+		e.batches[key] = batch
+		batch.timer = e.clock.AfterFunc(e.window, func() {
+			if e.detach(batch) {
+				e.loadBatch(batch)
+			}
+		})
+	}
+	load.offset = len(batch.representations)
+	_, _ = jsonparser.ArrayEach(representations, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		// the input belongs to the caller, it may be reused once the caller returned, e.g. after its context got canceled
+		batch.representations = append(batch.representations, append([]byte(nil), value...))
+	})
+	load.count = len(batch.representations) - load.offset
+	batch.loads = append(batch.loads, load)
+	full := e.maxBatchSize > 0 && len(batch.representations) >= e.maxBatchSize
+	if full {
+		delete(e.batches, key)
+		batch.timer.Stop()
+	}
+	e.mu.Unlock()
+
+	if full {
+		go e.loadBatch(batch)
+	}
+
+	select {
+	case <-load.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if _, err = w.Write(load.response); err != nil {
+		return err
+	}
+	return load.err
+}
+
+// detach removes the batch so that no more loads are added, it returns false if the batch was already sent
+func (e *EntityBatchingDataSource) detach(batch *entityBatch) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.batches[batch.key] != batch {
+		return false
+	}
+	delete(e.batches, batch.key)
+	return true
+}
+
+func (e *EntityBatchingDataSource) loadBatch(batch *entityBatch) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for _, load := range batch.loads {
+			select {
+			case <-load.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	representations := &bytes.Buffer{}
+	representations.Write(lBrack)
+	for i := range batch.representations {
+		if i != 0 {
+			representations.Write(comma)
+		}
+		representations.Write(batch.representations[i])
+	}
+	representations.Write(rBrack)
+
+	input, err := jsonparser.Set(batch.input, representations.Bytes(), representationsPath...)
+	if err != nil {
+		batch.fail(nil, err)
+		return
+	}
+
+	out := &bytes.Buffer{}
+	err = e.source.Load(ctx, input, out)
+	if err != nil {
+		batch.fail(out.Bytes(), err)
+		return
+	}
+	batch.split(out.Bytes())
+}
+
+// fail sends the response of a failed upstream request to all loads
+func (b *entityBatch) fail(response []byte, err error) {
+	for _, load := range b.loads {
+		load.response = response
+		load.err = err
+		close(load.done)
+	}
+}
+
+// split routes the entities and errors of the upstream response to the loads
+func (b *entityBatch) split(response []byte) {
+	data, _, _, _ := jsonparser.Get(response, "data")
+	entitiesData, dataType, _, _ := jsonparser.Get(data, entitiesArrayPath...)
+	if dataType != jsonparser.Array {
+		// without entities the response only contains errors, which apply to all loads
+		b.fail(response, nil)
+		return
+	}
+
+	var entities [][]byte
+	_, _ = jsonparser.ArrayEach(entitiesData, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		entities = append(entities, value)
+	})
+	if len(entities) != len(b.representations) {
+		message := fmt.Sprintf(`{"errors":[{"message":"entity batch response contains %d entities, expected %d"}]}`, len(entities), len(b.representations))
+		b.fail([]byte(message), nil)
+		return
+	}
+
+	loadErrors := make([][][]byte, len(b.loads))
+	_, _ = jsonparser.ArrayEach(response, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		index, entityErr := b.entityIndex(value)
+		for i, load := range b.loads {
+			if index == -1 {
+				loadErrors[i] = append(loadErrors[i], value)
+				continue
+			}
+			if index >= load.offset && index < load.offset+load.count {
+				if entityErr, err = jsonparser.Set(entityErr, []byte(strconv.Itoa(index-load.offset)), "path", "[1]"); err == nil {
+					loadErrors[i] = append(loadErrors[i], entityErr)
+				}
+			}
+		}
+	}, "errors")
+
+	for i, load := range b.loads {
+		buf := &bytes.Buffer{}
+		buf.Write(lBrace)
+		if len(loadErrors[i]) != 0 {
+			buf.WriteString(`"errors":[`)
+			buf.Write(bytes.Join(loadErrors[i], comma))
+			buf.WriteString(`],`)
+		}
+		buf.WriteString(`"data":{"_entities":[`)
+		buf.Write(bytes.Join(entities[load.offset:load.offset+load.count], comma))
+		buf.WriteString(`]}}`)
+		load.response = buf.Bytes()
+		close(load.done)
+	}
+}
+
+// entityIndex returns the index of the entity the error belongs to and a copy of the error, or -1 if it doesn't belong to an entity
+func (b *entityBatch) entityIndex(graphqlError []byte) (int, []byte) {
+	root, _ := jsonparser.GetString(graphqlError, "path", "[0]")
+	if root != "_entities" {
+		return -1, nil
+	}
+	index, err := jsonparser.GetInt(graphqlError, "path", "[1]")
+	if err != nil || index < 0 || int(index) >= len(b.representations) {
+		return -1, nil
+	}
+	return int(index), append([]byte(nil), graphqlError...)
+}
@@ -0,0 +1,241 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/assert"
+)
+
+// entitiesDataSource responds with one User entity per representation, the name is derived from the id
+// if unavailableID is set, an error with the path of its entity and an error without path are added
+type entitiesDataSource struct {
+	mu            sync.Mutex
+	inputs        []string
+	unavailableID string
+	err           error
+}
+
+func (e *entitiesDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	e.mu.Lock()
+	e.inputs = append(e.inputs, string(input))
+	e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+
+	var (
+		entities [][]byte
+		errs     string
+	)
+	_, _ = jsonparser.ArrayEach(input, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		id, _ := jsonparser.GetString(value, "id")
+		if id == e.unavailableID {
+			errs = fmt.Sprintf(`[{"message":"name unavailable","path":["_entities",%d,"name"]},{"message":"degraded"}]`, len(entities))
+		}
+		entities = append(entities, []byte(fmt.Sprintf(`{"name":"user-%s"}`, id)))
+	}, "body", "variables", "representations")
+
+	if errs != "" {
+		_, _ = fmt.Fprintf(w, `{"errors":%s,"data":{"_entities":[%s]}}`, errs, bytes.Join(entities, comma))
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, `{"data":{"_entities":[%s]}}`, bytes.Join(entities, comma))
+	return nil
+}
+
+func (e *entitiesDataSource) calls() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.inputs...)
+}
+
+func entityInput(url string, ids ...string) []byte {
+	representations := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		representations = append(representations, []byte(fmt.Sprintf(`{"__typename":"User","id":"%s"}`, id)))
+	}
+	return []byte(fmt.Sprintf(`{"method":"POST","url":"%s","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on User {name}}}","variables":{"representations":[%s]}}}`,
+		url, bytes.Join(representations, comma)))
+}
+
+func TestEntityBatchingDataSource(t *testing.T) {
+	loadConcurrently := func(dataSource DataSource, inputs ...[]byte) ([]string, []error) {
+		outputs := make([]string, len(inputs))
+		errs := make([]error, len(inputs))
+		wg := &sync.WaitGroup{}
+		for i := range inputs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				out := &bytes.Buffer{}
+				errs[i] = dataSource.Load(context.Background(), inputs[i], out)
+				outputs[i] = out.String()
+			}(i)
+		}
+		wg.Wait()
+		return outputs, errs
+	}
+
+	t.Run("5 concurrent loads are batched into one upstream request", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, 50*time.Millisecond, 0)
+
+		inputs := make([][]byte, 5)
+		for i := range inputs {
+			inputs[i] = entityInput("http://accounts", fmt.Sprint(i))
+		}
+		outputs, errs := loadConcurrently(dataSource, inputs...)
+
+		assert.Len(t, upstream.calls(), 1)
+		for i := range inputs {
+			assert.NoError(t, errs[i])
+			assert.Equal(t, fmt.Sprintf(`{"data":{"_entities":[{"name":"user-%d"}]}}`, i), outputs[i])
+		}
+
+		r := New(context.Background())
+		for i := range outputs {
+			buf := r.getBufPair()
+			assert.NoError(t, r.extractResponse([]byte(outputs[i]), buf, ProcessResponseConfig{ExtractGraphqlResponse: true, ExtractFederationEntities: true}))
+			assert.Equal(t, fmt.Sprintf(`{"name":"user-%d"}`, i), buf.Data.String())
+			r.freeBufPair(buf)
+		}
+	})
+
+	t.Run("loads with multiple representations get their slice", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, 50*time.Millisecond, 0)
+
+		outputs, errs := loadConcurrently(dataSource, entityInput("http://accounts", "1", "2"), entityInput("http://accounts", "3"))
+		assert.Len(t, upstream.calls(), 1)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-1"},{"name":"user-2"}]}}`, outputs[0])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-3"}]}}`, outputs[1])
+	})
+
+	t.Run("different inputs are not batched together", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, 50*time.Millisecond, 0)
+
+		outputs, _ := loadConcurrently(dataSource, entityInput("http://accounts", "1"), entityInput("http://reviews", "2"))
+		assert.Len(t, upstream.calls(), 2)
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-1"}]}}`, outputs[0])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-2"}]}}`, outputs[1])
+	})
+
+	t.Run("max batch size sends the batch early", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, time.Hour, 2)
+
+		outputs, _ := loadConcurrently(dataSource, entityInput("http://accounts", "1"), entityInput("http://accounts", "2"))
+		assert.Len(t, upstream.calls(), 1)
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-1"}]}}`, outputs[0])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-2"}]}}`, outputs[1])
+	})
+
+	t.Run("errors are routed to the load of the entity", func(t *testing.T) {
+		upstream := &entitiesDataSource{unavailableID: "2"}
+		dataSource := NewEntityBatchingDataSource(upstream, time.Hour, 2)
+
+		outputs, _ := loadConcurrently(dataSource, entityInput("http://accounts", "1"), entityInput("http://accounts", "2"))
+		assert.Len(t, upstream.calls(), 1)
+		assert.Equal(t, `{"errors":[{"message":"degraded"}],"data":{"_entities":[{"name":"user-1"}]}}`, outputs[0])
+		assert.Equal(t, `{"errors":[{"message":"name unavailable","path":["_entities",0,"name"]},{"message":"degraded"}],"data":{"_entities":[{"name":"user-2"}]}}`, outputs[1])
+	})
+
+	t.Run("upstream errors are returned to all loads", func(t *testing.T) {
+		upstream := &entitiesDataSource{err: errors.New("connection refused")}
+		dataSource := NewEntityBatchingDataSource(upstream, time.Hour, 2)
+
+		_, errs := loadConcurrently(dataSource, entityInput("http://accounts", "1"), entityInput("http://accounts", "2"))
+		assert.Len(t, upstream.calls(), 1)
+		assert.EqualError(t, errs[0], "connection refused")
+		assert.EqualError(t, errs[1], "connection refused")
+	})
+
+	t.Run("representations of canceled loads are not affected by reused inputs", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, 50*time.Millisecond, 0)
+
+		canceledInput := entityInput("http://accounts", "1")
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			canceled <- dataSource.Load(ctx, canceledInput, &bytes.Buffer{})
+		}()
+		assert.Eventually(t, func() bool {
+			dataSource.mu.Lock()
+			defer dataSource.mu.Unlock()
+			return len(dataSource.batches) == 1
+		}, time.Second, time.Millisecond)
+		cancel()
+		assert.Equal(t, context.Canceled, <-canceled)
+		// the caller reuses its buffer once Load returned
+		for i := range canceledInput {
+			canceledInput[i] = 'x'
+		}
+
+		out := &bytes.Buffer{}
+		err := dataSource.Load(context.Background(), entityInput("http://accounts", "2"), out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-2"}]}}`, out.String())
+		calls := upstream.calls()
+		assert.Len(t, calls, 1)
+		assert.Contains(t, calls[0], `"representations":[{"__typename":"User","id":"1"},{"__typename":"User","id":"2"}]`)
+	})
+
+	t.Run("inputs without representations are not batched", func(t *testing.T) {
+		dataSource := NewEntityBatchingDataSource(FakeDataSource(`{"data":{"me":{"id":"1"}}}`), time.Hour, 0)
+		out := &bytes.Buffer{}
+		err := dataSource.Load(context.Background(), []byte(`{"method":"POST","url":"http://accounts","body":{"query":"{me{id}}"}}`), out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"me":{"id":"1"}}}`, out.String())
+	})
+
+	t.Run("the batch is sent once the window of the clock elapsed", func(t *testing.T) {
+		upstream := &entitiesDataSource{}
+		dataSource := NewEntityBatchingDataSource(upstream, time.Second, 0)
+		clock := newFakeClock()
+		dataSource.SetClock(clock)
+
+		done := make(chan struct{})
+		var (
+			outputs []string
+			errs    []error
+		)
+		go func() {
+			outputs, errs = loadConcurrently(dataSource, entityInput("http://accounts", "1"), entityInput("http://accounts", "2"))
+			close(done)
+		}()
+
+		batchedLoads := func() int {
+			dataSource.mu.Lock()
+			defer dataSource.mu.Unlock()
+			for _, batch := range dataSource.batches {
+				return len(batch.loads)
+			}
+			return 0
+		}
+		assert.Eventually(t, func() bool { return batchedLoads() == 2 }, time.Second, time.Millisecond)
+
+		clock.Advance(time.Millisecond * 999)
+		assert.Equal(t, 2, batchedLoads())
+		assert.Len(t, upstream.calls(), 0)
+
+		clock.Advance(time.Millisecond)
+		<-done
+		assert.Len(t, upstream.calls(), 1)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-1"}]}}`, outputs[0])
+		assert.Equal(t, `{"data":{"_entities":[{"name":"user-2"}]}}`, outputs[1])
+	})
+}
@@ -0,0 +1,42 @@
+package resolve
+
+// SetErrorsOnly enables a dry-run mode in which all fetches are executed but only errors are written to the response
+// The data of the response is always null, fields are resolved for their errors without writing their values
+func (c *Context) SetErrorsOnly(errorsOnly bool) {
+	c.errorsOnly = errorsOnly
+}
+
+// writeFieldName writes the key of the field, the first field of an object opens it, the others are preceded by a comma
+// In errors only mode no data is written.
+func (r *Resolver) writeFieldName(ctx *Context, objectBuf *BufPair, field *Field, first bool) {
+	if ctx.errorsOnly {
+		return
+	}
+	if first {
+		objectBuf.Data.WriteBytes(lBrace)
+	} else {
+		objectBuf.Data.WriteBytes(comma)
+	}
+	objectBuf.Data.WriteBytes(quote)
+	objectBuf.Data.WriteBytes(field.responseName())
+	objectBuf.Data.WriteBytes(quote)
+	objectBuf.Data.WriteBytes(colon)
+}
+
+// writeData writes data which isn't resolved, e.g. a cached value of a field or the end of an object, in errors only mode no data is written
+func (r *Resolver) writeData(ctx *Context, objectBuf *BufPair, value []byte) {
+	if ctx.errorsOnly {
+		return
+	}
+	objectBuf.Data.WriteBytes(value)
+}
+
+// mergeField merges the resolved field into the object, in errors only mode only the errors are merged
+func (r *Resolver) mergeField(ctx *Context, fieldBuf, objectBuf *BufPair) {
+	if ctx.errorsOnly {
+		r.MergeBufPairErrors(fieldBuf, objectBuf)
+		fieldBuf.Data.Reset()
+		return
+	}
+	r.MergeBufPairs(fieldBuf, objectBuf, false)
+}
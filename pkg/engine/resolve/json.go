@@ -0,0 +1,30 @@
+package resolve
+
+import "github.com/buger/jsonparser"
+
+// JSONValueGetter abstracts the json operations the Resolver performs on upstream responses and variables, see Resolver.SetJSONValueGetter
+// This allows alternative json parsers to be benchmarked against jsonparser without changing the Resolver itself
+type JSONValueGetter interface {
+	Get(data []byte, keys ...string) (value []byte, dataType jsonparser.ValueType, offset int, err error)
+	ArrayEach(data []byte, cb func(value []byte, dataType jsonparser.ValueType, offset int, err error), keys ...string) (offset int, err error)
+	ObjectEach(data []byte, cb func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error, keys ...string) (err error)
+	EachKey(data []byte, cb func(int, []byte, jsonparser.ValueType, error), paths ...[]string) int
+}
+
+type jsonparserValueGetter struct{}
+
+func (jsonparserValueGetter) Get(data []byte, keys ...string) (value []byte, dataType jsonparser.ValueType, offset int, err error) {
+	return jsonparser.Get(data, keys...)
+}
+
+func (jsonparserValueGetter) ArrayEach(data []byte, cb func(value []byte, dataType jsonparser.ValueType, offset int, err error), keys ...string) (offset int, err error) {
+	return jsonparser.ArrayEach(data, cb, keys...)
+}
+
+func (jsonparserValueGetter) ObjectEach(data []byte, cb func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error, keys ...string) (err error) {
+	return jsonparser.ObjectEach(data, cb, keys...)
+}
+
+func (jsonparserValueGetter) EachKey(data []byte, cb func(int, []byte, jsonparser.ValueType, error), paths ...[]string) int {
+	return jsonparser.EachKey(data, cb, paths...)
+}
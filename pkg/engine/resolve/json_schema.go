@@ -0,0 +1,163 @@
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// JSONSchema is the subset of JSON Schema used to validate upstream responses against their contract, see SingleFetch.ResponseSchema
+// Supported keywords are type, properties, required, items, additionalProperties (boolean only) and enum,
+// all other keywords are ignored.
+type JSONSchema struct {
+	Type                 JSONSchemaType         `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *JSONSchema            `json:"items"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Enum                 []json.RawMessage      `json:"enum"`
+}
+
+// JSONSchemaType are the allowed types of a value, e.g. "string" or ["string","null"]
+type JSONSchemaType []string
+
+func (t *JSONSchemaType) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, lBrack) {
+		return json.Unmarshal(data, (*[]string)(t))
+	}
+	var typeName string
+	if err := json.Unmarshal(data, &typeName); err != nil {
+		return err
+	}
+	*t = JSONSchemaType{typeName}
+	return nil
+}
+
+// ParseJSONSchema parses a JSON Schema document
+func ParseJSONSchema(schema []byte) (*JSONSchema, error) {
+	parsed := &JSONSchema{}
+	if err := json.Unmarshal(schema, parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// Validate returns an error describing the first value of data which doesn't match the schema
+func (s *JSONSchema) Validate(data []byte) error {
+	value, dataType, _, err := jsonparser.Get(data)
+	if err != nil {
+		return fmt.Errorf("upstream response is not valid JSON: %w", err)
+	}
+	return s.validate(value, dataType, nil)
+}
+
+func (s *JSONSchema) validate(value []byte, dataType jsonparser.ValueType, path []string) error {
+	actualType := jsonSchemaTypeName(value, dataType)
+	if len(s.Type) != 0 && !s.Type.allows(actualType) {
+		return schemaViolation(path, fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), actualType))
+	}
+
+	if len(s.Enum) != 0 && !s.enumContains(value, dataType) {
+		return schemaViolation(path, "value is not one of the enum values")
+	}
+
+	switch dataType {
+	case jsonparser.Object:
+		for _, required := range s.Required {
+			if _, _, _, err := jsonparser.Get(value, required); err != nil {
+				return schemaViolation(path, "missing required property '"+required+"'")
+			}
+		}
+		return jsonparser.ObjectEach(value, func(key []byte, propertyValue []byte, propertyType jsonparser.ValueType, offset int) error {
+			propertyPath := append(path[:len(path):len(path)], string(key))
+			propertySchema, ok := s.Properties[string(key)]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return schemaViolation(propertyPath, "unexpected property")
+				}
+				return nil
+			}
+			return propertySchema.validate(propertyValue, propertyType, propertyPath)
+		})
+	case jsonparser.Array:
+		if s.Items == nil {
+			return nil
+		}
+		var (
+			index   int
+			itemErr error
+		)
+		_, err := jsonparser.ArrayEach(value, func(itemValue []byte, itemType jsonparser.ValueType, offset int, err error) {
+			if itemErr == nil {
+				itemErr = s.Items.validate(itemValue, itemType, append(path[:len(path):len(path)], strconv.Itoa(index)))
+			}
+			index++
+		})
+		if itemErr != nil {
+			return itemErr
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *JSONSchema) enumContains(value []byte, dataType jsonparser.ValueType) bool {
+	if dataType == jsonparser.String {
+		// strings are returned without quotes
+		value = append(append(append([]byte(nil), quote...), value...), quote...)
+	}
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, value); err != nil {
+		return false
+	}
+	enumValue := &bytes.Buffer{}
+	for i := range s.Enum {
+		enumValue.Reset()
+		if json.Compact(enumValue, s.Enum[i]) == nil && bytes.Equal(enumValue.Bytes(), compacted.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t JSONSchemaType) allows(typeName string) bool {
+	for _, allowed := range t {
+		if allowed == typeName || (allowed == "number" && typeName == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonSchemaTypeName(value []byte, dataType jsonparser.ValueType) string {
+	switch dataType {
+	case jsonparser.String:
+		return "string"
+	case jsonparser.Number:
+		if bytes.ContainsAny(value, ".eE") {
+			return "number"
+		}
+		return "integer"
+	case jsonparser.Object:
+		return "object"
+	case jsonparser.Array:
+		return "array"
+	case jsonparser.Boolean:
+		return "boolean"
+	case jsonparser.Null:
+		return "null"
+	default:
+		return "unknown"
+	}
+}
+
+func schemaViolation(path []string, message string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("upstream response doesn't match the schema: %s", message)
+	}
+	return fmt.Errorf("upstream response doesn't match the schema at path '%s': %s", strings.Join(path, "."), message)
+}
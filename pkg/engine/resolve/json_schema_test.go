@@ -0,0 +1,141 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userResponseSchema = `{
+	"type": "object",
+	"required": ["data"],
+	"properties": {
+		"data": {
+			"type": "object",
+			"properties": {
+				"user": {
+					"type": ["object", "null"],
+					"required": ["id", "name"],
+					"additionalProperties": false,
+					"properties": {
+						"id": {"type": "integer"},
+						"name": {"type": "string"},
+						"score": {"type": "number"},
+						"role": {"enum": ["ADMIN", "USER"]},
+						"tags": {"type": "array", "items": {"type": "string"}}
+					}
+				}
+			}
+		}
+	}
+}`
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(userResponseSchema))
+	require.NoError(t, err)
+
+	run := func(response, expectedErr string) func(t *testing.T) {
+		return func(t *testing.T) {
+			err := schema.Validate([]byte(response))
+			if expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, expectedErr)
+		}
+	}
+
+	t.Run("valid", run(`{"data":{"user":{"id":1,"name":"Jens","score":1.5,"role":"ADMIN","tags":["a","b"]}}}`, ""))
+	t.Run("integer is a number", run(`{"data":{"user":{"id":1,"name":"Jens","score":2}}}`, ""))
+	t.Run("nullable object", run(`{"data":{"user":null}}`, ""))
+	t.Run("wrong type", run(`{"data":{"user":{"id":"1","name":"Jens"}}}`,
+		"upstream response doesn't match the schema at path 'data.user.id': expected integer, got string"))
+	t.Run("number is not an integer", run(`{"data":{"user":{"id":1.5,"name":"Jens"}}}`,
+		"upstream response doesn't match the schema at path 'data.user.id': expected integer, got number"))
+	t.Run("missing required property", run(`{"data":{"user":{"id":1}}}`,
+		"upstream response doesn't match the schema at path 'data.user': missing required property 'name'"))
+	t.Run("missing required root property", run(`{"errors":[]}`,
+		"upstream response doesn't match the schema: missing required property 'data'"))
+	t.Run("unexpected property", run(`{"data":{"user":{"id":1,"name":"Jens","email":"jens@example.com"}}}`,
+		"upstream response doesn't match the schema at path 'data.user.email': unexpected property"))
+	t.Run("enum", run(`{"data":{"user":{"id":1,"name":"Jens","role":"OWNER"}}}`,
+		"upstream response doesn't match the schema at path 'data.user.role': value is not one of the enum values"))
+	t.Run("array item", run(`{"data":{"user":{"id":1,"name":"Jens","tags":["a",2]}}}`,
+		"upstream response doesn't match the schema at path 'data.user.tags.1': expected string, got integer"))
+	t.Run("invalid JSON", run(`{"data":`,
+		"upstream response is not valid JSON: Value looks like object, but can't find closing '}' symbol"))
+}
+
+func TestResolver_ValidateResponseSchema(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(userResponseSchema))
+	require.NoError(t, err)
+
+	response := func() *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:       0,
+					DataSource:     FakeDataSource(`{"data":{"user":{"id":"1","name":"Jens"}}}`),
+					ResponseSchema: schema,
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("user"),
+						Value: &Object{
+							Path:     []string{"user"},
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(flags FeatureFlags) string {
+		buf := &bytes.Buffer{}
+		ctx := &Context{Context: context.Background(), FeatureFlags: flags}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(), nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"user":{"name":"Jens"}}}`, resolve(0))
+	})
+
+	t.Run("mismatch adds an error", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[{"message":"upstream response doesn't match the schema at path 'data.user.id': expected integer, got string"}],"data":{"user":{"name":"Jens"}}}`,
+			resolve(FeatureFlagValidateResponseSchema))
+	})
+
+	t.Run("error messages are escaped", func(t *testing.T) {
+		quotedSchema, err := ParseJSONSchema([]byte(`{"type":"object","required":["say \"hello\""]}`))
+		require.NoError(t, err)
+		resolveResponse := response()
+		resolveResponse.Data.(*Object).Fetch.(*SingleFetch).ResponseSchema = quotedSchema
+
+		buf := &bytes.Buffer{}
+		ctx := &Context{Context: context.Background(), FeatureFlags: FeatureFlagValidateResponseSchema}
+		err = New(context.Background()).ResolveGraphQLResponse(ctx, resolveResponse, nil, buf)
+		assert.NoError(t, err)
+		assert.True(t, json.Valid(buf.Bytes()), buf.String())
+		assert.Equal(t, `{"errors":[{"message":"upstream response doesn't match the schema: missing required property 'say \"hello\"'"}],"data":{"user":{"name":"Jens"}}}`, buf.String())
+	})
+}
@@ -0,0 +1,120 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingJSONValueGetter struct {
+	jsonparserValueGetter
+	gets, arrayEaches, objectEaches int
+}
+
+func (c *countingJSONValueGetter) Get(data []byte, keys ...string) (value []byte, dataType jsonparser.ValueType, offset int, err error) {
+	c.gets++
+	return c.jsonparserValueGetter.Get(data, keys...)
+}
+
+func (c *countingJSONValueGetter) ArrayEach(data []byte, cb func(value []byte, dataType jsonparser.ValueType, offset int, err error), keys ...string) (offset int, err error) {
+	c.arrayEaches++
+	return c.jsonparserValueGetter.ArrayEach(data, cb, keys...)
+}
+
+func (c *countingJSONValueGetter) ObjectEach(data []byte, cb func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error, keys ...string) (err error) {
+	c.objectEaches++
+	return c.jsonparserValueGetter.ObjectEach(data, cb, keys...)
+}
+
+func TestResolver_JSONValueGetter(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	getter := &countingJSONValueGetter{}
+	r := New(c)
+	r.SetJSONValueGetter(getter)
+
+	node := &Object{
+		Fields: []*Field{
+			{
+				Name: []byte("name"),
+				Value: &String{
+					Path: []string{"name"},
+				},
+			},
+			{
+				Name: []byte("tags"),
+				Value: &Array{
+					Path: []string{"tags"},
+					Item: &String{},
+				},
+			},
+		},
+	}
+
+	buf := NewBufPair()
+	err := r.resolveNode(&Context{Context: context.Background()}, node, []byte(`{"name":"trilby","tags":["hat","felt"]}`), buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"trilby","tags":["hat","felt"]}`, buf.Data.String())
+	assert.Equal(t, 1, getter.arrayEaches)
+	assert.True(t, getter.gets > 0)
+}
+
+func TestResolver_SetJSONValueGetter(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("renders inputs and extracts responses with the getter", func(t *testing.T) {
+		getter := &countingJSONValueGetter{}
+		r := New(c)
+		r.SetJSONValueGetter(getter)
+
+		response := &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId: 0,
+					InputTemplate: InputTemplate{
+						Segments: []TemplateSegment{
+							{
+								SegmentType:          VariableSegmentType,
+								VariableSource:       VariableSourceContext,
+								VariableSourcePath:   []string{"filter"},
+								RenderAsGraphQLValue: true,
+							},
+						},
+					},
+					DataSource: FakeDataSource(`{"name":"trilby"} trailing`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
+					},
+				},
+			},
+		}
+
+		ctx := NewContext(context.Background())
+		ctx.Variables = []byte(`{"filter":{"tags":["hat"]}}`)
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(ctx, response, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"trilby"}}`, buf.String())
+		assert.Equal(t, 1, getter.objectEaches)
+		assert.Equal(t, 1, getter.arrayEaches)
+		assert.True(t, getter.gets >= 2)
+	})
+
+	t.Run("nil restores jsonparser", func(t *testing.T) {
+		r := New(c)
+		r.SetJSONValueGetter(nil)
+		assert.Equal(t, jsonparserValueGetter{}, r.json)
+	})
+}
@@ -0,0 +1,75 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/pool"
+)
+
+var errNoLoadBalancedBackends = errors.New("load balancer has no backends")
+
+// LoadBalancedBackend is one of several interchangeable DataSources of a LoadBalancingDataSource
+type LoadBalancedBackend struct {
+	DataSource DataSource
+	// Weight is the share of requests sent to the backend, values below 1 are treated as 1
+	Weight int
+}
+
+// LoadBalancingDataSource distributes the loads of one fetch across interchangeable upstream replicas
+// Backends are selected in weighted round-robin order, with equal weights this is plain round-robin.
+// If the selected backend fails, the next backends are tried until one succeeds or all of them failed.
+//
+// The backend is selected inside Load, so the single flight loader still deduplicates on the identifier of the fetch and the input:
+// identical concurrent requests share the load of the backend selected for the first of them.
+// The output of failed attempts is discarded, so requests sharing a load never see a partial response of a failed backend.
+type LoadBalancingDataSource struct {
+	backends []DataSource
+	// schedule contains the index of each backend once per weight
+	schedule []int
+	counter  uint64
+}
+
+func NewLoadBalancingDataSource(backends ...LoadBalancedBackend) *LoadBalancingDataSource {
+	l := &LoadBalancingDataSource{
+		backends: make([]DataSource, 0, len(backends)),
+	}
+	for i := range backends {
+		l.backends = append(l.backends, backends[i].DataSource)
+		weight := backends[i].Weight
+		if weight < 1 {
+			weight = 1
+		}
+		for j := 0; j < weight; j++ {
+			l.schedule = append(l.schedule, i)
+		}
+	}
+	return l
+}
+
+func (l *LoadBalancingDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	if len(l.backends) == 0 {
+		return errNoLoadBalancedBackends
+	}
+	selected := l.schedule[(atomic.AddUint64(&l.counter, 1)-1)%uint64(len(l.schedule))]
+
+	buf := pool.BytesBuffer.Get()
+	defer pool.BytesBuffer.Put(buf)
+
+	for i := 0; i < len(l.backends); i++ {
+		buf.Reset()
+		err = l.backends[(selected+i)%len(l.backends)].Load(ctx, input, buf)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		// the output of the last attempt is kept, it might contain the errors of the upstream
+		_, _ = w.Write(buf.Bytes())
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
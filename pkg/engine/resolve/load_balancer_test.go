@@ -0,0 +1,97 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type backendDataSource struct {
+	name  string
+	err   error
+	loads int
+}
+
+func (b *backendDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	b.loads++
+	_, _ = w.Write([]byte(b.name))
+	return b.err
+}
+
+func TestLoadBalancingDataSource(t *testing.T) {
+	load := func(t *testing.T, dataSource DataSource, count int) string {
+		results := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			out := &bytes.Buffer{}
+			assert.NoError(t, dataSource.Load(context.Background(), []byte(`{}`), out))
+			results = append(results, out.String())
+		}
+		return strings.Join(results, ",")
+	}
+
+	t.Run("round-robin", func(t *testing.T) {
+		dataSource := NewLoadBalancingDataSource(
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "a"}},
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "b"}},
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "c"}},
+		)
+		assert.Equal(t, "a,b,c,a,b,c", load(t, dataSource, 6))
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		dataSource := NewLoadBalancingDataSource(
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "a"}, Weight: 3},
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "b"}, Weight: 1},
+		)
+		assert.Equal(t, "a,a,a,b,a,a,a,b", load(t, dataSource, 8))
+	})
+
+	t.Run("failover to the next backend", func(t *testing.T) {
+		failing := &backendDataSource{name: "partial", err: errors.New("connection reset")}
+		dataSource := NewLoadBalancingDataSource(
+			LoadBalancedBackend{DataSource: failing},
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "b"}},
+		)
+		// the output of the failed backend is discarded
+		assert.Equal(t, "b,b,b", load(t, dataSource, 3))
+		assert.Equal(t, 2, failing.loads)
+	})
+
+	t.Run("all backends fail", func(t *testing.T) {
+		first := &backendDataSource{name: "a", err: errors.New("a failed")}
+		second := &backendDataSource{name: "b", err: errors.New("b failed")}
+		dataSource := NewLoadBalancingDataSource(
+			LoadBalancedBackend{DataSource: first},
+			LoadBalancedBackend{DataSource: second},
+		)
+		out := &bytes.Buffer{}
+		err := dataSource.Load(context.Background(), []byte(`{}`), out)
+		assert.EqualError(t, err, "b failed")
+		assert.Equal(t, "b", out.String())
+		assert.Equal(t, 1, first.loads)
+		assert.Equal(t, 1, second.loads)
+	})
+
+	t.Run("no failover after the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		second := &backendDataSource{name: "b"}
+		dataSource := NewLoadBalancingDataSource(
+			LoadBalancedBackend{DataSource: &backendDataSource{name: "a", err: context.Canceled}},
+			LoadBalancedBackend{DataSource: second},
+		)
+		err := dataSource.Load(ctx, []byte(`{}`), &bytes.Buffer{})
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 0, second.loads)
+	})
+
+	t.Run("no backends", func(t *testing.T) {
+		err := NewLoadBalancingDataSource().Load(context.Background(), []byte(`{}`), &bytes.Buffer{})
+		assert.Equal(t, errNoLoadBalancedBackends, err)
+	})
+}
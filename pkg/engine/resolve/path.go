@@ -0,0 +1,137 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParsePath parses a dotted path with array indices into the Path of a node, e.g. "items[0].id" into ["items","[0]","id"]
+// Keys containing a dot or a bracket escape it with a backslash, e.g. "a\.b" is the key "a.b".
+// An empty path is the path of the value itself.
+func ParsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var (
+		segments []string
+		key      strings.Builder
+		// hasKey is set once a key is started, so that an empty key can be told apart from an index
+		hasKey bool
+		// afterIndex is set right after an index, where a key must be preceded by a dot
+		afterIndex bool
+	)
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("invalid path '%s': trailing escape character", path)
+			}
+			if afterIndex {
+				return nil, fmt.Errorf("invalid path '%s': missing '.' after index at position %d", path, i)
+			}
+			i++
+			key.WriteByte(path[i])
+			hasKey = true
+		case '.':
+			if !hasKey && !afterIndex {
+				return nil, fmt.Errorf("invalid path '%s': empty key at position %d", path, i)
+			}
+			if hasKey {
+				segments = append(segments, key.String())
+			}
+			key.Reset()
+			hasKey, afterIndex = false, false
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("invalid path '%s': empty key at position %d", path, i+1)
+			}
+		case '[':
+			if hasKey {
+				segments = append(segments, key.String())
+				key.Reset()
+				hasKey = false
+			} else if i != 0 && !afterIndex {
+				return nil, fmt.Errorf("invalid path '%s': empty key at position %d", path, i)
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path '%s': missing ']' for index at position %d", path, i)
+			}
+			index := path[i+1 : i+end]
+			if index == "" || strings.Trim(index, "0123456789") != "" {
+				return nil, fmt.Errorf("invalid path '%s': index '%s' is not a number", path, index)
+			}
+			segments = append(segments, "["+index+"]")
+			i += end
+			afterIndex = true
+		case ']':
+			return nil, fmt.Errorf("invalid path '%s': unexpected ']' at position %d", path, i)
+		default:
+			if afterIndex {
+				return nil, fmt.Errorf("invalid path '%s': missing '.' after index at position %d", path, i)
+			}
+			key.WriteByte(c)
+			hasKey = true
+		}
+	}
+	if hasKey {
+		segments = append(segments, key.String())
+	}
+	return segments, nil
+}
+
+// MustParsePath is ParsePath for paths known to be valid, e.g. in plans built by hand, it panics if the path is invalid
+func MustParsePath(path string) []string {
+	segments, err := ParsePath(path)
+	if err != nil {
+		panic(err)
+	}
+	return segments
+}
+
+// NodeOption configures a node created with NewObject, NewArray or NewString
+type NodeOption func(options *nodeOptions)
+
+type nodeOptions struct {
+	path []string
+}
+
+func newNodeOptions(options []NodeOption) nodeOptions {
+	var opts nodeOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// WithPath sets the Path of the node from a dotted path with array indices, it panics if the path is invalid, see MustParsePath
+func WithPath(path string) NodeOption {
+	return func(options *nodeOptions) {
+		options.path = MustParsePath(path)
+	}
+}
+
+// NewObject creates an Object resolving the fields, e.g. NewObject(fields, WithPath("items[0]"))
+func NewObject(fields []*Field, options ...NodeOption) *Object {
+	opts := newNodeOptions(options)
+	return &Object{
+		Path:   opts.path,
+		Fields: fields,
+	}
+}
+
+// NewArray creates an Array resolving each of its elements with item
+func NewArray(item Node, options ...NodeOption) *Array {
+	opts := newNodeOptions(options)
+	return &Array{
+		Path: opts.path,
+		Item: item,
+	}
+}
+
+// NewString creates a String, e.g. NewString(WithPath("tags.a\\.b"))
+func NewString(options ...NodeOption) *String {
+	opts := newNodeOptions(options)
+	return &String{
+		Path: opts.path,
+	}
+}
@@ -0,0 +1,125 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	run := func(path string, expected []string, expectedErr string) func(t *testing.T) {
+		return func(t *testing.T) {
+			segments, err := ParsePath(path)
+			if expectedErr != "" {
+				assert.EqualError(t, err, expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, expected, segments)
+		}
+	}
+
+	t.Run("empty", run(``, nil, ""))
+	t.Run("key", run(`a`, []string{"a"}, ""))
+	t.Run("nested keys", run(`a.b`, []string{"a", "b"}, ""))
+	t.Run("index", run(`a[0].b`, []string{"a", "[0]", "b"}, ""))
+	t.Run("nested indices", run(`a[0][12]`, []string{"a", "[0]", "[12]"}, ""))
+	t.Run("leading index", run(`[1].id`, []string{"[1]", "id"}, ""))
+	t.Run("escaped dot", run(`a\.b.c`, []string{"a.b", "c"}, ""))
+	t.Run("escaped brackets", run(`a\[0\].b`, []string{"a[0]", "b"}, ""))
+	t.Run("escaped backslash", run(`a\\.b`, []string{`a\`, "b"}, ""))
+	t.Run("empty key", run(`a..b`, nil, "invalid path 'a..b': empty key at position 2"))
+	t.Run("trailing dot", run(`a.`, nil, "invalid path 'a.': empty key at position 2"))
+	t.Run("index after dot", run(`a.[0]`, nil, "invalid path 'a.[0]': empty key at position 2"))
+	t.Run("key after index", run(`a[0]b`, nil, "invalid path 'a[0]b': missing '.' after index at position 4"))
+	t.Run("unterminated index", run(`a[0`, nil, "invalid path 'a[0': missing ']' for index at position 1"))
+	t.Run("non numeric index", run(`a[b]`, nil, "invalid path 'a[b]': index 'b' is not a number"))
+	t.Run("unexpected bracket", run(`a]`, nil, "invalid path 'a]': unexpected ']' at position 1"))
+	t.Run("trailing escape", run(`a\`, nil, "invalid path 'a\\': trailing escape character"))
+
+	t.Run("must parse panics on invalid paths", func(t *testing.T) {
+		assert.Panics(t, func() {
+			MustParsePath(`a..b`)
+		})
+	})
+}
+
+func TestResolver_ParsedPath(t *testing.T) {
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"items":[{"id":"1"},{"id":"2","tags":{"a.b":["x","y"]}}]}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("secondId"),
+					Value: &String{
+						Path: MustParsePath(`items[1].id`),
+					},
+				},
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("tag"),
+					Value: &String{
+						Path: MustParsePath(`items[1].tags.a\.b[1]`),
+					},
+				},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := New(context.Background()).ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"data":{"secondId":"2","tag":"y"}}`, buf.String())
+}
+
+func TestNodeConstructors(t *testing.T) {
+	t.Run("with path", func(t *testing.T) {
+		assert.Equal(t, []string{"items", "[0]", "id"}, NewString(WithPath(`items[0].id`)).Path)
+		assert.Equal(t, []string{"a.b"}, NewObject(nil, WithPath(`a\.b`)).Path)
+		assert.Equal(t, []string{"items"}, NewArray(NewString(), WithPath(`items`)).Path)
+	})
+	t.Run("without path", func(t *testing.T) {
+		assert.Nil(t, NewString().Path)
+	})
+	t.Run("invalid path panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewString(WithPath(`a..b`))
+		})
+	})
+	t.Run("resolve", func(t *testing.T) {
+		response := &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"data":{"items":[{"tags":["x","y"]},{"tags":["z"]}]}}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("first"),
+						Value: NewObject([]*Field{
+							{
+								Name:  []byte("tags"),
+								Value: NewArray(NewString(), WithPath(`tags`)),
+							},
+						}, WithPath(`data.items[0]`)),
+					},
+				},
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"first":{"tags":["x","y"]}}}`, buf.String())
+	})
+}
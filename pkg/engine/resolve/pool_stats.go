@@ -0,0 +1,100 @@
+package resolve
+
+import "sync/atomic"
+
+// PoolStats counts the calls of a pool of the Resolver
+// News is the number of Gets which allocated a new item because the pool was empty,
+// a high ratio of News to Gets means that the pool is thrashing.
+// Discards is the number of items which weren't put back because their buffers exceeded MaxPooledBufferBytes.
+type PoolStats struct {
+	Gets     uint64
+	Puts     uint64
+	News     uint64
+	Discards uint64
+}
+
+// ResolverStats contains the PoolStats of all pools of the Resolver
+type ResolverStats struct {
+	ResultSets      PoolStats
+	ByteSlices      PoolStats
+	WaitGroups      PoolStats
+	BufPairs        PoolStats
+	BufPairSlices   PoolStats
+	ErrChans        PoolStats
+	Hash64s         PoolStats
+	InflightFetches PoolStats
+}
+
+// poolCounters only contains 64-bit fields, so that they stay 64-bit aligned for atomic access on 32-bit platforms
+type poolCounters struct {
+	gets, puts, news, discards uint64
+}
+
+func (p *poolCounters) stats() PoolStats {
+	return PoolStats{
+		Gets:     atomic.LoadUint64(&p.gets),
+		Puts:     atomic.LoadUint64(&p.puts),
+		News:     atomic.LoadUint64(&p.news),
+		Discards: atomic.LoadUint64(&p.discards),
+	}
+}
+
+type resolverPoolStats struct {
+	resultSets      poolCounters
+	byteSlices      poolCounters
+	waitGroups      poolCounters
+	bufPairs        poolCounters
+	bufPairSlices   poolCounters
+	errChans        poolCounters
+	hash64s         poolCounters
+	inflightFetches poolCounters
+	enabled         uint32
+}
+
+func (s *resolverPoolStats) get(counters *poolCounters) {
+	if atomic.LoadUint32(&s.enabled) == 1 {
+		atomic.AddUint64(&counters.gets, 1)
+	}
+}
+
+func (s *resolverPoolStats) put(counters *poolCounters) {
+	if atomic.LoadUint32(&s.enabled) == 1 {
+		atomic.AddUint64(&counters.puts, 1)
+	}
+}
+
+func (s *resolverPoolStats) discard(counters *poolCounters) {
+	if atomic.LoadUint32(&s.enabled) == 1 {
+		atomic.AddUint64(&counters.discards, 1)
+	}
+}
+
+func (s *resolverPoolStats) new(counters *poolCounters) {
+	if atomic.LoadUint32(&s.enabled) == 1 {
+		atomic.AddUint64(&counters.news, 1)
+	}
+}
+
+// SetPoolStatsEnabled enables counting the Get, Put and New calls of the pools of the Resolver
+// Counting is disabled by default because the atomic counters are shared by all concurrent resolves
+func (r *Resolver) SetPoolStatsEnabled(enabled bool) {
+	var value uint32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreUint32(&r.poolStats.enabled, value)
+}
+
+// Stats returns the PoolStats counted since the pool stats got enabled
+func (r *Resolver) Stats() ResolverStats {
+	return ResolverStats{
+		ResultSets:      r.poolStats.resultSets.stats(),
+		ByteSlices:      r.poolStats.byteSlices.stats(),
+		WaitGroups:      r.poolStats.waitGroups.stats(),
+		BufPairs:        r.poolStats.bufPairs.stats(),
+		BufPairSlices:   r.poolStats.bufPairSlices.stats(),
+		ErrChans:        r.poolStats.errChans.stats(),
+		Hash64s:         r.poolStats.hash64s.stats(),
+		InflightFetches: r.poolStats.inflightFetches.stats(),
+	}
+}
@@ -0,0 +1,148 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// RecordedResponse is one load of a DataSource captured by a RecordingDataSource
+type RecordedResponse struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// Error is the message of the error returned by the DataSource, empty if the load succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingDataSource captures the prepared input and the output of every load of the wrapped DataSource
+// The recorded responses can be saved to a file and served back by a ReplayDataSource,
+// e.g. to run the resolver against snapshots of real upstream interactions without live services.
+// If the same input is loaded multiple times, the last response wins.
+type RecordingDataSource struct {
+	source    DataSource
+	mu        sync.Mutex
+	responses []RecordedResponse
+	indices   map[string]int
+}
+
+func NewRecordingDataSource(source DataSource) *RecordingDataSource {
+	return &RecordingDataSource{
+		source:  source,
+		indices: map[string]int{},
+	}
+}
+
+func (r *RecordingDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	out := &bytes.Buffer{}
+	err = r.source.Load(ctx, input, out)
+	response := RecordedResponse{
+		Input:  string(input),
+		Output: out.String(),
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	if i, ok := r.indices[response.Input]; ok {
+		r.responses[i] = response
+	} else {
+		r.indices[response.Input] = len(r.responses)
+		r.responses = append(r.responses, response)
+	}
+	r.mu.Unlock()
+
+	if _, writeErr := w.Write(out.Bytes()); err == nil {
+		err = writeErr
+	}
+	return
+}
+
+// Responses returns the recorded responses in the order of their first load
+func (r *RecordingDataSource) Responses() []RecordedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedResponse(nil), r.responses...)
+}
+
+// WriteTo writes the recorded responses as JSON, the output can be read by NewReplayDataSource
+// Responses are sorted by input, so that recordings of concurrent loads are deterministic and can be diffed.
+func (r *RecordingDataSource) WriteTo(w io.Writer) (n int64, err error) {
+	responses := r.Responses()
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Input < responses[j].Input
+	})
+	data, err := json.MarshalIndent(responses, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	written, err := w.Write(data)
+	return int64(written), err
+}
+
+// Save writes the recorded responses to the file at path
+func (r *RecordingDataSource) Save(path string) error {
+	buf := &bytes.Buffer{}
+	if _, err := r.WriteTo(buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ReplayDataSource serves the responses recorded by a RecordingDataSource
+// Responses are looked up by the prepared input bytes, loading an input without recorded response returns ErrNoRecordedResponse.
+type ReplayDataSource struct {
+	responses map[string]RecordedResponse
+}
+
+// NewReplayDataSource reads recorded responses written by RecordingDataSource.WriteTo
+func NewReplayDataSource(r io.Reader) (*ReplayDataSource, error) {
+	var responses []RecordedResponse
+	if err := json.NewDecoder(r).Decode(&responses); err != nil {
+		return nil, err
+	}
+	replay := &ReplayDataSource{
+		responses: make(map[string]RecordedResponse, len(responses)),
+	}
+	for i := range responses {
+		replay.responses[responses[i].Input] = responses[i]
+	}
+	return replay, nil
+}
+
+// LoadReplayDataSource reads the recorded responses saved by RecordingDataSource.Save
+func LoadReplayDataSource(path string) (*ReplayDataSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewReplayDataSource(file)
+}
+
+func (r *ReplayDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	response, ok := r.responses[string(input)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoRecordedResponse, input)
+	}
+	if _, err = io.WriteString(w, response.Output); err != nil {
+		return err
+	}
+	if response.Error != "" {
+		return recordedError(response.Error)
+	}
+	return nil
+}
+
+// recordedError replays the error of a recorded load, only the message of the original error is preserved
+type recordedError string
+
+func (e recordedError) Error() string {
+	return string(e)
+}
@@ -0,0 +1,160 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingDataSource struct {
+	output string
+	err    error
+}
+
+func (f *failingDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	_, _ = w.Write([]byte(f.output))
+	return f.err
+}
+
+func TestRecordingDataSource(t *testing.T) {
+	response := func(dataSource DataSource) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: dataSource,
+					InputTemplate: InputTemplate{
+						Segments: []TemplateSegment{
+							{
+								SegmentType: StaticSegmentType,
+								Data:        []byte(`{"method":"POST","url":"http://localhost:4001","body":{"query":"{users{name}}"}}`),
+							},
+						},
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Item: &Object{
+								Fields: []*Field{
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Path: []string{"name"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(t *testing.T, dataSource DataSource) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		buf := &bytes.Buffer{}
+		err := New(c).ResolveGraphQLResponse(&Context{Context: context.Background()}, response(dataSource), nil, buf)
+		return buf.String(), err
+	}
+
+	t.Run("record and replay a response", func(t *testing.T) {
+		recorder := NewRecordingDataSource(FakeDataSource(`{"users":[{"name":"Jens"},{"name":"Stefan"}]}`))
+		recorded, err := resolve(t, recorder)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens"},{"name":"Stefan"}]}}`, recorded)
+		assert.Equal(t, []RecordedResponse{
+			{
+				Input:  `{"method":"POST","url":"http://localhost:4001","body":{"query":"{users{name}}"}}`,
+				Output: `{"users":[{"name":"Jens"},{"name":"Stefan"}]}`,
+			},
+		}, recorder.Responses())
+
+		path := filepath.Join(t.TempDir(), "responses.json")
+		assert.NoError(t, recorder.Save(path))
+
+		replay, err := LoadReplayDataSource(path)
+		assert.NoError(t, err)
+		replayed, err := resolve(t, replay)
+		assert.NoError(t, err)
+		assert.Equal(t, recorded, replayed)
+	})
+
+	t.Run("replay errors of the upstream", func(t *testing.T) {
+		recorder := NewRecordingDataSource(&failingDataSource{output: `partial`, err: errors.New("connection reset")})
+		out := &bytes.Buffer{}
+		err := recorder.Load(context.Background(), []byte(`{"id":1}`), out)
+		assert.EqualError(t, err, "connection reset")
+		assert.Equal(t, `partial`, out.String())
+
+		buf := &bytes.Buffer{}
+		_, err = recorder.WriteTo(buf)
+		assert.NoError(t, err)
+		replay, err := NewReplayDataSource(buf)
+		assert.NoError(t, err)
+
+		out.Reset()
+		err = replay.Load(context.Background(), []byte(`{"id":1}`), out)
+		assert.EqualError(t, err, "connection reset")
+		assert.Equal(t, `partial`, out.String())
+	})
+
+	t.Run("last response wins", func(t *testing.T) {
+		dataSource := &failingDataSource{output: `{"id":1}`}
+		recorder := NewRecordingDataSource(dataSource)
+		assert.NoError(t, recorder.Load(context.Background(), []byte(`{"id":1}`), &bytes.Buffer{}))
+		dataSource.output = `{"id":2}`
+		assert.NoError(t, recorder.Load(context.Background(), []byte(`{"id":1}`), &bytes.Buffer{}))
+		assert.Equal(t, []RecordedResponse{{Input: `{"id":1}`, Output: `{"id":2}`}}, recorder.Responses())
+	})
+
+	t.Run("recordings are sorted by input", func(t *testing.T) {
+		record := func(inputs ...string) string {
+			recorder := NewRecordingDataSource(&failingDataSource{output: `{}`})
+			for _, input := range inputs {
+				assert.NoError(t, recorder.Load(context.Background(), []byte(input), &bytes.Buffer{}))
+			}
+			buf := &bytes.Buffer{}
+			_, err := recorder.WriteTo(buf)
+			assert.NoError(t, err)
+			return buf.String()
+		}
+
+		recorded := record(`{"id":2}`, `{"id":1}`)
+		assert.Equal(t, record(`{"id":1}`, `{"id":2}`), recorded)
+		assert.Equal(t, `[
+  {
+    "input": "{\"id\":1}",
+    "output": "{}"
+  },
+  {
+    "input": "{\"id\":2}",
+    "output": "{}"
+  }
+]`, recorded)
+	})
+
+	t.Run("replay miss", func(t *testing.T) {
+		replay, err := NewReplayDataSource(bytes.NewBufferString(`[]`))
+		assert.NoError(t, err)
+		out := &bytes.Buffer{}
+		err = replay.Load(context.Background(), []byte(`{"id":1}`), out)
+		assert.True(t, errors.Is(err, ErrNoRecordedResponse))
+		assert.EqualError(t, err, `no recorded response for input: {"id":1}`)
+		assert.Equal(t, 0, out.Len())
+
+		_, err = resolve(t, replay)
+		assert.True(t, errors.Is(err, ErrNoRecordedResponse))
+	})
+}
@@ -1,17 +1,23 @@
-//go:generate mockgen --build_flags=--mod=mod -self_package=github.com/jensneuse/graphql-go-tools/pkg/engine/resolve -destination=resolve_mock_test.go -package=resolve . DataSource,BeforeFetchHook,AfterFetchHook
+//go:generate mockgen --build_flags=--mod=mod -self_package=github.com/jensneuse/graphql-go-tools/pkg/engine/resolve -destination=resolve_mock_test.go -package=resolve . DataSource,BeforeFetchHook,AfterFetchHook,FetchCompleteHook,FetchInputRewriteHook,BeforeFetchResponseHook
 
 package resolve
 
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"hash"
 	"io"
+	"math"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -34,7 +40,6 @@ var (
 	comma             = []byte(",")
 	colon             = []byte(":")
 	quote             = []byte("\"")
-	quotedComma       = []byte(`","`)
 	null              = []byte("null")
 	literalData       = []byte("data")
 	literalErrors     = []byte("errors")
@@ -44,17 +49,37 @@ var (
 	literalColumn     = []byte("column")
 	literalPath       = []byte("path")
 	literalExtensions = []byte("extensions")
+	literalTruncated  = []byte("truncatedLists")
+	literalCursor     = []byte("cursor")
 
 	unableToResolveMsg = []byte("unable to resolve")
 	emptyArray         = []byte("[]")
+	emptyObject        = []byte("{}")
+	heartbeatFrame     = []byte("{}")
+	negativeZero       = []byte("-0")
 )
 
 var (
 	errNonNullableFieldValueIsNull = errors.New("non Nullable field value is null")
-	errTypeNameSkipped             = errors.New("skipped because of __typename condition")
-	errHeaderPathInvalid           = errors.New("invalid header path: header variables must be of this format: .request.header.{{ key }} ")
-
-	ErrUnableToResolve = errors.New("unable to resolve operation")
+	// errNonNullableFieldValueIsNullReported is errNonNullableFieldValueIsNull of a value which added an error itself,
+	// so that the parent doesn't add another error for it
+	errNonNullableFieldValueIsNullReported = errors.Errorf("error reported: %w", errNonNullableFieldValueIsNull)
+	errBooleanCoercion                     = errors.New("unable to coerce value to Boolean")
+	errTypeNameSkipped                     = errors.New("skipped because of __typename condition")
+	errDuplicateKey                        = errors.New("duplicate key")
+	errTransformNotRegistered              = errors.New("transform is not registered")
+	errTrailingResponseData                = errors.New("unexpected data after the end of the upstream response")
+	errFetchTimedOut                       = errors.New("fetch timed out")
+	errInvalidTransformedResponse          = errors.New("response transform returned invalid JSON")
+	errNumberOutOfRange                    = errors.New("number is out of range")
+	errInvalidEnumValue                    = errors.New("invalid GraphQL enum value")
+	errHeaderPathInvalid                   = errors.New("invalid header path: header variables must be of this format: .request.header.{{ key }} ")
+
+	ErrUnableToResolve               = errors.New("unable to resolve operation")
+	ErrMaxPreparedInputBytesExceeded = errors.New("prepared inputs exceed the maximum size")
+	ErrNoRecordedResponse            = errors.New("no recorded response for input")
+	ErrMaxResponseBytesExceeded      = errors.New("response exceeds maximum size")
+	ErrMissingTypeName               = errors.New("__typename is missing for fields conditioned on the type")
 )
 
 var (
@@ -98,6 +123,7 @@ const (
 	NodeKindBoolean
 	NodeKindInteger
 	NodeKindFloat
+	NodeKindStaticValue
 
 	FetchKindSingle FetchKind = iota + 1
 	FetchKindParallel
@@ -105,42 +131,127 @@ const (
 
 type HookContext struct {
 	CurrentPath []byte
+	// Fallback is true if the primary DataSource of the fetch failed and the response was loaded from the FallbackDataSource
+	Fallback bool
 }
 
 type BeforeFetchHook interface {
 	OnBeforeFetch(ctx HookContext, input []byte)
 }
 
+// BeforeFetchResponseHook is a BeforeFetchHook which can respond to a fetch itself, e.g. from a request-level cache
+// It's set with SetBeforeFetchHook, OnBeforeFetchResponse is called after OnBeforeFetch.
+// If handled is true, the response is extracted like the response of the DataSource and the DataSource isn't loaded,
+// the AfterFetchHook and the FetchCompleteHook aren't called for the fetch.
+type BeforeFetchResponseHook interface {
+	BeforeFetchHook
+	OnBeforeFetchResponse(ctx HookContext, input []byte) (response []byte, handled bool)
+}
+
 type AfterFetchHook interface {
 	OnData(ctx HookContext, output []byte, singleFlight bool)
 	OnError(ctx HookContext, output []byte, singleFlight bool)
 }
 
+// FetchCompleteHook is called each time the DataSource of a fetch returns
+// duration is the time it took to load the fetch and responseSize the number of bytes returned by the DataSource
+// It's not called for single flight followers as they don't load the fetch themselves
+type FetchCompleteHook interface {
+	OnFetchComplete(ctx HookContext, dataSourceID []byte, duration time.Duration, responseSize int, err error)
+}
+
+// FetchInputRewriteHook is called after the input of a fetch got rendered and before the fetch is loaded
+// The returned input replaces the rendered input, e.g. to add a signature computed over the request body
+// Fetches are deduplicated by the rewritten input. A returned error fails the fetch.
+type FetchInputRewriteHook interface {
+	RewriteFetchInput(ctx HookContext, dataSourceID, input []byte) ([]byte, error)
+}
+
+// FieldCache serves the values of fields from a cache, it's the building block of a granular response cache
+// The fields of an object are looked up by their path, e.g. "/data/user/name", before the fetch of the object is loaded.
+// A SingleFetch is skipped if all fields reading from its buffers are cached, so partial hits only load the remaining fetches.
+// Fields with OnTypeName or a Condition depend on the upstream data and are never looked up.
+type FieldCache interface {
+	// Get returns the JSON value of the field at ctx.CurrentPath, it's written to the response as is
+	Get(ctx HookContext) (value []byte, ok bool)
+}
+
 type Context struct {
 	context.Context
-	Variables       []byte
-	Request         Request
-	pathElements    [][]byte
-	patches         []patch
-	usedBuffers     []*bytes.Buffer
-	currentPatch    int
-	maxPatch        int
-	pathPrefix      []byte
-	beforeFetchHook BeforeFetchHook
-	afterFetchHook  AfterFetchHook
-	position        Position
+	Variables []byte
+	Request   Request
+	// TraceID is rendered into the inputs of fetches by template segments with VariableSourceTrace
+	// so that the spans of the upstreams can be correlated with the request
+	TraceID []byte
+	// FeatureFlags enables experimental resolver behaviors for this request only
+	FeatureFlags          FeatureFlags
+	pathElements          []pathElement
+	patches               []patch
+	usedBuffers           []*bytes.Buffer
+	currentPatch          int
+	maxPatch              int
+	pathPrefix            []byte
+	beforeFetchHook       BeforeFetchHook
+	afterFetchHook        AfterFetchHook
+	fetchCompleteHook     FetchCompleteHook
+	fetchInputRewriteHook FetchInputRewriteHook
+	fieldCache            FieldCache
+	position              Position
+	resolveOptions
+	// json is the JSONValueGetter of the Resolver, it's set when a response is resolved and used to render inputs
+	json JSONValueGetter
+}
+
+// resolveOptions are the per request options of the Context and the state of their features
+// They're copied to clones and reset by Free as a unit.
+type resolveOptions struct {
+	errorsOnly            bool
+	collectAllErrors      bool
+	fetchErrorsInResponse bool
+	preExtractedData      bool
+	// preparedInputBytes is the total size of the prepared inputs of the current response, it's shared with clones
+	preparedInputBytes    *int64
+	maxPreparedInputBytes int64
+	// truncatedArrays collects the notices of arrays truncated to their MaxItems, it's shared with clones
+	truncatedArrays *truncatedArrays
+	tracingEnabled  bool
+	// tracing records the fetch timings of the current response if tracingEnabled is set, it's shared with clones
+	tracing *tracing
+	// cancellationChecks counts the calls of checkCanceled, the context is only consulted every cancellationCheckInterval calls
+	cancellationChecks int
+}
+
+type truncatedArrays struct {
+	mu      sync.Mutex
+	notices bytes.Buffer
 }
 
 type Request struct {
 	Header http.Header
 }
 
+// FeatureFlags is a bit set of experimental resolver behaviors, it's set per request on the Context
+// so that behaviors can be compared without planning the operation again
+type FeatureFlags uint64
+
+const (
+	// FeatureFlagStrictIntRange resolves all Integer nodes as if StrictRange was set
+	FeatureFlagStrictIntRange FeatureFlags = 1 << iota
+	// FeatureFlagValidateResponseSchema validates the responses of fetches with a ResponseSchema, e.g. in dev or staging environments
+	FeatureFlagValidateResponseSchema
+)
+
+// Enabled returns true if all flags of flag are set
+func (f FeatureFlags) Enabled(flag FeatureFlags) bool {
+	return f&flag == flag
+}
+
 func NewContext(ctx context.Context) *Context {
 	return &Context{
 		Context:      ctx,
 		Variables:    make([]byte, 0, 4096),
 		pathPrefix:   make([]byte, 0, 4096),
-		pathElements: make([][]byte, 0, 16),
+		pathElements: make([]pathElement, 0, 16),
 		patches:      make([]patch, 0, 48),
 		usedBuffers:  make([]*bytes.Buffer, 0, 48),
 		currentPatch: -1,
@@ -154,10 +265,11 @@ func (c *Context) Clone() Context {
 	copy(variables, c.Variables)
 	pathPrefix := make([]byte, len(c.pathPrefix))
 	copy(pathPrefix, c.pathPrefix)
-	pathElements := make([][]byte, len(c.pathElements))
+	pathElements := make([]pathElement, len(c.pathElements))
 	for i := range pathElements {
-		pathElements[i] = make([]byte, len(c.pathElements[i]))
-		copy(pathElements[i], c.pathElements[i])
+		pathElements[i] = c.pathElements[i]
+		pathElements[i].name = make([]byte, len(c.pathElements[i].name))
+		copy(pathElements[i].name, c.pathElements[i].name)
 	}
 	patches := make([]patch, len(c.patches))
 	for i := range patches {
@@ -172,37 +284,52 @@ func (c *Context) Clone() Context {
 		copy(patches[i].data, c.patches[i].data)
 	}
 	return Context{
-		Context:         c.Context,
-		Variables:       variables,
-		Request:         c.Request,
-		pathElements:    pathElements,
-		patches:         patches,
-		usedBuffers:     make([]*bytes.Buffer, 0, 48),
-		currentPatch:    c.currentPatch,
-		maxPatch:        c.maxPatch,
-		pathPrefix:      pathPrefix,
-		beforeFetchHook: c.beforeFetchHook,
-		afterFetchHook:  c.afterFetchHook,
-		position:        c.position,
+		Context:               c.Context,
+		Variables:             variables,
+		Request:               c.Request,
+		TraceID:               c.TraceID,
+		FeatureFlags:          c.FeatureFlags,
+		pathElements:          pathElements,
+		patches:               patches,
+		usedBuffers:           make([]*bytes.Buffer, 0, 48),
+		currentPatch:          c.currentPatch,
+		maxPatch:              c.maxPatch,
+		pathPrefix:            pathPrefix,
+		beforeFetchHook:       c.beforeFetchHook,
+		afterFetchHook:        c.afterFetchHook,
+		fetchCompleteHook:     c.fetchCompleteHook,
+		fetchInputRewriteHook: c.fetchInputRewriteHook,
+		fieldCache:            c.fieldCache,
+		position:              c.position,
+		resolveOptions:        c.resolveOptions,
+		json:                  c.json,
 	}
 }
 
 func (c *Context) Free() {
 	c.Context = nil
 	c.Variables = c.Variables[:0]
-	c.pathPrefix = c.pathPrefix[:0]
 	c.pathElements = c.pathElements[:0]
-	c.patches = c.patches[:0]
-	for i := range c.usedBuffers {
-		pool.BytesBuffer.Put(c.usedBuffers[i])
-	}
-	c.usedBuffers = c.usedBuffers[:0]
-	c.currentPatch = -1
-	c.maxPatch = -1
+	c.resetPatches()
 	c.beforeFetchHook = nil
 	c.afterFetchHook = nil
+	c.fetchCompleteHook = nil
+	c.fetchInputRewriteHook = nil
+	c.fieldCache = nil
 	c.Request.Header = nil
+	c.TraceID = nil
+	c.FeatureFlags = 0
 	c.position = Position{}
+	c.resolveOptions = resolveOptions{}
+	c.json = nil
+}
+
+// jsonValueGetter returns the JSONValueGetter of the Resolver resolving the response, jsonparser if the Context isn't resolved by a Resolver
+func (c *Context) jsonValueGetter() JSONValueGetter {
+	if c.json == nil {
+		return jsonparserValueGetter{}
+	}
+	return c.json
 }
 
 func (c *Context) SetBeforeFetchHook(hook BeforeFetchHook) {
@@ -213,17 +340,168 @@ func (c *Context) SetAfterFetchHook(hook AfterFetchHook) {
 	c.afterFetchHook = hook
 }
 
+func (c *Context) SetFetchCompleteHook(hook FetchCompleteHook) {
+	c.fetchCompleteHook = hook
+}
+
+func (c *Context) SetFetchInputRewriteHook(hook FetchInputRewriteHook) {
+	c.fetchInputRewriteHook = hook
+}
+
+func (c *Context) SetFieldCache(cache FieldCache) {
+	c.fieldCache = cache
+}
+
+// SetCollectAllErrors enables a debug-only mode in which a null value of a non-nullable field doesn't null the enclosing object
+// The field resolves to null, its error is added with the path of the field and the siblings are resolved as usual,
+// so that all failing fields show up in one response. Responses in this mode don't comply with the GraphQL specification.
+func (c *Context) SetCollectAllErrors(collectAllErrors bool) {
+	c.collectAllErrors = collectAllErrors
+}
+
+// SetFetchErrorsInResponse adds the error of a failed Load of a fetch to the errors of the response instead of failing the whole response
+// The error has the path of the object the fetch is attached to, the fields of the fetch resolve as if the upstream returned no data.
+// Errors of a canceled context still fail the response.
+func (c *Context) SetFetchErrorsInResponse(enabled bool) {
+	c.fetchErrorsInResponse = enabled
+}
+
+// SetPreExtractedData makes ResolveGraphQLResponse treat its data as the data of the response instead of a {"data":...,"errors":[...]} envelope
+// e.g. if the data was already extracted from a cached response, errors can't be passed along with the data in this mode
+func (c *Context) SetPreExtractedData(preExtracted bool) {
+	c.preExtractedData = preExtracted
+}
+
+// SetMaxPreparedInputBytes limits the total size of the inputs prepared for the fetches of a response, e.g. the upstream request bodies
+// Resolving fails with ErrMaxPreparedInputBytesExceeded once the limit is exceeded, 0 (default) disables the limit
+// Each frame of a subscription is a response of its own, the patches of a streaming response count towards the initial response
+func (c *Context) SetMaxPreparedInputBytes(max int64) {
+	c.maxPreparedInputBytes = max
+}
+
+// PreparedInputBytes returns the total size of the inputs prepared for the fetches of the last resolved response
+func (c *Context) PreparedInputBytes() int64 {
+	if c.preparedInputBytes == nil {
+		return 0
+	}
+	return atomic.LoadInt64(c.preparedInputBytes)
+}
+
+func (c *Context) resetPreparedInputBytes() {
+	c.preparedInputBytes = new(int64)
+}
+
+func (c *Context) addPreparedInputBytes(n int) error {
+	if c.preparedInputBytes == nil {
+		return nil
+	}
+	total := atomic.AddInt64(c.preparedInputBytes, int64(n))
+	if c.maxPreparedInputBytes > 0 && total > c.maxPreparedInputBytes {
+		return fmt.Errorf("%w: %d of %d bytes", ErrMaxPreparedInputBytesExceeded, total, c.maxPreparedInputBytes)
+	}
+	return nil
+}
+
+func (c *Context) resetTruncatedArrays() {
+	c.truncatedArrays = &truncatedArrays{}
+}
+
+// addTruncatedArray records the notice for an array at the current path which was truncated from count to maxItems items
+func (c *Context) addTruncatedArray(count, maxItems int) {
+	if c.truncatedArrays == nil {
+		return
+	}
+	c.truncatedArrays.mu.Lock()
+	defer c.truncatedArrays.mu.Unlock()
+	notices := &c.truncatedArrays.notices
+	if notices.Len() != 0 {
+		notices.Write(comma)
+	}
+	notices.WriteString(`{"path":`)
+	c.writeErrorPath(notices)
+	notices.WriteString(`,"count":`)
+	notices.WriteString(strconv.Itoa(count))
+	notices.WriteString(`,"maxItems":`)
+	notices.WriteString(strconv.Itoa(maxItems))
+	notices.Write(rBrace)
+}
+
+// extensions returns the extensions of the response, or nil if there are none
+func (c *Context) extensions() []byte {
+	hasTruncatedArrays := c.truncatedArrays != nil && c.truncatedArrays.notices.Len() != 0
+	if !hasTruncatedArrays && c.tracing == nil {
+		return nil
+	}
+	extensions := &bytes.Buffer{}
+	extensions.Write(lBrace)
+	if hasTruncatedArrays {
+		extensions.Write(quote)
+		extensions.Write(literalTruncated)
+		extensions.Write(quote)
+		extensions.Write(colon)
+		extensions.Write(lBrack)
+		extensions.Write(c.truncatedArrays.notices.Bytes())
+		extensions.Write(rBrack)
+	}
+	if c.tracing != nil {
+		if hasTruncatedArrays {
+			extensions.Write(comma)
+		}
+		c.tracing.writeTo(extensions)
+	}
+	extensions.Write(rBrace)
+	return extensions.Bytes()
+}
+
+// cancellationCheckInterval is the number of array items and object fields resolved between two checks of the context
+const cancellationCheckInterval = 32
+
+// checkCanceled returns the error of the context once it's canceled or its deadline is exceeded, so that resolving large responses stops
+// when the client is gone. The context is checked every cancellationCheckInterval calls to keep the hot loops of resolving cheap.
+func (c *Context) checkCanceled() error {
+	c.cancellationChecks++
+	if c.cancellationChecks%cancellationCheckInterval != 0 || c.Context == nil {
+		return nil
+	}
+	return c.Context.Err()
+}
+
+// writeErrorPath writes the current path as GraphQL error path, e.g. ["users",0,"name"]
+func (c *Context) writeErrorPath(buf *bytes.Buffer) {
+	buf.Write(lBrack)
+	for i := range c.pathElements {
+		if i != 0 {
+			buf.Write(comma)
+		}
+		// list indices are rendered as numbers, field names as strings
+		if c.pathElements[i].isIndex {
+			buf.WriteString(strconv.Itoa(c.pathElements[i].index))
+			continue
+		}
+		buf.Write(quote)
+		buf.Write(c.pathElements[i].name)
+		buf.Write(quote)
+	}
+	buf.Write(rBrack)
+}
+
 func (c *Context) setPosition(position Position) {
 	c.position = position
 }
 
+// pathElement is an element of the current path, either the name of a field or the index of a list item
+type pathElement struct {
+	name    []byte
+	index   int
+	isIndex bool
+}
+
 func (c *Context) addPathElement(elem []byte) {
-	c.pathElements = append(c.pathElements, elem)
+	c.pathElements = append(c.pathElements, pathElement{name: elem})
 }
 
 func (c *Context) addIntegerPathElement(elem int) {
-	b := unsafebytes.StringToBytes(strconv.Itoa(elem))
-	c.pathElements = append(c.pathElements, b)
+	c.pathElements = append(c.pathElements, pathElement{index: elem, isIndex: true})
 }
 
 func (c *Context) removeLastPathElement() {
@@ -240,15 +518,32 @@ func (c *Context) path() []byte {
 		buf.Write(literal.DATA)
 	}
 	for i := range c.pathElements {
-		if i == 0 && bytes.Equal(literal.DATA, c.pathElements[0]) {
+		if i == 0 && bytes.Equal(literal.DATA, c.pathElements[0].name) {
 			continue
 		}
 		_, _ = buf.Write(literal.SLASH)
-		_, _ = buf.Write(c.pathElements[i])
+		if c.pathElements[i].isIndex {
+			_, _ = buf.WriteString(strconv.Itoa(c.pathElements[i].index))
+			continue
+		}
+		_, _ = buf.Write(c.pathElements[i].name)
 	}
 	return buf.Bytes()
 }
 
+// resetPatches releases all patches of a streaming response so that the Context can be used for the next one,
+// e.g. to resolve the next frame of a subscription
+func (c *Context) resetPatches() {
+	c.pathPrefix = c.pathPrefix[:0]
+	c.patches = c.patches[:0]
+	for i := range c.usedBuffers {
+		pool.BytesBuffer.Put(c.usedBuffers[i])
+	}
+	c.usedBuffers = c.usedBuffers[:0]
+	c.currentPatch = -1
+	c.maxPatch = -1
+}
+
 func (c *Context) addPatch(index int, path, extraPath, data []byte) {
 	next := patch{path: path, extraPath: extraPath, data: data, index: index}
 	c.patches = append(c.patches, next)
@@ -282,34 +577,93 @@ type SubscriptionDataSource interface {
 	Start(ctx context.Context, input []byte, next chan<- []byte) error
 }
 
+// ErrorReportingSubscriptionDataSource is implemented by subscription data sources which can fail after the subscription started
+// If implemented, StartWithErrors is used instead of Start
+// An error sent on errs is written to the client as a GraphQL error after all previously sent frames, then the subscription ends
+// errs is buffered for one error, so that sending an error never blocks
+type ErrorReportingSubscriptionDataSource interface {
+	StartWithErrors(ctx context.Context, input []byte, next chan<- []byte, errs chan<- error) error
+}
+
 type Resolver struct {
-	EnableSingleFlightLoader bool
-	resultSetPool            sync.Pool
-	byteSlicesPool           sync.Pool
-	waitGroupPool            sync.Pool
-	bufPairPool              sync.Pool
-	bufPairSlicePool         sync.Pool
-	errChanPool              sync.Pool
-	hash64Pool               sync.Pool
-	inflightFetchPool        sync.Pool
-	inflightFetchMu          sync.Mutex
-	inflightFetches          map[uint64]*inflightFetch
-	ctx                      context.Context
+	// droppedSubscriptionFrames is accessed atomically, it's the first field so that it's 64-bit aligned on 32-bit platforms
+	droppedSubscriptionFrames uint64
+	EnableSingleFlightLoader  bool
+	// SingleFlightDisabledFor disables the single flight loader for the fetches of the data sources it returns true for,
+	// e.g. for all data sources of an auth service, in addition to the DisallowSingleFlight of each fetch
+	SingleFlightDisabledFor func(dataSourceIdentifier []byte) bool
+	// MaxConcurrentArrayResolvers limits the number of goroutines used to resolve the items of an asynchronous array
+	// If set to 0 (default), one goroutine is spawned per array item
+	MaxConcurrentArrayResolvers int
+	// MaxConcurrentSubscriptionFrames limits the number of subscription frames resolved at the same time
+	// Frames are written in the order they arrived, the subscription source is not read while the limit is reached
+	// If set to 0 or 1 (default), frames are resolved one after another
+	MaxConcurrentSubscriptionFrames int
+	// MaxResponseBytes limits the size of a resolved response, resolving fails with ErrMaxResponseBytesExceeded once the limit is exceeded
+	// The size is checked whenever an array item or an object field is merged, so a response is aborted before it's completely resolved
+	// If set to 0 (default), the size of responses is unlimited
+	MaxResponseBytes int
+	// MaxPooledBufferBytes is the capacity above which a buffer isn't put back into the pools of the Resolver after use
+	// Buffers grow with the responses they hold, capping them bounds the memory kept by the pools after a burst of large responses
+	// If set to 0 (default), all buffers are put back
+	MaxPooledBufferBytes int
+	// StrictTypeNameConditions resolves an object to null if the data of a field with OnTypeName lacks __typename
+	// and adds ErrMissingTypeName as error with the path of the object, null bubbles up like for any non-nullable field.
+	// Without it such fields are skipped, so an object mixing conditioned and unconditioned fields is resolved partially
+	StrictTypeNameConditions bool
+	// MaxDecompressedResponseBytes limits the size of compressed upstream responses after decompression, e.g. to reject decompression bombs
+	// If set to 0 (default), DefaultMaxDecompressedResponseBytes is used
+	MaxDecompressedResponseBytes int
+
+	resultSetPool     sync.Pool
+	byteSlicesPool    sync.Pool
+	waitGroupPool     sync.Pool
+	bufPairPool       sync.Pool
+	bufPairSlicePool  sync.Pool
+	errChanPool       sync.Pool
+	hash64Pool        sync.Pool
+	inflightFetchPool sync.Pool
+	inflightFetchMu   sync.Mutex
+	inflightFetches   map[uint64]*inflightFetch
+	ctx               context.Context
+	clock             Clock
+	json              JSONValueGetter
+	transforms        map[string]TransformFunc
+	responseTransform ResponseTransformFunc
+	poolStats         *resolverPoolStats
 }
 
 type inflightFetch struct {
 	waitLoad sync.WaitGroup
 	waitFree sync.WaitGroup
 	err      error
+	fallback bool
 	bufPair  BufPair
 }
 
+// writeTo copies the loaded data and errors to buf, bufPair is shared by all loads of the fetch and isn't modified
+func (f *inflightFetch) writeTo(buf *BufPair) {
+	if f.bufPair.HasData() {
+		buf.Data.WriteBytes(f.bufPair.Data.Bytes())
+	}
+	if f.bufPair.HasErrors() {
+		buf.Errors.WriteBytes(f.bufPair.Errors.Bytes())
+	}
+	buf.timedOut = f.bufPair.timedOut
+}
+
 // New returns a new Resolver, ctx.Done() is used to cancel all active subscriptions & streams
 func New(ctx context.Context) *Resolver {
+	stats := &resolverPoolStats{}
 	return &Resolver{
-		ctx: ctx,
+		ctx:        ctx,
+		clock:      realClock{},
+		json:       jsonparserValueGetter{},
+		transforms: map[string]TransformFunc{},
+		poolStats:  stats,
 		resultSetPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.resultSets)
 				return &resultSet{
 					buffers: make(map[int]*BufPair, 8),
 				}
@@ -317,17 +671,20 @@ func New(ctx context.Context) *Resolver {
 		},
 		byteSlicesPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.byteSlices)
 				slice := make([][]byte, 0, 24)
 				return &slice
 			},
 		},
 		waitGroupPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.waitGroups)
 				return &sync.WaitGroup{}
 			},
 		},
 		bufPairPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.bufPairs)
 				pair := BufPair{
 					Data:   fastbuffer.New(),
 					Errors: fastbuffer.New(),
@@ -337,22 +694,26 @@ func New(ctx context.Context) *Resolver {
 		},
 		bufPairSlicePool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.bufPairSlices)
 				slice := make([]*BufPair, 0, 24)
 				return &slice
 			},
 		},
 		errChanPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.errChans)
 				return make(chan error, 1)
 			},
 		},
 		hash64Pool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.hash64s)
 				return xxhash.New()
 			},
 		},
 		inflightFetchPool: sync.Pool{
 			New: func() interface{} {
+				stats.new(&stats.inflightFetches)
 				return &inflightFetch{
 					bufPair: BufPair{
 						Data:   fastbuffer.New(),
@@ -365,6 +726,71 @@ func New(ctx context.Context) *Resolver {
 	}
 }
 
+// TransformFunc transforms the JSON value of a scalar field, e.g. `"foo"` into `"FOO"`
+// The returned value must be valid JSON
+type TransformFunc func(value []byte) ([]byte, error)
+
+// RegisterTransform registers a TransformFunc which can be referenced by name via the Transform field of scalar nodes
+// Transforms must be registered before resolving starts
+func (r *Resolver) RegisterTransform(name string, transform TransformFunc) {
+	r.transforms[name] = transform
+}
+
+// ValidateTransforms returns an error if the node or any of its children reference a transform which is not registered
+func (r *Resolver) ValidateTransforms(node Node) error {
+	var transform string
+	switch n := node.(type) {
+	case *Object:
+		for i := range n.Fields {
+			if err := r.ValidateTransforms(n.Fields[i].Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Array:
+		return r.ValidateTransforms(n.Item)
+	case *String:
+		transform = n.Transform
+	case *Boolean:
+		transform = n.Transform
+	case *Integer:
+		transform = n.Transform
+	case *Float:
+		transform = n.Transform
+	}
+	if transform == "" {
+		return nil
+	}
+	if _, ok := r.transforms[transform]; !ok {
+		return fmt.Errorf("%w: %s", errTransformNotRegistered, transform)
+	}
+	return nil
+}
+
+// ResponseTransformFunc transforms the resolved data of a response before it's written, e.g. to redact values
+// data is the value of the "data" key of the response, the returned data must be valid JSON
+type ResponseTransformFunc func(ctx *Context, data []byte) ([]byte, error)
+
+// SetResponseTransform sets a ResponseTransformFunc applied to the data of all responses resolved by ResolveGraphQLResponse
+// It's nil by default, setting it to nil disables it again
+func (r *Resolver) SetResponseTransform(transform ResponseTransformFunc) {
+	r.responseTransform = transform
+}
+
+// SetJSONValueGetter replaces the JSONValueGetter used to read upstream responses and variables, it's jsonparser by default
+// Setting it to nil restores the default. It must not be called while responses are resolved.
+func (r *Resolver) SetJSONValueGetter(getter JSONValueGetter) {
+	if getter == nil {
+		getter = jsonparserValueGetter{}
+	}
+	r.json = getter
+}
+
+// SetClock replaces the Clock used for time dependent operations like flushing streaming responses
+func (r *Resolver) SetClock(clock Clock) {
+	r.clock = clock
+}
+
 func (r *Resolver) resolveNode(ctx *Context, node Node, data []byte, bufPair *BufPair) (err error) {
 	switch n := node.(type) {
 	case *Object:
@@ -378,12 +804,24 @@ func (r *Resolver) resolveNode(ctx *Context, node Node, data []byte, bufPair *Bu
 		r.resolveNull(bufPair.Data)
 		return
 	case *String:
+		if n.Transform != "" {
+			return r.resolveTransformed(ctx, n, n.Transform, data, bufPair)
+		}
 		return r.resolveString(n, data, bufPair)
 	case *Boolean:
-		return r.resolveBoolean(n, data, bufPair)
+		if n.Transform != "" {
+			return r.resolveTransformed(ctx, n, n.Transform, data, bufPair)
+		}
+		return r.resolveBoolean(ctx, n, data, bufPair)
 	case *Integer:
-		return r.resolveInteger(n, data, bufPair)
+		if n.Transform != "" {
+			return r.resolveTransformed(ctx, n, n.Transform, data, bufPair)
+		}
+		return r.resolveInteger(ctx, n, data, bufPair)
 	case *Float:
+		if n.Transform != "" {
+			return r.resolveTransformed(ctx, n, n.Transform, data, bufPair)
+		}
 		return r.resolveFloat(n, data, bufPair)
 	case *EmptyObject:
 		r.resolveEmptyObject(bufPair.Data)
@@ -391,11 +829,53 @@ func (r *Resolver) resolveNode(ctx *Context, node Node, data []byte, bufPair *Bu
 	case *EmptyArray:
 		r.resolveEmptyArray(bufPair.Data)
 		return
+	case *StaticValue:
+		bufPair.Data.WriteBytes(n.Value)
+		return
 	default:
 		return
 	}
 }
 
+// resolveTransformed resolves a scalar node and passes the resulting JSON value through the registered transform
+// null values are not transformed
+func (r *Resolver) resolveTransformed(ctx *Context, node Node, transform string, data []byte, bufPair *BufPair) (err error) {
+	fn, ok := r.transforms[transform]
+	if !ok {
+		return fmt.Errorf("%w: %s", errTransformNotRegistered, transform)
+	}
+
+	valueBuf := r.getBufPair()
+	defer r.freeBufPair(valueBuf)
+
+	switch n := node.(type) {
+	case *String:
+		err = r.resolveString(n, data, valueBuf)
+	case *Boolean:
+		err = r.resolveBoolean(ctx, n, data, valueBuf)
+	case *Integer:
+		err = r.resolveInteger(ctx, n, data, valueBuf)
+	case *Float:
+		err = r.resolveFloat(n, data, valueBuf)
+	}
+	r.MergeBufPairErrors(valueBuf, bufPair)
+	if err != nil {
+		return err
+	}
+
+	value := valueBuf.Data.Bytes()
+	if bytes.Equal(value, null) {
+		bufPair.Data.WriteBytes(null)
+		return nil
+	}
+	transformed, err := fn(value)
+	if err != nil {
+		return err
+	}
+	bufPair.Data.WriteBytes(transformed)
+	return nil
+}
+
 func (r *Resolver) validateContext(ctx *Context) (err error) {
 	if ctx.maxPatch != -1 || ctx.currentPatch != -1 {
 		return fmt.Errorf("Context must be resetted using Free() before re-using it")
@@ -403,24 +883,102 @@ func (r *Resolver) validateContext(ctx *Context) (err error) {
 	return nil
 }
 
-func (r *Resolver) extractResponse(responseData []byte, bufPair *BufPair, cfg ProcessResponseConfig) {
+// extractFetchResponse extracts the response of a fetch into bufPair
+// batch responses are kept as is, they get scattered into the batch buffers by extractBatchResponse
+func (r *Resolver) extractFetchResponse(ctx *Context, fetch *SingleFetch, responseData []byte, bufPair *BufPair) (err error) {
+	// decompress first, so that the response schema and batch responses see the decompressed response
+	responseData, err = decompressResponse(responseData, fetch.ProcessResponseConfig.ContentEncoding, r.maxDecompressedResponseBytes())
+	if err != nil {
+		return
+	}
+	if fetch.ResponseSchema != nil && len(responseData) != 0 && ctx.FeatureFlags.Enabled(FeatureFlagValidateResponseSchema) {
+		if schemaErr := fetch.ResponseSchema.Validate(responseData); schemaErr != nil {
+			bufPair.WriteErr(escapedErrorMessage(schemaErr), nil, nil, nil)
+		}
+	}
+	if len(fetch.BatchBufferIds) == 0 {
+		return r.extractResponse(responseData, bufPair, fetch.ProcessResponseConfig)
+	}
+	if len(responseData) == 0 {
+		return
+	}
+	responseData, err = r.trimTrailingResponseData(responseData, fetch.ProcessResponseConfig.StrictResponseParsing)
+	if err != nil {
+		return
+	}
+	bufPair.Data.WriteBytes(responseData)
+	return
+}
+
+func (r *Resolver) maxDecompressedResponseBytes() int {
+	if r.MaxDecompressedResponseBytes > 0 {
+		return r.MaxDecompressedResponseBytes
+	}
+	return DefaultMaxDecompressedResponseBytes
+}
+
+// extractBatchResponse scatters the responses of a batch fetch into the batch buffers by index
+// the buffer of the fetch itself only keeps errors, e.g. when the number of responses is not as expected
+func (r *Resolver) extractBatchResponse(fetch *SingleFetch, set *resultSet) {
+	if len(fetch.BatchBufferIds) == 0 {
+		return
+	}
+	batchBuf := set.buffers[fetch.BufferId]
+	if !batchBuf.HasData() {
+		for _, bufferID := range fetch.BatchBufferIds {
+			set.buffers[bufferID].timedOut = batchBuf.timedOut
+		}
+		return
+	}
+
+	responses := r.getByteSlices()
+	defer r.freeByteSlices(responses)
+
+	_, err := r.json.ArrayEach(batchBuf.Data.Bytes(), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		*responses = append(*responses, value)
+	})
+
+	switch {
+	case err != nil:
+		batchBuf.WriteErr([]byte("batch response is not an array"), nil, nil, nil)
+	case len(*responses) != len(fetch.BatchBufferIds):
+		message := fmt.Sprintf("batch response contains %d results, expected %d", len(*responses), len(fetch.BatchBufferIds))
+		batchBuf.WriteErr([]byte(message), nil, nil, nil)
+	default:
+		for i, bufferID := range fetch.BatchBufferIds {
+			if extractErr := r.extractResponse((*responses)[i], set.buffers[bufferID], fetch.ProcessResponseConfig); extractErr != nil {
+				set.buffers[bufferID].WriteErr([]byte(extractErr.Error()), nil, nil, nil)
+			}
+		}
+	}
+
+	batchBuf.Data.Reset()
+}
+
+func (r *Resolver) extractResponse(responseData []byte, bufPair *BufPair, cfg ProcessResponseConfig) (err error) {
 	if len(responseData) == 0 {
 		return
 	}
 
+	responseData, err = r.trimTrailingResponseData(responseData, cfg.StrictResponseParsing)
+	if err != nil {
+		return
+	}
+
 	if !cfg.ExtractGraphqlResponse {
 		bufPair.Data.WriteBytes(responseData)
+		r.checkDuplicateKeys(bufPair, cfg)
 		return
 	}
 
-	jsonparser.EachKey(responseData, func(i int, bytes []byte, valueType jsonparser.ValueType, err error) {
+	r.json.EachKey(responseData, func(i int, bytes []byte, valueType jsonparser.ValueType, err error) {
 		switch i {
 		case rootErrorsPathIndex:
-			_, _ = jsonparser.ArrayEach(bytes, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			_, _ = r.json.ArrayEach(bytes, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 				var (
 					message, locations, path, extensions []byte
 				)
-				jsonparser.EachKey(value, func(i int, bytes []byte, valueType jsonparser.ValueType, err error) {
+				r.json.EachKey(value, func(i int, bytes []byte, valueType jsonparser.ValueType, err error) {
 					switch i {
 					case errorsMessagePathIndex:
 						message = bytes
@@ -433,30 +991,129 @@ func (r *Resolver) extractResponse(responseData []byte, bufPair *BufPair, cfg Pr
 					}
 				}, errorPaths...)
 				if message != nil {
+					if cfg.DefaultErrorCode != "" {
+						extensions = r.withDefaultErrorCode(extensions, cfg.DefaultErrorCode)
+					}
 					bufPair.WriteErr(message, locations, path, extensions)
 				}
 			})
 		case rootDataPathIndex:
 			if cfg.ExtractFederationEntities {
-				data, _, _, _ := jsonparser.Get(bytes, entitiesPath...)
+				data, _, _, _ := r.json.Get(bytes, entitiesPath...)
 				bufPair.Data.WriteBytes(data)
 				return
 			}
 			bufPair.Data.WriteBytes(bytes)
 		}
-	}, responsePaths...)
+	}, cfg.responsePaths()...)
+	r.checkDuplicateKeys(bufPair, cfg)
+	return
+}
+
+// withDefaultErrorCode returns the extensions of an upstream error with the code added if they don't contain one
+// extensions which aren't an object are replaced
+func (r *Resolver) withDefaultErrorCode(extensions []byte, code string) []byte {
+	quotedCode := strconv.Quote(code)
+	_, dataType, _, err := r.json.Get(extensions)
+	if err != nil || dataType != jsonparser.Object {
+		return []byte(`{"code":` + quotedCode + `}`)
+	}
+	if _, _, _, err = r.json.Get(extensions, "code"); err == nil {
+		return extensions
+	}
+	trimmed := bytes.TrimSpace(extensions)
+	augmented := make([]byte, 0, len(trimmed)+len(quotedCode)+9)
+	augmented = append(augmented, `{"code":`...)
+	augmented = append(augmented, quotedCode...)
+	rest := bytes.TrimSpace(trimmed[1:])
+	if !bytes.Equal(rest, rBrace) {
+		augmented = append(augmented, comma...)
+	}
+	return append(augmented, rest...)
+}
+
+// checkDuplicateKeys adds an error for the first duplicate object key in the extracted data if enabled
+// the data itself is left untouched, so resolving continues with the first occurrence of the key
+func (r *Resolver) checkDuplicateKeys(bufPair *BufPair, cfg ProcessResponseConfig) {
+	if !cfg.DetectDuplicateKeys || !bufPair.HasData() {
+		return
+	}
+	key, path, ok := r.findDuplicateKey(bufPair.Data.Bytes(), nil)
+	if !ok {
+		return
+	}
+	message := "duplicate key '" + string(key) + "' in upstream response"
+	if len(path) != 0 {
+		message += " at path '" + strings.Join(path, ".") + "'"
+	}
+	bufPair.WriteErr([]byte(message), nil, nil, nil)
+}
+
+func (r *Resolver) findDuplicateKey(data []byte, path []string) (key []byte, keyPath []string, found bool) {
+	_, dataType, _, err := r.json.Get(data)
+	if err != nil {
+		return nil, nil, false
+	}
+	switch dataType {
+	case jsonparser.Object:
+		keys := map[string]struct{}{}
+		_ = r.json.ObjectEach(data, func(k []byte, value []byte, valueType jsonparser.ValueType, offset int) error {
+			if _, exists := keys[string(k)]; exists {
+				key, keyPath, found = k, path, true
+				return errDuplicateKey
+			}
+			keys[string(k)] = struct{}{}
+			if valueType != jsonparser.Object && valueType != jsonparser.Array {
+				return nil
+			}
+			key, keyPath, found = r.findDuplicateKey(value, append(path[:len(path):len(path)], string(k)))
+			if found {
+				return errDuplicateKey
+			}
+			return nil
+		})
+	case jsonparser.Array:
+		i := 0
+		_, _ = r.json.ArrayEach(data, func(value []byte, valueType jsonparser.ValueType, offset int, err error) {
+			if !found && (valueType == jsonparser.Object || valueType == jsonparser.Array) {
+				key, keyPath, found = r.findDuplicateKey(value, append(path[:len(path):len(path)], strconv.Itoa(i)))
+			}
+			i++
+		})
+	}
+	return
+}
+
+// trimTrailingResponseData cuts off everything after the first JSON value of an upstream response
+// In strict mode, any non whitespace data after the first JSON value results in an error
+func (r *Resolver) trimTrailingResponseData(responseData []byte, strict bool) ([]byte, error) {
+	_, _, end, err := r.json.Get(responseData)
+	if err != nil {
+		return responseData, nil
+	}
+	if strict && len(bytes.TrimSpace(responseData[end:])) != 0 {
+		return nil, errTrailingResponseData
+	}
+	return responseData[:end], nil
 }
 
 func (r *Resolver) ResolveGraphQLResponse(ctx *Context, response *GraphQLResponse, data []byte, writer io.Writer) (err error) {
+	ctx.json = r.json
 	buf := r.getBufPair()
 	defer r.freeBufPair(buf)
 
 	responseBuf := r.getBufPair()
 	defer r.freeBufPair(responseBuf)
 
-	r.extractResponse(data, responseBuf, ProcessResponseConfig{ExtractGraphqlResponse: true})
+	err = r.extractResponse(data, responseBuf, ProcessResponseConfig{ExtractGraphqlResponse: !ctx.preExtractedData})
+	if err != nil {
+		return
+	}
 
-	ignoreData := false
+	ctx.resetPreparedInputBytes()
+	ctx.resetTruncatedArrays()
+	ctx.resetTracing(r.clock)
+	ignoreData := ctx.errorsOnly
 	err = r.resolveNode(ctx, response.Data, responseBuf.Data.Bytes(), buf)
 	if err != nil {
 		if !errors.Is(err, errNonNullableFieldValueIsNull) {
@@ -467,54 +1124,416 @@ func (r *Resolver) ResolveGraphQLResponse(ctx *Context, response *GraphQLRespons
 	if responseBuf.Errors.Len() > 0 {
 		r.MergeBufPairErrors(responseBuf, buf)
 	}
+	if r.responseTransform != nil && !ignoreData && buf.HasData() {
+		err = r.transformResponse(ctx, buf)
+		if err != nil {
+			return
+		}
+	}
+
+	return writeGraphqlResponseWithExtensions(buf, writer, ignoreData, ctx.extensions())
+}
+
+func (r *Resolver) transformResponse(ctx *Context, buf *BufPair) error {
+	transformed, err := r.responseTransform(ctx, buf.Data.Bytes())
+	if err != nil {
+		return err
+	}
+	if !json.Valid(transformed) {
+		return errInvalidTransformedResponse
+	}
+	// the transformed data might share the memory of the buffer, append copies overlapping memory correctly
+	buf.Data.Reset()
+	buf.Data.WriteBytes(transformed)
+	return nil
+}
+
+// AppendGraphQLResponse resolves the response like ResolveGraphQLResponse and appends it to dst
+// It returns the extended slice, so that callers can re-use the response buffer across requests, e.g. by pooling it.
+// If resolving fails, dst is returned unchanged.
+func (r *Resolver) AppendGraphQLResponse(ctx *Context, response *GraphQLResponse, data []byte, dst []byte) ([]byte, error) {
+	writer := appendWriter{buf: dst}
+	if err := r.ResolveGraphQLResponse(ctx, response, data, &writer); err != nil {
+		return dst, err
+	}
+	return writer.buf, nil
+}
+
+type appendWriter struct {
+	buf []byte
+}
+
+func (a *appendWriter) Write(p []byte) (n int, err error) {
+	a.buf = append(a.buf, p...)
+	return len(p), nil
+}
+
+func (r *Resolver) ResolveGraphQLSubscription(ctx *Context, subscription *GraphQLSubscription, writer FlushWriter) (err error) {
+	ctx.json = r.json
+
+	buf := r.getBufPair()
+	err = subscription.Trigger.InputTemplate.Render(ctx, nil, buf.Data)
+	if err != nil {
+		return
+	}
+	rendered := buf.Data.Bytes()
+	subscriptionInput := make([]byte, len(rendered))
+	copy(subscriptionInput, rendered)
+	r.freeBufPair(buf)
+
+	c, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resolverDone := r.ctx.Done()
+
+	var sourceNext chan []byte
+	if subscription.Trigger.BufferMode == SubscriptionBufferModeBlock {
+		sourceNext = make(chan []byte, subscription.Trigger.BufferSize)
+	} else {
+		sourceNext = make(chan []byte)
+	}
+	sourceErrs := make(chan error, 1)
+	if source, ok := subscription.Trigger.Source.(ErrorReportingSubscriptionDataSource); ok {
+		err = source.StartWithErrors(c, subscriptionInput, sourceNext, sourceErrs)
+	} else {
+		err = subscription.Trigger.Source.Start(c, subscriptionInput, sourceNext)
+	}
+	if err != nil {
+		if errors.Is(err, ErrUnableToResolve) {
+			_, err = writer.Write([]byte(`{"errors":[{"message":"unable to resolve"}]}`))
+			if err != nil {
+				return err
+			}
+			writer.Flush()
+			return nil
+		}
+		return err
+	}
+
+	var (
+		next <-chan []byte = sourceNext
+		errs <-chan error  = sourceErrs
+	)
+	if subscription.Trigger.BufferMode == SubscriptionBufferModeDropOldest {
+		next, errs = r.dropOldestSubscriptionFrames(c, subscription.Trigger.BufferSize, sourceNext, sourceErrs)
+	}
+
+	if r.MaxConcurrentSubscriptionFrames > 1 {
+		return r.resolveSubscriptionFramesConcurrently(ctx, subscription, next, errs, writer)
+	}
+
+	heartbeat := r.newHeartbeatTimer(subscription)
+	defer heartbeat.stop()
+
+	for {
+		select {
+		case <-resolverDone:
+			return nil
+		case sourceErr := <-errs:
+			// frames which were buffered before the error are written first
+			err = r.resolveBufferedSubscriptionFrames(ctx, subscription, next, writer)
+			if err != nil {
+				return err
+			}
+			_, err = writer.Write(subscriptionErrorFrame(sourceErr))
+			if err != nil {
+				return err
+			}
+			writer.Flush()
+			return nil
+		case <-heartbeat.C():
+			err = r.writeHeartbeat(writer)
+			if err != nil {
+				return err
+			}
+			heartbeat.reset()
+		case data, ok := <-next:
+			if !ok {
+				return nil
+			}
+			err = r.resolveSubscriptionFrame(ctx, subscription, data, writer)
+			if err != nil {
+				return err
+			}
+			heartbeat.reset()
+		}
+	}
+}
+
+func (r *Resolver) resolveBufferedSubscriptionFrames(ctx *Context, subscription *GraphQLSubscription, next <-chan []byte, writer FlushWriter) error {
+	for {
+		select {
+		case data, ok := <-next:
+			if !ok {
+				return nil
+			}
+			if err := r.resolveSubscriptionFrame(ctx, subscription, data, writer); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// dropOldestSubscriptionFrames reads the frames of the source without blocking it and buffers up to bufferSize frames
+// if the buffer is full, the oldest buffered frame is dropped, with a bufferSize of 1 the latest frame wins
+// errors of the source are forwarded after all previously received frames are buffered
+func (r *Resolver) dropOldestSubscriptionFrames(ctx context.Context, bufferSize int, sourceNext <-chan []byte, sourceErrs <-chan error) (<-chan []byte, <-chan error) {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	next := make(chan []byte, bufferSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		closeNext := true
+		defer func() {
+			if closeNext {
+				close(next)
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sourceErr := <-sourceErrs:
+				// next stays open, otherwise the error could get lost if the closed channel is read first
+				closeNext = false
+				errs <- sourceErr
+				return
+			case data, ok := <-sourceNext:
+				if !ok {
+					return
+				}
+				select {
+				case next <- data:
+					continue
+				default:
+				}
+				select {
+				case <-next:
+					atomic.AddUint64(&r.droppedSubscriptionFrames, 1)
+				default:
+				}
+				// this goroutine is the only sender, so there's space after one frame was dropped or read
+				next <- data
+			}
+		}
+	}()
+
+	return next, errs
+}
+
+// DroppedSubscriptionFrames returns the number of subscription frames dropped by SubscriptionBufferModeDropOldest
+func (r *Resolver) DroppedSubscriptionFrames() uint64 {
+	return atomic.LoadUint64(&r.droppedSubscriptionFrames)
+}
+
+// heartbeatTimer fires once no frame was written for the HeartbeatInterval of the subscription
+// if heartbeats are disabled, the heartbeatTimer is nil and its channel never fires
+type heartbeatTimer struct {
+	timer    Timer
+	interval time.Duration
+}
+
+func (r *Resolver) newHeartbeatTimer(subscription *GraphQLSubscription) *heartbeatTimer {
+	if subscription.HeartbeatInterval <= 0 {
+		return nil
+	}
+	return &heartbeatTimer{
+		timer:    r.clock.NewTimer(subscription.HeartbeatInterval),
+		interval: subscription.HeartbeatInterval,
+	}
+}
+
+func (h *heartbeatTimer) C() <-chan time.Time {
+	if h == nil {
+		return nil
+	}
+	return h.timer.C()
+}
+
+// reset starts the interval again, a heartbeat which already fired but wasn't received yet is discarded
+func (h *heartbeatTimer) reset() {
+	if h == nil {
+		return
+	}
+	if !h.timer.Stop() {
+		select {
+		case <-h.timer.C():
+		default:
+		}
+	}
+	h.timer.Reset(h.interval)
+}
+
+func (h *heartbeatTimer) stop() {
+	if h == nil {
+		return
+	}
+	h.timer.Stop()
+}
+
+// subscriptionErrorFrame returns a GraphQL response containing the error of a subscription source
+func subscriptionErrorFrame(err error) []byte {
+	message, _ := json.Marshal(err.Error())
+	frame := make([]byte, 0, len(message)+26)
+	frame = append(frame, `{"errors":[{"message":`...)
+	frame = append(frame, message...)
+	frame = append(frame, `}]}`...)
+	return frame
+}
+
+func (r *Resolver) writeHeartbeat(writer FlushWriter) error {
+	if heartbeatWriter, ok := writer.(HeartbeatWriter); ok {
+		return heartbeatWriter.WriteHeartbeat()
+	}
+	_, err := writer.Write(heartbeatFrame)
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return nil
+}
+
+func (r *Resolver) resolveSubscriptionFrame(ctx *Context, subscription *GraphQLSubscription, data []byte, writer FlushWriter) (err error) {
+	if subscription.StreamingResponse != nil {
+		err = r.ResolveGraphQLStreamingResponse(ctx, subscription.StreamingResponse, data, writer)
+		ctx.resetPatches()
+		return err
+	}
+	err = r.ResolveGraphQLResponse(ctx, subscription.Response, data, writer)
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return nil
+}
 
-	return writeGraphqlResponse(buf, writer, ignoreData)
+type subscriptionFrame struct {
+	writer *frameWriter
+	err    error
 }
 
-func (r *Resolver) ResolveGraphQLSubscription(ctx *Context, subscription *GraphQLSubscription, writer FlushWriter) (err error) {
+// frameWriter buffers the output of a subscription frame and remembers where it was flushed
+type frameWriter struct {
+	buf     bytes.Buffer
+	flushes []int
+}
 
-	buf := r.getBufPair()
-	err = subscription.Trigger.InputTemplate.Render(ctx, nil, buf.Data)
-	if err != nil {
-		return
+func (f *frameWriter) Write(p []byte) (n int, err error) {
+	return f.buf.Write(p)
+}
+
+func (f *frameWriter) Flush() {
+	f.flushes = append(f.flushes, f.buf.Len())
+}
+
+func (f *frameWriter) writeTo(writer FlushWriter) (err error) {
+	out := f.buf.Bytes()
+	start := 0
+	for _, end := range f.flushes {
+		_, err = writer.Write(out[start:end])
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		start = end
 	}
-	rendered := buf.Data.Bytes()
-	subscriptionInput := make([]byte, len(rendered))
-	copy(subscriptionInput, rendered)
-	r.freeBufPair(buf)
+	return nil
+}
 
-	c, cancel := context.WithCancel(ctx)
-	defer cancel()
+// resolveSubscriptionFramesConcurrently resolves up to MaxConcurrentSubscriptionFrames frames at the same time
+// each frame is resolved with a clone of the Context into its own frameWriter
+// the frames are queued in arrival order and written to the writer once they're resolved, so the output order is preserved
+func (r *Resolver) resolveSubscriptionFramesConcurrently(ctx *Context, subscription *GraphQLSubscription, next <-chan []byte, errs <-chan error, writer FlushWriter) error {
 	resolverDone := r.ctx.Done()
+	done := make(chan struct{})
+	defer close(done)
 
-	next := make(chan []byte)
-	err = subscription.Trigger.Source.Start(c, subscriptionInput, next)
-	if err != nil {
-		if errors.Is(err, ErrUnableToResolve) {
-			_, err = writer.Write([]byte(`{"errors":[{"message":"unable to resolve"}]}`))
-			if err != nil {
-				return err
-			}
-			writer.Flush()
-			return nil
+	// the frame currently written doesn't occupy a slot in the queue
+	queue := make(chan chan subscriptionFrame, r.MaxConcurrentSubscriptionFrames-1)
+
+	enqueue := func(data []byte) bool {
+		frame := make(chan subscriptionFrame, 1)
+		select {
+		case queue <- frame:
+		case <-done:
+			return false
 		}
-		return err
+		cloned := ctx.Clone()
+		go func(ctx Context) {
+			out := &frameWriter{}
+			err := r.resolveSubscriptionFrame(&ctx, subscription, data, out)
+			ctx.Free()
+			frame <- subscriptionFrame{writer: out, err: err}
+		}(cloned)
+		return true
 	}
 
+	go func() {
+		defer close(queue)
+		for {
+			select {
+			case <-resolverDone:
+				return
+			case <-done:
+				return
+			case sourceErr := <-errs:
+				// frames which were buffered before the error are queued first
+			drain:
+				for {
+					select {
+					case data, ok := <-next:
+						if !ok || !enqueue(data) {
+							break drain
+						}
+					default:
+						break drain
+					}
+				}
+				// the error is queued like a resolved frame, so that it's written after all previous frames
+				out := &frameWriter{}
+				_, _ = out.Write(subscriptionErrorFrame(sourceErr))
+				out.Flush()
+				frame := make(chan subscriptionFrame, 1)
+				frame <- subscriptionFrame{writer: out}
+				select {
+				case queue <- frame:
+				case <-done:
+				}
+				return
+			case data, ok := <-next:
+				if !ok || !enqueue(data) {
+					return
+				}
+			}
+		}
+	}()
+
+	heartbeat := r.newHeartbeatTimer(subscription)
+	defer heartbeat.stop()
+
+	// frames which were received before the resolver is done are still written
 	for {
 		select {
-		case <-resolverDone:
-			return nil
-		default:
-			data, ok := <-next
+		case <-heartbeat.C():
+			if err := r.writeHeartbeat(writer); err != nil {
+				return err
+			}
+			heartbeat.reset()
+		case frame, ok := <-queue:
 			if !ok {
 				return nil
 			}
-			err = r.ResolveGraphQLResponse(ctx, subscription.Response, data, writer)
-			if err != nil {
+			resolved := <-frame
+			if resolved.err != nil {
+				return resolved.err
+			}
+			if err := resolved.writer.writeTo(writer); err != nil {
 				return err
 			}
-			writer.Flush()
+			heartbeat.reset()
 		}
 	}
 }
@@ -531,7 +1550,13 @@ func (r *Resolver) ResolveGraphQLStreamingResponse(ctx *Context, response *Graph
 	}
 	writer.Flush()
 
-	nextFlush := time.Now().Add(time.Millisecond * time.Duration(response.FlushInterval))
+	// with a flush interval of 0 each patch is flushed on its own, the clock is not consulted
+	// comparing against the clock would batch patches depending on the resolution of the clock
+	flushEachPatch := response.FlushInterval <= 0
+	var nextFlush time.Time
+	if !flushEachPatch {
+		nextFlush = r.clock.Now().Add(time.Millisecond * time.Duration(response.FlushInterval))
+	}
 
 	buf := pool.BytesBuffer.Get()
 	defer pool.BytesBuffer.Put(buf)
@@ -565,8 +1590,7 @@ Loop:
 				return err
 			}
 
-			now := time.Now()
-			if now.After(nextFlush) {
+			if flushEachPatch || r.clock.Now().After(nextFlush) {
 				buf.Write(literal.RBRACK)
 				_, err = writer.Write(buf.Bytes())
 				if err != nil {
@@ -575,7 +1599,9 @@ Loop:
 				writer.Flush()
 				buf.Reset()
 				buf.Write(literal.LBRACK)
-				nextFlush = time.Now().Add(time.Millisecond * time.Duration(response.FlushInterval))
+				if !flushEachPatch {
+					nextFlush = r.clock.Now().Add(time.Millisecond * time.Duration(response.FlushInterval))
+				}
 			}
 		}
 	}
@@ -593,12 +1619,18 @@ Loop:
 }
 
 func (r *Resolver) ResolveGraphQLResponsePatch(ctx *Context, patch *GraphQLResponsePatch, data, path, extraPath []byte, writer io.Writer) (err error) {
+	ctx.json = r.json
 
 	buf := r.getBufPair()
 	defer r.freeBufPair(buf)
 
 	ctx.pathPrefix = append(path, extraPath...)
 
+	var cursor []byte
+	if len(patch.CursorPath) != 0 {
+		cursor = r.patchCursor(patch.CursorPath, data)
+	}
+
 	if patch.Fetch != nil {
 		set := r.getResultSet()
 		defer r.freeResultSet(set)
@@ -651,12 +1683,40 @@ func (r *Resolver) ResolveGraphQLResponsePatch(ctx *Context, patch *GraphQLRespo
 		err = writeSafe(err, writer, quote)
 		err = writeSafe(err, writer, colon)
 		_, err = writer.Write(buf.Data.Bytes())
+		if cursor != nil {
+			err = writeSafe(err, writer, comma)
+			err = writeSafe(err, writer, quote)
+			err = writeSafe(err, writer, literalCursor)
+			err = writeSafe(err, writer, quote)
+			err = writeSafe(err, writer, colon)
+			err = writeSafe(err, writer, cursor)
+		}
 		err = writeSafe(err, writer, rBrace)
 	}
 
 	return
 }
 
+// patchCursor returns the cursor of a streamed item as JSON value, missing cursors are null
+// the cursor is taken from the item itself, before the patch fetches additional data
+func (r *Resolver) patchCursor(cursorPath []string, data []byte) []byte {
+	value, dataType, _, err := r.json.Get(data, cursorPath...)
+	if err != nil {
+		return null
+	}
+	switch dataType {
+	case jsonparser.String:
+		cursor := make([]byte, 0, len(value)+2)
+		cursor = append(cursor, quote...)
+		cursor = append(cursor, value...)
+		return append(cursor, quote...)
+	case jsonparser.Number:
+		return value
+	default:
+		return null
+	}
+}
+
 func (r *Resolver) resolveEmptyArray(b *fastbuffer.FastBuffer) {
 	b.WriteBytes(lBrack)
 	b.WriteBytes(rBrack)
@@ -673,13 +1733,10 @@ func (r *Resolver) resolveArray(ctx *Context, array *Array, data []byte, arrayBu
 		return
 	}
 
-	arrayItems := r.byteSlicesPool.Get().(*[][]byte)
-	defer func() {
-		*arrayItems = (*arrayItems)[:0]
-		r.byteSlicesPool.Put(arrayItems)
-	}()
+	arrayItems := r.getByteSlices()
+	defer r.freeByteSlices(arrayItems)
 
-	_, err = jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+	_, err = r.json.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 		*arrayItems = append(*arrayItems, value)
 	}, array.Path...)
 
@@ -692,6 +1749,11 @@ func (r *Resolver) resolveArray(ctx *Context, array *Array, data []byte, arrayBu
 		return nil
 	}
 
+	if array.MaxItems > 0 && len(*arrayItems) > array.MaxItems {
+		ctx.addTruncatedArray(len(*arrayItems), array.MaxItems)
+		*arrayItems = (*arrayItems)[:array.MaxItems]
+	}
+
 	if array.ResolveAsynchronous && !array.Stream.Enabled {
 		return r.resolveArrayAsynchronous(ctx, array, arrayItems, arrayBuf)
 	}
@@ -709,9 +1771,12 @@ func (r *Resolver) resolveArraySynchronous(ctx *Context, array *Array, arrayItem
 		dataWritten     int
 	)
 	for i := range *arrayItems {
+		if err = ctx.checkCanceled(); err != nil {
+			return
+		}
 
 		if array.Stream.Enabled {
-			if i > array.Stream.InitialBatchSize-1 {
+			if i >= array.Stream.InitialCount {
 				ctx.addIntegerPathElement(i)
 				r.preparePatch(ctx, array.Stream.PatchIndex, nil, (*arrayItems)[i])
 				ctx.removeLastPathElement()
@@ -721,17 +1786,21 @@ func (r *Resolver) resolveArraySynchronous(ctx *Context, array *Array, arrayItem
 
 		ctx.addIntegerPathElement(i)
 		err = r.resolveNode(ctx, array.Item, (*arrayItems)[i], itemBuf)
+		err = r.collectNonNullableError(ctx, array.Item, itemBuf, err)
 		ctx.removeLastPathElement()
 		if err != nil {
+			if errors.Is(err, errTypeNameSkipped) {
+				err = nil
+				continue
+			}
+			// the errors of the item bubble up with the null to the nearest nullable parent
+			r.MergeBufPairErrors(itemBuf, arrayBuf)
 			if errors.Is(err, errNonNullableFieldValueIsNull) && array.Nullable {
+				// the array is the nearest nullable parent, it drops its own data
 				arrayBuf.Data.Reset()
 				r.resolveNull(arrayBuf.Data)
 				return nil
 			}
-			if errors.Is(err, errTypeNameSkipped) {
-				err = nil
-				continue
-			}
 			return
 		}
 		dataWritten += itemBuf.Data.Len()
@@ -739,6 +1808,9 @@ func (r *Resolver) resolveArraySynchronous(ctx *Context, array *Array, arrayItem
 		if !hasPreviousItem && dataWritten != 0 {
 			hasPreviousItem = true
 		}
+		if err = r.checkResponseSize(arrayBuf); err != nil {
+			return
+		}
 	}
 
 	arrayBuf.Data.WriteBytes(rBrack)
@@ -758,24 +1830,22 @@ func (r *Resolver) resolveArrayAsynchronous(ctx *Context, array *Array, arrayIte
 	errCh := r.getErrChan()
 	defer r.freeErrChan(errCh)
 
-	wg.Add(len(*arrayItems))
+	for range *arrayItems {
+		*bufSlice = append(*bufSlice, r.getBufPair())
+	}
 
-	for i := range *arrayItems {
-		itemBuf := r.getBufPair()
-		*bufSlice = append(*bufSlice, itemBuf)
-		itemData := (*arrayItems)[i]
-		cloned := ctx.Clone()
-		go func(ctx Context, i int) {
-			ctx.addPathElement([]byte(strconv.Itoa(i)))
-			if e := r.resolveNode(&ctx, array.Item, itemData, itemBuf); e != nil && !errors.Is(e, errTypeNameSkipped) {
-				select {
-				case errCh <- e:
-				default:
-				}
-			}
-			ctx.Free()
-			wg.Done()
-		}(cloned, i)
+	if r.MaxConcurrentArrayResolvers > 0 && r.MaxConcurrentArrayResolvers < len(*arrayItems) {
+		r.resolveArrayItemsWithWorkerPool(ctx, array, arrayItems, *bufSlice, wg, errCh)
+	} else {
+		wg.Add(len(*arrayItems))
+		for i := range *arrayItems {
+			cloned := ctx.Clone()
+			go func(ctx Context, i int) {
+				r.resolveArrayItem(&ctx, array, i, (*arrayItems)[i], (*bufSlice)[i], errCh)
+				ctx.Free()
+				wg.Done()
+			}(cloned, i)
+		}
 	}
 
 	wg.Wait()
@@ -786,6 +1856,10 @@ func (r *Resolver) resolveArrayAsynchronous(ctx *Context, array *Array, arrayIte
 	}
 
 	if err != nil {
+		// the errors of the items bubble up with the null to the nearest nullable parent
+		for i := range *bufSlice {
+			r.MergeBufPairErrors((*bufSlice)[i], arrayBuf)
+		}
 		if errors.Is(err, errNonNullableFieldValueIsNull) && array.Nullable {
 			arrayBuf.Data.Reset()
 			r.resolveNull(arrayBuf.Data)
@@ -804,15 +1878,58 @@ func (r *Resolver) resolveArrayAsynchronous(ctx *Context, array *Array, arrayIte
 		if !hasPreviousItem && dataWritten != 0 {
 			hasPreviousItem = true
 		}
+		if err = r.checkResponseSize(arrayBuf); err != nil {
+			return
+		}
 	}
 
 	arrayBuf.Data.WriteBytes(rBrack)
 	return
 }
 
-func (r *Resolver) resolveInteger(integer *Integer, data []byte, integerBuf *BufPair) error {
-	value, dataType, _, err := jsonparser.Get(data, integer.Path...)
-	if err != nil || dataType != jsonparser.Number {
+// resolveArrayItemsWithWorkerPool resolves the array items using MaxConcurrentArrayResolvers workers
+// each worker clones the Context once and re-uses it for all items it resolves
+// item results are written into the buffer with the same index to keep the order of the array
+func (r *Resolver) resolveArrayItemsWithWorkerPool(ctx *Context, array *Array, arrayItems *[][]byte, bufSlice []*BufPair, wg *sync.WaitGroup, errCh chan error) {
+	indices := make(chan int, len(*arrayItems))
+	for i := range *arrayItems {
+		indices <- i
+	}
+	close(indices)
+
+	wg.Add(r.MaxConcurrentArrayResolvers)
+	for j := 0; j < r.MaxConcurrentArrayResolvers; j++ {
+		cloned := ctx.Clone()
+		go func(ctx Context) {
+			for i := range indices {
+				r.resolveArrayItem(&ctx, array, i, (*arrayItems)[i], bufSlice[i], errCh)
+			}
+			ctx.Free()
+			wg.Done()
+		}(cloned)
+	}
+}
+
+func (r *Resolver) resolveArrayItem(ctx *Context, array *Array, i int, itemData []byte, itemBuf *BufPair, errCh chan error) {
+	ctx.addIntegerPathElement(i)
+	e := r.resolveNode(ctx, array.Item, itemData, itemBuf)
+	e = r.collectNonNullableError(ctx, array.Item, itemBuf, e)
+	if e != nil && !errors.Is(e, errTypeNameSkipped) {
+		select {
+		case errCh <- e:
+		default:
+		}
+	}
+	ctx.removeLastPathElement()
+}
+
+func (r *Resolver) resolveInteger(ctx *Context, integer *Integer, data []byte, integerBuf *BufPair) error {
+	value, dataType, _, err := r.json.Get(data, integer.Path...)
+	if err == nil && dataType == jsonparser.Number && integer.Conversion != nil {
+		value, err = integer.Conversion.convertInteger(value)
+	}
+	strictRange := integer.StrictRange || ctx.FeatureFlags.Enabled(FeatureFlagStrictIntRange)
+	if err != nil || dataType != jsonparser.Number || (strictRange && !isInt32(value)) {
 		if !integer.Nullable {
 			return errNonNullableFieldValueIsNull
 		}
@@ -823,8 +1940,25 @@ func (r *Resolver) resolveInteger(integer *Integer, data []byte, integerBuf *Buf
 	return nil
 }
 
+// isInt32 reports whether the JSON number is within the range of a GraphQL Int (signed 32-bit)
+// Numbers in exponent or fractional notation are accepted as long as their value is an integer in range, e.g. 1e3 or 1.0
+func isInt32(value []byte) bool {
+	if i, err := strconv.ParseInt(unsafebytes.BytesToString(value), 10, 64); err == nil {
+		return i >= math.MinInt32 && i <= math.MaxInt32
+	}
+	f, err := strconv.ParseFloat(unsafebytes.BytesToString(value), 64)
+	return err == nil && f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32
+}
+
 func (r *Resolver) resolveFloat(floatValue *Float, data []byte, floatBuf *BufPair) error {
-	value, dataType, _, err := jsonparser.Get(data, floatValue.Path...)
+	value, dataType, _, err := r.json.Get(data, floatValue.Path...)
+	if err == nil && dataType == jsonparser.Number {
+		if floatValue.Conversion != nil {
+			value, err = floatValue.Conversion.convertFloat(value, floatValue.Precision)
+		} else if floatValue.Canonicalize {
+			value, err = canonicalFloat(value, floatValue.Precision)
+		}
+	}
 	if err != nil || dataType != jsonparser.Number {
 		if !floatValue.Nullable {
 			return errNonNullableFieldValueIsNull
@@ -836,8 +1970,30 @@ func (r *Resolver) resolveFloat(floatValue *Float, data []byte, floatBuf *BufPai
 	return nil
 }
 
-func (r *Resolver) resolveBoolean(boolean *Boolean, data []byte, booleanBuf *BufPair) error {
-	value, valueType, _, err := jsonparser.Get(data, boolean.Path...)
+// canonicalFloat formats the JSON number as a decimal number without exponent and trailing zeros
+func canonicalFloat(value []byte, precision int) ([]byte, error) {
+	// values exceeding the range of float64 return ErrRange, so only finite values get formatted
+	f, err := strconv.ParseFloat(unsafebytes.BytesToString(value), 64)
+	if err != nil {
+		return nil, err
+	}
+	if precision <= 0 {
+		return strconv.AppendFloat(nil, f, 'f', -1, 64), nil
+	}
+	out := strconv.AppendFloat(nil, f, 'f', precision, 64)
+	out = bytes.TrimRight(out, "0")
+	out = bytes.TrimSuffix(out, literal.DOT)
+	if bytes.Equal(out, negativeZero) {
+		return out[1:], nil
+	}
+	return out, nil
+}
+
+func (r *Resolver) resolveBoolean(ctx *Context, boolean *Boolean, data []byte, booleanBuf *BufPair) error {
+	value, valueType, _, err := r.json.Get(data, boolean.Path...)
+	if err == nil && valueType == jsonparser.String && boolean.hasValueMapping() {
+		return r.resolveMappedBoolean(ctx, boolean, value, booleanBuf)
+	}
 	if err != nil || valueType != jsonparser.Boolean {
 		if !boolean.Nullable {
 			return errNonNullableFieldValueIsNull
@@ -849,6 +2005,29 @@ func (r *Resolver) resolveBoolean(boolean *Boolean, data []byte, booleanBuf *Buf
 	return nil
 }
 
+// resolveMappedBoolean maps a string value to true or false using the TrueValues and FalseValues of the Boolean
+// Unmapped values are null, on non-nullable fields a coercion error is added and the null bubbles up to the nearest nullable parent
+func (r *Resolver) resolveMappedBoolean(ctx *Context, boolean *Boolean, value []byte, booleanBuf *BufPair) error {
+	for i := range boolean.TrueValues {
+		if string(value) == boolean.TrueValues[i] {
+			booleanBuf.Data.WriteBytes(literal.TRUE)
+			return nil
+		}
+	}
+	for i := range boolean.FalseValues {
+		if string(value) == boolean.FalseValues[i] {
+			booleanBuf.Data.WriteBytes(literal.FALSE)
+			return nil
+		}
+	}
+	if !boolean.Nullable {
+		r.addFieldError(ctx, booleanBuf, escapedErrorMessage(fmt.Errorf("%w: %q", errBooleanCoercion, value)))
+		return errNonNullableFieldValueIsNullReported
+	}
+	r.resolveNull(booleanBuf.Data)
+	return nil
+}
+
 func (r *Resolver) resolveString(str *String, data []byte, stringBuf *BufPair) error {
 	var (
 		value     []byte
@@ -856,7 +2035,7 @@ func (r *Resolver) resolveString(str *String, data []byte, stringBuf *BufPair) e
 		err       error
 	)
 	if len(data) != 0 && str.Path == nil {
-		_, valueType, _, _ = jsonparser.Get(data)
+		_, valueType, _, _ = r.json.Get(data)
 		if valueType == jsonparser.String || unicode.IsLetter(rune(data[0])) {
 			value = data
 		} else if !str.Nullable {
@@ -867,7 +2046,7 @@ func (r *Resolver) resolveString(str *String, data []byte, stringBuf *BufPair) e
 		}
 	}
 	if value == nil {
-		value, valueType, _, err = jsonparser.Get(data, str.Path...)
+		value, valueType, _, err = r.json.Get(data, str.Path...)
 		if err != nil || valueType != jsonparser.String {
 			if !str.Nullable {
 				return errNonNullableFieldValueIsNull
@@ -897,7 +2076,26 @@ func (r *Resolver) resolveNull(b *fastbuffer.FastBuffer) {
 	b.WriteBytes(null)
 }
 
+// addMissingTypeNameError adds ErrMissingTypeName with the path of the object lacking __typename
+func (r *Resolver) addMissingTypeNameError(ctx *Context, objectBuf *BufPair) {
+	path := pool.BytesBuffer.Get()
+	defer pool.BytesBuffer.Put(path)
+
+	var pathBytes []byte
+	if len(ctx.pathElements) > 0 {
+		ctx.writeErrorPath(path)
+		pathBytes = path.Bytes()
+	}
+
+	objectBuf.WriteErr([]byte(ErrMissingTypeName.Error()), nil, pathBytes, nil)
+}
+
 func (r *Resolver) addResolveError(ctx *Context, objectBuf *BufPair) {
+	r.addFieldError(ctx, objectBuf, unableToResolveMsg)
+}
+
+// addFieldError adds an error with the location and the path of the current field, message must be escaped for a JSON string
+func (r *Resolver) addFieldError(ctx *Context, objectBuf *BufPair, message []byte) {
 	locations, path := pool.BytesBuffer.Get(), pool.BytesBuffer.Get()
 	defer pool.BytesBuffer.Put(locations)
 	defer pool.BytesBuffer.Put(path)
@@ -921,21 +2119,21 @@ func (r *Resolver) addResolveError(ctx *Context, objectBuf *BufPair) {
 	locations.Write(rBrack)
 
 	if len(ctx.pathElements) > 0 {
-		path.Write(lBrack)
-		path.Write(quote)
-		path.Write(bytes.Join(ctx.pathElements, quotedComma))
-		path.Write(quote)
-		path.Write(rBrack)
-
+		ctx.writeErrorPath(path)
 		pathBytes = path.Bytes()
 	}
 
-	objectBuf.WriteErr(unableToResolveMsg, locations.Bytes(), pathBytes, nil)
+	objectBuf.WriteErr(message, locations.Bytes(), pathBytes, nil)
 }
 
 func (r *Resolver) resolveObject(ctx *Context, object *Object, data []byte, objectBuf *BufPair) (err error) {
 	if len(object.Path) != 0 {
-		data, _, _, _ = jsonparser.Get(data, object.Path...)
+		var dataType jsonparser.ValueType
+		data, dataType, _, _ = r.json.Get(data, object.Path...)
+
+		if dataType == jsonparser.NotExist && object.OptionalChaining {
+			data = emptyObject
+		}
 
 		if len(data) == 0 {
 			if object.Nullable {
@@ -948,11 +2146,18 @@ func (r *Resolver) resolveObject(ctx *Context, object *Object, data []byte, obje
 		}
 	}
 
+	var cached [][]byte
+	fetch := object.Fetch
+	if ctx.fieldCache != nil {
+		cached = r.cachedFields(ctx, object.Fields)
+		fetch = uncachedFetch(fetch, object.Fields, cached)
+	}
+
 	var set *resultSet
-	if object.Fetch != nil {
+	if fetch != nil {
 		set = r.getResultSet()
 		defer r.freeResultSet(set)
-		err = r.resolveFetch(ctx, object.Fetch, data, set)
+		err = r.resolveFetch(ctx, fetch, data, set)
 		if err != nil {
 			return
 		}
@@ -965,40 +2170,56 @@ func (r *Resolver) resolveObject(ctx *Context, object *Object, data []byte, obje
 	defer r.freeBufPair(fieldBuf)
 
 	typeNameSkip := false
+	conditionSkip := false
 	first := true
 	for i := range object.Fields {
+		if err = ctx.checkCanceled(); err != nil {
+			return
+		}
 
 		var fieldData []byte
 		if set != nil && object.Fields[i].HasBuffer {
-			buffer, ok := set.buffers[object.Fields[i].BufferID]
-			if ok {
-				fieldData = buffer.Data.Bytes()
-			}
+			fieldData = r.fieldBufferData(set, object.Fields[i])
 		} else {
 			fieldData = data
 		}
 
 		if object.Fields[i].OnTypeName != nil {
-			typeName, _, _, _ := jsonparser.Get(fieldData, "__typename")
+			typeName, typeNameType, _, _ := r.json.Get(fieldData, "__typename")
+			if typeNameType == jsonparser.NotExist && r.StrictTypeNameConditions {
+				objectBuf.Data.Reset()
+				r.addMissingTypeNameError(ctx, objectBuf)
+				if object.Nullable {
+					r.resolveNull(objectBuf.Data)
+					return nil
+				}
+				return errNonNullableFieldValueIsNull
+			}
 			if !bytes.Equal(typeName, object.Fields[i].OnTypeName) {
 				typeNameSkip = true
 				continue
 			}
 		}
 
-		if first {
-			objectBuf.Data.WriteBytes(lBrace)
-			first = false
-		} else {
-			objectBuf.Data.WriteBytes(comma)
+		if object.Fields[i].Condition != nil && !object.Fields[i].Condition.evaluate(r.json, fieldData) {
+			conditionSkip = true
+			continue
+		}
+
+		r.writeFieldName(ctx, objectBuf, object.Fields[i], first)
+		first = false
+		if cached != nil && cached[i] != nil {
+			r.writeData(ctx, objectBuf, cached[i])
+			continue
+		}
+		if set != nil && object.Fields[i].HasBuffer && object.Fields[i].TimeoutDefault != nil && set.bufferTimedOut(object.Fields[i].BufferID) {
+			r.writeData(ctx, objectBuf, object.Fields[i].TimeoutDefault)
+			continue
 		}
-		objectBuf.Data.WriteBytes(quote)
-		objectBuf.Data.WriteBytes(object.Fields[i].Name)
-		objectBuf.Data.WriteBytes(quote)
-		objectBuf.Data.WriteBytes(colon)
 		ctx.addPathElement(object.Fields[i].Name)
 		ctx.setPosition(object.Fields[i].Position)
 		err = r.resolveNode(ctx, object.Fields[i].Value, fieldData, fieldBuf)
+		err = r.collectNonNullableError(ctx, object.Fields[i].Value, fieldBuf, err)
 		ctx.removeLastPathElement()
 		if err != nil {
 			if errors.Is(err, errTypeNameSkipped) {
@@ -1016,19 +2237,26 @@ func (r *Resolver) resolveObject(ctx *Context, object *Object, data []byte, obje
 				}
 
 				// if fied is of object type than we should not add resolve error here
-				if _, ok := object.Fields[i].Value.(*Object); !ok {
+				if _, ok := object.Fields[i].Value.(*Object); !ok && err != errNonNullableFieldValueIsNullReported {
 					r.addResolveError(ctx, objectBuf)
 				}
 			}
 
 			return
 		}
-		r.MergeBufPairs(fieldBuf, objectBuf, false)
+		r.mergeField(ctx, fieldBuf, objectBuf)
+		if err = r.checkResponseSize(objectBuf); err != nil {
+			return
+		}
 	}
 	if first {
 		if typeNameSkip {
 			return errTypeNameSkipped
 		}
+		if conditionSkip {
+			r.resolveEmptyObject(objectBuf.Data)
+			return
+		}
 		if !object.Nullable {
 			r.addResolveError(ctx, objectBuf)
 			return errNonNullableFieldValueIsNull
@@ -1036,20 +2264,138 @@ func (r *Resolver) resolveObject(ctx *Context, object *Object, data []byte, obje
 		r.resolveNull(objectBuf.Data)
 		return
 	}
-	objectBuf.Data.WriteBytes(rBrace)
+	r.writeData(ctx, objectBuf, rBrace)
 	return
 }
 
+// cachedFields looks up the fields in the FieldCache, the value of a field missing in the cache is nil
+func (r *Resolver) cachedFields(ctx *Context, fields []*Field) [][]byte {
+	cached := make([][]byte, len(fields))
+	for i := range fields {
+		if fields[i].OnTypeName != nil || fields[i].Condition != nil {
+			continue
+		}
+		ctx.addPathElement(fields[i].Name)
+		if value, ok := ctx.fieldCache.Get(r.hookCtx(ctx)); ok {
+			cached[i] = value
+		}
+		ctx.removeLastPathElement()
+	}
+	return cached
+}
+
+// uncachedFetch returns the fetches loading the buffers of fields missing in the cache, it's nil if no fetch is required
+func uncachedFetch(fetch Fetch, fields []*Field, cached [][]byte) Fetch {
+	switch f := fetch.(type) {
+	case *SingleFetch:
+		if fetchRequired(f, fields, cached) {
+			return f
+		}
+		return nil
+	case *ParallelFetch:
+		required := make([]*SingleFetch, 0, len(f.Fetches))
+		for i := range f.Fetches {
+			if fetchRequired(f.Fetches[i], fields, cached) {
+				required = append(required, f.Fetches[i])
+			}
+		}
+		switch len(required) {
+		case 0:
+			return nil
+		case len(f.Fetches):
+			return f
+		default:
+			return &ParallelFetch{Fetches: required}
+		}
+	default:
+		return fetch
+	}
+}
+
+func fetchRequired(fetch *SingleFetch, fields []*Field, cached [][]byte) bool {
+	for i := range fields {
+		if cached[i] != nil || !fields[i].HasBuffer {
+			continue
+		}
+		if fetch.loadsBuffer(fields[i].BufferID) {
+			return true
+		}
+		for _, bufferID := range fields[i].FallbackBufferIDs {
+			if fetch.loadsBuffer(bufferID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectNonNullableError resolves the value to null and adds the error with the current path instead of returning it if SetCollectAllErrors is enabled
+// Objects add the errors of their fields themselves, so no additional error is added for them
+func (r *Resolver) collectNonNullableError(ctx *Context, node Node, buf *BufPair, err error) error {
+	if !ctx.collectAllErrors || !errors.Is(err, errNonNullableFieldValueIsNull) {
+		return err
+	}
+	buf.Data.Reset()
+	if _, ok := node.(*Object); !ok && err != errNonNullableFieldValueIsNullReported {
+		r.addResolveError(ctx, buf)
+	}
+	r.resolveNull(buf.Data)
+	return nil
+}
+
+// fieldBufferData returns the data of the first buffer in which the value of the field is not null
+// BufferID is tried first, followed by FallbackBufferIDs in order
+// If the value is null in all buffers, the data of BufferID is returned
+func (r *Resolver) fieldBufferData(set *resultSet, field *Field) []byte {
+	data := set.bufferData(field.BufferID)
+	if len(field.FallbackBufferIDs) == 0 || r.hasNonNullValue(data, field.Value) {
+		return data
+	}
+	for _, bufferID := range field.FallbackBufferIDs {
+		fallbackData := set.bufferData(bufferID)
+		if r.hasNonNullValue(fallbackData, field.Value) {
+			return fallbackData
+		}
+	}
+	return data
+}
+
+func (r *Resolver) hasNonNullValue(data []byte, node Node) bool {
+	_, valueType, _, err := r.json.Get(data, nodePath(node)...)
+	return err == nil && valueType != jsonparser.Null
+}
+
+func nodePath(node Node) []string {
+	switch n := node.(type) {
+	case *Object:
+		return n.Path
+	case *Array:
+		return n.Path
+	case *String:
+		return n.Path
+	case *Boolean:
+		return n.Path
+	case *Integer:
+		return n.Path
+	case *Float:
+		return n.Path
+	default:
+		return nil
+	}
+}
+
 func (r *Resolver) freeResultSet(set *resultSet) {
 	for i := range set.buffers {
 		set.buffers[i].Reset()
-		r.bufPairPool.Put(set.buffers[i])
+		r.putBufPair(set.buffers[i])
 		delete(set.buffers, i)
 	}
+	r.poolStats.put(&r.poolStats.resultSets)
 	r.resultSetPool.Put(set)
 }
 
 func (r *Resolver) resolveFetch(ctx *Context, fetch Fetch, data []byte, set *resultSet) (err error) {
+	hookCtx := r.fetchHooksCtx(ctx)
 	switch f := fetch.(type) {
 	case *SingleFetch:
 		preparedInput := r.getBufPair()
@@ -1058,7 +2404,10 @@ func (r *Resolver) resolveFetch(ctx *Context, fetch Fetch, data []byte, set *res
 		if err != nil {
 			return err
 		}
-		err = r.resolveSingleFetch(ctx, f, preparedInput.Data, set.buffers[f.BufferId])
+		err = r.resolveSingleFetch(ctx, hookCtx, f, preparedInput.Data, set.buffers[f.BufferId])
+		if err == nil {
+			r.extractBatchResponse(f, set)
+		}
 	case *ParallelFetch:
 		preparedInputs := r.getBufPairSlice()
 		defer r.freeBufPairSlice(preparedInputs)
@@ -1078,7 +2427,9 @@ func (r *Resolver) resolveFetch(ctx *Context, fetch Fetch, data []byte, set *res
 			buf := set.buffers[f.Fetches[i].BufferId]
 			wg.Add(1)
 			go func(s *SingleFetch, buf *BufPair) {
-				_ = r.resolveSingleFetch(ctx, s, preparedInput.Data, buf)
+				if err := r.resolveSingleFetch(ctx, hookCtx, s, preparedInput.Data, buf); err == nil {
+					r.extractBatchResponse(s, set)
+				}
 				wg.Done()
 			}(singleFetch, buf)
 		}
@@ -1089,30 +2440,48 @@ func (r *Resolver) resolveFetch(ctx *Context, fetch Fetch, data []byte, set *res
 
 func (r *Resolver) prepareSingleFetch(ctx *Context, fetch *SingleFetch, data []byte, set *resultSet, preparedInput *fastbuffer.FastBuffer) (err error) {
 	err = fetch.InputTemplate.Render(ctx, data, preparedInput)
+	if err == nil && ctx.fetchInputRewriteHook != nil {
+		err = r.rewriteFetchInput(ctx, fetch, preparedInput)
+	}
+	if err == nil {
+		err = ctx.addPreparedInputBytes(preparedInput.Len())
+	}
 	buf := r.getBufPair()
 	set.buffers[fetch.BufferId] = buf
+	for _, bufferID := range fetch.BatchBufferIds {
+		set.buffers[bufferID] = r.getBufPair()
+	}
 	return
 }
 
-func (r *Resolver) resolveSingleFetch(ctx *Context, fetch *SingleFetch, preparedInput *fastbuffer.FastBuffer, buf *BufPair) (err error) {
+func (r *Resolver) rewriteFetchInput(ctx *Context, fetch *SingleFetch, preparedInput *fastbuffer.FastBuffer) error {
+	rewritten, err := ctx.fetchInputRewriteHook.RewriteFetchInput(r.hookCtx(ctx), fetch.DataSourceIdentifier, preparedInput.Bytes())
+	if err != nil {
+		return err
+	}
+	// the rewritten input might share the memory of the prepared input, append copies overlapping memory correctly
+	preparedInput.Reset()
+	preparedInput.WriteBytes(rewritten)
+	return nil
+}
+
+func (r *Resolver) resolveSingleFetch(ctx *Context, hookCtx HookContext, fetch *SingleFetch, preparedInput *fastbuffer.FastBuffer, buf *BufPair) (err error) {
 	dataBuf := pool.BytesBuffer.Get()
 	defer pool.BytesBuffer.Put(dataBuf)
 
 	if ctx.beforeFetchHook != nil {
-		ctx.beforeFetchHook.OnBeforeFetch(r.hookCtx(ctx), preparedInput.Bytes())
-	}
-
-	if !r.EnableSingleFlightLoader || fetch.DisallowSingleFlight {
-		err = fetch.DataSource.Load(ctx.Context, preparedInput.Bytes(), dataBuf)
-		r.extractResponse(dataBuf.Bytes(), buf, fetch.ProcessResponseConfig)
-		if ctx.afterFetchHook != nil {
-			if buf.HasData() {
-				ctx.afterFetchHook.OnData(r.hookCtx(ctx), buf.Data.Bytes(), false)
-			}
-			if buf.HasErrors() {
-				ctx.afterFetchHook.OnError(r.hookCtx(ctx), buf.Errors.Bytes(), false)
+		ctx.beforeFetchHook.OnBeforeFetch(hookCtx, preparedInput.Bytes())
+		if responseHook, ok := ctx.beforeFetchHook.(BeforeFetchResponseHook); ok {
+			if response, handled := responseHook.OnBeforeFetchResponse(hookCtx, preparedInput.Bytes()); handled {
+				return r.extractFetchResponse(ctx, fetch, response, buf)
 			}
 		}
+	}
+
+	if !r.EnableSingleFlightLoader || fetch.DisallowSingleFlight || r.singleFlightDisabled(fetch) {
+		var fallback bool
+		fallback, err = r.loadAndExtractFetch(ctx, hookCtx, fetch, preparedInput.Bytes(), dataBuf, buf)
+		r.callAfterFetchHook(ctx, r.fetchHookCtx(hookCtx, fallback), buf, false)
 		return
 	}
 
@@ -1128,19 +2497,9 @@ func (r *Resolver) resolveSingleFetch(ctx *Context, fetch *SingleFetch, prepared
 		inflight.waitFree.Add(1)
 		defer inflight.waitFree.Done()
 		r.inflightFetchMu.Unlock()
-		inflight.waitLoad.Wait()
-		if inflight.bufPair.HasData() {
-			if ctx.afterFetchHook != nil {
-				ctx.afterFetchHook.OnData(r.hookCtx(ctx), inflight.bufPair.Data.Bytes(), true)
-			}
-			buf.Data.WriteBytes(inflight.bufPair.Data.Bytes())
-		}
-		if inflight.bufPair.HasErrors() {
-			if ctx.afterFetchHook != nil {
-				ctx.afterFetchHook.OnError(r.hookCtx(ctx), inflight.bufPair.Errors.Bytes(), true)
-			}
-			buf.Errors.WriteBytes(inflight.bufPair.Errors.Bytes())
-		}
+		inflight.waitLoad.Wait()
+		r.callAfterFetchHook(ctx, r.fetchHookCtx(hookCtx, inflight.fallback), &inflight.bufPair, true)
+		inflight.writeTo(buf)
 		return inflight.err
 	}
 
@@ -1150,23 +2509,10 @@ func (r *Resolver) resolveSingleFetch(ctx *Context, fetch *SingleFetch, prepared
 
 	r.inflightFetchMu.Unlock()
 
-	err = fetch.DataSource.Load(ctx.Context, preparedInput.Bytes(), dataBuf)
-	r.extractResponse(dataBuf.Bytes(), &inflight.bufPair, fetch.ProcessResponseConfig)
+	inflight.fallback, err = r.loadAndExtractFetch(ctx, hookCtx, fetch, preparedInput.Bytes(), dataBuf, &inflight.bufPair)
 	inflight.err = err
-
-	if inflight.bufPair.HasData() {
-		if ctx.afterFetchHook != nil {
-			ctx.afterFetchHook.OnData(r.hookCtx(ctx), inflight.bufPair.Data.Bytes(), false)
-		}
-		buf.Data.WriteBytes(inflight.bufPair.Data.Bytes())
-	}
-
-	if inflight.bufPair.HasErrors() {
-		if ctx.afterFetchHook != nil {
-			ctx.afterFetchHook.OnError(r.hookCtx(ctx), inflight.bufPair.Errors.Bytes(), true)
-		}
-		buf.Errors.WriteBytes(inflight.bufPair.Errors.Bytes())
-	}
+	r.callAfterFetchHook(ctx, r.fetchHookCtx(hookCtx, inflight.fallback), &inflight.bufPair, false)
+	inflight.writeTo(buf)
 
 	inflight.waitLoad.Done()
 
@@ -1182,17 +2528,124 @@ func (r *Resolver) resolveSingleFetch(ctx *Context, fetch *SingleFetch, prepared
 	return
 }
 
+// loadAndExtractFetch loads the fetch and extracts its response into buf
+// A timed out fetch and, if Context.SetFetchErrorsInResponse is enabled, a failed fetch add their error to buf instead, see writeLoadError
+func (r *Resolver) loadAndExtractFetch(ctx *Context, hookCtx HookContext, fetch *SingleFetch, input []byte, dataBuf *bytes.Buffer, buf *BufPair) (fallback bool, err error) {
+	fallback, err = r.loadFetch(ctx, hookCtx, fetch, input, dataBuf)
+	buf.timedOut = err == errFetchTimedOut
+	err = r.writeLoadError(ctx, err, dataBuf, buf)
+	if buf.timedOut {
+		return fallback, err
+	}
+	if extractErr := r.extractFetchResponse(ctx, fetch, dataBuf.Bytes(), buf); err == nil {
+		err = extractErr
+	}
+	return fallback, err
+}
+
+// callAfterFetchHook reports the data and the errors of a fetch to the AfterFetchHook
+func (r *Resolver) callAfterFetchHook(ctx *Context, hookCtx HookContext, buf *BufPair, singleFlight bool) {
+	if ctx.afterFetchHook == nil {
+		return
+	}
+	if buf.HasData() {
+		ctx.afterFetchHook.OnData(hookCtx, buf.Data.Bytes(), singleFlight)
+	}
+	if buf.HasErrors() {
+		ctx.afterFetchHook.OnError(hookCtx, buf.Errors.Bytes(), singleFlight)
+	}
+}
+
+// writeLoadError adds the error of a failed load to buf at the current path if Context.SetFetchErrorsInResponse is enabled
+// The output of the failed load is discarded and nil is returned, otherwise err is returned as is
+// Timeouts of fetches are always added, see SingleFetch.Timeout
+func (r *Resolver) writeLoadError(ctx *Context, err error, out *bytes.Buffer, buf *BufPair) error {
+	if err == nil || (!ctx.fetchErrorsInResponse && err != errFetchTimedOut) || ctx.Context.Err() != nil {
+		return err
+	}
+	out.Reset()
+	path := pool.BytesBuffer.Get()
+	defer pool.BytesBuffer.Put(path)
+	ctx.writeErrorPath(path)
+	buf.WriteErr(escapedErrorMessage(err), nil, path.Bytes(), nil)
+	return nil
+}
+
+// escapedErrorMessage returns the message of err escaped for a JSON string, WriteErr writes messages as is
+func escapedErrorMessage(err error) []byte {
+	message, _ := json.Marshal(err.Error())
+	return message[1 : len(message)-1]
+}
+
+func (r *Resolver) singleFlightDisabled(fetch *SingleFetch) bool {
+	return r.SingleFlightDisabledFor != nil && r.SingleFlightDisabledFor(fetch.DataSourceIdentifier)
+}
+
 func (r *Resolver) hookCtx(ctx *Context) HookContext {
 	return HookContext{
 		CurrentPath: ctx.path(),
 	}
 }
 
+// fetchHooksCtx returns the HookContext of the fetches of the current object, it's empty if no fetch hook is set
+// It's created before the fetches are loaded, the path of the Context can't be written by parallel fetches concurrently
+func (r *Resolver) fetchHooksCtx(ctx *Context) HookContext {
+	if ctx.beforeFetchHook == nil && ctx.afterFetchHook == nil && ctx.fetchCompleteHook == nil {
+		return HookContext{}
+	}
+	return r.hookCtx(ctx)
+}
+
+func (r *Resolver) fetchHookCtx(hookCtx HookContext, fallback bool) HookContext {
+	hookCtx.Fallback = fallback
+	return hookCtx
+}
+
+// loadFetch loads the fetch and reports its duration and response size to the FetchCompleteHook and the tracing of the response
+func (r *Resolver) loadFetch(ctx *Context, hookCtx HookContext, fetch *SingleFetch, input []byte, out *bytes.Buffer) (fallback bool, err error) {
+	if ctx.fetchCompleteHook == nil && ctx.tracing == nil {
+		return r.loadWithFallback(ctx, fetch, input, out)
+	}
+	start := r.clock.Now()
+	fallback, err = r.loadWithFallback(ctx, fetch, input, out)
+	end := r.clock.Now()
+	ctx.addFetchTrace(fetch, start, end)
+	if ctx.fetchCompleteHook != nil {
+		ctx.fetchCompleteHook.OnFetchComplete(r.fetchHookCtx(hookCtx, fallback), fetch.DataSourceIdentifier, end.Sub(start), out.Len(), err)
+	}
+	return fallback, err
+}
+
+// loadWithFallback loads the fetch from its DataSource, if that fails the FallbackDataSource is loaded instead
+// the output of the failed load is discarded, there's no fallback if the context is done or the Timeout is exceeded
+func (r *Resolver) loadWithFallback(ctx *Context, fetch *SingleFetch, input []byte, out *bytes.Buffer) (fallback bool, err error) {
+	loadCtx := ctx.Context
+	if fetch.Timeout > 0 {
+		var cancel context.CancelFunc
+		loadCtx, cancel = context.WithTimeout(ctx.Context, fetch.Timeout)
+		defer cancel()
+	}
+	err = fetch.DataSource.Load(loadCtx, input, out)
+	if err != nil && fetch.Timeout > 0 && loadCtx.Err() == context.DeadlineExceeded && ctx.Context.Err() == nil {
+		out.Reset()
+		return false, errFetchTimedOut
+	}
+	if err == nil || fetch.FallbackDataSource == nil || loadCtx.Err() != nil {
+		return false, err
+	}
+	out.Reset()
+	return true, fetch.FallbackDataSource.Load(loadCtx, input, out)
+}
+
 type Object struct {
 	Nullable bool
 	Path     []string
 	Fields   []*Field
 	Fetch    Fetch
+	// OptionalChaining resolves the fields against an empty object if Path is absent in the data, e.g. for sparse upstream data
+	// Nullable fields resolve to null without an error, non-nullable fields still produce an error
+	// An explicit null value is not affected and resolves as usual
+	OptionalChaining bool
 }
 
 func (_ *Object) NodeKind() NodeKind {
@@ -1211,15 +2664,78 @@ func (_ *EmptyArray) NodeKind() NodeKind {
 	return NodeKindEmptyArray
 }
 
+// StaticValue resolves to a constant without reading any data, e.g. for metadata fields injected by a gateway
+// Value is written as is, so it must be valid JSON, e.g. `"v1"`, `42` or `true`
+type StaticValue struct {
+	Value []byte
+}
+
+// NewStaticStringValue returns a StaticValue for the quoted and escaped string
+func NewStaticStringValue(value string) *StaticValue {
+	quoted, _ := json.Marshal(value)
+	return &StaticValue{
+		Value: quoted,
+	}
+}
+
+func (_ *StaticValue) NodeKind() NodeKind {
+	return NodeKindStaticValue
+}
+
 type Field struct {
-	Name       []byte
-	Value      Node
-	Position   Position
-	Defer      *DeferField
-	Stream     *StreamField
-	HasBuffer  bool
-	BufferID   int
-	OnTypeName []byte
+	Name      []byte
+	Value     Node
+	Position  Position
+	Defer     *DeferField
+	Stream    *StreamField
+	HasBuffer bool
+	BufferID  int
+	// FallbackBufferIDs are tried in order if the field value is null or absent in the buffer with BufferID
+	// The first buffer with a non null value wins
+	FallbackBufferIDs []int
+	OnTypeName        []byte
+	// Condition omits the field from the response if the upstream data doesn't satisfy it
+	Condition *FieldCondition
+	// TimeoutDefault is the JSON value of the field if the fetch of its buffer exceeded the Timeout
+	TimeoutDefault []byte
+	// ResponseName is the key of the field in the response if set, e.g. to rename colliding fields of stitched schemas
+	// Name is still used for the path of the field, e.g. in errors and hooks
+	ResponseName []byte
+}
+
+// responseName returns the key of the field in the response
+func (f *Field) responseName() []byte {
+	if f.ResponseName != nil {
+		return f.ResponseName
+	}
+	return f.Name
+}
+
+type ConditionOperator int
+
+const (
+	ConditionOperatorEqual ConditionOperator = iota
+	ConditionOperatorNotEqual
+)
+
+// FieldCondition compares a value of the upstream data to a static JSON value
+// A value which is absent in the upstream data is not equal to any value
+type FieldCondition struct {
+	// Path is the path of the value in the data of the enclosing object, e.g. a sibling field
+	Path     []string
+	Operator ConditionOperator
+	// Value is the JSON value to compare to, e.g. true, 1 or "EUR"
+	Value []byte
+}
+
+func (c *FieldCondition) evaluate(getter JSONValueGetter, data []byte) bool {
+	actual, actualType, _, actualErr := getter.Get(data, c.Path...)
+	expected, expectedType, _, expectedErr := getter.Get(c.Value)
+	equal := actualErr == nil && expectedErr == nil && actualType == expectedType && bytes.Equal(actual, expected)
+	if c.Operator == ConditionOperatorNotEqual {
+		return !equal
+	}
+	return equal
 }
 
 type Position struct {
@@ -1228,6 +2744,10 @@ type Position struct {
 }
 
 type StreamField struct {
+	// InitialCount is the initialCount argument of the @stream directive, or of its legacy name initialBatchSize
+	InitialCount int
+	// Deprecated: use InitialCount, postprocess.ProcessStream uses InitialBatchSize if InitialCount isn't set
+	// The planner only sets InitialCount
 	InitialBatchSize int
 }
 
@@ -1250,11 +2770,31 @@ type resultSet struct {
 	buffers map[int]*BufPair
 }
 
+func (r *resultSet) bufferData(bufferID int) []byte {
+	buffer, ok := r.buffers[bufferID]
+	if !ok {
+		return nil
+	}
+	return buffer.Data.Bytes()
+}
+
+func (r *resultSet) bufferTimedOut(bufferID int) bool {
+	buffer, ok := r.buffers[bufferID]
+	return ok && buffer.timedOut
+}
+
 type SingleFetch struct {
 	BufferId   int
 	Input      string
 	DataSource DataSource
-	Variables  Variables
+	// FallbackDataSource is loaded with the same input if the DataSource returns an error, e.g. a secondary upstream
+	// Its response is processed like the response of the DataSource, hooks are called with HookContext.Fallback set
+	FallbackDataSource DataSource
+	// Timeout bounds the Load of the DataSource, e.g. for an optional upstream which must not block the response
+	// If the Timeout is exceeded, the fetch doesn't fail but resolves without data and adds a timeout error at its path
+	// Fields of the buffer resolve to their TimeoutDefault, or are resolved from the empty buffer if it's not set
+	Timeout   time.Duration
+	Variables Variables
 	// DisallowSingleFlight is used for write operations like mutations, POST, DELETE etc. to disable singleFlight
 	// By default SingleFlight for fetches is disabled and needs to be enabled on the Resolver first
 	// If the resolver allows SingleFlight it's up the each individual DataSource Planner to decide whether an Operation
@@ -1263,11 +2803,52 @@ type SingleFetch struct {
 	InputTemplate         InputTemplate
 	DataSourceIdentifier  []byte
 	ProcessResponseConfig ProcessResponseConfig
+	// BatchBufferIds is set if the upstream responds with a JSON array of GraphQL responses, e.g. for batched requests
+	// The n-th response of the array is extracted into the buffer with the n-th id using the ProcessResponseConfig
+	// If the number of responses doesn't match the number of buffers, no response is extracted and an error is added
+	BatchBufferIds []int
+	// ResponseSchema is the expected shape of the upstream response, it's validated before the response is extracted
+	// Validation is opt-in with FeatureFlagValidateResponseSchema, a mismatch adds an error but the response is extracted as usual,
+	// so that changes of the upstream contract show up as explicit errors instead of silent nulls
+	ResponseSchema *JSONSchema
+	// TraceField is the root field resolved by the fetch, the planner sets it for the Apollo Tracing extension if tracing is enabled
+	TraceField *TraceField
 }
 
 type ProcessResponseConfig struct {
 	ExtractGraphqlResponse    bool
 	ExtractFederationEntities bool
+	// StrictResponseParsing makes the fetch fail if the upstream appends data after the JSON response
+	// By default, everything after the first JSON value is ignored
+	StrictResponseParsing bool
+	// DetectDuplicateKeys adds an error to the response if an object in the upstream data contains the same key twice
+	// The first occurrence of a duplicate key is used to resolve the data
+	DetectDuplicateKeys bool
+	// DataPath and ErrorsPath are the paths of the data and the errors within the upstream response
+	// They default to "data" and "errors" and are only used if ExtractGraphqlResponse is set,
+	// e.g. DataPath: []string{"result"} for an upstream responding with {"result":{...}}
+	DataPath   []string
+	ErrorsPath []string
+	// DefaultErrorCode is added as extensions.code to the upstream errors which don't have a code, existing codes are kept
+	DefaultErrorCode string
+	// ContentEncoding decompresses the upstream response before it's parsed, ContentEncodingGzip or ContentEncodingDeflate
+	// Responses without the header of the encoding and unknown encodings are passed through untouched
+	ContentEncoding string
+}
+
+// responsePaths returns the paths of the errors and the data in the order of rootErrorsPathIndex and rootDataPathIndex
+func (c ProcessResponseConfig) responsePaths() [][]string {
+	if len(c.DataPath) == 0 && len(c.ErrorsPath) == 0 {
+		return responsePaths
+	}
+	paths := [][]string{responsePaths[rootErrorsPathIndex], responsePaths[rootDataPathIndex]}
+	if len(c.ErrorsPath) != 0 {
+		paths[rootErrorsPathIndex] = c.ErrorsPath
+	}
+	if len(c.DataPath) != 0 {
+		paths[rootDataPathIndex] = c.DataPath
+	}
+	return paths
 }
 
 type InputTemplate struct {
@@ -1275,6 +2856,7 @@ type InputTemplate struct {
 }
 
 func (i *InputTemplate) Render(ctx *Context, data []byte, preparedInput *fastbuffer.FastBuffer) (err error) {
+	json := ctx.jsonValueGetter()
 	for j := range i.Segments {
 		switch i.Segments[j].SegmentType {
 		case StaticSegmentType:
@@ -1282,11 +2864,18 @@ func (i *InputTemplate) Render(ctx *Context, data []byte, preparedInput *fastbuf
 		case VariableSegmentType:
 			switch i.Segments[j].VariableSource {
 			case VariableSourceObject:
-				err = i.renderObjectVariable(data, i.Segments[j].VariableSourcePath, preparedInput)
+				err = i.renderObjectVariable(json, data, i.Segments[j], preparedInput)
 			case VariableSourceContext:
-				err = i.renderContextVariable(ctx, i.Segments[j].VariableSourcePath, i.Segments[j].RenderAsGraphQLValue, preparedInput)
+				if i.Segments[j].Branches != nil {
+					err = i.renderContextVariableBranch(ctx, data, i.Segments[j], preparedInput)
+				} else {
+					err = i.renderContextVariable(json, ctx, i.Segments[j], preparedInput)
+				}
 			case VariableSourceRequestHeader:
-				err = i.renderHeaderVariable(ctx, i.Segments[j].VariableSourcePath, preparedInput)
+				err = i.renderHeaderVariable(ctx, i.Segments[j], preparedInput)
+			case VariableSourceTrace:
+				// the trace id usually comes from a header of the client and is rendered into a JSON string of the input
+				writeEscapedJSONString(preparedInput, unsafebytes.BytesToString(ctx.TraceID))
 			default:
 				err = fmt.Errorf("InputTemplate.Render: cannot resolve variable of kind: %d", i.Segments[j].VariableSource)
 			}
@@ -1298,28 +2887,165 @@ func (i *InputTemplate) Render(ctx *Context, data []byte, preparedInput *fastbuf
 	return
 }
 
-func (i *InputTemplate) renderObjectVariable(data []byte, path []string, preparedInput *fastbuffer.FastBuffer) error {
-	value, _, _, err := jsonparser.Get(data, path...)
+func (i *InputTemplate) renderObjectVariable(json JSONValueGetter, data []byte, segment TemplateSegment, preparedInput *fastbuffer.FastBuffer) error {
+	value, valueType, _, err := json.Get(data, segment.VariableSourcePath...)
 	if err != nil {
 		return err
 	}
+	switch segment.Encoding {
+	case EncodingURLComponent:
+		return i.renderURLComponent(value, valueType, preparedInput)
+	case EncodingBase64:
+		return i.renderBase64(json, value, valueType, segment, preparedInput)
+	}
 	preparedInput.WriteBytes(value)
 	return nil
 }
 
-func (i *InputTemplate) renderContextVariable(ctx *Context, path []string, renderAsGraphQLValue bool, preparedInput *fastbuffer.FastBuffer) error {
-	value, valueType, _, err := jsonparser.Get(ctx.Variables, path...)
+func (i *InputTemplate) renderContextVariable(json JSONValueGetter, ctx *Context, segment TemplateSegment, preparedInput *fastbuffer.FastBuffer) error {
+	value, valueType, _, err := json.Get(ctx.Variables, segment.VariableSourcePath...)
+	if err == jsonparser.KeyPathNotFoundError && segment.DefaultValue != nil {
+		value, valueType, _, err = json.Get(segment.DefaultValue)
+	}
 	if err != nil {
 		return err
 	}
-	if !renderAsGraphQLValue {
+	switch segment.Encoding {
+	case EncodingURLComponent:
+		return i.renderURLComponent(value, valueType, preparedInput)
+	case EncodingBase64:
+		return i.renderBase64(json, value, valueType, segment, preparedInput)
+	}
+	if segment.RenderAsGraphQLEnum {
+		return i.renderGraphQLEnum(json, value, valueType, preparedInput)
+	}
+	if segment.RenderAsJSONString && valueType == jsonparser.String {
+		return i.renderJSONString(value, preparedInput)
+	}
+	if !segment.RenderAsGraphQLValue {
 		preparedInput.WriteBytes(value)
 		return nil
 	}
-	return i.renderGraphQLValue(value, valueType, preparedInput)
+	return i.renderGraphQLValue(json, value, valueType, preparedInput)
+}
+
+// renderContextVariableBranch renders the segments of the branch matching the context variable
+func (i *InputTemplate) renderContextVariableBranch(ctx *Context, data []byte, segment TemplateSegment, preparedInput *fastbuffer.FastBuffer) error {
+	segments, err := segment.Branches.segments(ctx.jsonValueGetter(), ctx.Variables, segment)
+	if err != nil {
+		return err
+	}
+	branch := InputTemplate{Segments: segments}
+	return branch.Render(ctx, data, preparedInput)
+}
+
+// renderURLComponent writes the value percent-encoded like encodeURIComponent, strings are unescaped before they're encoded
+func (i *InputTemplate) renderURLComponent(value []byte, valueType jsonparser.ValueType, preparedInput *fastbuffer.FastBuffer) error {
+	component := string(value)
+	if valueType == jsonparser.String {
+		unescaped, err := jsonparser.ParseString(value)
+		if err != nil {
+			return err
+		}
+		component = unescaped
+	}
+	// QueryEscape encodes spaces as "+" which is only valid in query strings, a literal "+" is encoded as "%2B"
+	preparedInput.WriteString(strings.ReplaceAll(url.QueryEscape(component), "+", "%20"))
+	return nil
+}
+
+// renderBase64 writes the value encoded with standard base64, strings are unescaped before they're encoded
+// The encoded value is a string, so it's quoted if the segment renders strings as JSON strings or GraphQL values
+func (i *InputTemplate) renderBase64(json JSONValueGetter, value []byte, valueType jsonparser.ValueType, segment TemplateSegment, preparedInput *fastbuffer.FastBuffer) error {
+	if valueType == jsonparser.String {
+		unescaped, err := jsonparser.ParseString(value)
+		if err != nil {
+			return err
+		}
+		value = []byte(unescaped)
+	}
+	encoded := []byte(base64.StdEncoding.EncodeToString(value))
+	switch {
+	case segment.RenderAsJSONString:
+		// base64 doesn't contain any characters which must be escaped
+		preparedInput.WriteBytes(quote)
+		preparedInput.WriteBytes(encoded)
+		preparedInput.WriteBytes(quote)
+		return nil
+	case segment.RenderAsGraphQLValue:
+		return i.renderGraphQLValue(json, encoded, jsonparser.String, preparedInput)
+	default:
+		preparedInput.WriteBytes(encoded)
+		return nil
+	}
+}
+
+// renderJSONString writes a raw JSON string value as a quoted and escaped JSON string
+func (i *InputTemplate) renderJSONString(value []byte, preparedInput *fastbuffer.FastBuffer) error {
+	unescaped, err := jsonparser.ParseString(value)
+	if err != nil {
+		return err
+	}
+	writeJSONString(preparedInput, unescaped)
+	return nil
+}
+
+// renderGraphQLEnum writes string values unquoted as GraphQL enum values, lists are rendered as lists of enum values
+// Strings which aren't valid enum names are rejected, so that a variable can't inject arbitrary GraphQL into the query
+func (i *InputTemplate) renderGraphQLEnum(json JSONValueGetter, data []byte, valueType jsonparser.ValueType, buf *fastbuffer.FastBuffer) (err error) {
+	switch valueType {
+	case jsonparser.String:
+		if !isGraphQLEnumValue(data) {
+			return fmt.Errorf("%w: %q", errInvalidEnumValue, data)
+		}
+		buf.WriteBytes(data)
+	case jsonparser.Null:
+		buf.WriteBytes(literal.NULL)
+	case jsonparser.Array:
+		buf.WriteBytes(literal.LBRACK)
+		first := true
+		var arrayErr error
+		_, err = json.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			if arrayErr != nil {
+				return
+			}
+			if !first {
+				buf.WriteBytes(literal.COMMA)
+			} else {
+				first = false
+			}
+			arrayErr = i.renderGraphQLEnum(json, value, dataType, buf)
+		})
+		if arrayErr != nil {
+			return arrayErr
+		}
+		if err != nil {
+			return err
+		}
+		buf.WriteBytes(literal.RBRACK)
+	default:
+		return fmt.Errorf("%w: %s", errInvalidEnumValue, data)
+	}
+	return
+}
+
+// isGraphQLEnumValue reports whether value is a GraphQL name other than true, false and null
+func isGraphQLEnumValue(value []byte) bool {
+	if len(value) == 0 || bytes.Equal(value, literal.TRUE) || bytes.Equal(value, literal.FALSE) || bytes.Equal(value, literal.NULL) {
+		return false
+	}
+	for i, c := range value {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i != 0:
+		default:
+			return false
+		}
+	}
+	return true
 }
 
-func (i *InputTemplate) renderGraphQLValue(data []byte, valueType jsonparser.ValueType, buf *fastbuffer.FastBuffer) (err error) {
+func (i *InputTemplate) renderGraphQLValue(json JSONValueGetter, data []byte, valueType jsonparser.ValueType, buf *fastbuffer.FastBuffer) (err error) {
 	switch valueType {
 	case jsonparser.String:
 		buf.WriteBytes(literal.BACKSLASH)
@@ -1330,7 +3056,7 @@ func (i *InputTemplate) renderGraphQLValue(data []byte, valueType jsonparser.Val
 	case jsonparser.Object:
 		buf.WriteBytes(literal.LBRACE)
 		first := true
-		err = jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+		err = json.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
 			if !first {
 				buf.WriteBytes(literal.COMMA)
 			} else {
@@ -1338,7 +3064,7 @@ func (i *InputTemplate) renderGraphQLValue(data []byte, valueType jsonparser.Val
 			}
 			buf.WriteBytes(key)
 			buf.WriteBytes(literal.COLON)
-			return i.renderGraphQLValue(value, dataType, buf)
+			return i.renderGraphQLValue(json, value, dataType, buf)
 		})
 		if err != nil {
 			return err
@@ -1352,13 +3078,13 @@ func (i *InputTemplate) renderGraphQLValue(data []byte, valueType jsonparser.Val
 		buf.WriteBytes(literal.LBRACK)
 		first := true
 		var arrayErr error
-		_, err = jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		_, err = json.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 			if !first {
 				buf.WriteBytes(literal.COMMA)
 			} else {
 				first = false
 			}
-			arrayErr = i.renderGraphQLValue(value, dataType, buf)
+			arrayErr = i.renderGraphQLValue(json, value, dataType, buf)
 		})
 		if arrayErr != nil {
 			return arrayErr
@@ -1373,7 +3099,8 @@ func (i *InputTemplate) renderGraphQLValue(data []byte, valueType jsonparser.Val
 	return
 }
 
-func (i *InputTemplate) renderHeaderVariable(ctx *Context, path []string, preparedInput *fastbuffer.FastBuffer) error {
+func (i *InputTemplate) renderHeaderVariable(ctx *Context, segment TemplateSegment, preparedInput *fastbuffer.FastBuffer) error {
+	path := segment.VariableSourcePath
 	if len(path) != 1 {
 		return errHeaderPathInvalid
 	}
@@ -1382,6 +3109,17 @@ func (i *InputTemplate) renderHeaderVariable(ctx *Context, path []string, prepar
 	// could be simplified once go 1.12 support will be dropped
 	canonicalName := textproto.CanonicalMIMEHeaderKey(path[0])
 	value := ctx.Request.Header[canonicalName]
+	if segment.RenderAsArray {
+		preparedInput.WriteBytes(literal.LBRACK)
+		for j := range value {
+			if j != 0 {
+				preparedInput.WriteBytes(literal.COMMA)
+			}
+			writeJSONString(preparedInput, value[j])
+		}
+		preparedInput.WriteBytes(literal.RBRACK)
+		return nil
+	}
 	if len(value) == 0 {
 		return nil
 	}
@@ -1398,6 +3136,144 @@ func (i *InputTemplate) renderHeaderVariable(ctx *Context, path []string, prepar
 	return nil
 }
 
+// PlaceholderStyle is the syntax of the placeholders written by InputTemplate.RenderParameterized
+type PlaceholderStyle int
+
+const (
+	// PlaceholderStyleDollar writes numbered placeholders, e.g. $1, $2 (PostgreSQL)
+	PlaceholderStyleDollar PlaceholderStyle = iota + 1
+	// PlaceholderStyleQuestionMark writes positional placeholders, e.g. ? (MySQL, SQLite)
+	PlaceholderStyleQuestionMark
+)
+
+// RenderParameterized renders the template for data sources with parameterized queries, e.g. SQL data sources
+// Static segments are written to query as is, each variable segment is written as a placeholder and its value is appended to args,
+// so that variable values are never inlined into the query.
+// Values are converted to string, int64, float64, bool or nil, objects and arrays are passed as their JSON encoding.
+// The render options of segments, e.g. RenderAsGraphQLValue, don't apply to parameters.
+func (i *InputTemplate) RenderParameterized(ctx *Context, data []byte, style PlaceholderStyle, query *fastbuffer.FastBuffer) (args []interface{}, err error) {
+	for j := range i.Segments {
+		switch i.Segments[j].SegmentType {
+		case StaticSegmentType:
+			query.WriteBytes(i.Segments[j].Data)
+		case VariableSegmentType:
+			if i.Segments[j].Branches != nil {
+				return nil, errors.New("InputTemplate.RenderParameterized: variable branches are not supported")
+			}
+			arg, err := i.parameterValue(ctx, data, i.Segments[j])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			switch style {
+			case PlaceholderStyleDollar:
+				query.WriteString("$")
+				query.WriteString(strconv.Itoa(len(args)))
+			case PlaceholderStyleQuestionMark:
+				query.WriteString("?")
+			default:
+				return nil, fmt.Errorf("InputTemplate.RenderParameterized: unknown placeholder style: %d", style)
+			}
+		}
+	}
+	return args, nil
+}
+
+func (i *InputTemplate) parameterValue(ctx *Context, data []byte, segment TemplateSegment) (interface{}, error) {
+	var (
+		value     []byte
+		valueType jsonparser.ValueType
+		err       error
+	)
+	json := ctx.jsonValueGetter()
+	switch segment.VariableSource {
+	case VariableSourceObject:
+		value, valueType, _, err = json.Get(data, segment.VariableSourcePath...)
+	case VariableSourceContext:
+		value, valueType, _, err = json.Get(ctx.Variables, segment.VariableSourcePath...)
+		if err == jsonparser.KeyPathNotFoundError && segment.DefaultValue != nil {
+			value, valueType, _, err = json.Get(segment.DefaultValue)
+		}
+	case VariableSourceRequestHeader:
+		return i.headerParameterValue(ctx, segment)
+	case VariableSourceTrace:
+		return string(ctx.TraceID), nil
+	default:
+		return nil, fmt.Errorf("InputTemplate.RenderParameterized: cannot resolve variable of kind: %d", segment.VariableSource)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch valueType {
+	case jsonparser.String:
+		return jsonparser.ParseString(value)
+	case jsonparser.Number:
+		if integer, err := jsonparser.ParseInt(value); err == nil {
+			return integer, nil
+		}
+		return jsonparser.ParseFloat(value)
+	case jsonparser.Boolean:
+		return jsonparser.ParseBoolean(value)
+	case jsonparser.Null:
+		return nil, nil
+	default:
+		return string(value), nil
+	}
+}
+
+func (i *InputTemplate) headerParameterValue(ctx *Context, segment TemplateSegment) (interface{}, error) {
+	if len(segment.VariableSourcePath) != 1 {
+		return nil, errHeaderPathInvalid
+	}
+	values := ctx.Request.Header[textproto.CanonicalMIMEHeaderKey(segment.VariableSourcePath[0])]
+	if !segment.RenderAsArray {
+		return strings.Join(values, ","), nil
+	}
+	if values == nil {
+		values = []string{}
+	}
+	array, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return string(array), nil
+}
+
+// writeJSONString writes value as a quoted JSON string, escaping quotes, backslashes and control characters
+func writeJSONString(buf *fastbuffer.FastBuffer, value string) {
+	buf.WriteBytes(quote)
+	writeEscapedJSONString(buf, value)
+	buf.WriteBytes(quote)
+}
+
+// writeEscapedJSONString writes value escaped for the content of a JSON string, without the enclosing quotes
+func writeEscapedJSONString(buf *fastbuffer.FastBuffer, value string) {
+	const hex = "0123456789abcdef"
+	start := 0
+	for j := 0; j < len(value); j++ {
+		c := value[j]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		buf.WriteString(value[start:j])
+		switch c {
+		case '"', '\\':
+			buf.WriteBytes([]byte{'\\', c})
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteBytes([]byte{'\\', 'u', '0', '0', hex[c>>4], hex[c&0xF]})
+		}
+		start = j + 1
+	}
+	buf.WriteString(value[start:])
+}
+
 type SegmentType int
 type VariableSource int
 
@@ -1408,6 +3284,8 @@ const (
 	VariableSourceObject VariableSource = iota + 1
 	VariableSourceContext
 	VariableSourceRequestHeader
+	// VariableSourceTrace renders the TraceID of the Context
+	VariableSourceTrace
 )
 
 type TemplateSegment struct {
@@ -1416,12 +3294,80 @@ type TemplateSegment struct {
 	VariableSource       VariableSource
 	VariableSourcePath   []string
 	RenderAsGraphQLValue bool
+	// DefaultValue is the JSON value rendered if the VariableSourcePath cannot be found in the context variables
+	DefaultValue []byte
+	// RenderAsJSONString renders string values of context variables as quoted and escaped JSON strings
+	RenderAsJSONString bool
+	// RenderAsArray renders all values of a request header as a JSON array of strings
+	RenderAsArray bool
+	// Branches renders one of the branches instead of the value of a context variable,
+	// e.g. to forward an explicit null as "clear this field" but omit the field if the variable is absent
+	Branches *VariableBranches
+	// Encoding encodes the value of a context or object variable before it's rendered, e.g. for a path segment of a URL
+	Encoding EncodingKind
+	// RenderAsGraphQLEnum renders string values of context variables unquoted as GraphQL enum values, e.g. SIT instead of \"SIT\"
+	// Lists are rendered as lists of enum values, null as null, values of other types and invalid enum names fail rendering
+	RenderAsGraphQLEnum bool
+}
+
+// EncodingKind is the encoding of the value of a TemplateSegment
+type EncodingKind int
+
+const (
+	// EncodingNone renders the value as is
+	EncodingNone EncodingKind = iota
+	// EncodingURLComponent percent-encodes the value, so that it can be used as a path segment or a query parameter of a URL
+	EncodingURLComponent
+	// EncodingBase64 encodes the value with standard base64, e.g. for cursors or tokens of a request body
+	// The encoded value is rendered as a string, RenderAsJSONString and RenderAsGraphQLValue quote it
+	EncodingBase64
+)
+
+// VariableBranches are the segments rendered depending on the presence of a context variable
+// A DefaultValue of the segment is used if the variable is absent, an explicit null doesn't use the DefaultValue.
+type VariableBranches struct {
+	// Absent is rendered if the variable was omitted and the segment has no DefaultValue
+	Absent []TemplateSegment
+	// Null is rendered if the variable is null
+	Null []TemplateSegment
+	// Value is rendered if the variable has a value other than null, it usually contains the variable segment without Branches
+	Value []TemplateSegment
+}
+
+func (b *VariableBranches) segments(json JSONValueGetter, variables []byte, segment TemplateSegment) ([]TemplateSegment, error) {
+	_, valueType, _, err := json.Get(variables, segment.VariableSourcePath...)
+	if err == jsonparser.KeyPathNotFoundError && segment.DefaultValue != nil {
+		_, valueType, _, err = json.Get(segment.DefaultValue)
+	}
+	switch {
+	case err == jsonparser.KeyPathNotFoundError:
+		return b.Absent, nil
+	case err != nil:
+		return nil, err
+	case valueType == jsonparser.Null:
+		return b.Null, nil
+	default:
+		return b.Value, nil
+	}
 }
 
 func (_ *SingleFetch) FetchKind() FetchKind {
 	return FetchKindSingle
 }
 
+// loadsBuffer returns true if the fetch loads the buffer with bufferID
+func (s *SingleFetch) loadsBuffer(bufferID int) bool {
+	if s.BufferId == bufferID {
+		return true
+	}
+	for _, id := range s.BatchBufferIds {
+		if id == bufferID {
+			return true
+		}
+	}
+	return false
+}
+
 type ParallelFetch struct {
 	Fetches []*SingleFetch
 }
@@ -1433,6 +3379,8 @@ func (_ *ParallelFetch) FetchKind() FetchKind {
 type String struct {
 	Path     []string
 	Nullable bool
+	// Transform is the name of a TransformFunc registered on the Resolver, applied to the resolved value
+	Transform string
 }
 
 func (_ *String) NodeKind() NodeKind {
@@ -1442,6 +3390,16 @@ func (_ *String) NodeKind() NodeKind {
 type Boolean struct {
 	Path     []string
 	Nullable bool
+	// Transform is the name of a TransformFunc registered on the Resolver, applied to the resolved value
+	Transform string
+	// TrueValues and FalseValues map string values of the upstream to true and false, e.g. "ENABLED" and "DISABLED"
+	// Other string values can't be coerced, they resolve to null if the field is nullable and to an error otherwise
+	TrueValues  []string
+	FalseValues []string
+}
+
+func (b *Boolean) hasValueMapping() bool {
+	return len(b.TrueValues) != 0 || len(b.FalseValues) != 0
 }
 
 func (_ *Boolean) NodeKind() NodeKind {
@@ -1451,6 +3409,15 @@ func (_ *Boolean) NodeKind() NodeKind {
 type Float struct {
 	Path     []string
 	Nullable bool
+	// Canonicalize re-formats the value as a plain decimal number without exponent and trailing zeros, e.g. 1e3 resolves to 1000
+	// Values which are not finite, e.g. 1e400, resolve to null or violate the non-null constraint
+	Canonicalize bool
+	// Precision rounds canonicalized and converted values to at most Precision digits after the decimal point, 0 keeps all digits
+	Precision int
+	// Conversion converts the value into another unit, converted values are always formatted like canonicalized values
+	Conversion *UnitConversion
+	// Transform is the name of a TransformFunc registered on the Resolver, applied to the resolved value
+	Transform string
 }
 
 func (_ *Float) NodeKind() NodeKind {
@@ -1460,6 +3427,12 @@ func (_ *Float) NodeKind() NodeKind {
 type Integer struct {
 	Path     []string
 	Nullable bool
+	// StrictRange rejects values outside of the signed 32-bit range of a GraphQL Int, they resolve to null or violate the non-null constraint
+	StrictRange bool
+	// Conversion converts the value into another unit, the StrictRange check applies to the converted value
+	Conversion *UnitConversion
+	// Transform is the name of a TransformFunc registered on the Resolver, applied to the resolved value
+	Transform string
 }
 
 func (_ *Integer) NodeKind() NodeKind {
@@ -1472,10 +3445,18 @@ type Array struct {
 	ResolveAsynchronous bool
 	Item                Node
 	Stream              Stream
+	// MaxItems truncates the array to its first MaxItems items, e.g. to protect clients from unexpectedly large upstream lists
+	// Each truncation is reported in the extensions of the response, 0 disables the limit
+	MaxItems int
 }
 
 type Stream struct {
-	Enabled          bool
+	Enabled bool
+	// InitialCount is the number of items resolved as part of the initial response
+	// all following items are sent as patches
+	InitialCount int
+	// Deprecated: use InitialCount, postprocess.ProcessStream moves InitialBatchSize into InitialCount if InitialCount isn't set
+	// The resolver only reads InitialCount
 	InitialBatchSize int
 	PatchIndex       int
 }
@@ -1528,11 +3509,21 @@ const (
 	VariableKindContext VariableKind = iota + 1
 	VariableKindObject
 	VariableKindHeader
+	VariableKindTrace
 )
 
 type ContextVariable struct {
 	Path                 []string
 	RenderAsGraphQLValue bool
+	// DefaultValue is an optional JSON value, rendered instead of returning an error if Path is missing
+	DefaultValue []byte
+	// RenderAsJSONString renders string values as quoted JSON strings, e.g. to embed them into a JSON body
+	// Values of other types are rendered as is
+	RenderAsJSONString bool
+	// Encoding encodes the value before it's rendered, e.g. EncodingURLComponent for a path segment of a URL
+	Encoding EncodingKind
+	// RenderAsGraphQLEnum renders the value unquoted as a GraphQL enum value, e.g. for an enum argument of an upstream query
+	RenderAsGraphQLEnum bool
 }
 
 func (c *ContextVariable) TemplateSegment() TemplateSegment {
@@ -1541,6 +3532,10 @@ func (c *ContextVariable) TemplateSegment() TemplateSegment {
 		VariableSource:       VariableSourceContext,
 		VariableSourcePath:   c.Path,
 		RenderAsGraphQLValue: c.RenderAsGraphQLValue,
+		DefaultValue:         c.DefaultValue,
+		RenderAsJSONString:   c.RenderAsJSONString,
+		Encoding:             c.Encoding,
+		RenderAsGraphQLEnum:  c.RenderAsGraphQLEnum,
 	}
 }
 
@@ -1555,6 +3550,18 @@ func (c *ContextVariable) Equals(another Variable) bool {
 	if len(c.Path) != len(anotherContextVariable.Path) {
 		return false
 	}
+	if !bytes.Equal(c.DefaultValue, anotherContextVariable.DefaultValue) {
+		return false
+	}
+	if c.RenderAsJSONString != anotherContextVariable.RenderAsJSONString {
+		return false
+	}
+	if c.Encoding != anotherContextVariable.Encoding {
+		return false
+	}
+	if c.RenderAsGraphQLEnum != anotherContextVariable.RenderAsGraphQLEnum {
+		return false
+	}
 	for i := range c.Path {
 		if c.Path[i] != anotherContextVariable.Path[i] {
 			return false
@@ -1569,6 +3576,8 @@ func (_ *ContextVariable) VariableKind() VariableKind {
 
 type ObjectVariable struct {
 	Path []string
+	// Encoding encodes the value before it's rendered, e.g. EncodingURLComponent for a path segment of a URL
+	Encoding EncodingKind
 }
 
 func (o *ObjectVariable) TemplateSegment() TemplateSegment {
@@ -1576,6 +3585,7 @@ func (o *ObjectVariable) TemplateSegment() TemplateSegment {
 		SegmentType:        VariableSegmentType,
 		VariableSource:     VariableSourceObject,
 		VariableSourcePath: o.Path,
+		Encoding:           o.Encoding,
 	}
 }
 
@@ -1587,7 +3597,7 @@ func (o *ObjectVariable) Equals(another Variable) bool {
 		return false
 	}
 	anotherObjectVariable := another.(*ObjectVariable)
-	if len(o.Path) != len(anotherObjectVariable.Path) {
+	if len(o.Path) != len(anotherObjectVariable.Path) || o.Encoding != anotherObjectVariable.Encoding {
 		return false
 	}
 	for i := range o.Path {
@@ -1604,6 +3614,8 @@ func (o *ObjectVariable) VariableKind() VariableKind {
 
 type HeaderVariable struct {
 	Path []string
+	// RenderAsArray renders all header values as a JSON array instead of joining them with commas
+	RenderAsArray bool
 }
 
 func (h *HeaderVariable) TemplateSegment() TemplateSegment {
@@ -1611,6 +3623,7 @@ func (h *HeaderVariable) TemplateSegment() TemplateSegment {
 		SegmentType:        VariableSegmentType,
 		VariableSource:     VariableSourceRequestHeader,
 		VariableSourcePath: h.Path,
+		RenderAsArray:      h.RenderAsArray,
 	}
 }
 
@@ -1629,6 +3642,9 @@ func (h *HeaderVariable) Equals(another Variable) bool {
 	if len(h.Path) != len(anotherHeaderVariable.Path) {
 		return false
 	}
+	if h.RenderAsArray != anotherHeaderVariable.RenderAsArray {
+		return false
+	}
 	for i := range h.Path {
 		if h.Path[i] != anotherHeaderVariable.Path[i] {
 			return false
@@ -1637,9 +3653,39 @@ func (h *HeaderVariable) Equals(another Variable) bool {
 	return true
 }
 
+// TraceVariable renders the TraceID of the Context, e.g. into a tracing header of the upstream request
+type TraceVariable struct{}
+
+func (_ *TraceVariable) TemplateSegment() TemplateSegment {
+	return TemplateSegment{
+		SegmentType:    VariableSegmentType,
+		VariableSource: VariableSourceTrace,
+	}
+}
+
+func (_ *TraceVariable) VariableKind() VariableKind {
+	return VariableKindTrace
+}
+
+func (t *TraceVariable) Equals(another Variable) bool {
+	return another != nil && another.VariableKind() == t.VariableKind()
+}
+
 type GraphQLSubscription struct {
 	Trigger  GraphQLSubscriptionTrigger
 	Response *GraphQLResponse
+	// StreamingResponse is set if the Response contains deferred or streamed fields
+	// Each frame is then resolved as a streaming response, its InitialResponse is the Response of the subscription
+	StreamingResponse *GraphQLStreamingResponse
+	// HeartbeatInterval is the time without frames after which a heartbeat is written, e.g. to keep connections behind idle-timeout proxies open
+	// If set to 0 (default), no heartbeats are written
+	HeartbeatInterval time.Duration
+}
+
+// HeartbeatWriter is implemented by writers with their own keep-alive frame, e.g. the SSEWriter sends a comment
+// Other writers receive heartbeatFrame
+type HeartbeatWriter interface {
+	WriteHeartbeat() error
 }
 
 type GraphQLSubscriptionTrigger struct {
@@ -1647,8 +3693,23 @@ type GraphQLSubscriptionTrigger struct {
 	InputTemplate InputTemplate
 	Variables     Variables
 	Source        SubscriptionDataSource
+	// BufferSize is the number of frames buffered between the source and the resolver, so that a slow writer doesn't stall the source
+	// If set to 0 (default), the source blocks until the resolver reads the frame
+	BufferSize int
+	// BufferMode decides what happens to new frames if the buffer is full
+	BufferMode SubscriptionBufferMode
 }
 
+type SubscriptionBufferMode int
+
+const (
+	// SubscriptionBufferModeBlock blocks the source until there's space in the buffer (default)
+	SubscriptionBufferModeBlock SubscriptionBufferMode = iota
+	// SubscriptionBufferModeDropOldest never blocks the source, the oldest buffered frame is dropped if the buffer is full
+	// A BufferSize of 0 or 1 keeps only the latest frame, dropped frames are counted by Resolver.DroppedSubscriptionFrames
+	SubscriptionBufferModeDropOldest
+)
+
 type FlushWriter interface {
 	io.Writer
 	Flush()
@@ -1668,11 +3729,16 @@ type GraphQLResponsePatch struct {
 	Value     Node
 	Fetch     Fetch
 	Operation []byte
+	// CursorPath is the path of the cursor within the data of a streamed item
+	// If set, the patch contains the cursor next to the value so that clients can resume the stream after the item
+	CursorPath []string
 }
 
 type BufPair struct {
 	Data   *fastbuffer.FastBuffer
 	Errors *fastbuffer.FastBuffer
+	// timedOut is set if the fetch of the buffer exceeded its Timeout
+	timedOut bool
 }
 
 func NewBufPair() *BufPair {
@@ -1693,6 +3759,7 @@ func (b *BufPair) HasErrors() bool {
 func (b *BufPair) Reset() {
 	b.Data.Reset()
 	b.Errors.Reset()
+	b.timedOut = false
 }
 
 func (b *BufPair) writeErrors(data []byte) {
@@ -1742,6 +3809,14 @@ func (b *BufPair) WriteErr(message, locations, path, extensions []byte) {
 	b.writeErrors(rBrace)
 }
 
+// checkResponseSize fails if buf exceeds MaxResponseBytes, buf is a part of the response, so the response exceeds it as well
+func (r *Resolver) checkResponseSize(buf *BufPair) error {
+	if r.MaxResponseBytes > 0 && buf.Data.Len()+buf.Errors.Len() > r.MaxResponseBytes {
+		return fmt.Errorf("%w of %d bytes", ErrMaxResponseBytesExceeded, r.MaxResponseBytes)
+	}
+	return nil
+}
+
 func (r *Resolver) MergeBufPairs(from, to *BufPair, prefixDataWithComma bool) {
 	r.MergeBufPairData(from, to, prefixDataWithComma)
 	r.MergeBufPairErrors(from, to)
@@ -1772,66 +3847,115 @@ func (r *Resolver) MergeBufPairErrors(from, to *BufPair) {
 func (r *Resolver) freeBufPair(pair *BufPair) {
 	pair.Data.Reset()
 	pair.Errors.Reset()
+	r.putBufPair(pair)
+}
+
+// putBufPair puts the reset pair back into the pool unless it's oversized
+func (r *Resolver) putBufPair(pair *BufPair) {
+	if r.oversized(pair) {
+		r.poolStats.discard(&r.poolStats.bufPairs)
+		return
+	}
+	r.poolStats.put(&r.poolStats.bufPairs)
 	r.bufPairPool.Put(pair)
 }
 
+// oversized returns true if a buffer of the pair grew past MaxPooledBufferBytes, it's left to the garbage collector then
+func (r *Resolver) oversized(pair *BufPair) bool {
+	return r.MaxPooledBufferBytes > 0 && (pair.Data.Cap() > r.MaxPooledBufferBytes || pair.Errors.Cap() > r.MaxPooledBufferBytes)
+}
+
 func (r *Resolver) getResultSet() *resultSet {
+	r.poolStats.get(&r.poolStats.resultSets)
 	return r.resultSetPool.Get().(*resultSet)
 }
 
 func (r *Resolver) getBufPair() *BufPair {
+	r.poolStats.get(&r.poolStats.bufPairs)
 	return r.bufPairPool.Get().(*BufPair)
 }
 
 func (r *Resolver) getBufPairSlice() *[]*BufPair {
+	r.poolStats.get(&r.poolStats.bufPairSlices)
 	return r.bufPairSlicePool.Get().(*[]*BufPair)
 }
 
+func (r *Resolver) getByteSlices() *[][]byte {
+	r.poolStats.get(&r.poolStats.byteSlices)
+	return r.byteSlicesPool.Get().(*[][]byte)
+}
+
+func (r *Resolver) freeByteSlices(slices *[][]byte) {
+	*slices = (*slices)[:0]
+	r.poolStats.put(&r.poolStats.byteSlices)
+	r.byteSlicesPool.Put(slices)
+}
+
 func (r *Resolver) freeBufPairSlice(slice *[]*BufPair) {
 	for i := range *slice {
 		r.freeBufPair((*slice)[i])
 	}
 	*slice = (*slice)[:0]
+	r.poolStats.put(&r.poolStats.bufPairSlices)
 	r.bufPairSlicePool.Put(slice)
 }
 
 func (r *Resolver) getErrChan() chan error {
+	r.poolStats.get(&r.poolStats.errChans)
 	return r.errChanPool.Get().(chan error)
 }
 
 func (r *Resolver) freeErrChan(ch chan error) {
+	r.poolStats.put(&r.poolStats.errChans)
 	r.errChanPool.Put(ch)
 }
 
 func (r *Resolver) getWaitGroup() *sync.WaitGroup {
+	r.poolStats.get(&r.poolStats.waitGroups)
 	return r.waitGroupPool.Get().(*sync.WaitGroup)
 }
 
 func (r *Resolver) freeWaitGroup(wg *sync.WaitGroup) {
+	r.poolStats.put(&r.poolStats.waitGroups)
 	r.waitGroupPool.Put(wg)
 }
 
 func (r *Resolver) getInflightFetch() *inflightFetch {
+	r.poolStats.get(&r.poolStats.inflightFetches)
 	return r.inflightFetchPool.Get().(*inflightFetch)
 }
 
 func (r *Resolver) freeInflightFetch(f *inflightFetch) {
 	f.bufPair.Data.Reset()
 	f.bufPair.Errors.Reset()
+	f.bufPair.timedOut = false
 	f.err = nil
+	f.fallback = false
+	if r.oversized(&f.bufPair) {
+		r.poolStats.discard(&r.poolStats.inflightFetches)
+		return
+	}
+	r.poolStats.put(&r.poolStats.inflightFetches)
 	r.inflightFetchPool.Put(f)
 }
 
 func (r *Resolver) getHash64() hash.Hash64 {
+	r.poolStats.get(&r.poolStats.hash64s)
 	return r.hash64Pool.Get().(hash.Hash64)
 }
 
 func (r *Resolver) putHash64(h hash.Hash64) {
 	h.Reset()
+	r.poolStats.put(&r.poolStats.hash64s)
 	r.hash64Pool.Put(h)
 }
 
 func writeGraphqlResponse(buf *BufPair, writer io.Writer, ignoreData bool) (err error) {
+	return writeGraphqlResponseWithExtensions(buf, writer, ignoreData, nil)
+}
+
+// writeGraphqlResponseWithExtensions writes the response like writeGraphqlResponse, followed by the extensions if they're not nil
+func writeGraphqlResponseWithExtensions(buf *BufPair, writer io.Writer, ignoreData bool, extensions []byte) (err error) {
 	hasErrors := buf.Errors.Len() != 0
 	hasData := buf.Data.Len() != 0 && !ignoreData
 
@@ -1858,6 +3982,15 @@ func writeGraphqlResponse(buf *BufPair, writer io.Writer, ignoreData bool) (err
 	} else {
 		err = writeSafe(err, writer, literal.NULL)
 	}
+
+	if extensions != nil {
+		err = writeSafe(err, writer, comma)
+		err = writeSafe(err, writer, quote)
+		err = writeSafe(err, writer, literalExtensions)
+		err = writeSafe(err, writer, quote)
+		err = writeSafe(err, writer, colon)
+		err = writeSafe(err, writer, extensions)
+	}
 	err = writeSafe(err, writer, rBrace)
 
 	return err
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/jensneuse/graphql-go-tools/pkg/engine/resolve (interfaces: DataSource,BeforeFetchHook,AfterFetchHook)
+// Source: github.com/jensneuse/graphql-go-tools/pkg/engine/resolve (interfaces: DataSource,BeforeFetchHook,AfterFetchHook,FetchCompleteHook,FetchInputRewriteHook,BeforeFetchResponseHook)
 
 // Package resolve is a generated GoMock package.
 package resolve
@@ -8,6 +8,7 @@ import (
 	context "context"
 	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -130,3 +131,126 @@ func (mr *MockAfterFetchHookMockRecorder) OnError(arg0, arg1, arg2 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnError", reflect.TypeOf((*MockAfterFetchHook)(nil).OnError), arg0, arg1, arg2)
 }
+
+// MockFetchCompleteHook is a mock of FetchCompleteHook interface.
+type MockFetchCompleteHook struct {
+	ctrl     *gomock.Controller
+	recorder *MockFetchCompleteHookMockRecorder
+}
+
+// MockFetchCompleteHookMockRecorder is the mock recorder for MockFetchCompleteHook.
+type MockFetchCompleteHookMockRecorder struct {
+	mock *MockFetchCompleteHook
+}
+
+// NewMockFetchCompleteHook creates a new mock instance.
+func NewMockFetchCompleteHook(ctrl *gomock.Controller) *MockFetchCompleteHook {
+	mock := &MockFetchCompleteHook{ctrl: ctrl}
+	mock.recorder = &MockFetchCompleteHookMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFetchCompleteHook) EXPECT() *MockFetchCompleteHookMockRecorder {
+	return m.recorder
+}
+
+// OnFetchComplete mocks base method.
+func (m *MockFetchCompleteHook) OnFetchComplete(arg0 HookContext, arg1 []byte, arg2 time.Duration, arg3 int, arg4 error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnFetchComplete", arg0, arg1, arg2, arg3, arg4)
+}
+
+// OnFetchComplete indicates an expected call of OnFetchComplete.
+func (mr *MockFetchCompleteHookMockRecorder) OnFetchComplete(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnFetchComplete", reflect.TypeOf((*MockFetchCompleteHook)(nil).OnFetchComplete), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MockFetchInputRewriteHook is a mock of FetchInputRewriteHook interface.
+type MockFetchInputRewriteHook struct {
+	ctrl     *gomock.Controller
+	recorder *MockFetchInputRewriteHookMockRecorder
+}
+
+// MockFetchInputRewriteHookMockRecorder is the mock recorder for MockFetchInputRewriteHook.
+type MockFetchInputRewriteHookMockRecorder struct {
+	mock *MockFetchInputRewriteHook
+}
+
+// NewMockFetchInputRewriteHook creates a new mock instance.
+func NewMockFetchInputRewriteHook(ctrl *gomock.Controller) *MockFetchInputRewriteHook {
+	mock := &MockFetchInputRewriteHook{ctrl: ctrl}
+	mock.recorder = &MockFetchInputRewriteHookMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFetchInputRewriteHook) EXPECT() *MockFetchInputRewriteHookMockRecorder {
+	return m.recorder
+}
+
+// RewriteFetchInput mocks base method.
+func (m *MockFetchInputRewriteHook) RewriteFetchInput(arg0 HookContext, arg1, arg2 []byte) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewriteFetchInput", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RewriteFetchInput indicates an expected call of RewriteFetchInput.
+func (mr *MockFetchInputRewriteHookMockRecorder) RewriteFetchInput(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewriteFetchInput", reflect.TypeOf((*MockFetchInputRewriteHook)(nil).RewriteFetchInput), arg0, arg1, arg2)
+}
+
+// MockBeforeFetchResponseHook is a mock of BeforeFetchResponseHook interface.
+type MockBeforeFetchResponseHook struct {
+	ctrl     *gomock.Controller
+	recorder *MockBeforeFetchResponseHookMockRecorder
+}
+
+// MockBeforeFetchResponseHookMockRecorder is the mock recorder for MockBeforeFetchResponseHook.
+type MockBeforeFetchResponseHookMockRecorder struct {
+	mock *MockBeforeFetchResponseHook
+}
+
+// NewMockBeforeFetchResponseHook creates a new mock instance.
+func NewMockBeforeFetchResponseHook(ctrl *gomock.Controller) *MockBeforeFetchResponseHook {
+	mock := &MockBeforeFetchResponseHook{ctrl: ctrl}
+	mock.recorder = &MockBeforeFetchResponseHookMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBeforeFetchResponseHook) EXPECT() *MockBeforeFetchResponseHookMockRecorder {
+	return m.recorder
+}
+
+// OnBeforeFetch mocks base method.
+func (m *MockBeforeFetchResponseHook) OnBeforeFetch(arg0 HookContext, arg1 []byte) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnBeforeFetch", arg0, arg1)
+}
+
+// OnBeforeFetch indicates an expected call of OnBeforeFetch.
+func (mr *MockBeforeFetchResponseHookMockRecorder) OnBeforeFetch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnBeforeFetch", reflect.TypeOf((*MockBeforeFetchResponseHook)(nil).OnBeforeFetch), arg0, arg1)
+}
+
+// OnBeforeFetchResponse mocks base method.
+func (m *MockBeforeFetchResponseHook) OnBeforeFetchResponse(arg0 HookContext, arg1 []byte) ([]byte, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnBeforeFetchResponse", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// OnBeforeFetchResponse indicates an expected call of OnBeforeFetchResponse.
+func (mr *MockBeforeFetchResponseHookMockRecorder) OnBeforeFetchResponse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnBeforeFetchResponse", reflect.TypeOf((*MockBeforeFetchResponseHook)(nil).OnBeforeFetchResponse), arg0, arg1)
+}
@@ -2,11 +2,18 @@ package resolve
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/jensneuse/graphql-go-tools/pkg/fastbuffer"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
 )
 
 type _fakeDataSource struct {
@@ -357,6 +365,292 @@ func TestResolver_ResolveNode(t *testing.T) {
 			},
 		}, Context{Context: context.Background()}, `{"synchronousFriends":[{"id":1,"name":"Alex"},{"id":2,"name":"Patric"}],"asynchronousFriends":[{"id":1,"name":"Alex"},{"id":2,"name":"Patric"}],"nullableFriends":null,"strings":["foo","bar","baz"],"integers":[123,456,789],"floats":[1.2,3.4,5.6],"booleans":[true,false,true]}`
 	}))
+	t.Run("resolve asynchronous array with bounded concurrency", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		r.MaxConcurrentArrayResolvers = 2
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"friends":[{"id":1,"name":"Alex"},{"id":2,"name":"Patric"},{"id":3,"name":"Jens"},{"id":4,"name":"Sergiy"},{"id":5,"name":"Vasyl"}]}`),
+			},
+			Fields: []*Field{
+				{
+					BufferID:  0,
+					HasBuffer: true,
+					Name:      []byte("friends"),
+					Value: &Array{
+						Path:                []string{"friends"},
+						ResolveAsynchronous: true,
+						Item: &Object{
+							Fields: []*Field{
+								{
+									Name: []byte("id"),
+									Value: &Integer{
+										Path: []string{"id"},
+									},
+								},
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"friends":[{"id":1,"name":"Alex"},{"id":2,"name":"Patric"},{"id":3,"name":"Jens"},{"id":4,"name":"Sergiy"},{"id":5,"name":"Vasyl"}]}`
+	}))
+	t.Run("field with fallback buffers", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		return &Object{
+			Fetch: &ParallelFetch{
+				Fetches: []*SingleFetch{
+					{
+						BufferId:   0,
+						DataSource: FakeDataSource(`{"name":null,"price":10}`),
+					},
+					{
+						BufferId:   1,
+						DataSource: FakeDataSource(`{"name":null,"reviews":null}`),
+					},
+					{
+						BufferId:   2,
+						DataSource: FakeDataSource(`{"name":"Trilby","price":20}`),
+					},
+				},
+			},
+			Fields: []*Field{
+				{
+					HasBuffer:         true,
+					BufferID:          0,
+					FallbackBufferIDs: []int{1, 2},
+					Name:              []byte("name"),
+					Value: &String{
+						Path: []string{"name"},
+					},
+				},
+				{
+					HasBuffer:         true,
+					BufferID:          0,
+					FallbackBufferIDs: []int{2},
+					Name:              []byte("price"),
+					Value: &Integer{
+						Path: []string{"price"},
+					},
+				},
+				{
+					HasBuffer:         true,
+					BufferID:          0,
+					FallbackBufferIDs: []int{1, 2},
+					Name:              []byte("reviews"),
+					Value: &Array{
+						Path:     []string{"reviews"},
+						Nullable: true,
+						Item: &String{
+							Path: []string{"body"},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"name":"Trilby","price":10,"reviews":null}`
+	}))
+	t.Run("fields with conditions", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		product := &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("name"),
+					Value: &String{
+						Path: []string{"name"},
+					},
+				},
+				{
+					Name: []byte("discount"),
+					Value: &Integer{
+						Path: []string{"discount"},
+					},
+					Condition: &FieldCondition{
+						Path:     []string{"hasDiscount"},
+						Operator: ConditionOperatorEqual,
+						Value:    []byte(`true`),
+					},
+				},
+				{
+					Name: []byte("price"),
+					Value: &Integer{
+						Path: []string{"price"},
+					},
+					Condition: &FieldCondition{
+						Path:     []string{"currency"},
+						Operator: ConditionOperatorNotEqual,
+						Value:    []byte(`"EUR"`),
+					},
+				},
+			},
+		}
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"products":[{"name":"Trilby","hasDiscount":true,"discount":5,"currency":"USD","price":20},{"name":"Fedora","hasDiscount":false,"discount":0,"currency":"EUR","price":30},{"name":"Boater","price":40}]}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("products"),
+					Value: &Array{
+						Path: []string{"products"},
+						Item: product,
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"products":[{"name":"Trilby","discount":5,"price":20},{"name":"Fedora"},{"name":"Boater","price":40}]}`
+	}))
+	t.Run("object with all fields omitted by conditions", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"hasDiscount":false,"discount":0}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("discount"),
+					Value: &Integer{
+						Path: []string{"discount"},
+					},
+					Condition: &FieldCondition{
+						Path:  []string{"hasDiscount"},
+						Value: []byte(`true`),
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{}`
+	}))
+	t.Run("object with optional chaining for absent intermediate objects", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"user":{"name":"Jens"}}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("user"),
+					Value: &Object{
+						Path: []string{"user"},
+						Fields: []*Field{
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+							{
+								Name: []byte("address"),
+								Value: &Object{
+									Path:             []string{"address"},
+									OptionalChaining: true,
+									Fields: []*Field{
+										{
+											Name: []byte("city"),
+											Value: &String{
+												Path:     []string{"city"},
+												Nullable: true,
+											},
+										},
+										{
+											Name: []byte("geo"),
+											Value: &Object{
+												Path:             []string{"geo"},
+												OptionalChaining: true,
+												Fields: []*Field{
+													{
+														Name: []byte("lat"),
+														Value: &Float{
+															Path:     []string{"lat"},
+															Nullable: true,
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"user":{"name":"Jens","address":{"city":null,"geo":{"lat":null}}}}`
+	}))
+	t.Run("object with optional chaining for present intermediate objects", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"address":{"city":"Berlin"}}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("address"),
+					Value: &Object{
+						Path:             []string{"address"},
+						OptionalChaining: true,
+						Fields: []*Field{
+							{
+								Name: []byte("city"),
+								Value: &String{
+									Path:     []string{"city"},
+									Nullable: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"address":{"city":"Berlin"}}`
+	}))
+	t.Run("static values", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		return &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"name":"Jens"}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("name"),
+					Value: &String{
+						Path: []string{"name"},
+					},
+				},
+				{
+					Name:  []byte("apiVersion"),
+					Value: NewStaticStringValue("v1"),
+				},
+				{
+					Name:  []byte("serviceName"),
+					Value: NewStaticStringValue(`users "eu"`),
+				},
+				{
+					Name: []byte("maxPageSize"),
+					Value: &StaticValue{
+						Value: []byte(`100`),
+					},
+				},
+				{
+					Name: []byte("deprecated"),
+					Value: &StaticValue{
+						Value: []byte(`false`),
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"name":"Jens","apiVersion":"v1","serviceName":"users \"eu\"","maxPageSize":100,"deprecated":false}`
+	}))
 	t.Run("array response from data source", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
 		return &Object{
 				Fetch: &SingleFetch{
@@ -630,194 +924,107 @@ func TestResolver_ResolveNode(t *testing.T) {
 	}))
 }
 
-func TestResolver_WithHooks(t *testing.T) {
-	testFn := func(fn func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string)) func(t *testing.T) {
-		ctrl := gomock.NewController(t)
-		c, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		r := New(c)
-		node, ctx, expectedOutput := fn(t, r, ctrl)
-		return func(t *testing.T) {
-			buf := &BufPair{
-				Data:   fastbuffer.New(),
-				Errors: fastbuffer.New(),
+func TestResolver_Transforms(t *testing.T) {
+	newResolver := func(ctx context.Context) *Resolver {
+		r := New(ctx)
+		r.RegisterTransform("uppercase", func(value []byte) ([]byte, error) {
+			return bytes.ToUpper(value), nil
+		})
+		r.RegisterTransform("centsToDollars", func(value []byte) ([]byte, error) {
+			cents, err := strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, err
 			}
-			err := r.resolveNode(&ctx, node, nil, buf)
-			assert.Equal(t, buf.Errors.String(), "", "want error buf to be empty")
-			assert.NoError(t, err)
-			assert.Equal(t, expectedOutput, buf.Data.String())
-			ctrl.Finish()
-		}
+			return []byte(strconv.FormatFloat(float64(cents)/100, 'f', 2, 64)), nil
+		})
+		return r
 	}
-	t.Run("resolve with hooks", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
-
-		pathEq := func(expected string) gomock.Matcher {
-			return hookContextPathMatcher{path: expected}
-		}
 
-		beforeFetch := NewMockBeforeFetchHook(ctrl)
-		beforeFetch.EXPECT().OnBeforeFetch(pathEq("/data/user"), []byte("fakeInput")).Return()
-		afterFetch := NewMockAfterFetchHook(ctrl)
-		afterFetch.EXPECT().OnData(pathEq("/data/user"), []byte(`{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}`), false).Return()
+	object := func(nameTransform, priceTransform string) *Object {
 		return &Object{
 			Fields: []*Field{
 				{
-					Name: []byte("data"),
-					Value: &Object{
-						Fields: []*Field{
-							{
-								Name: []byte("user"),
-								Value: &Object{
-									Fetch: &SingleFetch{
-										BufferId:   0,
-										DataSource: FakeDataSource(`{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}`),
-										InputTemplate: InputTemplate{
-											Segments: []TemplateSegment{
-												{
-													SegmentType: StaticSegmentType,
-													Data:        []byte("fakeInput"),
-												},
-											},
-										},
-									},
-									Fields: []*Field{
-										{
-											BufferID:  0,
-											HasBuffer: true,
-											Name:      []byte("id"),
-											Value: &String{
-												Path: []string{"id"},
-											},
-										},
-										{
-											BufferID:  0,
-											HasBuffer: true,
-											Name:      []byte("name"),
-											Value: &String{
-												Path: []string{"name"},
-											},
-										},
-										{
-											BufferID:  0,
-											HasBuffer: true,
-											Name:      []byte("registered"),
-											Value: &Boolean{
-												Path: []string{"registered"},
-											},
-										},
-										{
-											BufferID:  0,
-											HasBuffer: true,
-											Name:      []byte("pet"),
-											Value: &Object{
-												Path: []string{"pet"},
-												Fields: []*Field{
-													{
-														Name: []byte("name"),
-														Value: &String{
-															Path: []string{"name"},
-														},
-													},
-													{
-														Name: []byte("kind"),
-														Value: &String{
-															Path: []string{"kind"},
-														},
-													},
-												},
-											},
-										},
-									},
-								},
-							},
-						},
+					Name: []byte("name"),
+					Value: &String{
+						Path:      []string{"name"},
+						Nullable:  true,
+						Transform: nameTransform,
+					},
+				},
+				{
+					Name: []byte("price"),
+					Value: &Float{
+						Path:      []string{"price"},
+						Transform: priceTransform,
 					},
 				},
 			},
-		}, Context{Context: context.Background(), beforeFetchHook: beforeFetch, afterFetchHook: afterFetch}, `{"data":{"user":{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}}}`
-	}))
-}
+		}
+	}
 
-func TestResolver_ResolveGraphQLResponse(t *testing.T) {
-	testFn := func(fn func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string)) func(t *testing.T) {
-		t.Helper()
+	t.Run("apply transforms", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := newResolver(c)
 
-		ctrl := gomock.NewController(t)
+		buf := NewBufPair()
+		err := r.resolveNode(&Context{Context: context.Background()}, object("uppercase", "centsToDollars"), []byte(`{"name":"trilby","price":1999}`), buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":"TRILBY","price":19.99}`, buf.Data.String())
+	})
+
+	t.Run("null values are not transformed", func(t *testing.T) {
 		c, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		r := New(c)
-		node, ctx, expectedOutput := fn(t, r, ctrl)
-		return func(t *testing.T) {
-			t.Helper()
+		r := newResolver(c)
 
-			buf := &bytes.Buffer{}
-			err := r.ResolveGraphQLResponse(&ctx, node, nil, buf)
-			assert.NoError(t, err)
-			assert.Equal(t, expectedOutput, buf.String())
-			ctrl.Finish()
-		}
-	}
-	t.Run("empty graphql response for nullable object", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		return &GraphQLResponse{
-			Data: &Object{
-				Nullable: true,
-			},
-		}, Context{Context: context.Background()}, `{"data":null}`
-	}))
-	t.Run("empty graphql response for not nullable query field", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		return &GraphQLResponse{
-			Data: &Object{
-				Nullable: false,
-				Fields: []*Field{
-					{
-						BufferID:  0,
-						HasBuffer: true,
-						Name:      []byte("country"),
-						Position: Position{
-							Line:   3,
-							Column: 4,
-						},
-						Value: &Object{
-							Nullable: false,
-							Path:     []string{"country"},
-							Fields: []*Field{
-								{
-									Name: []byte("name"),
-									Value: &String{
-										Nullable: true,
-										Path:     []string{"name"},
-									},
-									Position: Position{
-										Line:   4,
-										Column: 5,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":3,"column":4}],"path":["country"]}],"data":null}`
-	}))
-	t.Run("fetch with simple error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		r.EnableSingleFlightLoader = true
-		mockDataSource := NewMockDataSource(ctrl)
-		mockDataSource.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				pair := NewBufPair()
-				pair.WriteErr([]byte("errorMessage"), nil, nil, nil)
-				return writeGraphqlResponse(pair, w, false)
-			})
+		buf := NewBufPair()
+		err := r.resolveNode(&Context{Context: context.Background()}, object("uppercase", ""), []byte(`{"name":null,"price":19.99}`), buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":null,"price":19.99}`, buf.Data.String())
+	})
+
+	t.Run("transform error", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := newResolver(c)
+
+		buf := NewBufPair()
+		err := r.resolveNode(&Context{Context: context.Background()}, object("", "centsToDollars"), []byte(`{"name":"trilby","price":19.99}`), buf)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown transform", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := newResolver(c)
+
+		buf := NewBufPair()
+		err := r.resolveNode(&Context{Context: context.Background()}, object("lowercase", ""), []byte(`{"name":"trilby","price":19.99}`), buf)
+		assert.True(t, errors.Is(err, errTransformNotRegistered))
+	})
+
+	t.Run("validate transforms", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := newResolver(c)
+
+		assert.NoError(t, r.ValidateTransforms(object("uppercase", "centsToDollars")))
+		assert.NoError(t, r.ValidateTransforms(&Array{Item: object("", "")}))
+
+		err := r.ValidateTransforms(&Array{Item: object("uppercase", "lowercase")})
+		assert.True(t, errors.Is(err, errTransformNotRegistered))
+		assert.Contains(t, err.Error(), "lowercase")
+	})
+}
+
+func TestResolver_AppendGraphQLResponse(t *testing.T) {
+	response := func(transform string) *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: false,
 				Fetch: &SingleFetch{
 					BufferId:   0,
-					DataSource: mockDataSource,
-					ProcessResponseConfig: ProcessResponseConfig{
-						ExtractGraphqlResponse: true,
-					},
+					DataSource: FakeDataSource(`{"name":"Jens"}`),
 				},
 				Fields: []*Field{
 					{
@@ -825,166 +1032,695 @@ func TestResolver_ResolveGraphQLResponse(t *testing.T) {
 						BufferID:  0,
 						Name:      []byte("name"),
 						Value: &String{
-							Path:     []string{"name"},
-							Nullable: true,
+							Path:      []string{"name"},
+							Transform: transform,
 						},
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage"}],"data":{"name":null}}`
-	}))
-	t.Run("nested fetch error for non-nullable field", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		r.EnableSingleFlightLoader = true
-		mockDataSource := NewMockDataSource(ctrl)
-		mockDataSource.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				pair := NewBufPair()
-				pair.WriteErr([]byte("errorMessage"), nil, nil, nil)
-				return writeGraphqlResponse(pair, w, false)
-			})
+		}
+	}
+
+	t.Run("appends to the caller buffer", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resolver := New(c)
+
+		dst := make([]byte, 0, 1024)
+		dst = append(dst, "prefix:"...)
+
+		out, err := resolver.AppendGraphQLResponse(NewContext(c), response(""), nil, dst)
+		assert.NoError(t, err)
+		assert.Equal(t, `prefix:{"data":{"name":"Jens"}}`, string(out))
+		assert.True(t, &dst[0] == &out[0], "the buffer should be re-used")
+
+		out, err = resolver.AppendGraphQLResponse(NewContext(c), response(""), nil, out[:0])
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, string(out))
+	})
+
+	t.Run("returns the caller buffer unchanged on error", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resolver := New(c)
+
+		dst := []byte("prefix:")
+
+		out, err := resolver.AppendGraphQLResponse(NewContext(c), response("unknown"), nil, dst)
+		assert.True(t, errors.Is(err, errTransformNotRegistered))
+		assert.Equal(t, "prefix:", string(out))
+	})
+}
+
+func TestResolver_ResponseTransform(t *testing.T) {
+	response := func() *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: false,
 				Fetch: &SingleFetch{
 					BufferId:   0,
-					DataSource: FakeDataSource(`{"id":1}`),
+					DataSource: FakeDataSource(`{"name":"Jens","email":"jens@example.com"}`),
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("nestedObject"),
-						Value: &Object{
-							Nullable: false,
-							Fetch: &SingleFetch{
-								BufferId:   1,
-								DataSource: mockDataSource,
-								ProcessResponseConfig: ProcessResponseConfig{
-									ExtractGraphqlResponse: true,
-								},
-							},
-							Fields: []*Field{
-								{
-									HasBuffer: true,
-									BufferID:  1,
-									Name:      []byte("foo"),
-									Value: &String{
-										Path:     []string{"foo"},
-										Nullable: false,
-									},
-								},
-							},
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
 						},
 					},
-				},
-			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage"},{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["nestedObject"]}],"data":null}`
-	}))
-	t.Run("fetch with two Errors", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		r.EnableSingleFlightLoader = true
-		mockDataSource := NewMockDataSource(ctrl)
-		mockDataSource.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				pair := NewBufPair()
-				pair.WriteErr([]byte("errorMessage1"), nil, nil, nil)
-				pair.WriteErr([]byte("errorMessage2"), nil, nil, nil)
-				return writeGraphqlResponse(pair, w, false)
-			}).
-			Return(nil)
-		return &GraphQLResponse{
-			Data: &Object{
-				Fetch: &SingleFetch{
-					BufferId:   0,
-					DataSource: mockDataSource,
-					ProcessResponseConfig: ProcessResponseConfig{
-						ExtractGraphqlResponse: true,
-					},
-				},
-				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("name"),
+						Name:      []byte("email"),
 						Value: &String{
-							Path:     []string{"name"},
-							Nullable: true,
+							Path: []string{"email"},
 						},
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage1"},{"message":"errorMessage2"}],"data":{"name":null}}`
-	}))
-	t.Run("null field should bubble up to parent with error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		}
+	}
+
+	resolve := func(transform ResponseTransformFunc) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := New(c)
+		r.SetResponseTransform(transform)
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(&Context{Context: c}, response(), nil, buf)
+		return buf.String(), err
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		out, err := resolve(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","email":"jens@example.com"}}`, out)
+	})
+
+	t.Run("transform the data", func(t *testing.T) {
+		out, err := resolve(func(ctx *Context, data []byte) ([]byte, error) {
+			return bytes.Replace(data, []byte(`"jens@example.com"`), []byte(`"***"`), 1), nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","email":"***"}}`, out)
+	})
+
+	t.Run("reject invalid JSON", func(t *testing.T) {
+		out, err := resolve(func(ctx *Context, data []byte) ([]byte, error) {
+			return data[:len(data)-1], nil
+		})
+		assert.Equal(t, errInvalidTransformedResponse, err)
+		assert.Equal(t, ``, out)
+	})
+
+	t.Run("return the error of the transform", func(t *testing.T) {
+		transformErr := errors.New("transform failed")
+		_, err := resolve(func(ctx *Context, data []byte) ([]byte, error) {
+			return nil, transformErr
+		})
+		assert.Equal(t, transformErr, err)
+	})
+}
+
+func TestResolver_Stats(t *testing.T) {
+	response := func() *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: true,
 				Fetch: &SingleFetch{
 					BufferId:   0,
-					DataSource: FakeDataSource(`[{"id":1},{"id":2},{"id":3}]`),
+					DataSource: FakeDataSource(`{"users":[{"name":"Jens"},{"name":"Stefan"}]}`),
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("stringObject"),
-						Value: &Object{
-							Nullable: true,
-							Fields: []*Field{
-								{
-									Name: []byte("stringField"),
-									Value: &String{
-										Nullable: false,
+						Name:      []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Item: &Object{
+								Fields: []*Field{
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Path: []string{"name"},
+										},
 									},
 								},
 							},
 						},
 					},
+				},
+			},
+		}
+	}
+
+	resolve := func(r *Resolver) {
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(&Context{Context: context.Background()}, response(), nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens"},{"name":"Stefan"}]}}`, buf.String())
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := New(c)
+		resolve(r)
+		assert.Equal(t, ResolverStats{}, r.Stats())
+	})
+
+	t.Run("count gets and puts when enabled", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := New(c)
+		r.SetPoolStatsEnabled(true)
+		resolve(r)
+		resolve(r)
+
+		stats := r.Stats()
+		for name, poolStats := range map[string]PoolStats{
+			"ResultSets": stats.ResultSets,
+			"ByteSlices": stats.ByteSlices,
+			"BufPairs":   stats.BufPairs,
+		} {
+			assert.Greater(t, poolStats.Gets, uint64(0), name)
+			assert.Equal(t, poolStats.Gets, poolStats.Puts, name)
+			assert.LessOrEqual(t, poolStats.News, poolStats.Gets, name)
+		}
+
+		r.SetPoolStatsEnabled(false)
+		resolve(r)
+		assert.Equal(t, stats, r.Stats())
+	})
+}
+
+func TestResolver_MaxPooledBufferBytes(t *testing.T) {
+	inflate := func(r *Resolver) {
+		pair := r.getBufPair()
+		pair.Data.WriteBytes(make([]byte, 1<<20))
+		r.freeBufPair(pair)
+	}
+
+	t.Run("oversized buffers are discarded", func(t *testing.T) {
+		r := New(context.Background())
+		r.MaxPooledBufferBytes = 4096
+		r.SetPoolStatsEnabled(true)
+		inflate(r)
+		assert.Equal(t, uint64(1), r.Stats().BufPairs.Discards)
+		assert.Equal(t, uint64(0), r.Stats().BufPairs.Puts)
+
+		for i := 0; i < 8; i++ {
+			pair := r.getBufPair()
+			assert.LessOrEqual(t, pair.Data.Cap(), r.MaxPooledBufferBytes)
+			defer r.freeBufPair(pair)
+		}
+	})
+
+	t.Run("buffers within the limit are put back", func(t *testing.T) {
+		r := New(context.Background())
+		r.MaxPooledBufferBytes = 4096
+		r.SetPoolStatsEnabled(true)
+		pair := r.getBufPair()
+		pair.Data.WriteBytes(make([]byte, 2048))
+		r.freeBufPair(pair)
+		assert.Equal(t, uint64(0), r.Stats().BufPairs.Discards)
+		assert.Equal(t, uint64(1), r.Stats().BufPairs.Puts)
+	})
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		r := New(context.Background())
+		r.SetPoolStatsEnabled(true)
+		inflate(r)
+		assert.Equal(t, uint64(0), r.Stats().BufPairs.Discards)
+		assert.Equal(t, uint64(1), r.Stats().BufPairs.Puts)
+	})
+}
+
+func TestResolver_MaxPreparedInputBytes(t *testing.T) {
+	// both fetches prepare an input of 12 bytes
+	response := func() *GraphQLResponse {
+		fetch := func(bufferID int, data string) *SingleFetch {
+			return &SingleFetch{
+				BufferId:   bufferID,
+				DataSource: FakeDataSource(data),
+				InputTemplate: InputTemplate{
+					Segments: []TemplateSegment{
+						{
+							SegmentType: StaticSegmentType,
+							Data:        []byte(`{"id":"123"}`),
+						},
+					},
+				},
+			}
+		}
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: fetch(0, `{"user":{"name":"Jens"}}`),
+				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("integerObject"),
+						Name:      []byte("user"),
 						Value: &Object{
-							Nullable: true,
+							Path:  []string{"user"},
+							Fetch: fetch(1, `{"pet":{"name":"Woofie"}}`),
 							Fields: []*Field{
 								{
-									Name: []byte("integerField"),
-									Value: &Integer{
-										Nullable: false,
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
 									},
 								},
-							},
-						},
-					},
-					{
-						HasBuffer: true,
-						BufferID:  0,
-						Name:      []byte("floatObject"),
-						Value: &Object{
-							Nullable: true,
-							Fields: []*Field{
 								{
-									Name: []byte("floatField"),
-									Value: &Float{
-										Nullable: false,
+									HasBuffer: true,
+									BufferID:  1,
+									Name:      []byte("pet"),
+									Value: &Object{
+										Path: []string{"pet"},
+										Fields: []*Field{
+											{
+												Name: []byte("name"),
+												Value: &String{
+													Path: []string{"name"},
+												},
+											},
+										},
 									},
 								},
 							},
 						},
 					},
+				},
+			},
+		}
+	}
+
+	resolve := func(max int64) (*Context, string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx := NewContext(c)
+		ctx.SetMaxPreparedInputBytes(max)
+		buf := &bytes.Buffer{}
+		err := New(c).ResolveGraphQLResponse(ctx, response(), nil, buf)
+		return ctx, buf.String(), err
+	}
+
+	t.Run("track prepared input bytes without limit", func(t *testing.T) {
+		ctx, out, err := resolve(0)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"user":{"name":"Jens","pet":{"name":"Woofie"}}}}`, out)
+		assert.Equal(t, int64(24), ctx.PreparedInputBytes())
+	})
+
+	t.Run("resolve within the limit", func(t *testing.T) {
+		_, out, err := resolve(24)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"user":{"name":"Jens","pet":{"name":"Woofie"}}}}`, out)
+	})
+
+	t.Run("abort once the limit is exceeded", func(t *testing.T) {
+		ctx, _, err := resolve(23)
+		assert.True(t, errors.Is(err, ErrMaxPreparedInputBytesExceeded))
+		assert.Equal(t, int64(24), ctx.PreparedInputBytes())
+	})
+
+	t.Run("count each response on its own", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx := NewContext(c)
+		ctx.SetMaxPreparedInputBytes(24)
+		resolver := New(c)
+		for i := 0; i < 2; i++ {
+			err := resolver.ResolveGraphQLResponse(ctx, response(), nil, &bytes.Buffer{})
+			assert.NoError(t, err)
+			assert.Equal(t, int64(24), ctx.PreparedInputBytes())
+		}
+	})
+}
+
+func TestResolver_ResolveIntegerStrictRange(t *testing.T) {
+	object := func(nullable, strictRange bool) *Object {
+		return &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("count"),
+					Value: &Integer{
+						Path:        []string{"count"},
+						Nullable:    nullable,
+						StrictRange: strictRange,
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(node Node, data string) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		buf := NewBufPair()
+		err := New(c).resolveNode(&Context{Context: c}, node, []byte(data), buf)
+		return buf.Data.String(), err
+	}
+
+	t.Run("values at the boundaries", func(t *testing.T) {
+		for _, value := range []string{"2147483647", "-2147483648", "0", "2147483647.0", "-2.147483648e9"} {
+			out, err := resolve(object(false, true), `{"count":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"count":`+value+`}`, out)
+		}
+	})
+
+	t.Run("values outside of the range of non nullable field", func(t *testing.T) {
+		for _, value := range []string{"2147483648", "-2147483649", "9999999999", "2147483648.0", "-1e10"} {
+			_, err := resolve(object(false, true), `{"count":`+value+`}`)
+			assert.Equal(t, errNonNullableFieldValueIsNull, err, value)
+		}
+	})
+
+	t.Run("values with a fractional part", func(t *testing.T) {
+		for _, value := range []string{"1.5", "-0.1", "2.5e-1", "1e-3"} {
+			_, err := resolve(object(false, true), `{"count":`+value+`}`)
+			assert.Equal(t, errNonNullableFieldValueIsNull, err, value)
+			out, err := resolve(object(true, true), `{"count":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"count":null}`, out, value)
+		}
+	})
+
+	t.Run("values in exponent notation without a fractional part", func(t *testing.T) {
+		for _, value := range []string{"1.0", "1e2", "1.5e1"} {
+			out, err := resolve(object(false, true), `{"count":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"count":`+value+`}`, out)
+		}
+	})
+
+	t.Run("values outside of the range of nullable field", func(t *testing.T) {
+		for _, value := range []string{"2147483648", "-2147483649", "9999999999", "2147483648.0", "-1e10"} {
+			out, err := resolve(object(true, true), `{"count":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"count":null}`, out, value)
+		}
+	})
+
+	t.Run("values outside of the range without strict range", func(t *testing.T) {
+		out, err := resolve(object(false, false), `{"count":9999999999}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"count":9999999999}`, out)
+	})
+
+	t.Run("strict range enabled by feature flag", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx := &Context{Context: c, FeatureFlags: FeatureFlagStrictIntRange}
+
+		buf := NewBufPair()
+		err := New(c).resolveNode(ctx, object(true, false), []byte(`{"count":9999999999}`), buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"count":null}`, buf.Data.String())
+
+		clone := ctx.Clone()
+		assert.True(t, clone.FeatureFlags.Enabled(FeatureFlagStrictIntRange))
+		ctx.Free()
+		assert.False(t, ctx.FeatureFlags.Enabled(FeatureFlagStrictIntRange))
+	})
+}
+
+func TestResolver_ResolveFloatCanonicalize(t *testing.T) {
+	object := func(nullable bool, precision int) *Object {
+		return &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("price"),
+					Value: &Float{
+						Path:         []string{"price"},
+						Nullable:     nullable,
+						Canonicalize: true,
+						Precision:    precision,
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(node Node, data string) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		buf := NewBufPair()
+		err := New(c).resolveNode(&Context{Context: c}, node, []byte(data), buf)
+		return buf.Data.String(), err
+	}
+
+	t.Run("canonical representation", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"1e10":            "10000000000",
+			"1.5E-3":          "0.0015",
+			"-2.50e+2":        "-250",
+			"1.500":           "1.5",
+			"1.0":             "1",
+			"0.1":             "0.1",
+			"1.0000000000001": "1.0000000000001",
+		} {
+			out, err := resolve(object(false, 0), `{"price":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"price":`+expected+`}`, out, value)
+		}
+	})
+
+	t.Run("precision", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"1.0000000000001": "1",
+			"1.23456":         "1.235",
+			"1.2":             "1.2",
+			"12e-1":           "1.2",
+			"-0.0001":         "0",
+		} {
+			out, err := resolve(object(false, 3), `{"price":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"price":`+expected+`}`, out, value)
+		}
+	})
+
+	t.Run("values which are not finite", func(t *testing.T) {
+		for _, value := range []string{"1e400", "-1e400", "NaN", "Infinity"} {
+			out, err := resolve(object(true, 0), `{"price":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"price":null}`, out, value)
+
+			_, err = resolve(object(false, 0), `{"price":`+value+`}`)
+			assert.Equal(t, errNonNullableFieldValueIsNull, err, value)
+		}
+	})
+}
+
+func TestResolver_ResolveUnitConversion(t *testing.T) {
+	resolve := func(node Node, data string) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		buf := NewBufPair()
+		err := New(c).resolveNode(&Context{Context: c}, &Object{
+			Fields: []*Field{
+				{
+					Name:  []byte("amount"),
+					Value: node,
+				},
+			},
+		}, []byte(data), buf)
+		return buf.Data.String(), err
+	}
+
+	t.Run("cents to dollars", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"1999":  "19.99",
+			"100":   "1",
+			"-5":    "-0.05",
+			"0":     "0",
+			"1.5e3": "15",
+		} {
+			out, err := resolve(&Float{
+				Path:       []string{"amount"},
+				Conversion: &UnitConversion{Divisor: 100},
+				Precision:  2,
+			}, `{"amount":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"amount":`+expected+`}`, out, value)
+		}
+	})
+
+	t.Run("meters to feet", func(t *testing.T) {
+		out, err := resolve(&Float{
+			Path:       []string{"amount"},
+			Conversion: &UnitConversion{Divisor: 0.3048},
+		}, `{"amount":3.048}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"amount":10}`, out)
+	})
+
+	t.Run("rounding modes", func(t *testing.T) {
+		for _, tc := range []struct {
+			mode     RoundingMode
+			expected []string
+		}{
+			{mode: RoundingModeHalfAwayFromZero, expected: []string{"3", "4", "-3", "3"}},
+			{mode: RoundingModeHalfEven, expected: []string{"2", "4", "-2", "3"}},
+			{mode: RoundingModeTowardZero, expected: []string{"2", "3", "-2", "2"}},
+			{mode: RoundingModeFloor, expected: []string{"2", "3", "-3", "2"}},
+			{mode: RoundingModeCeiling, expected: []string{"3", "4", "-2", "3"}},
+		} {
+			for i, value := range []string{"25", "35", "-25", "27"} {
+				out, err := resolve(&Integer{
+					Path:       []string{"amount"},
+					Conversion: &UnitConversion{Divisor: 10, Rounding: tc.mode},
+				}, `{"amount":`+value+`}`)
+				assert.NoError(t, err)
+				assert.Equal(t, `{"amount":`+tc.expected[i]+`}`, out, fmt.Sprintf("mode %d, value %s", tc.mode, value))
+			}
+		}
+	})
+
+	t.Run("rounding modes with precision", func(t *testing.T) {
+		out, err := resolve(&Float{
+			Path:       []string{"amount"},
+			Conversion: &UnitConversion{Multiplier: 1, Rounding: RoundingModeHalfEven},
+			Precision:  2,
+		}, `{"amount":0.125}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"amount":0.12}`, out)
+	})
+
+	t.Run("multiplier and divisor", func(t *testing.T) {
+		out, err := resolve(&Integer{
+			Path:       []string{"amount"},
+			Conversion: &UnitConversion{Multiplier: 3, Divisor: 2},
+		}, `{"amount":5}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"amount":8}`, out)
+	})
+
+	t.Run("integer overflow", func(t *testing.T) {
+		node := &Integer{
+			Path:       []string{"amount"},
+			Nullable:   true,
+			Conversion: &UnitConversion{Multiplier: 1e10},
+		}
+		out, err := resolve(node, `{"amount":1e10}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"amount":null}`, out)
+
+		node.Nullable = false
+		_, err = resolve(node, `{"amount":1e10}`)
+		assert.Equal(t, errNonNullableFieldValueIsNull, err)
+	})
+
+	t.Run("strict range applies to the converted value", func(t *testing.T) {
+		out, err := resolve(&Integer{
+			Path:        []string{"amount"},
+			Nullable:    true,
+			StrictRange: true,
+			Conversion:  &UnitConversion{Divisor: 100},
+		}, `{"amount":300000000000}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"amount":null}`, out)
+	})
+
+	t.Run("float overflow", func(t *testing.T) {
+		for _, value := range []string{"1e300", "1e400"} {
+			out, err := resolve(&Float{
+				Path:       []string{"amount"},
+				Nullable:   true,
+				Conversion: &UnitConversion{Multiplier: 1e10},
+			}, `{"amount":`+value+`}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"amount":null}`, out, value)
+		}
+	})
+}
+
+func TestResolver_ResolveBooleanValueMapping(t *testing.T) {
+	object := func(nullable bool) *Object {
+		return &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("enabled"),
+					Value: &Boolean{
+						Path:        []string{"status"},
+						Nullable:    nullable,
+						TrueValues:  []string{"ENABLED", "ACTIVE"},
+						FalseValues: []string{"DISABLED"},
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(node Node, data string) (string, error) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		buf := NewBufPair()
+		err := New(c).resolveNode(&Context{Context: c}, node, []byte(data), buf)
+		return buf.Data.String(), err
+	}
+
+	t.Run("map configured values", func(t *testing.T) {
+		out, err := resolve(object(false), `{"status":"ENABLED"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":true}`, out)
+
+		out, err = resolve(object(false), `{"status":"ACTIVE"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":true}`, out)
+
+		out, err = resolve(object(false), `{"status":"DISABLED"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":false}`, out)
+	})
+
+	t.Run("boolean values are not mapped", func(t *testing.T) {
+		out, err := resolve(object(false), `{"status":false}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":false}`, out)
+	})
+
+	t.Run("unknown value of nullable field", func(t *testing.T) {
+		out, err := resolve(object(true), `{"status":"UNKNOWN"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":null}`, out)
+	})
+
+	t.Run("unknown value of non nullable field", func(t *testing.T) {
+		_, err := resolve(object(false), `{"status":"enabled"}`)
+		assert.True(t, errors.Is(err, errNonNullableFieldValueIsNull))
+
+		buf := NewBufPair()
+		err = New(context.Background()).resolveNode(NewContext(context.Background()), object(false), []byte(`{"status":"enabled"}`), buf)
+		assert.True(t, errors.Is(err, errNonNullableFieldValueIsNull))
+		assert.Equal(t, `{"message":"unable to coerce value to Boolean: \"enabled\"","locations":[{"line":0,"column":0}],"path":["enabled"]}`, buf.Errors.String())
+	})
+
+	t.Run("unknown value of non nullable field bubbles up to the nearest nullable parent", func(t *testing.T) {
+		response := &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"user":{"name":"Jens","status":"enabled"}}`),
+				},
+				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("booleanObject"),
+						Name:      []byte("user"),
 						Value: &Object{
+							Path:     []string{"user"},
 							Nullable: true,
 							Fields: []*Field{
 								{
-									Name: []byte("booleanField"),
+									Name: []byte("enabled"),
 									Value: &Boolean{
-										Nullable: false,
+										Path:       []string{"status"},
+										TrueValues: []string{"ENABLED"},
 									},
+									Position: Position{Line: 3, Column: 5},
 								},
 							},
 						},
@@ -992,182 +1728,708 @@ func TestResolver_ResolveGraphQLResponse(t *testing.T) {
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("objectObject"),
-						Value: &Object{
-							Nullable: true,
-							Fields: []*Field{
-								{
-									Name: []byte("objectField"),
-									Value: &Object{
-										Nullable: false,
-									},
-								},
-							},
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"user", "name"},
 						},
 					},
-					{
-						HasBuffer: true,
-						BufferID:  0,
-						Name:      []byte("arrayObject"),
-						Value: &Object{
-							Nullable: true,
-							Fields: []*Field{
-								{
-									Name: []byte("arrayField"),
-									Value: &Array{
-										Nullable: false,
-										Item: &String{
-											Nullable: false,
-											Path:     []string{"nonExisting"},
+				},
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"errors":[{"message":"unable to coerce value to Boolean: \"enabled\"","locations":[{"line":3,"column":5}],"path":["user","enabled"]}],"data":{"user":null,"name":"Jens"}}`, buf.String())
+	})
+
+	t.Run("string values without mapping", func(t *testing.T) {
+		out, err := resolve(&Object{
+			Fields: []*Field{
+				{
+					Name: []byte("enabled"),
+					Value: &Boolean{
+						Path:     []string{"status"},
+						Nullable: true,
+					},
+				},
+			},
+		}, `{"status":"ENABLED"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"enabled":null}`, out)
+	})
+}
+
+func TestResolver_WithHooks(t *testing.T) {
+	testFn := func(fn func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string)) func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := New(c)
+		node, ctx, expectedOutput := fn(t, r, ctrl)
+		return func(t *testing.T) {
+			buf := &BufPair{
+				Data:   fastbuffer.New(),
+				Errors: fastbuffer.New(),
+			}
+			err := r.resolveNode(&ctx, node, nil, buf)
+			assert.Equal(t, buf.Errors.String(), "", "want error buf to be empty")
+			assert.NoError(t, err)
+			assert.Equal(t, expectedOutput, buf.Data.String())
+			ctrl.Finish()
+		}
+	}
+	t.Run("resolve with hooks", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+
+		pathEq := func(expected string) gomock.Matcher {
+			return hookContextPathMatcher{path: expected}
+		}
+
+		beforeFetch := NewMockBeforeFetchHook(ctrl)
+		beforeFetch.EXPECT().OnBeforeFetch(pathEq("/data/user"), []byte("fakeInput")).Return()
+		afterFetch := NewMockAfterFetchHook(ctrl)
+		afterFetch.EXPECT().OnData(pathEq("/data/user"), []byte(`{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}`), false).Return()
+		return &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("data"),
+					Value: &Object{
+						Fields: []*Field{
+							{
+								Name: []byte("user"),
+								Value: &Object{
+									Fetch: &SingleFetch{
+										BufferId:   0,
+										DataSource: FakeDataSource(`{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}`),
+										InputTemplate: InputTemplate{
+											Segments: []TemplateSegment{
+												{
+													SegmentType: StaticSegmentType,
+													Data:        []byte("fakeInput"),
+												},
+											},
+										},
+									},
+									Fields: []*Field{
+										{
+											BufferID:  0,
+											HasBuffer: true,
+											Name:      []byte("id"),
+											Value: &String{
+												Path: []string{"id"},
+											},
+										},
+										{
+											BufferID:  0,
+											HasBuffer: true,
+											Name:      []byte("name"),
+											Value: &String{
+												Path: []string{"name"},
+											},
+										},
+										{
+											BufferID:  0,
+											HasBuffer: true,
+											Name:      []byte("registered"),
+											Value: &Boolean{
+												Path: []string{"registered"},
+											},
+										},
+										{
+											BufferID:  0,
+											HasBuffer: true,
+											Name:      []byte("pet"),
+											Value: &Object{
+												Path: []string{"pet"},
+												Fields: []*Field{
+													{
+														Name: []byte("name"),
+														Value: &String{
+															Path: []string{"name"},
+														},
+													},
+													{
+														Name: []byte("kind"),
+														Value: &String{
+															Path: []string{"kind"},
+														},
+													},
+												},
+											},
 										},
 									},
 								},
 							},
 						},
 					},
-					{
-						HasBuffer: true,
-						BufferID:  0,
-						Name:      []byte("asynchronousArrayObject"),
-						Value: &Object{
-							Nullable: true,
-							Fields: []*Field{
+				},
+			},
+		}, Context{Context: context.Background(), beforeFetchHook: beforeFetch, afterFetchHook: afterFetch}, `{"data":{"user":{"id":"1","name":"Jens","registered":true,"pet":{"name":"Barky","kind":"Dog"}}}}`
+	}))
+	t.Run("resolve parallel fetches with hooks", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node Node, ctx Context, expectedOutput string) {
+		pathEq := hookContextPathMatcher{path: "/data/user"}
+
+		beforeFetch := NewMockBeforeFetchHook(ctrl)
+		beforeFetch.EXPECT().OnBeforeFetch(pathEq, gomock.Any()).Times(2)
+		afterFetch := NewMockAfterFetchHook(ctrl)
+		afterFetch.EXPECT().OnData(pathEq, gomock.Any(), false).Times(2)
+		fetchComplete := NewMockFetchCompleteHook(ctrl)
+		fetchComplete.EXPECT().OnFetchComplete(pathEq, gomock.Any(), gomock.Any(), gomock.Any(), nil).Times(2)
+		return &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("user"),
+					Value: &Object{
+						Fetch: &ParallelFetch{
+							Fetches: []*SingleFetch{
 								{
-									Name: []byte("arrayField"),
-									Value: &Array{
-										Nullable:            false,
-										ResolveAsynchronous: true,
-										Item: &String{
-											Nullable: false,
-											Path:     []string{"nonExisting"},
-										},
-									},
+									BufferId:   0,
+									DataSource: FakeDataSource(`{"name":"Jens"}`),
+								},
+								{
+									BufferId:   1,
+									DataSource: FakeDataSource(`{"registered":true}`),
 								},
 							},
 						},
-					},
-					{
-						HasBuffer: true,
-						BufferID:  0,
-						Name:      []byte("nullableArray"),
-						Value: &Array{
-							Nullable: true,
-							Item: &String{
-								Nullable: false,
-								Path:     []string{"name"},
+						Fields: []*Field{
+							{
+								BufferID:  0,
+								HasBuffer: true,
+								Name:      []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+							{
+								BufferID:  1,
+								HasBuffer: true,
+								Name:      []byte("registered"),
+								Value: &Boolean{
+									Path: []string{"registered"},
+								},
 							},
 						},
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["objectObject","objectField"]}],"data":{"stringObject":null,"integerObject":null,"floatObject":null,"booleanObject":null,"objectObject":null,"arrayObject":null,"asynchronousArrayObject":null,"nullableArray":null}}`
+		}, Context{Context: context.Background(), beforeFetchHook: beforeFetch, afterFetchHook: afterFetch, fetchCompleteHook: fetchComplete}, `{"user":{"name":"Jens","registered":true}}`
 	}))
-	t.Run("empty nullable array should resolve correctly", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		return &GraphQLResponse{
+}
+
+func TestResolver_WithHooks_SingleFlight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := New(c)
+	r.EnableSingleFlightLoader = true
+
+	data := []byte(`{"name":"Jens"}`)
+	errs := []byte(`{"message":"errorMessage"}`)
+
+	followerStarted := make(chan struct{})
+	dataSource := NewMockDataSource(ctrl)
+	dataSource.EXPECT().
+		Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+		DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+			<-followerStarted
+			// give the follower time to join the inflight fetch
+			time.Sleep(time.Millisecond * 50)
+			_, err = w.Write([]byte(`{"errors":[{"message":"errorMessage"}],"data":{"name":"Jens"}}`))
+			return
+		}).
+		Times(1)
+
+	leaderHook := NewMockAfterFetchHook(ctrl)
+	leaderHook.EXPECT().OnData(gomock.Any(), data, false).Times(1)
+	leaderHook.EXPECT().OnError(gomock.Any(), errs, false).Times(1)
+
+	followerHook := NewMockAfterFetchHook(ctrl)
+	followerHook.EXPECT().OnData(gomock.Any(), data, true).Times(1)
+	followerHook.EXPECT().OnError(gomock.Any(), errs, true).Times(1)
+
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &SingleFetch{
+				BufferId:             0,
+				DataSource:           dataSource,
+				DataSourceIdentifier: []byte("user"),
+				ProcessResponseConfig: ProcessResponseConfig{
+					ExtractGraphqlResponse: true,
+				},
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("name"),
+					Value: &String{
+						Path: []string{"name"},
+					},
+				},
+			},
+		},
+	}
+
+	expected := `{"errors":[{"message":"errorMessage"}],"data":{"name":"Jens"}}`
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ctx := &Context{Context: context.Background(), afterFetchHook: leaderHook}
+		buf := &bytes.Buffer{}
+		assert.NoError(t, r.ResolveGraphQLResponse(ctx, response, nil, buf))
+		assert.Equal(t, expected, buf.String())
+	}()
+	go func() {
+		defer wg.Done()
+		// wait until the leader registered the inflight fetch
+		for {
+			r.inflightFetchMu.Lock()
+			started := len(r.inflightFetches) != 0
+			r.inflightFetchMu.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(followerStarted)
+		ctx := &Context{Context: context.Background(), afterFetchHook: followerHook}
+		buf := &bytes.Buffer{}
+		assert.NoError(t, r.ResolveGraphQLResponse(ctx, response, nil, buf))
+		assert.Equal(t, expected, buf.String())
+	}()
+	wg.Wait()
+}
+
+// blockingDataSource blocks each load until release is closed
+type blockingDataSource struct {
+	loads   int64
+	release chan struct{}
+}
+
+func (b *blockingDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	atomic.AddInt64(&b.loads, 1)
+	<-b.release
+	_, err := w.Write([]byte(`{"data":{"name":"Jens"}}`))
+	return err
+}
+
+func TestResolver_SingleFlightDisabledFor(t *testing.T) {
+	run := func(t *testing.T, dataSourceID string, expectedInflightFetches int) {
+		dataSource := &blockingDataSource{release: make(chan struct{})}
+		response := &GraphQLResponse{
 			Data: &Object{
-				Nullable: true,
 				Fetch: &SingleFetch{
-					BufferId:   0,
-					DataSource: FakeDataSource(`[]`),
+					BufferId:             0,
+					DataSource:           dataSource,
+					DataSourceIdentifier: []byte(dataSourceID),
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("nullableArray"),
-						Value: &Array{
-							Nullable: true,
-							Item: &Object{
-								Nullable: false,
-								Fields: []*Field{
-									{
-										Name: []byte("foo"),
-										Value: &String{
-											Nullable: false,
-										},
-									},
-								},
-							},
-						},
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"data":{"nullableArray":[]}}`
-	}))
-	t.Run("empty not nullable array should resolve correctly", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		}
+
+		r := New(context.Background())
+		r.EnableSingleFlightLoader = true
+		r.SingleFlightDisabledFor = func(dataSourceIdentifier []byte) bool {
+			return bytes.HasPrefix(dataSourceIdentifier, []byte("auth"))
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			buf := &bytes.Buffer{}
+			assert.NoError(t, r.ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf))
+			assert.Equal(t, `{"data":{"name":"Jens"}}`, buf.String())
+		}()
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt64(&dataSource.loads) == 1
+		}, time.Second, time.Millisecond)
+
+		r.inflightFetchMu.Lock()
+		inflightFetches := len(r.inflightFetches)
+		r.inflightFetchMu.Unlock()
+		assert.Equal(t, expectedInflightFetches, inflightFetches)
+
+		close(dataSource.release)
+		<-done
+	}
+
+	t.Run("disabled for matching data sources", func(t *testing.T) {
+		run(t, "auth_tokens", 0)
+	})
+
+	t.Run("enabled for other data sources", func(t *testing.T) {
+		run(t, "users", 1)
+	})
+}
+
+func TestResolver_FallbackDataSource(t *testing.T) {
+	response := func(dataSource, fallback DataSource) *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: false,
 				Fetch: &SingleFetch{
-					BufferId:   0,
-					DataSource: FakeDataSource(`[]`),
+					BufferId:             0,
+					DataSource:           dataSource,
+					FallbackDataSource:   fallback,
+					DataSourceIdentifier: []byte("user"),
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("notNullableArray"),
-						Value: &Array{
-							Nullable: false,
-							Item: &Object{
-								Nullable: false,
-								Fields: []*Field{
-									{
-										Name: []byte("foo"),
-										Value: &String{
-											Nullable: false,
-										},
-									},
-								},
-							},
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
 						},
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"data":{"notNullableArray":[]}}`
-	}))
-	t.Run("when data null not nullable array should resolve to data null and errors", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		}
+	}
+
+	failingDataSource := func(ctrl *gomock.Controller, message string) *MockDataSource {
+		dataSource := NewMockDataSource(ctrl)
+		dataSource.EXPECT().
+			Load(gomock.Any(), []byte("input"), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) error {
+				_, _ = w.Write([]byte(`partial`))
+				return errors.New(message)
+			})
+		return dataSource
+	}
+
+	resolve := func(r *Resolver, hook AfterFetchHook, response *GraphQLResponse) (string, error) {
+		response.Data.(*Object).Fetch.(*SingleFetch).InputTemplate = InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte("input"),
+				},
+			},
+		}
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(&Context{Context: context.Background(), afterFetchHook: hook}, response, nil, buf)
+		return buf.String(), err
+	}
+
+	t.Run("load the fallback if the primary fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		hook := NewMockAfterFetchHook(ctrl)
+		hook.EXPECT().OnData(hookContextFallbackMatcher{fallback: true}, []byte(`{"name":"Jens"}`), false)
+
+		out, err := resolve(New(context.Background()), hook, response(failingDataSource(ctrl, "primary unavailable"), FakeDataSource(`{"data":{"name":"Jens"}}`)))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, out)
+	})
+
+	t.Run("don't load the fallback if the primary succeeds", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fallback := NewMockDataSource(ctrl)
+		fallback.EXPECT().Load(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+		hook := NewMockAfterFetchHook(ctrl)
+		hook.EXPECT().OnData(hookContextFallbackMatcher{fallback: false}, []byte(`{"name":"Jens"}`), false)
+
+		out, err := resolve(New(context.Background()), hook, response(FakeDataSource(`{"data":{"name":"Jens"}}`), fallback))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, out)
+	})
+
+	t.Run("mark the fallback response for single flight fetches", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r := New(context.Background())
+		r.EnableSingleFlightLoader = true
+		hook := NewMockAfterFetchHook(ctrl)
+		hook.EXPECT().OnData(hookContextFallbackMatcher{fallback: true}, []byte(`{"name":"Jens"}`), false)
+
+		out, err := resolve(r, hook, response(failingDataSource(ctrl, "primary unavailable"), FakeDataSource(`{"data":{"name":"Jens"}}`)))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, out)
+	})
+
+	t.Run("return the error of the fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		_, err := resolve(New(context.Background()), nil, response(failingDataSource(ctrl, "primary unavailable"), failingDataSource(ctrl, "fallback unavailable")))
+		assert.EqualError(t, err, "fallback unavailable")
+	})
+}
+
+func TestResolver_FetchCompleteHook(t *testing.T) {
+	const upstreamResponse = `{"data":{"name":"Jens"}}`
+
+	response := func(dataSource DataSource) *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: false,
 				Fetch: &SingleFetch{
-					BufferId:              0,
-					DataSource:            FakeDataSource(`{"data":null}`),
-					ProcessResponseConfig: ProcessResponseConfig{ExtractGraphqlResponse: true},
+					BufferId:             0,
+					DataSource:           dataSource,
+					DataSourceIdentifier: []byte("user"),
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("nonNullArray"),
-						Value: &Array{
-							Nullable: false,
-							Item: &Object{
-								Nullable: false,
-								Fields: []*Field{
-									{
-										Name: []byte("foo"),
-										Value: &String{
-											Nullable: false,
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	slowDataSource := func(ctrl *gomock.Controller, release <-chan struct{}, err error) *MockDataSource {
+		dataSource := NewMockDataSource(ctrl)
+		dataSource.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) error {
+				if release != nil {
+					<-release
+				}
+				time.Sleep(time.Millisecond)
+				_, _ = w.Write([]byte(upstreamResponse))
+				return err
+			}).
+			Times(1)
+		return dataSource
+	}
+
+	expectFetchComplete := func(hook *MockFetchCompleteHook, expectedErr error) {
+		hook.EXPECT().
+			OnFetchComplete(hookContextPathMatcher{path: "/data"}, []byte("user"), gomock.Any(), len(upstreamResponse), expectedErr).
+			Do(func(ctx HookContext, dataSourceID []byte, duration time.Duration, responseSize int, err error) {
+				assert.Greater(t, int64(duration), int64(0))
+			}).
+			Times(1)
+	}
+
+	t.Run("report duration and response size", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		hook := NewMockFetchCompleteHook(ctrl)
+		expectFetchComplete(hook, nil)
+
+		ctx := &Context{Context: context.Background(), fetchCompleteHook: hook}
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(slowDataSource(ctrl, nil, nil)), nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, upstreamResponse, buf.String())
+	})
+
+	t.Run("report the error of the data source", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		loadErr := errors.New("upstream unavailable")
+		hook := NewMockFetchCompleteHook(ctrl)
+		expectFetchComplete(hook, loadErr)
+
+		ctx := &Context{Context: context.Background(), fetchCompleteHook: hook}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(slowDataSource(ctrl, nil, loadErr)), nil, &bytes.Buffer{})
+		assert.Equal(t, loadErr, err)
+	})
+
+	t.Run("report single flight fetches only for the leader", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r := New(context.Background())
+		r.EnableSingleFlightLoader = true
+
+		followerStarted := make(chan struct{})
+		res := response(slowDataSource(ctrl, followerStarted, nil))
+
+		hook := NewMockFetchCompleteHook(ctrl)
+		expectFetchComplete(hook, nil)
+
+		wg := &sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ctx := &Context{Context: context.Background(), fetchCompleteHook: hook}
+			assert.NoError(t, r.ResolveGraphQLResponse(ctx, res, nil, &bytes.Buffer{}))
+		}()
+		go func() {
+			defer wg.Done()
+			// wait until the leader registered the inflight fetch
+			for {
+				r.inflightFetchMu.Lock()
+				started := len(r.inflightFetches) != 0
+				r.inflightFetchMu.Unlock()
+				if started {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			close(followerStarted)
+			ctx := &Context{Context: context.Background(), fetchCompleteHook: hook}
+			assert.NoError(t, r.ResolveGraphQLResponse(ctx, res, nil, &bytes.Buffer{}))
+		}()
+		wg.Wait()
+	})
+}
+
+type mapFieldCache map[string]string
+
+func (m mapFieldCache) Get(ctx HookContext) ([]byte, bool) {
+	value, ok := m[string(ctx.CurrentPath)]
+	return []byte(value), ok
+}
+
+func TestResolver_CollectAllErrors(t *testing.T) {
+	scores := func(name string, asynchronous bool) *Field {
+		return &Field{
+			Name: []byte(name),
+			Value: &Array{
+				Path:                []string{"scores"},
+				ResolveAsynchronous: asynchronous,
+				Item:                &Integer{},
+			},
+		}
+	}
+	response := func() *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"email":"jens@example.com","scores":[1,null],"address":{"city":"Berlin"}}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("user"),
+						Value: &Object{
+							Fields: []*Field{
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
+								},
+								{
+									Name: []byte("email"),
+									Value: &String{
+										Path: []string{"email"},
+									},
+								},
+								scores("scores", false),
+								scores("asyncScores", true),
+								{
+									Name: []byte("address"),
+									Value: &Object{
+										Path: []string{"address"},
+										Fields: []*Field{
+											{
+												Name: []byte("city"),
+												Value: &String{
+													Path: []string{"city"},
+												},
+											},
+											{
+												Name: []byte("zip"),
+												Value: &String{
+													Path: []string{"zip"},
+												},
+											},
 										},
 									},
 								},
 							},
 						},
 					},
+				},
+			},
+		}
+	}
+	resolve := func(collectAllErrors bool) string {
+		ctx := &Context{Context: context.Background()}
+		ctx.SetCollectAllErrors(collectAllErrors)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(), nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("abort on the first error by default", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user"]}],"data":null}`, resolve(false))
+	})
+
+	t.Run("collect all errors", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[`+
+			`{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","name"]},`+
+			`{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","scores",1]},`+
+			`{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","asyncScores",1]},`+
+			`{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","address","zip"]}],`+
+			`"data":{"user":{"name":null,"email":"jens@example.com","scores":[1,null],"asyncScores":[1,null],"address":{"city":"Berlin","zip":null}}}}`,
+			resolve(true))
+	})
+}
+
+func TestResolver_ArrayMaxItems(t *testing.T) {
+	response := func(asynchronous bool) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"users":[{"name":"Jens","tags":["a","b","c"]},{"tags":["d"]},{"name":"Stefan"},{"name":"Max"}]}`),
+				},
+				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("nullableArray"),
+						Name:      []byte("users"),
 						Value: &Array{
-							Nullable: true,
+							Path:                []string{"users"},
+							ResolveAsynchronous: asynchronous,
+							MaxItems:            2,
 							Item: &Object{
-								Nullable: false,
+								Nullable: true,
 								Fields: []*Field{
 									{
-										Name: []byte("foo"),
+										Name: []byte("name"),
 										Value: &String{
-											Nullable: false,
+											Path: []string{"name"},
+										},
+									},
+									{
+										Name: []byte("tags"),
+										Value: &Array{
+											Path:     []string{"tags"},
+											Nullable: true,
+											MaxItems: 1,
+											Item:     &String{},
 										},
 									},
 								},
@@ -1176,36 +2438,62 @@ func TestResolver_ResolveGraphQLResponse(t *testing.T) {
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}]}],"data":null}`
-	}))
-	t.Run("when data null and errors present not nullable array should result to null data upsteam error and resolve error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		}
+	}
+	resolve := func(plan *GraphQLResponse, collectAllErrors bool) string {
+		ctx := &Context{Context: context.Background()}
+		ctx.SetCollectAllErrors(collectAllErrors)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, plan, nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+	extensions := `"extensions":{"truncatedLists":[{"path":["users"],"count":4,"maxItems":2},{"path":["users",0,"tags"],"count":3,"maxItems":1}]}`
+
+	t.Run("synchronous", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens","tags":["a"]},null]},`+extensions+`}`, resolve(response(false), false))
+	})
+
+	t.Run("asynchronous", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens","tags":["a"]},null]},`+extensions+`}`, resolve(response(true), false))
+	})
+
+	t.Run("error paths of the retained items", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["users",1,"name"]}],`+
+			`"data":{"users":[{"name":"Jens","tags":["a"]},{"name":null,"tags":["d"]}]},`+extensions+`}`, resolve(response(false), true))
+	})
+
+	t.Run("no extensions without truncation", func(t *testing.T) {
+		plan := response(false)
+		users := plan.Data.(*Object).Fields[0].Value.(*Array)
+		users.MaxItems = 4
+		users.Item.(*Object).Fields[1].Value.(*Array).MaxItems = 0
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens","tags":["a","b","c"]},null,{"name":"Stefan","tags":null},{"name":"Max","tags":null}]}}`, resolve(plan, false))
+	})
+}
+
+func TestResolver_MaxResponseBytes(t *testing.T) {
+	response := func(asynchronous bool) *GraphQLResponse {
 		return &GraphQLResponse{
 			Data: &Object{
-				Nullable: false,
 				Fetch: &SingleFetch{
-					BufferId: 0,
-					DataSource: FakeDataSource(
-						`{"errors":[{"message":"Could not get a name","locations":[{"line":3,"column":5}],"path":["todos",0,"name"]}],"data":null}`),
-					ProcessResponseConfig: ProcessResponseConfig{ExtractGraphqlResponse: true},
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"users":[{"name":"Jens"},{"name":"Stefan"},{"name":"Max"},{"name":"Sergiy"}]}`),
 				},
 				Fields: []*Field{
 					{
 						HasBuffer: true,
 						BufferID:  0,
-						Name:      []byte("todos"),
+						Name:      []byte("users"),
 						Value: &Array{
-							Nullable: false,
+							Path:                []string{"users"},
+							ResolveAsynchronous: asynchronous,
 							Item: &Object{
-								Nullable: false,
 								Fields: []*Field{
 									{
 										Name: []byte("name"),
 										Value: &String{
-											Nullable: false,
-										},
-										Position: Position{
-											Line:   100,
-											Column: 777,
+											Path: []string{"name"},
 										},
 									},
 								},
@@ -1214,410 +2502,2542 @@ func TestResolver_ResolveGraphQLResponse(t *testing.T) {
 					},
 				},
 			},
-		}, Context{Context: context.Background()}, `{"errors":[{"message":"Could not get a name","locations":[{"line":3,"column":5}],"path":["todos",0,"name"]},{"message":"unable to resolve","locations":[{"line":0,"column":0}]}],"data":null}`
-	}))
-	t.Run("complex GraphQL Server plan", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		r.EnableSingleFlightLoader = true
-		serviceOne := NewMockDataSource(ctrl)
-		serviceOne.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				expected := `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":123,"firstArg":"firstArgValue"}}}`
-				assert.Equal(t, expected, actual)
-				_, err = w.Write([]byte(`{"serviceOne":{"fieldOne":"fieldOneValue"},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}`))
-				return
-			}).
-			Return(nil)
-
-		serviceTwo := NewMockDataSource(ctrl)
-		serviceTwo.EXPECT().
+		}
+	}
+	resolve := func(maxResponseBytes int, asynchronous bool) (string, error) {
+		resolver := New(context.Background())
+		resolver.MaxResponseBytes = maxResponseBytes
+		buf := &bytes.Buffer{}
+		err := resolver.ResolveGraphQLResponse(NewContext(context.Background()), response(asynchronous), nil, buf)
+		return buf.String(), err
+	}
+	expected := `{"data":{"users":[{"name":"Jens"},{"name":"Stefan"},{"name":"Max"},{"name":"Sergiy"}]}}`
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		out, err := resolve(0, false)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("within the limit", func(t *testing.T) {
+		out, err := resolve(len(expected), false)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("array overflows the limit", func(t *testing.T) {
+		out, err := resolve(40, false)
+		assert.True(t, errors.Is(err, ErrMaxResponseBytesExceeded))
+		assert.EqualError(t, err, "response exceeds maximum size of 40 bytes")
+		assert.Equal(t, "", out)
+	})
+
+	t.Run("asynchronous array overflows the limit", func(t *testing.T) {
+		_, err := resolve(40, true)
+		assert.True(t, errors.Is(err, ErrMaxResponseBytesExceeded))
+	})
+}
+
+// cancelingDataSource cancels the context of the request once its data is loaded, like a client disconnecting during resolving
+type cancelingDataSource struct {
+	cancel context.CancelFunc
+	data   []byte
+}
+
+func (c *cancelingDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	_, err := w.Write(c.data)
+	c.cancel()
+	return err
+}
+
+func TestResolver_CancellationDuringResolving(t *testing.T) {
+	users := &bytes.Buffer{}
+	users.WriteString(`{"users":[`)
+	for i := 0; i < 100000; i++ {
+		if i != 0 {
+			users.WriteString(",")
+		}
+		users.WriteString(`{"name":"Jens","tags":["a","b"]}`)
+	}
+	users.WriteString(`]}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: &cancelingDataSource{cancel: cancel, data: users.Bytes()},
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("users"),
+					Value: &Array{
+						Path: []string{"users"},
+						Item: &Object{
+							Fields: []*Field{
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
+								},
+								{
+									Name: []byte("tags"),
+									Value: &Array{
+										Path: []string{"tags"},
+										Item: &String{},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	resolver := New(context.Background())
+	buf := &bytes.Buffer{}
+	resolveCtx := NewContext(ctx)
+	err := resolver.ResolveGraphQLResponse(resolveCtx, response, nil, buf)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, "", buf.String())
+	// the context is checked every cancellationCheckInterval items and fields, so only the first items are resolved
+	assert.LessOrEqual(t, resolveCtx.cancellationChecks, cancellationCheckInterval)
+}
+
+func TestResolver_FieldCache(t *testing.T) {
+	fetch := func(bufferID int, dataSource DataSource) *SingleFetch {
+		return &SingleFetch{
+			BufferId:   bufferID,
+			DataSource: dataSource,
+			InputTemplate: InputTemplate{
+				Segments: []TemplateSegment{
+					{
+						SegmentType: StaticSegmentType,
+						Data:        []byte(fmt.Sprintf(`{"buffer":%d}`, bufferID)),
+					},
+				},
+			},
+		}
+	}
+	field := func(bufferID int, name string) *Field {
+		return &Field{
+			HasBuffer: true,
+			BufferID:  bufferID,
+			Name:      []byte(name),
+			Value: &String{
+				Path: []string{name},
+			},
+		}
+	}
+	expectLoad := func(dataSource *MockDataSource, response string) {
+		dataSource.EXPECT().
 			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				expected := `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":12.34,"secondArg":true}}}`
-				assert.Equal(t, expected, actual)
-				_, err = w.Write([]byte(`{"serviceTwo":{"fieldTwo":"fieldTwoValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"}}`))
-				return
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) error {
+				_, err := w.Write([]byte(response))
+				return err
 			}).
-			Return(nil)
+			Times(1)
+	}
+	resolve := func(cache FieldCache, response *GraphQLResponse) (string, error) {
+		ctx := &Context{Context: context.Background()}
+		ctx.SetFieldCache(cache)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response, nil, buf)
+		return buf.String(), err
+	}
+
+	t.Run("skip the fetch if all fields are cached", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		out, err := resolve(mapFieldCache{
+			"/data/name":  `"Jens"`,
+			"/data/email": `"jens@example.com"`,
+		}, &GraphQLResponse{
+			Data: &Object{
+				Fetch:  fetch(0, NewMockDataSource(ctrl)),
+				Fields: []*Field{field(0, "name"), field(0, "email")},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","email":"jens@example.com"}}`, out)
+	})
+
+	t.Run("load the fetch on a partial hit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dataSource := NewMockDataSource(ctrl)
+		expectLoad(dataSource, `{"name":"Upstream","email":"jens@example.com"}`)
+
+		out, err := resolve(mapFieldCache{
+			"/data/name": `"Jens"`,
+		}, &GraphQLResponse{
+			Data: &Object{
+				Fetch:  fetch(0, dataSource),
+				Fields: []*Field{field(0, "name"), field(0, "email")},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","email":"jens@example.com"}}`, out)
+	})
+
+	t.Run("load only the parallel fetches of uncached fields", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		reviews := NewMockDataSource(ctrl)
+		expectLoad(reviews, `{"rating":"5"}`)
+
+		out, err := resolve(mapFieldCache{
+			"/data/name": `"Jens"`,
+		}, &GraphQLResponse{
+			Data: &Object{
+				Fetch: &ParallelFetch{
+					Fetches: []*SingleFetch{
+						fetch(0, NewMockDataSource(ctrl)),
+						fetch(1, reviews),
+					},
+				},
+				Fields: []*Field{field(0, "name"), field(1, "rating")},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","rating":"5"}}`, out)
+	})
+
+	t.Run("look up fields of array items by their path", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		users := NewMockDataSource(ctrl)
+		expectLoad(users, `{"users":[{"id":"1"},{"id":"2"}]}`)
+		names := NewMockDataSource(ctrl)
+		expectLoad(names, `{"name":"Stefan"}`)
+
+		out, err := resolve(mapFieldCache{
+			"/data/users/0/name": `"Jens"`,
+		}, &GraphQLResponse{
+			Data: &Object{
+				Fetch: fetch(0, users),
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Item: &Object{
+								Fetch: &SingleFetch{
+									BufferId:   1,
+									DataSource: names,
+									InputTemplate: InputTemplate{
+										Segments: []TemplateSegment{
+											{
+												SegmentType:        VariableSegmentType,
+												VariableSource:     VariableSourceObject,
+												VariableSourcePath: []string{"id"},
+											},
+										},
+									},
+								},
+								Fields: []*Field{field(1, "name")},
+							},
+						},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"users":[{"name":"Jens"},{"name":"Stefan"}]}}`, out)
+	})
+
+	t.Run("don't look up fields depending on the upstream data", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dataSource := NewMockDataSource(ctrl)
+		expectLoad(dataSource, `{"__typename":"User","name":"Upstream"}`)
+
+		name := field(0, "name")
+		name.OnTypeName = []byte("User")
+		out, err := resolve(mapFieldCache{
+			"/data/name": `"Jens"`,
+		}, &GraphQLResponse{
+			Data: &Object{
+				Fetch:  fetch(0, dataSource),
+				Fields: []*Field{name},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Upstream"}}`, out)
+	})
+}
+
+func TestResolver_FetchInputRewriteHook(t *testing.T) {
+	fetch := func(bufferID int, dataSource DataSource) *SingleFetch {
+		return &SingleFetch{
+			BufferId:             bufferID,
+			DataSource:           dataSource,
+			DataSourceIdentifier: []byte("user"),
+			InputTemplate: InputTemplate{
+				Segments: []TemplateSegment{
+					{
+						SegmentType: StaticSegmentType,
+						Data:        []byte(`{"query":"{me{name}}"}`),
+					},
+				},
+			},
+		}
+	}
+	field := func(bufferID int, name string) *Field {
+		return &Field{
+			HasBuffer: true,
+			BufferID:  bufferID,
+			Name:      []byte(name),
+			Value: &String{
+				Path: []string{"name"},
+			},
+		}
+	}
+	expectLoad := func(dataSource *MockDataSource, input string) {
+		dataSource.EXPECT().
+			Load(gomock.Any(), []byte(input), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) error {
+				_, err := w.Write([]byte(`{"name":"Jens"}`))
+				return err
+			}).
+			Times(1)
+	}
+
+	t.Run("load the rewritten input", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dataSource := NewMockDataSource(ctrl)
+		expectLoad(dataSource, `{"query":"{me{name}}","signature":"abc"}`)
+
+		hook := NewMockFetchInputRewriteHook(ctrl)
+		hook.EXPECT().
+			RewriteFetchInput(hookContextPathMatcher{path: "/data"}, []byte("user"), []byte(`{"query":"{me{name}}"}`)).
+			DoAndReturn(func(ctx HookContext, dataSourceID, input []byte) ([]byte, error) {
+				// the hook may re-use the memory of the input
+				return append(input[:len(input)-1], `,"signature":"abc"}`...), nil
+			}).
+			Times(1)
+
+		ctx := &Context{Context: context.Background()}
+		ctx.SetFetchInputRewriteHook(hook)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, &GraphQLResponse{
+			Data: &Object{
+				Fetch:  fetch(0, dataSource),
+				Fields: []*Field{field(0, "name")},
+			},
+		}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, buf.String())
+	})
+
+	t.Run("deduplicate fetches by the rewritten input", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dataSource := NewMockDataSource(ctrl)
+		expectLoad(dataSource, `{"signature":"1"}`)
+		expectLoad(dataSource, `{"signature":"2"}`)
+
+		signatures := 0
+		hook := NewMockFetchInputRewriteHook(ctrl)
+		hook.EXPECT().
+			RewriteFetchInput(gomock.Any(), []byte("user"), []byte(`{"query":"{me{name}}"}`)).
+			DoAndReturn(func(ctx HookContext, dataSourceID, input []byte) ([]byte, error) {
+				signatures++
+				return []byte(fmt.Sprintf(`{"signature":"%d"}`, signatures)), nil
+			}).
+			Times(2)
+
+		r := New(context.Background())
+		r.EnableSingleFlightLoader = true
+		ctx := &Context{Context: context.Background()}
+		ctx.SetFetchInputRewriteHook(hook)
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(ctx, &GraphQLResponse{
+			Data: &Object{
+				Fetch: &ParallelFetch{
+					Fetches: []*SingleFetch{fetch(0, dataSource), fetch(1, dataSource)},
+				},
+				Fields: []*Field{field(0, "name"), field(1, "nickname")},
+			},
+		}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens","nickname":"Jens"}}`, buf.String())
+	})
+
+	t.Run("fail the fetch if the rewrite fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		rewriteErr := errors.New("unable to sign")
+		hook := NewMockFetchInputRewriteHook(ctrl)
+		hook.EXPECT().
+			RewriteFetchInput(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, rewriteErr).
+			Times(1)
+
+		ctx := &Context{Context: context.Background()}
+		ctx.SetFetchInputRewriteHook(hook)
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, &GraphQLResponse{
+			Data: &Object{
+				Fetch:  fetch(0, NewMockDataSource(ctrl)),
+				Fields: []*Field{field(0, "name")},
+			},
+		}, nil, &bytes.Buffer{})
+		assert.Equal(t, rewriteErr, err)
+	})
+}
+
+func TestResolver_BeforeFetchResponseHook(t *testing.T) {
+	response := func(dataSource DataSource) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:             0,
+					DataSource:           dataSource,
+					DataSourceIdentifier: []byte("user"),
+					InputTemplate: InputTemplate{
+						Segments: []TemplateSegment{
+							{
+								SegmentType: StaticSegmentType,
+								Data:        []byte(`{"query":"{me{name}}"}`),
+							},
+						},
+					},
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("respond from the hook without loading the data source", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		hook := NewMockBeforeFetchResponseHook(ctrl)
+		hook.EXPECT().
+			OnBeforeFetch(hookContextPathMatcher{path: "/data"}, []byte(`{"query":"{me{name}}"}`)).
+			Times(1)
+		hook.EXPECT().
+			OnBeforeFetchResponse(hookContextPathMatcher{path: "/data"}, []byte(`{"query":"{me{name}}"}`)).
+			Return([]byte(`{"data":{"name":"Cached"}}`), true).
+			Times(1)
+		afterFetchHook := NewMockAfterFetchHook(ctrl)
+
+		ctx := &Context{Context: context.Background()}
+		ctx.SetBeforeFetchHook(hook)
+		ctx.SetAfterFetchHook(afterFetchHook)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(NewMockDataSource(ctrl)), nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Cached"}}`, buf.String())
+	})
+
+	for _, singleFlight := range []bool{false, true} {
+		t.Run(fmt.Sprintf("load the data source if the hook doesn't handle the fetch with single flight %t", singleFlight), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			hook := NewMockBeforeFetchResponseHook(ctrl)
+			hook.EXPECT().
+				OnBeforeFetch(gomock.Any(), gomock.Any()).
+				Times(1)
+			hook.EXPECT().
+				OnBeforeFetchResponse(gomock.Any(), gomock.Any()).
+				Return(nil, false).
+				Times(1)
+
+			resolver := New(context.Background())
+			resolver.EnableSingleFlightLoader = singleFlight
+			ctx := &Context{Context: context.Background()}
+			ctx.SetBeforeFetchHook(hook)
+			buf := &bytes.Buffer{}
+			err := resolver.ResolveGraphQLResponse(ctx, response(FakeDataSource(`{"data":{"name":"Jens"}}`)), nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"data":{"name":"Jens"}}`, buf.String())
+		})
+	}
+
+	t.Run("before fetch hooks keep working", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		hook := NewMockBeforeFetchHook(ctrl)
+		hook.EXPECT().
+			OnBeforeFetch(gomock.Any(), []byte(`{"query":"{me{name}}"}`)).
+			Times(1)
+
+		ctx := &Context{Context: context.Background()}
+		ctx.SetBeforeFetchHook(hook)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(FakeDataSource(`{"data":{"name":"Jens"}}`)), nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, buf.String())
+	})
+}
+
+func TestResolver_FetchTimeout(t *testing.T) {
+	response := func(enrichment DataSource, timeout time.Duration) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &ParallelFetch{
+					Fetches: []*SingleFetch{
+						{
+							BufferId:             0,
+							DataSource:           FakeDataSource(`{"name":"trilby"}`),
+							DataSourceIdentifier: []byte("products"),
+						},
+						{
+							BufferId:             1,
+							DataSource:           enrichment,
+							DataSourceIdentifier: []byte("ratings"),
+							Timeout:              timeout,
+						},
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
+					},
+					{
+						HasBuffer:      true,
+						BufferID:       1,
+						Name:           []byte("rating"),
+						TimeoutDefault: []byte(`0`),
+						Value: &Integer{
+							Path: []string{"rating"},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  1,
+						Name:      []byte("reviews"),
+						Value: &Integer{
+							Path:     []string{"reviews"},
+							Nullable: true,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(t *testing.T, r *Resolver, response *GraphQLResponse) string {
+		buf := &bytes.Buffer{}
+		err := r.ResolveGraphQLResponse(&Context{Context: context.Background()}, response, nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("resolve the fetch within the timeout", func(t *testing.T) {
+		out := resolve(t, New(context.Background()), response(&_slowDataSource{data: `{"rating":5,"reviews":12}`}, time.Second))
+		assert.Equal(t, `{"data":{"name":"trilby","rating":5,"reviews":12}}`, out)
+	})
+
+	t.Run("resolve defaults if the fetch exceeds the timeout", func(t *testing.T) {
+		out := resolve(t, New(context.Background()), response(&_slowDataSource{data: `{"rating":5,"reviews":12}`, delay: time.Second}, time.Millisecond))
+		assert.Equal(t, `{"errors":[{"message":"fetch timed out","path":[]}],"data":{"name":"trilby","rating":0,"reviews":null}}`, out)
+	})
+
+	t.Run("resolve defaults for single flight fetches", func(t *testing.T) {
+		r := New(context.Background())
+		r.EnableSingleFlightLoader = true
+		out := resolve(t, r, response(&_slowDataSource{data: `{"rating":5,"reviews":12}`, delay: time.Second}, time.Millisecond))
+		assert.Equal(t, `{"errors":[{"message":"fetch timed out","path":[]}],"data":{"name":"trilby","rating":0,"reviews":null}}`, out)
+	})
+
+	t.Run("report timeouts to the after fetch hook", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		afterFetch := NewMockAfterFetchHook(ctrl)
+		afterFetch.EXPECT().OnData(gomock.Any(), []byte(`{"name":"trilby"}`), false)
+		afterFetch.EXPECT().OnError(gomock.Any(), []byte(`{"message":"fetch timed out","path":[]}`), false)
+
+		ctx := NewContext(context.Background())
+		ctx.SetAfterFetchHook(afterFetch)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response(&_slowDataSource{data: `{"rating":5}`, delay: time.Second}, time.Millisecond), nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"errors":[{"message":"fetch timed out","path":[]}],"data":{"name":"trilby","rating":0,"reviews":null}}`, buf.String())
+	})
+
+	t.Run("fail on errors of the data source", func(t *testing.T) {
+		res := response(&_slowDataSource{err: errors.New("unavailable")}, time.Second)
+		res.Data.(*Object).Fetch = res.Data.(*Object).Fetch.(*ParallelFetch).Fetches[1]
+		err := New(context.Background()).ResolveGraphQLResponse(&Context{Context: context.Background()}, res, nil, &bytes.Buffer{})
+		assert.EqualError(t, err, "unavailable")
+	})
+}
+
+// _slowDataSource responds with data after delay unless the context is done before
+type _slowDataSource struct {
+	data  string
+	delay time.Duration
+	err   error
+}
+
+func (s *_slowDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.delay):
+	}
+	if s.err != nil {
+		return s.err
+	}
+	_, err = w.Write([]byte(s.data))
+	return
+}
+
+func TestResolver_ResolveGraphQLResponse(t *testing.T) {
+	testFn := func(fn func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string)) func(t *testing.T) {
+		t.Helper()
+
+		ctrl := gomock.NewController(t)
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := New(c)
+		node, ctx, expectedOutput := fn(t, r, ctrl)
+		return func(t *testing.T) {
+			t.Helper()
+
+			buf := &bytes.Buffer{}
+			err := r.ResolveGraphQLResponse(&ctx, node, nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedOutput, buf.String())
+			ctrl.Finish()
+		}
+	}
+	t.Run("empty graphql response for nullable object", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: true,
+			},
+		}, Context{Context: context.Background()}, `{"data":null}`
+	}))
+	t.Run("empty graphql response for not nullable query field", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fields: []*Field{
+					{
+						BufferID:  0,
+						HasBuffer: true,
+						Name:      []byte("country"),
+						Position: Position{
+							Line:   3,
+							Column: 4,
+						},
+						Value: &Object{
+							Nullable: false,
+							Path:     []string{"country"},
+							Fields: []*Field{
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Nullable: true,
+										Path:     []string{"name"},
+									},
+									Position: Position{
+										Line:   4,
+										Column: 5,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":3,"column":4}],"path":["country"]}],"data":null}`
+	}))
+	t.Run("optional chaining bubbles up non-nullable fields of absent intermediate objects", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"name":"Jens"}`),
+				},
+				Fields: []*Field{
+					{
+						BufferID:  0,
+						HasBuffer: true,
+						Name:      []byte("address"),
+						Value: &Object{
+							Nullable:         true,
+							Path:             []string{"address"},
+							OptionalChaining: true,
+							Fields: []*Field{
+								{
+									Name: []byte("city"),
+									Value: &String{
+										Path: []string{"city"},
+									},
+									Position: Position{
+										Line:   3,
+										Column: 5,
+									},
+								},
+							},
+						},
+						Position: Position{
+							Line:   2,
+							Column: 3,
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"data":{"address":null}}`
+	}))
+	t.Run("fetch with simple error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		r.EnableSingleFlightLoader = true
+		mockDataSource := NewMockDataSource(ctrl)
+		mockDataSource.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				pair := NewBufPair()
+				pair.WriteErr([]byte("errorMessage"), nil, nil, nil)
+				return writeGraphqlResponse(pair, w, false)
+			})
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: mockDataSource,
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path:     []string{"name"},
+							Nullable: true,
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage"}],"data":{"name":null}}`
+	}))
+	t.Run("errors only mode with fetch error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"errors":[{"message":"errorMessage"}],"data":{"name":"Jens"}}`),
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path:     []string{"name"},
+							Nullable: true,
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background(), resolveOptions: resolveOptions{errorsOnly: true}}, `{"errors":[{"message":"errorMessage"}],"data":null}`
+	}))
+	t.Run("errors only mode without errors", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"name":"Jens"}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path: []string{"name"},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background(), resolveOptions: resolveOptions{errorsOnly: true}}, `{"data":null}`
+	}))
+	t.Run("errors only mode doesn't write data", func(t *testing.T) {
+		object := &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"user":{"name":"Jens","pets":[{"name":"Woofie"},{}]}}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("user"),
+					Value: &Object{
+						Path: []string{"user"},
+						Fields: []*Field{
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+							{
+								Name: []byte("pets"),
+								Value: &Array{
+									Path:     []string{"pets"},
+									Nullable: true,
+									Item: &Object{
+										Fields: []*Field{
+											{
+												Name: []byte("name"),
+												Value: &String{
+													Path: []string{"name"},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		resolve := func(errorsOnly bool) *BufPair {
+			ctx := NewContext(context.Background())
+			ctx.SetErrorsOnly(errorsOnly)
+			buf := NewBufPair()
+			err := New(context.Background()).resolveNode(ctx, object, nil, buf)
+			assert.NoError(t, err)
+			return buf
+		}
+		expectedErrors := `{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","pets",1]}`
+
+		buf := resolve(false)
+		assert.Equal(t, `{"user":{"name":"Jens","pets":null}}`, buf.Data.String())
+		assert.Equal(t, expectedErrors, buf.Errors.String())
+
+		buf = resolve(true)
+		assert.Equal(t, "", buf.Data.String())
+		assert.Equal(t, expectedErrors, buf.Errors.String())
+	})
+	t.Run("nested fetch error for non-nullable field", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		r.EnableSingleFlightLoader = true
+		mockDataSource := NewMockDataSource(ctrl)
+		mockDataSource.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			DoAndReturn(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				pair := NewBufPair()
+				pair.WriteErr([]byte("errorMessage"), nil, nil, nil)
+				return writeGraphqlResponse(pair, w, false)
+			})
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"id":1}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("nestedObject"),
+						Value: &Object{
+							Nullable: false,
+							Fetch: &SingleFetch{
+								BufferId:   1,
+								DataSource: mockDataSource,
+								ProcessResponseConfig: ProcessResponseConfig{
+									ExtractGraphqlResponse: true,
+								},
+							},
+							Fields: []*Field{
+								{
+									HasBuffer: true,
+									BufferID:  1,
+									Name:      []byte("foo"),
+									Value: &String{
+										Path:     []string{"foo"},
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage"},{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["nestedObject"]}],"data":null}`
+	}))
+	t.Run("fetch with two Errors", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		r.EnableSingleFlightLoader = true
+		mockDataSource := NewMockDataSource(ctrl)
+		mockDataSource.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				pair := NewBufPair()
+				pair.WriteErr([]byte("errorMessage1"), nil, nil, nil)
+				pair.WriteErr([]byte("errorMessage2"), nil, nil, nil)
+				return writeGraphqlResponse(pair, w, false)
+			}).
+			Return(nil)
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: mockDataSource,
+					ProcessResponseConfig: ProcessResponseConfig{
+						ExtractGraphqlResponse: true,
+					},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path:     []string{"name"},
+							Nullable: true,
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"errorMessage1"},{"message":"errorMessage2"}],"data":{"name":null}}`
+	}))
+	t.Run("null field should bubble up to parent with error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: true,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`[{"id":1},{"id":2},{"id":3}]`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("stringObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("stringField"),
+									Value: &String{
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("integerObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("integerField"),
+									Value: &Integer{
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("floatObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("floatField"),
+									Value: &Float{
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("booleanObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("booleanField"),
+									Value: &Boolean{
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("objectObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("objectField"),
+									Value: &Object{
+										Nullable: false,
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("arrayObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("arrayField"),
+									Value: &Array{
+										Nullable: false,
+										Item: &String{
+											Nullable: false,
+											Path:     []string{"nonExisting"},
+										},
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("asynchronousArrayObject"),
+						Value: &Object{
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("arrayField"),
+									Value: &Array{
+										Nullable:            false,
+										ResolveAsynchronous: true,
+										Item: &String{
+											Nullable: false,
+											Path:     []string{"nonExisting"},
+										},
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("nullableArray"),
+						Value: &Array{
+							Nullable: true,
+							Item: &String{
+								Nullable: false,
+								Path:     []string{"name"},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["objectObject","objectField"]}],"data":{"stringObject":null,"integerObject":null,"floatObject":null,"booleanObject":null,"objectObject":null,"arrayObject":null,"asynchronousArrayObject":null,"nullableArray":null}}`
+	}))
+	t.Run("empty nullable array should resolve correctly", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: true,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`[]`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("nullableArray"),
+						Value: &Array{
+							Nullable: true,
+							Item: &Object{
+								Nullable: false,
+								Fields: []*Field{
+									{
+										Name: []byte("foo"),
+										Value: &String{
+											Nullable: false,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"data":{"nullableArray":[]}}`
+	}))
+	t.Run("empty not nullable array should resolve correctly", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`[]`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("notNullableArray"),
+						Value: &Array{
+							Nullable: false,
+							Item: &Object{
+								Nullable: false,
+								Fields: []*Field{
+									{
+										Name: []byte("foo"),
+										Value: &String{
+											Nullable: false,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"data":{"notNullableArray":[]}}`
+	}))
+	t.Run("when data null not nullable array should resolve to data null and errors", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:              0,
+					DataSource:            FakeDataSource(`{"data":null}`),
+					ProcessResponseConfig: ProcessResponseConfig{ExtractGraphqlResponse: true},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("nonNullArray"),
+						Value: &Array{
+							Nullable: false,
+							Item: &Object{
+								Nullable: false,
+								Fields: []*Field{
+									{
+										Name: []byte("foo"),
+										Value: &String{
+											Nullable: false,
+										},
+									},
+								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("nullableArray"),
+						Value: &Array{
+							Nullable: true,
+							Item: &Object{
+								Nullable: false,
+								Fields: []*Field{
+									{
+										Name: []byte("foo"),
+										Value: &String{
+											Nullable: false,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}]}],"data":null}`
+	}))
+	t.Run("when data null and errors present not nullable array should result to null data upsteam error and resolve error", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId: 0,
+					DataSource: FakeDataSource(
+						`{"errors":[{"message":"Could not get a name","locations":[{"line":3,"column":5}],"path":["todos",0,"name"]}],"data":null}`),
+					ProcessResponseConfig: ProcessResponseConfig{ExtractGraphqlResponse: true},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("todos"),
+						Value: &Array{
+							Nullable: false,
+							Item: &Object{
+								Nullable: false,
+								Fields: []*Field{
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Nullable: false,
+										},
+										Position: Position{
+											Line:   100,
+											Column: 777,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"Could not get a name","locations":[{"line":3,"column":5}],"path":["todos",0,"name"]},{"message":"unable to resolve","locations":[{"line":0,"column":0}]}],"data":null}`
+	}))
+	t.Run("error path of asynchronous array item contains integer index", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"todos":[{"details":{"name":"foo"}},{"id":2}]}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("todos"),
+						Value: &Array{
+							Path:                []string{"todos"},
+							Nullable:            false,
+							ResolveAsynchronous: true,
+							Item: &Object{
+								Nullable: true,
+								Fields: []*Field{
+									{
+										Name: []byte("details"),
+										Value: &Object{
+											Nullable: false,
+											Path:     []string{"details"},
+											Fields: []*Field{
+												{
+													Name: []byte("name"),
+													Value: &String{
+														Path: []string{"name"},
+													},
+												},
+											},
+										},
+										Position: Position{
+											Line:   3,
+											Column: 5,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":3,"column":5}],"path":["todos",1,"details"]}],"data":{"todos":[{"details":{"name":"foo"}},null]}}`
+	}))
+	t.Run("error path of numeric field name contains string", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		return &GraphQLResponse{
+			Data: &Object{
+				Nullable: false,
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"codes":{"404":{"message":"not found"},"500":null}}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("codes"),
+						Value: &Object{
+							Nullable: true,
+							Path:     []string{"codes"},
+							Fields: []*Field{
+								{
+									Name: []byte("500"),
+									Value: &Object{
+										Nullable: false,
+										Path:     []string{"500"},
+										Fields: []*Field{
+											{
+												Name: []byte("message"),
+												Value: &String{
+													Path: []string{"message"},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background()}, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["codes","500"]}],"data":{"codes":null}}`
+	}))
+	t.Run("complex GraphQL Server plan", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		r.EnableSingleFlightLoader = true
+		serviceOne := NewMockDataSource(ctrl)
+		serviceOne.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				expected := `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":123,"firstArg":"firstArgValue"}}}`
+				assert.Equal(t, expected, actual)
+				_, err = w.Write([]byte(`{"serviceOne":{"fieldOne":"fieldOneValue"},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}`))
+				return
+			}).
+			Return(nil)
+
+		serviceTwo := NewMockDataSource(ctrl)
+		serviceTwo.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				expected := `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":12.34,"secondArg":true}}}`
+				assert.Equal(t, expected, actual)
+				_, err = w.Write([]byte(`{"serviceTwo":{"fieldTwo":"fieldTwoValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"}}`))
+				return
+			}).
+			Return(nil)
+
+		nestedServiceOne := NewMockDataSource(ctrl)
+		nestedServiceOne.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				expected := `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`
+				assert.Equal(t, expected, actual)
+				_, err = w.Write([]byte(`{"serviceOne":{"fieldOne":"fieldOneValue"}}`))
+				return
+			}).
+			Return(nil)
+
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &ParallelFetch{
+					Fetches: []*SingleFetch{
+						{
+							BufferId: 0,
+							Input:    `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":$$1$$,"firstArg":"$$0$$"}}}`,
+							InputTemplate: InputTemplate{
+								Segments: []TemplateSegment{
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":`),
+									},
+									{
+										SegmentType:        VariableSegmentType,
+										VariableSource:     VariableSourceContext,
+										VariableSourcePath: []string{"thirdArg"},
+									},
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`,"firstArg":"`),
+									},
+									{
+										SegmentType:        VariableSegmentType,
+										VariableSource:     VariableSourceContext,
+										VariableSourcePath: []string{"firstArg"},
+									},
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`"}}}`),
+									},
+								},
+							},
+							DataSource: serviceOne,
+							Variables: NewVariables(
+								&ContextVariable{
+									Path: []string{"firstArg"},
+								},
+								&ContextVariable{
+									Path: []string{"thirdArg"},
+								},
+							),
+						},
+						{
+							BufferId: 1,
+							Input:    `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":$$1$$,"secondArg":$$0$$}}}`,
+							InputTemplate: InputTemplate{
+								Segments: []TemplateSegment{
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":`),
+									},
+									{
+										SegmentType:        VariableSegmentType,
+										VariableSource:     VariableSourceContext,
+										VariableSourcePath: []string{"fourthArg"},
+									},
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`,"secondArg":`),
+									},
+									{
+										SegmentType:        VariableSegmentType,
+										VariableSource:     VariableSourceContext,
+										VariableSourcePath: []string{"secondArg"},
+									},
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`}}}`),
+									},
+								},
+							},
+							DataSource: serviceTwo,
+							Variables: NewVariables(
+								&ContextVariable{
+									Path: []string{"secondArg"},
+								},
+								&ContextVariable{
+									Path: []string{"fourthArg"},
+								},
+							),
+						},
+					},
+				},
+				Fields: []*Field{
+					{
+						BufferID:  0,
+						HasBuffer: true,
+						Name:      []byte("serviceOne"),
+						Value: &Object{
+							Path: []string{"serviceOne"},
+							Fields: []*Field{
+								{
+									Name: []byte("fieldOne"),
+									Value: &String{
+										Path: []string{"fieldOne"},
+									},
+								},
+							},
+						},
+					},
+					{
+						BufferID:  1,
+						HasBuffer: true,
+						Name:      []byte("serviceTwo"),
+						Value: &Object{
+							Path: []string{"serviceTwo"},
+							Fetch: &SingleFetch{
+								BufferId: 2,
+								Input:    `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`,
+								InputTemplate: InputTemplate{
+									Segments: []TemplateSegment{
+										{
+											SegmentType: StaticSegmentType,
+											Data:        []byte(`{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`),
+										},
+									},
+								},
+								DataSource: nestedServiceOne,
+								Variables:  Variables{},
+							},
+							Fields: []*Field{
+								{
+									Name: []byte("fieldTwo"),
+									Value: &String{
+										Path: []string{"fieldTwo"},
+									},
+								},
+								{
+									BufferID:  2,
+									HasBuffer: true,
+									Name:      []byte("serviceOneResponse"),
+									Value: &Object{
+										Path: []string{"serviceOne"},
+										Fields: []*Field{
+											{
+												Name: []byte("fieldOne"),
+												Value: &String{
+													Path: []string{"fieldOne"},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					{
+						BufferID:  0,
+						HasBuffer: true,
+						Name:      []byte("anotherServiceOne"),
+						Value: &Object{
+							Path: []string{"anotherServiceOne"},
+							Fields: []*Field{
+								{
+									Name: []byte("fieldOne"),
+									Value: &String{
+										Path: []string{"fieldOne"},
+									},
+								},
+							},
+						},
+					},
+					{
+						BufferID:  1,
+						HasBuffer: true,
+						Name:      []byte("secondServiceTwo"),
+						Value: &Object{
+							Path: []string{"secondServiceTwo"},
+							Fields: []*Field{
+								{
+									Name: []byte("fieldTwo"),
+									Value: &String{
+										Path: []string{"fieldTwo"},
+									},
+								},
+							},
+						},
+					},
+					{
+						BufferID:  0,
+						HasBuffer: true,
+						Name:      []byte("reusingServiceOne"),
+						Value: &Object{
+							Path: []string{"reusingServiceOne"},
+							Fields: []*Field{
+								{
+									Name: []byte("fieldOne"),
+									Value: &String{
+										Path: []string{"fieldOne"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background(), Variables: []byte(`{"firstArg":"firstArgValue","thirdArg":123,"secondArg": true, "fourthArg": 12.34}`)}, `{"data":{"serviceOne":{"fieldOne":"fieldOneValue"},"serviceTwo":{"fieldTwo":"fieldTwoValue","serviceOneResponse":{"fieldOne":"fieldOneValue"}},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}}`
+	}))
+	t.Run("federation", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
+		r.EnableSingleFlightLoader = true
+
+		userService := NewMockDataSource(ctrl)
+		userService.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				expected := `{"method":"POST","url":"http://localhost:4001","body":{"query":"{me {id username}}"}}`
+				assert.Equal(t, expected, actual)
+				_, err = w.Write([]byte(`{"me": {"id": "1234","username": "Me","__typename": "User"}}`))
+				return
+			}).
+			Return(nil)
+
+		reviewsService := NewMockDataSource(ctrl)
+		reviewsService.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				expected := `{"method":"POST","url":"http://localhost:4002","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on User {reviews {body product {upc __typename}}}}}","variables":{"representations":[{"id":"1234","__typename":"User"}]}}}`
+				assert.Equal(t, expected, actual)
+				_, err = w.Write([]byte(`{"reviews": [{"body": "A highly effective form of birth control.","product": {"upc": "top-1","__typename": "Product"}},{"body": "Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product": {"upc": "top-1","__typename": "Product"}}]}`))
+				return
+			}).
+			Return(nil)
+
+		productServiceCallCount := 0
+
+		productService := NewMockDataSource(ctrl)
+		productService.EXPECT().
+			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+				actual := string(input)
+				productServiceCallCount++
+				switch productServiceCallCount {
+				case 1:
+					expected := `{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"top-1","__typename":"Product"}]}}}`
+					assert.Equal(t, expected, actual)
+					_, err = w.Write([]byte(`{"name": "Trilby"}`))
+				case 2:
+					expected := `{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"top-1","__typename":"Product"}]}}}`
+					assert.Equal(t, expected, actual)
+					_, err = w.Write([]byte(`{"name": "Trilby"}`))
+				}
+				return
+			}).
+			Return(nil).Times(2)
+
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId: 0,
+					InputTemplate: InputTemplate{
+						Segments: []TemplateSegment{
+							{
+								Data:        []byte(`{"method":"POST","url":"http://localhost:4001","body":{"query":"{me {id username}}"}}`),
+								SegmentType: StaticSegmentType,
+							},
+						},
+					},
+					DataSource: userService,
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("me"),
+						Value: &Object{
+							Fetch: &SingleFetch{
+								BufferId: 1,
+								InputTemplate: InputTemplate{
+									Segments: []TemplateSegment{
+										{
+											Data:        []byte(`{"method":"POST","url":"http://localhost:4002","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on User {reviews {body product {upc __typename}}}}}","variables":{"representations":[{"id":"`),
+											SegmentType: StaticSegmentType,
+										},
+										{
+											SegmentType:        VariableSegmentType,
+											VariableSource:     VariableSourceObject,
+											VariableSourcePath: []string{"id"},
+										},
+										{
+											Data:        []byte(`","__typename":"User"}]}}}`),
+											SegmentType: StaticSegmentType,
+										},
+									},
+								},
+								DataSource: reviewsService,
+							},
+							Path:     []string{"me"},
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("id"),
+									Value: &String{
+										Path: []string{"id"},
+									},
+								},
+								{
+									Name: []byte("username"),
+									Value: &String{
+										Path: []string{"username"},
+									},
+								},
+								{
+
+									HasBuffer: true,
+									BufferID:  1,
+									Name:      []byte("reviews"),
+									Value: &Array{
+										Path:     []string{"reviews"},
+										Nullable: true,
+										Item: &Object{
+											Nullable: true,
+											Fields: []*Field{
+												{
+													Name: []byte("body"),
+													Value: &String{
+														Path: []string{"body"},
+													},
+												},
+												{
+													Name: []byte("product"),
+													Value: &Object{
+														Path: []string{"product"},
+														Fetch: &SingleFetch{
+															BufferId:   2,
+															DataSource: productService,
+															InputTemplate: InputTemplate{
+																Segments: []TemplateSegment{
+																	{
+																		Data:        []byte(`{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"`),
+																		SegmentType: StaticSegmentType,
+																	},
+																	{
+																		SegmentType:        VariableSegmentType,
+																		VariableSource:     VariableSourceObject,
+																		VariableSourcePath: []string{"upc"},
+																	},
+																	{
+																		Data:        []byte(`","__typename":"Product"}]}}}`),
+																		SegmentType: StaticSegmentType,
+																	},
+																},
+															},
+														},
+														Fields: []*Field{
+															{
+																Name: []byte("upc"),
+																Value: &String{
+																	Path: []string{"upc"},
+																},
+															},
+															{
+																HasBuffer: true,
+																BufferID:  2,
+																Name:      []byte("name"),
+																Value: &String{
+																	Path: []string{"name"},
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, Context{Context: context.Background(), Variables: nil}, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A highly effective form of birth control.","product":{"upc":"top-1","name":"Trilby"}},{"body":"Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product":{"upc":"top-1","name":"Trilby"}}]}}}`
+	}))
+}
+
+func TestResolver_WithHeader(t *testing.T) {
+	cases := []struct {
+		name, header, variable string
+	}{
+		{"header and variable are of equal case", "Authorization", "Authorization"},
+		{"header is downcased and variable is uppercased", "authorization", "AUTHORIZATION"},
+		{"header is uppercasesed and variable is downcased", "AUTHORIZATION", "authorization"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			resolver := New(c)
+
+			header := make(http.Header)
+			header.Set(tc.header, "foo")
+			ctx := &Context{
+				Context: context.Background(),
+				Request: Request{
+					Header: header,
+				},
+			}
+
+			ctrl := gomock.NewController(t)
+			fakeService := NewMockDataSource(ctrl)
+			fakeService.EXPECT().
+				Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
+				Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
+					actual := string(input)
+					assert.Equal(t, "foo", actual)
+					_, err = w.Write([]byte(`{"bar":"baz"}`))
+					return
+				}).
+				Return(nil)
+
+			out := &bytes.Buffer{}
+			res := &GraphQLResponse{
+				Data: &Object{
+					Fetch: &SingleFetch{
+						BufferId:   0,
+						DataSource: fakeService,
+						InputTemplate: InputTemplate{
+							Segments: []TemplateSegment{
+								{
+									SegmentType:        VariableSegmentType,
+									VariableSource:     VariableSourceRequestHeader,
+									VariableSourcePath: []string{tc.variable},
+								},
+							},
+						},
+					},
+					Fields: []*Field{
+						{
+							Name: []byte("bar"),
+							Value: &String{
+								Path: []string{"bar"},
+							},
+							HasBuffer: true,
+							BufferID:  0,
+						},
+					},
+				},
+			}
+			err := resolver.ResolveGraphQLResponse(ctx, res, nil, out)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"data":{"bar":"baz"}}`, out.String())
+		})
+	}
+}
+
+type TestFlushWriter struct {
+	flushed []string
+	buf     bytes.Buffer
+}
+
+func (t *TestFlushWriter) Write(p []byte) (n int, err error) {
+	return t.buf.Write(p)
+}
+
+func (t *TestFlushWriter) Flush() {
+	t.flushed = append(t.flushed, t.buf.String())
+	t.buf.Reset()
+}
+
+func FakeStream(cancelFunc func(), messageFunc func(count int) (message string, ok bool)) *_fakeStream {
+	return &_fakeStream{
+		cancel:      cancelFunc,
+		messageFunc: messageFunc,
+	}
+}
+
+type _fakeStream struct {
+	cancel      context.CancelFunc
+	messageFunc func(counter int) (message string, ok bool)
+	input       []byte
+}
+
+func (f *_fakeStream) Start(ctx context.Context, input []byte, next chan<- []byte) error {
+	f.input = input
+	go func() {
+		time.Sleep(time.Millisecond)
+		count := 0
+		for {
+			if count == 3 {
+				f.cancel()
+				return
+			}
+			message, ok := f.messageFunc(count)
+			next <- []byte(message)
+			if !ok {
+				f.cancel()
+				return
+			}
+			count++
+		}
+	}()
+	return nil
+}
+
+func (f *_fakeStream) UniqueIdentifier() []byte {
+	return []byte("fake")
+}
+
+func TestResolver_ResolveGraphQLSubscription(t *testing.T) {
+	setup := func(ctx context.Context, stream *_fakeStream) (*Resolver, *GraphQLSubscription, *TestFlushWriter) {
+		plan := &GraphQLSubscription{
+			Trigger: GraphQLSubscriptionTrigger{
+				Source: stream,
+			},
+			Response: &GraphQLResponse{
+				Data: &Object{
+					Fields: []*Field{
+						{
+							Name: []byte("counter"),
+							Value: &Integer{
+								Path: []string{"counter"},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		out := &TestFlushWriter{
+			buf: bytes.Buffer{},
+		}
+
+		return New(ctx), plan, out
+	}
+
+	t.Run("should return errors if the upstream data has errors", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+			return `{"errors":[{"message":"Validation error occurred","locations":[{"line":1,"column":1}],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}],"data":null}`, false
+		})
+
+		resolver, plan, out := setup(c, fakeStream)
+		ctx := Context{
+			Context: c,
+		}
+
+		err := resolver.ResolveGraphQLSubscription(&ctx, plan, out)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(out.flushed))
+		assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}]},{"message":"Validation error occurred","locations":[{"line":1,"column":1}],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}],"data":null}`, out.flushed[0])
+	})
+
+	t.Run("should successfully get result from upstream", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+			return fmt.Sprintf(`{"data":{"counter":%d}}`, count), true
+		})
+
+		resolver, plan, out := setup(c, fakeStream)
+		ctx := Context{
+			Context: c,
+		}
+
+		err := resolver.ResolveGraphQLSubscription(&ctx, plan, out)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(out.flushed))
+		assert.Equal(t, `{"data":{"counter":0}}`, out.flushed[0])
+		assert.Equal(t, `{"data":{"counter":1}}`, out.flushed[1])
+		assert.Equal(t, `{"data":{"counter":2}}`, out.flushed[2])
+	})
+
+	t.Run("should render request headers into the trigger input", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+			return `{"data":{"counter":0}}`, false
+		})
+
+		resolver, plan, out := setup(c, fakeStream)
+		plan.Trigger.InputTemplate = InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`{"header":{"Authorization":["`),
+				},
+				(&HeaderVariable{Path: []string{"Authorization"}}).TemplateSegment(),
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`"]},"body":{"query":"subscription{counter}"}}`),
+				},
+			},
+		}
+		ctx := Context{
+			Context: c,
+			Request: Request{
+				Header: http.Header{"Authorization": []string{"Bearer 123"}},
+			},
+		}
+
+		err := resolver.ResolveGraphQLSubscription(&ctx, plan, out)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"header":{"Authorization":["Bearer 123"]},"body":{"query":"subscription{counter}"}}`, string(fakeStream.input))
+		assert.Equal(t, []string{`{"data":{"counter":0}}`}, out.flushed)
+	})
+
+	t.Run("should resolve each frame incrementally if the response contains streamed fields", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+			return fmt.Sprintf(`{"data":{"users":[{"id":%d},{"id":%d}]}}`, count*2, count*2+1), count < 1
+		})
+
+		item := &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("id"),
+					Value: &Integer{
+						Path: []string{"id"},
+					},
+				},
+			},
+		}
+		response := &GraphQLResponse{
+			Data: &Object{
+				Fields: []*Field{
+					{
+						Name: []byte("users"),
+						Value: &Array{
+							Path: []string{"users"},
+							Stream: Stream{
+								Enabled:      true,
+								InitialCount: 1,
+								PatchIndex:   0,
+							},
+							Item: item,
+						},
+					},
+				},
+			},
+		}
+		plan := &GraphQLSubscription{
+			Trigger: GraphQLSubscriptionTrigger{
+				Source: fakeStream,
+			},
+			Response: response,
+			StreamingResponse: &GraphQLStreamingResponse{
+				InitialResponse: response,
+				Patches: []*GraphQLResponsePatch{
+					{
+						Operation: literal.ADD,
+						Value:     item,
+					},
+				},
+			},
+		}
 
-		nestedServiceOne := NewMockDataSource(ctrl)
-		nestedServiceOne.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				expected := `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`
-				assert.Equal(t, expected, actual)
-				_, err = w.Write([]byte(`{"serviceOne":{"fieldOne":"fieldOneValue"}}`))
-				return
-			}).
-			Return(nil)
+		out := &TestFlushWriter{
+			buf: bytes.Buffer{},
+		}
+		// the stream only cancels the resolver, so that the patches of the last frame are resolved
+		err := New(c).ResolveGraphQLSubscription(NewContext(context.Background()), plan, out)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`{"data":{"users":[{"id":0}]}}`,
+			`[{"op":"add","path":"/data/users/1","value":{"id":1}}]`,
+			`{"data":{"users":[{"id":2}]}}`,
+			`[{"op":"add","path":"/data/users/1","value":{"id":3}}]`,
+		}, out.flushed)
+	})
 
-		return &GraphQLResponse{
-			Data: &Object{
-				Fetch: &ParallelFetch{
-					Fetches: []*SingleFetch{
-						{
-							BufferId: 0,
-							Input:    `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":$$1$$,"firstArg":"$$0$$"}}}`,
+	t.Run("concurrent frames", func(t *testing.T) {
+		run := func(t *testing.T, maxConcurrentFrames int) *subscriptionFrameDataSource {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dataSource := &subscriptionFrameDataSource{}
+			fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+				return fmt.Sprintf(`{"data":{"counter":%d}}`, count), true
+			})
+
+			plan := &GraphQLSubscription{
+				Trigger: GraphQLSubscriptionTrigger{
+					Source: fakeStream,
+				},
+				Response: &GraphQLResponse{
+					Data: &Object{
+						Fetch: &SingleFetch{
+							BufferId:   0,
+							DataSource: dataSource,
 							InputTemplate: InputTemplate{
 								Segments: []TemplateSegment{
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":`),
-									},
-									{
-										SegmentType:        VariableSegmentType,
-										VariableSource:     VariableSourceContext,
-										VariableSourcePath: []string{"thirdArg"},
-									},
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`,"firstArg":"`),
-									},
 									{
 										SegmentType:        VariableSegmentType,
-										VariableSource:     VariableSourceContext,
-										VariableSourcePath: []string{"firstArg"},
-									},
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`"}}}`),
+										VariableSource:     VariableSourceObject,
+										VariableSourcePath: []string{"counter"},
 									},
 								},
 							},
-							DataSource: serviceOne,
-							Variables: NewVariables(
-								&ContextVariable{
-									Path: []string{"firstArg"},
-								},
-								&ContextVariable{
-									Path: []string{"thirdArg"},
-								},
-							),
 						},
-						{
-							BufferId: 1,
-							Input:    `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":$$1$$,"secondArg":$$0$$}}}`,
-							InputTemplate: InputTemplate{
-								Segments: []TemplateSegment{
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":`),
-									},
-									{
-										SegmentType:        VariableSegmentType,
-										VariableSource:     VariableSourceContext,
-										VariableSourcePath: []string{"fourthArg"},
-									},
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`,"secondArg":`),
-									},
-									{
-										SegmentType:        VariableSegmentType,
-										VariableSource:     VariableSourceContext,
-										VariableSourcePath: []string{"secondArg"},
-									},
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`}}}`),
-									},
+						Fields: []*Field{
+							{
+								Name: []byte("counter"),
+								Value: &Integer{
+									Path: []string{"counter"},
 								},
 							},
-							DataSource: serviceTwo,
-							Variables: NewVariables(
-								&ContextVariable{
-									Path: []string{"secondArg"},
-								},
-								&ContextVariable{
-									Path: []string{"fourthArg"},
+							{
+								HasBuffer: true,
+								BufferID:  0,
+								Name:      []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
 								},
-							),
+							},
 						},
 					},
 				},
-				Fields: []*Field{
+			}
+
+			resolver := New(c)
+			resolver.MaxConcurrentSubscriptionFrames = maxConcurrentFrames
+			out := &TestFlushWriter{
+				buf: bytes.Buffer{},
+			}
+
+			err := resolver.ResolveGraphQLSubscription(NewContext(context.Background()), plan, out)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				`{"data":{"counter":0,"name":"frame 0"}}`,
+				`{"data":{"counter":1,"name":"frame 1"}}`,
+				`{"data":{"counter":2,"name":"frame 2"}}`,
+			}, out.flushed)
+			return dataSource
+		}
+
+		t.Run("should write frames in arrival order", func(t *testing.T) {
+			dataSource := run(t, 3)
+			assert.Greater(t, atomic.LoadInt32(&dataSource.maxInFlight), int32(1))
+		})
+
+		t.Run("should not resolve more frames at the same time than configured", func(t *testing.T) {
+			dataSource := run(t, 2)
+			assert.LessOrEqual(t, atomic.LoadInt32(&dataSource.maxInFlight), int32(2))
+		})
+
+		t.Run("should resolve one frame after another by default", func(t *testing.T) {
+			dataSource := run(t, 0)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&dataSource.maxInFlight))
+		})
+	})
+
+	t.Run("source errors", func(t *testing.T) {
+		run := func(t *testing.T, maxConcurrentFrames int, stream *erroringStream) []string {
+			resolver, plan, out := setup(context.Background(), nil)
+			plan.Trigger.Source = stream
+			resolver.MaxConcurrentSubscriptionFrames = maxConcurrentFrames
+
+			err := resolver.ResolveGraphQLSubscription(NewContext(context.Background()), plan, out)
+			assert.NoError(t, err)
+			return out.flushed
+		}
+
+		for _, maxConcurrentFrames := range []int{0, 2} {
+			t.Run(fmt.Sprintf("max concurrent frames %d", maxConcurrentFrames), func(t *testing.T) {
+				t.Run("should write a mid-stream error after the previous frames and end the subscription", func(t *testing.T) {
+					flushed := run(t, maxConcurrentFrames, &erroringStream{
+						messages: []string{`{"data":{"counter":0}}`, `{"data":{"counter":1}}`},
+						err:      errors.New(`upstream "counter" service unavailable`),
+					})
+					assert.Equal(t, []string{
+						`{"data":{"counter":0}}`,
+						`{"data":{"counter":1}}`,
+						`{"errors":[{"message":"upstream \"counter\" service unavailable"}]}`,
+					}, flushed)
+				})
+
+				t.Run("should end the subscription without an error if the source closes gracefully", func(t *testing.T) {
+					flushed := run(t, maxConcurrentFrames, &erroringStream{
+						messages: []string{`{"data":{"counter":0}}`, `{"data":{"counter":1}}`},
+					})
+					assert.Equal(t, []string{
+						`{"data":{"counter":0}}`,
+						`{"data":{"counter":1}}`,
+					}, flushed)
+				})
+			})
+		}
+	})
+
+	t.Run("buffering", func(t *testing.T) {
+		messages := func(count int) []string {
+			out := make([]string, count)
+			for i := range out {
+				out[i] = fmt.Sprintf(`{"data":{"counter":%d}}`, i)
+			}
+			return out
+		}
+		run := func(t *testing.T, maxConcurrentFrames int, bufferSize int, bufferMode SubscriptionBufferMode, messages []string) (*Resolver, []string) {
+			resolver, plan, out := setup(context.Background(), nil)
+			plan.Trigger.Source = &erroringStream{messages: messages}
+			plan.Trigger.BufferSize = bufferSize
+			plan.Trigger.BufferMode = bufferMode
+			resolver.MaxConcurrentSubscriptionFrames = maxConcurrentFrames
+
+			err := resolver.ResolveGraphQLSubscription(NewContext(context.Background()), plan, &slowFlushWriter{FlushWriter: out, delay: 10 * time.Millisecond})
+			assert.NoError(t, err)
+			return resolver, out.flushed
+		}
+
+		for _, maxConcurrentFrames := range []int{0, 2} {
+			t.Run(fmt.Sprintf("max concurrent frames %d", maxConcurrentFrames), func(t *testing.T) {
+				t.Run("should drop the oldest frames for a slow writer and keep the latest frame", func(t *testing.T) {
+					resolver, flushed := run(t, maxConcurrentFrames, 1, SubscriptionBufferModeDropOldest, messages(20))
+					assert.Less(t, len(flushed), 20)
+					if assert.NotEmpty(t, flushed) {
+						assert.Equal(t, `{"data":{"counter":19}}`, flushed[len(flushed)-1])
+					}
+					previous := -1
+					for _, frame := range flushed {
+						var counter int
+						_, err := fmt.Sscanf(frame, `{"data":{"counter":%d}}`, &counter)
+						assert.NoError(t, err)
+						assert.Greater(t, counter, previous)
+						previous = counter
+					}
+					assert.Equal(t, uint64(20-len(flushed)), resolver.DroppedSubscriptionFrames())
+				})
+
+				t.Run("should deliver all frames in block mode", func(t *testing.T) {
+					resolver, flushed := run(t, maxConcurrentFrames, 5, SubscriptionBufferModeBlock, messages(10))
+					assert.Equal(t, messages(10), flushed)
+					assert.Equal(t, uint64(0), resolver.DroppedSubscriptionFrames())
+				})
+			})
+		}
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		run := func(t *testing.T, maxConcurrentFrames int, writer func(out FlushWriter) FlushWriter, expectedHeartbeat string) {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			stream := &channelStream{messages: make(chan []byte)}
+			resolver, plan, out := setup(c, nil)
+			plan.Trigger.Source = stream
+			plan.HeartbeatInterval = time.Second
+			clock := newFakeClock()
+			resolver.SetClock(clock)
+			resolver.MaxConcurrentSubscriptionFrames = maxConcurrentFrames
+
+			flushed := make(chan struct{}, 16)
+			done := make(chan error)
+			go func() {
+				done <- resolver.ResolveGraphQLSubscription(&Context{Context: c}, plan, writer(&signalingFlushWriter{FlushWriter: out, flushed: flushed}))
+			}()
+
+			// the clock is advanced until the resolver waits for the heartbeat
+			heartbeatWritten := false
+			for i := 0; i < 1000 && !heartbeatWritten; i++ {
+				clock.Advance(time.Second)
+				select {
+				case <-flushed:
+					heartbeatWritten = true
+				case <-time.After(time.Millisecond):
+				}
+			}
+			assert.True(t, heartbeatWritten)
+
+			stream.messages <- []byte(`{"data":{"counter":1}}`)
+			<-flushed
+			cancel()
+			assert.NoError(t, <-done)
+			// the timer is reset after each heartbeat and frame instead of creating a new one
+			assert.Len(t, clock.timers, 1)
+
+			if assert.GreaterOrEqual(t, len(out.flushed), 2) {
+				for _, heartbeat := range out.flushed[:len(out.flushed)-1] {
+					assert.Equal(t, expectedHeartbeat, heartbeat)
+				}
+				assert.Contains(t, out.flushed[len(out.flushed)-1], `{"data":{"counter":1}}`)
+			}
+		}
+
+		t.Run("should write heartbeats while no frames arrive", func(t *testing.T) {
+			run(t, 0, func(out FlushWriter) FlushWriter { return out }, `{}`)
+		})
+
+		t.Run("should write heartbeats while resolving frames concurrently", func(t *testing.T) {
+			run(t, 2, func(out FlushWriter) FlushWriter { return out }, `{}`)
+		})
+
+		t.Run("should use the heartbeat of the writer", func(t *testing.T) {
+			run(t, 0, func(out FlushWriter) FlushWriter { return NewSSEWriter(out) }, ": keep-alive\n\n")
+		})
+
+		t.Run("should not write heartbeats by default", func(t *testing.T) {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+				return fmt.Sprintf(`{"data":{"counter":%d}}`, count), count < 1
+			})
+			resolver, plan, out := setup(c, fakeStream)
+			clock := newFakeClock()
+			resolver.SetClock(clock)
+
+			err := resolver.ResolveGraphQLSubscription(&Context{Context: c}, plan, out)
+			assert.NoError(t, err)
+			assert.Len(t, clock.timers, 0)
+			assert.Equal(t, []string{`{"data":{"counter":0}}`, `{"data":{"counter":1}}`}, out.flushed)
+		})
+	})
+}
+
+// erroringStream sends its messages and then either sends err or closes next if err is nil
+type erroringStream struct {
+	messages []string
+	err      error
+}
+
+func (e *erroringStream) Start(ctx context.Context, input []byte, next chan<- []byte) error {
+	return errors.New("want StartWithErrors to be used")
+}
+
+func (e *erroringStream) StartWithErrors(ctx context.Context, input []byte, next chan<- []byte, errs chan<- error) error {
+	go func() {
+		for _, message := range e.messages {
+			select {
+			case next <- []byte(message):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if e.err != nil {
+			errs <- e.err
+			return
+		}
+		close(next)
+	}()
+	return nil
+}
+
+func (e *erroringStream) UniqueIdentifier() []byte {
+	return []byte("erroring")
+}
+
+// channelStream forwards messages to the subscription until its context is done
+type channelStream struct {
+	messages chan []byte
+}
+
+func (c *channelStream) Start(ctx context.Context, input []byte, next chan<- []byte) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-c.messages:
+				select {
+				case next <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *channelStream) UniqueIdentifier() []byte {
+	return []byte("channel")
+}
+
+// signalingFlushWriter signals each flush, so that tests can wait for frames written in another goroutine
+type signalingFlushWriter struct {
+	FlushWriter
+	flushed chan struct{}
+}
+
+func (s *signalingFlushWriter) Flush() {
+	s.FlushWriter.Flush()
+	s.flushed <- struct{}{}
+}
+
+// slowFlushWriter delays each flush to simulate a slow consumer
+type slowFlushWriter struct {
+	FlushWriter
+	delay time.Duration
+}
+
+func (s *slowFlushWriter) Flush() {
+	time.Sleep(s.delay)
+	s.FlushWriter.Flush()
+}
+
+// subscriptionFrameDataSource delays earlier frames longer than later ones and tracks how many frames are loaded at the same time
+type subscriptionFrameDataSource struct {
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (s *subscriptionFrameDataSource) UniqueIdentifier() []byte {
+	return []byte("subscriptionFrame")
+}
+
+func (s *subscriptionFrameDataSource) Load(ctx context.Context, input []byte, w io.Writer) (err error) {
+	inFlight := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if inFlight <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, inFlight) {
+			break
+		}
+	}
+
+	counter, err := strconv.Atoi(string(input))
+	if err != nil {
+		return err
+	}
+	time.Sleep(time.Duration(3-counter) * 10 * time.Millisecond)
+	_, err = fmt.Fprintf(w, `{"name":"frame %d"}`, counter)
+	return
+}
+
+func BenchmarkResolver_ResolveNode(b *testing.B) {
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resolver := New(c)
+
+	serviceOneDS := FakeDataSource(`{"serviceOne":{"fieldOne":"fieldOneValue"},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}`)
+	serviceTwoDS := FakeDataSource(`{"serviceTwo":{"fieldTwo":"fieldTwoValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"}}`)
+	nestedServiceOneDS := FakeDataSource(`{"serviceOne":{"fieldOne":"fieldOneValue"}}`)
+
+	plan := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &ParallelFetch{
+				Fetches: []*SingleFetch{
 					{
-						BufferID:  0,
-						HasBuffer: true,
-						Name:      []byte("serviceOne"),
-						Value: &Object{
-							Path: []string{"serviceOne"},
-							Fields: []*Field{
+						BufferId: 0,
+						Input:    `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":$$1$$,"firstArg":$$0$$}}}`,
+						InputTemplate: InputTemplate{
+							Segments: []TemplateSegment{
 								{
-									Name: []byte("fieldOne"),
-									Value: &String{
-										Path: []string{"fieldOne"},
-									},
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":`),
+								},
+								{
+									SegmentType:        VariableSegmentType,
+									VariableSource:     VariableSourceContext,
+									VariableSourcePath: []string{"thirdArg"},
 								},
-							},
-						},
-					},
-					{
-						BufferID:  1,
-						HasBuffer: true,
-						Name:      []byte("serviceTwo"),
-						Value: &Object{
-							Path: []string{"serviceTwo"},
-							Fetch: &SingleFetch{
-								BufferId: 2,
-								Input:    `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`,
-								InputTemplate: InputTemplate{
-									Segments: []TemplateSegment{
-										{
-											SegmentType: StaticSegmentType,
-											Data:        []byte(`{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`),
-										},
-									},
+								{
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`,"firstArg":`),
 								},
-								DataSource: nestedServiceOne,
-								Variables:  Variables{},
-							},
-							Fields: []*Field{
 								{
-									Name: []byte("fieldTwo"),
-									Value: &String{
-										Path: []string{"fieldTwo"},
-									},
+									SegmentType:        VariableSegmentType,
+									VariableSource:     VariableSourceContext,
+									VariableSourcePath: []string{"firstArg"},
 								},
 								{
-									BufferID:  2,
-									HasBuffer: true,
-									Name:      []byte("serviceOneResponse"),
-									Value: &Object{
-										Path: []string{"serviceOne"},
-										Fields: []*Field{
-											{
-												Name: []byte("fieldOne"),
-												Value: &String{
-													Path: []string{"fieldOne"},
-												},
-											},
-										},
-									},
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`}}}`),
 								},
 							},
 						},
+						DataSource: serviceOneDS,
+						Variables: NewVariables(
+							&ContextVariable{
+								Path: []string{"firstArg"},
+							},
+							&ContextVariable{
+								Path: []string{"thirdArg"},
+							},
+						),
 					},
 					{
-						BufferID:  0,
-						HasBuffer: true,
-						Name:      []byte("anotherServiceOne"),
-						Value: &Object{
-							Path: []string{"anotherServiceOne"},
-							Fields: []*Field{
+						BufferId: 1,
+						Input:    `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":$$1$$,"secondArg":$$0$$}}}`,
+						InputTemplate: InputTemplate{
+							Segments: []TemplateSegment{
 								{
-									Name: []byte("fieldOne"),
-									Value: &String{
-										Path: []string{"fieldOne"},
-									},
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":`),
 								},
-							},
-						},
-					},
-					{
-						BufferID:  1,
-						HasBuffer: true,
-						Name:      []byte("secondServiceTwo"),
-						Value: &Object{
-							Path: []string{"secondServiceTwo"},
-							Fields: []*Field{
 								{
-									Name: []byte("fieldTwo"),
-									Value: &String{
-										Path: []string{"fieldTwo"},
-									},
+									SegmentType:        VariableSegmentType,
+									VariableSource:     VariableSourceContext,
+									VariableSourcePath: []string{"fourthArg"},
 								},
-							},
-						},
-					},
-					{
-						BufferID:  0,
-						HasBuffer: true,
-						Name:      []byte("reusingServiceOne"),
-						Value: &Object{
-							Path: []string{"reusingServiceOne"},
-							Fields: []*Field{
 								{
-									Name: []byte("fieldOne"),
-									Value: &String{
-										Path: []string{"fieldOne"},
-									},
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`,"secondArg":`),
+								},
+								{
+									SegmentType:        VariableSegmentType,
+									VariableSource:     VariableSourceContext,
+									VariableSourcePath: []string{"secondArg"},
+								},
+								{
+									SegmentType: StaticSegmentType,
+									Data:        []byte(`}}}`),
 								},
 							},
 						},
+						DataSource: serviceTwoDS,
+						Variables: NewVariables(
+							&ContextVariable{
+								Path: []string{"secondArg"},
+							},
+							&ContextVariable{
+								Path: []string{"fourthArg"},
+							},
+						),
 					},
 				},
 			},
-		}, Context{Context: context.Background(), Variables: []byte(`{"firstArg":"firstArgValue","thirdArg":123,"secondArg": true, "fourthArg": 12.34}`)}, `{"data":{"serviceOne":{"fieldOne":"fieldOneValue"},"serviceTwo":{"fieldTwo":"fieldTwoValue","serviceOneResponse":{"fieldOne":"fieldOneValue"}},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}}`
-	}))
-	t.Run("federation", testFn(func(t *testing.T, r *Resolver, ctrl *gomock.Controller) (node *GraphQLResponse, ctx Context, expectedOutput string) {
-		r.EnableSingleFlightLoader = true
-
-		userService := NewMockDataSource(ctrl)
-		userService.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				expected := `{"method":"POST","url":"http://localhost:4001","body":{"query":"{me {id username}}"}}`
-				assert.Equal(t, expected, actual)
-				_, err = w.Write([]byte(`{"me": {"id": "1234","username": "Me","__typename": "User"}}`))
-				return
-			}).
-			Return(nil)
-
-		reviewsService := NewMockDataSource(ctrl)
-		reviewsService.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				expected := `{"method":"POST","url":"http://localhost:4002","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on User {reviews {body product {upc __typename}}}}}","variables":{"representations":[{"id":"1234","__typename":"User"}]}}}`
-				assert.Equal(t, expected, actual)
-				_, err = w.Write([]byte(`{"reviews": [{"body": "A highly effective form of birth control.","product": {"upc": "top-1","__typename": "Product"}},{"body": "Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product": {"upc": "top-1","__typename": "Product"}}]}`))
-				return
-			}).
-			Return(nil)
-
-		productServiceCallCount := 0
-
-		productService := NewMockDataSource(ctrl)
-		productService.EXPECT().
-			Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-			Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-				actual := string(input)
-				productServiceCallCount++
-				switch productServiceCallCount {
-				case 1:
-					expected := `{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"top-1","__typename":"Product"}]}}}`
-					assert.Equal(t, expected, actual)
-					_, err = w.Write([]byte(`{"name": "Trilby"}`))
-				case 2:
-					expected := `{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"top-1","__typename":"Product"}]}}}`
-					assert.Equal(t, expected, actual)
-					_, err = w.Write([]byte(`{"name": "Trilby"}`))
-				}
-				return
-			}).
-			Return(nil).Times(2)
-
-		return &GraphQLResponse{
-			Data: &Object{
-				Fetch: &SingleFetch{
-					BufferId: 0,
-					InputTemplate: InputTemplate{
-						Segments: []TemplateSegment{
+			Fields: []*Field{
+				{
+					BufferID:  0,
+					HasBuffer: true,
+					Name:      []byte("serviceOne"),
+					Value: &Object{
+						Path: []string{"serviceOne"},
+						Fields: []*Field{
 							{
-								Data:        []byte(`{"method":"POST","url":"http://localhost:4001","body":{"query":"{me {id username}}"}}`),
-								SegmentType: StaticSegmentType,
+								Name: []byte("fieldOne"),
+								Value: &String{
+									Path: []string{"fieldOne"},
+								},
 							},
 						},
 					},
-					DataSource: userService,
 				},
-				Fields: []*Field{
-					{
-						HasBuffer: true,
-						BufferID:  0,
-						Name:      []byte("me"),
-						Value: &Object{
-							Fetch: &SingleFetch{
-								BufferId: 1,
-								InputTemplate: InputTemplate{
-									Segments: []TemplateSegment{
-										{
-											Data:        []byte(`{"method":"POST","url":"http://localhost:4002","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on User {reviews {body product {upc __typename}}}}}","variables":{"representations":[{"id":"`),
-											SegmentType: StaticSegmentType,
-										},
-										{
-											SegmentType:        VariableSegmentType,
-											VariableSource:     VariableSourceObject,
-											VariableSourcePath: []string{"id"},
-										},
-										{
-											Data:        []byte(`","__typename":"User"}]}}}`),
-											SegmentType: StaticSegmentType,
-										},
+				{
+					BufferID:  1,
+					HasBuffer: true,
+					Name:      []byte("serviceTwo"),
+					Value: &Object{
+						Path: []string{"serviceTwo"},
+						Fetch: &SingleFetch{
+							BufferId: 2,
+							Input:    `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`,
+							InputTemplate: InputTemplate{
+								Segments: []TemplateSegment{
+									{
+										SegmentType: StaticSegmentType,
+										Data:        []byte(`{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`),
 									},
 								},
-								DataSource: reviewsService,
 							},
-							Path:     []string{"me"},
-							Nullable: true,
-							Fields: []*Field{
-								{
-									Name: []byte("id"),
-									Value: &String{
-										Path: []string{"id"},
-									},
-								},
-								{
-									Name: []byte("username"),
-									Value: &String{
-										Path: []string{"username"},
-									},
+							DataSource: nestedServiceOneDS,
+							Variables:  Variables{},
+						},
+						Fields: []*Field{
+							{
+								Name: []byte("fieldTwo"),
+								Value: &String{
+									Path: []string{"fieldTwo"},
 								},
-								{
-
-									HasBuffer: true,
-									BufferID:  1,
-									Name:      []byte("reviews"),
-									Value: &Array{
-										Path:     []string{"reviews"},
-										Nullable: true,
-										Item: &Object{
-											Nullable: true,
-											Fields: []*Field{
-												{
-													Name: []byte("body"),
-													Value: &String{
-														Path: []string{"body"},
-													},
-												},
-												{
-													Name: []byte("product"),
-													Value: &Object{
-														Path: []string{"product"},
-														Fetch: &SingleFetch{
-															BufferId:   2,
-															DataSource: productService,
-															InputTemplate: InputTemplate{
-																Segments: []TemplateSegment{
-																	{
-																		Data:        []byte(`{"method":"POST","url":"http://localhost:4003","body":{"query":"query($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}}","variables":{"representations":[{"upc":"`),
-																		SegmentType: StaticSegmentType,
-																	},
-																	{
-																		SegmentType:        VariableSegmentType,
-																		VariableSource:     VariableSourceObject,
-																		VariableSourcePath: []string{"upc"},
-																	},
-																	{
-																		Data:        []byte(`","__typename":"Product"}]}}}`),
-																		SegmentType: StaticSegmentType,
-																	},
-																},
-															},
-														},
-														Fields: []*Field{
-															{
-																Name: []byte("upc"),
-																Value: &String{
-																	Path: []string{"upc"},
-																},
-															},
-															{
-																HasBuffer: true,
-																BufferID:  2,
-																Name:      []byte("name"),
-																Value: &String{
-																	Path: []string{"name"},
-																},
-															},
-														},
-													},
-												},
+							},
+							{
+								BufferID:  2,
+								HasBuffer: true,
+								Name:      []byte("serviceOneResponse"),
+								Value: &Object{
+									Path: []string{"serviceOne"},
+									Fields: []*Field{
+										{
+											Name: []byte("fieldOne"),
+											Value: &String{
+												Path: []string{"fieldOne"},
 											},
 										},
 									},
@@ -1626,463 +5046,824 @@ func TestResolver_ResolveGraphQLResponse(t *testing.T) {
 						},
 					},
 				},
-			},
-		}, Context{Context: context.Background(), Variables: nil}, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A highly effective form of birth control.","product":{"upc":"top-1","name":"Trilby"}},{"body":"Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product":{"upc":"top-1","name":"Trilby"}}]}}}`
-	}))
-}
-
-func TestResolver_WithHeader(t *testing.T) {
-	cases := []struct {
-		name, header, variable string
-	}{
-		{"header and variable are of equal case", "Authorization", "Authorization"},
-		{"header is downcased and variable is uppercased", "authorization", "AUTHORIZATION"},
-		{"header is uppercasesed and variable is downcased", "AUTHORIZATION", "authorization"},
-	}
-
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			c, cancel := context.WithCancel(context.Background())
-			defer cancel()
-			resolver := New(c)
-
-			header := make(http.Header)
-			header.Set(tc.header, "foo")
-			ctx := &Context{
-				Context: context.Background(),
-				Request: Request{
-					Header: header,
+				{
+					BufferID:  0,
+					HasBuffer: true,
+					Name:      []byte("anotherServiceOne"),
+					Value: &Object{
+						Path: []string{"anotherServiceOne"},
+						Fields: []*Field{
+							{
+								Name: []byte("fieldOne"),
+								Value: &String{
+									Path: []string{"fieldOne"},
+								},
+							},
+						},
+					},
 				},
-			}
-
-			ctrl := gomock.NewController(t)
-			fakeService := NewMockDataSource(ctrl)
-			fakeService.EXPECT().
-				Load(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&bytes.Buffer{})).
-				Do(func(ctx context.Context, input []byte, w io.Writer) (err error) {
-					actual := string(input)
-					assert.Equal(t, "foo", actual)
-					_, err = w.Write([]byte(`{"bar":"baz"}`))
-					return
-				}).
-				Return(nil)
-
-			out := &bytes.Buffer{}
-			res := &GraphQLResponse{
-				Data: &Object{
-					Fetch: &SingleFetch{
-						BufferId:   0,
-						DataSource: fakeService,
-						InputTemplate: InputTemplate{
-							Segments: []TemplateSegment{
-								{
-									SegmentType:        VariableSegmentType,
-									VariableSource:     VariableSourceRequestHeader,
-									VariableSourcePath: []string{tc.variable},
+				{
+					BufferID:  1,
+					HasBuffer: true,
+					Name:      []byte("secondServiceTwo"),
+					Value: &Object{
+						Path: []string{"secondServiceTwo"},
+						Fields: []*Field{
+							{
+								Name: []byte("fieldTwo"),
+								Value: &String{
+									Path: []string{"fieldTwo"},
 								},
 							},
 						},
 					},
-					Fields: []*Field{
-						{
-							Name: []byte("bar"),
-							Value: &String{
-								Path: []string{"bar"},
+				},
+				{
+					BufferID:  0,
+					HasBuffer: true,
+					Name:      []byte("reusingServiceOne"),
+					Value: &Object{
+						Path: []string{"reusingServiceOne"},
+						Fields: []*Field{
+							{
+								Name: []byte("fieldOne"),
+								Value: &String{
+									Path: []string{"fieldOne"},
+								},
 							},
-							HasBuffer: true,
-							BufferID:  0,
 						},
 					},
 				},
-			}
-			err := resolver.ResolveGraphQLResponse(ctx, res, nil, out)
-			assert.NoError(t, err)
-			assert.Equal(t, `{"data":{"bar":"baz"}}`, out.String())
-		})
+			},
+		},
 	}
-}
 
-type TestFlushWriter struct {
-	flushed []string
-	buf     bytes.Buffer
-}
+	var err error
+	expected := []byte(`{"data":{"serviceOne":{"fieldOne":"fieldOneValue"},"serviceTwo":{"fieldTwo":"fieldTwoValue","serviceOneResponse":{"fieldOne":"fieldOneValue"}},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}}`)
 
-func (t *TestFlushWriter) Write(p []byte) (n int, err error) {
-	return t.buf.Write(p)
-}
+	pool := sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 1024))
+		},
+	}
 
-func (t *TestFlushWriter) Flush() {
-	t.flushed = append(t.flushed, t.buf.String())
-	t.buf.Reset()
-}
+	variables := []byte(`{"firstArg":"firstArgValue","thirdArg":123,"secondArg": true, "fourthArg": 12.34}`)
 
-func FakeStream(cancelFunc func(), messageFunc func(count int) (message string, ok bool)) *_fakeStream {
-	return &_fakeStream{
-		cancel:      cancelFunc,
-		messageFunc: messageFunc,
+	ctxPool := sync.Pool{
+		New: func() interface{} {
+			return NewContext(context.Background())
+		},
 	}
-}
 
-type _fakeStream struct {
-	cancel      context.CancelFunc
-	messageFunc func(counter int) (message string, ok bool)
-}
+	runBench := func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(expected)))
+		b.ResetTimer()
 
-func (f *_fakeStream) Start(ctx context.Context, input []byte, next chan<- []byte) error {
-	go func() {
-		time.Sleep(time.Millisecond)
-		count := 0
-		for {
-			if count == 3 {
-				f.cancel()
-				return
-			}
-			message, ok := f.messageFunc(count)
-			next <- []byte(message)
-			if !ok {
-				f.cancel()
-				return
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				// _ = resolver.ResolveGraphQLResponse(ctx, plan, nil, ioutil.Discard)
+				ctx := ctxPool.Get().(*Context)
+				ctx.Variables = variables
+				buf := pool.Get().(*bytes.Buffer)
+				err = resolver.ResolveGraphQLResponse(ctx, plan, nil, buf)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !bytes.Equal(expected, buf.Bytes()) {
+					b.Fatalf("want:\n%s\ngot:\n%s\n", string(expected), buf.String())
+				}
+
+				buf.Reset()
+				pool.Put(buf)
+
+				ctx.Free()
+				ctxPool.Put(ctx)
 			}
-			count++
-		}
-	}()
-	return nil
-}
+		})
+	}
 
-func (f *_fakeStream) UniqueIdentifier() []byte {
-	return []byte("fake")
+	b.Run("singleflight enabled (latency 0)", func(b *testing.B) {
+		serviceOneDS.artificialLatency = 0
+		serviceTwoDS.artificialLatency = 0
+		nestedServiceOneDS.artificialLatency = 0
+		resolver.EnableSingleFlightLoader = true
+		runBench(b)
+	})
 }
 
-func TestResolver_ResolveGraphQLSubscription(t *testing.T) {
-	setup := func(ctx context.Context, stream *_fakeStream) (*Resolver, *GraphQLSubscription, *TestFlushWriter) {
-		plan := &GraphQLSubscription{
-			Trigger: GraphQLSubscriptionTrigger{
-				Source: stream,
-			},
-			Response: &GraphQLResponse{
-				Data: &Object{
-					Fields: []*Field{
-						{
-							Name: []byte("counter"),
-							Value: &Integer{
-								Path: []string{"counter"},
+func BenchmarkResolver_ResolveArrayAsynchronous(b *testing.B) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	items := &bytes.Buffer{}
+	expected := &bytes.Buffer{}
+	items.WriteString(`{"items":[`)
+	expected.WriteString(`{"items":[`)
+	for i := 0; i < 5000; i++ {
+		if i != 0 {
+			items.WriteString(",")
+			expected.WriteString(",")
+		}
+		_, _ = fmt.Fprintf(items, `{"id":%d}`, i)
+		_, _ = fmt.Fprintf(expected, `{"id":%d}`, i)
+	}
+	items.WriteString(`]}`)
+	expected.WriteString(`]}`)
+
+	node := &Object{
+		Fetch: &SingleFetch{
+			BufferId:   0,
+			DataSource: FakeDataSource(items.String()),
+		},
+		Fields: []*Field{
+			{
+				BufferID:  0,
+				HasBuffer: true,
+				Name:      []byte("items"),
+				Value: &Array{
+					Path:                []string{"items"},
+					ResolveAsynchronous: true,
+					Item: &Object{
+						Fields: []*Field{
+							{
+								Name: []byte("id"),
+								Value: &Integer{
+									Path: []string{"id"},
+								},
 							},
 						},
 					},
 				},
 			},
-		}
+		},
+	}
 
-		out := &TestFlushWriter{
-			buf: bytes.Buffer{},
+	runBench := func(b *testing.B, maxConcurrentArrayResolvers int) {
+		resolver := New(c)
+		resolver.MaxConcurrentArrayResolvers = maxConcurrentArrayResolvers
+		buf := NewBufPair()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ctx := NewContext(context.Background())
+			err := resolver.resolveNode(ctx, node, nil, buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !bytes.Equal(expected.Bytes(), buf.Data.Bytes()) {
+				b.Fatalf("want:\n%s\ngot:\n%s\n", expected.String(), buf.Data.String())
+			}
+			buf.Reset()
+			ctx.Free()
 		}
+	}
 
-		return New(ctx), plan, out
+	b.Run("unbounded", func(b *testing.B) {
+		runBench(b, 0)
+	})
+	b.Run("bounded (8 workers)", func(b *testing.B) {
+		runBench(b, 8)
+	})
+}
+
+func TestResolver_ExtractResponse(t *testing.T) {
+	run := func(responseData string, cfg ProcessResponseConfig, expectedData, expectedErrors string, expectedErr error) func(t *testing.T) {
+		return func(t *testing.T) {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := New(c)
+			buf := NewBufPair()
+			err := r.extractResponse([]byte(responseData), buf, cfg)
+			assert.Equal(t, expectedErr, err)
+			assert.Equal(t, expectedData, buf.Data.String())
+			assert.Equal(t, expectedErrors, buf.Errors.String())
+		}
 	}
 
-	t.Run("should return errors if the upstream data has errors", func(t *testing.T) {
-		c, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	graphql := ProcessResponseConfig{ExtractGraphqlResponse: true}
+	strictGraphql := ProcessResponseConfig{ExtractGraphqlResponse: true, StrictResponseParsing: true}
 
-		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
-			return `{"errors":[{"message":"Validation error occurred","locations":[{"line":1,"column":1}],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}],"data":null}`, false
-		})
+	t.Run("valid response", run(`{"data":{"name":"Jens"}}`, graphql, `{"name":"Jens"}`, ``, nil))
+	t.Run("trailing whitespace", run(`{"data":{"name":"Jens"}}   `, graphql, `{"name":"Jens"}`, ``, nil))
+	t.Run("trailing newline", run("{\"data\":{\"name\":\"Jens\"}}\n", graphql, `{"name":"Jens"}`, ``, nil))
+	t.Run("concatenated second object", run(`{"data":{"name":"Jens"}}{"errors":[{"message":"foo"}],"data":{"name":"Sergiy"}}`, graphql, `{"name":"Jens"}`, ``, nil))
+	t.Run("concatenated second object without graphql extraction", run(`{"name":"Jens"}{"name":"Sergiy"}`, ProcessResponseConfig{}, `{"name":"Jens"}`, ``, nil))
+	t.Run("strict with trailing newline", run("{\"data\":{\"name\":\"Jens\"}}\n", strictGraphql, `{"name":"Jens"}`, ``, nil))
+	t.Run("strict with concatenated second object", run(`{"data":{"name":"Jens"}}{"data":{"name":"Sergiy"}}`, strictGraphql, ``, ``, errTrailingResponseData))
 
-		resolver, plan, out := setup(c, fakeStream)
-		ctx := Context{
-			Context: c,
+	detectDuplicates := ProcessResponseConfig{ExtractGraphqlResponse: true, DetectDuplicateKeys: true}
+
+	t.Run("duplicate keys are ignored by default", run(`{"data":{"name":"Jens","name":"Sergiy"}}`, graphql, `{"name":"Jens","name":"Sergiy"}`, ``, nil))
+	t.Run("detect no duplicate keys", run(`{"data":{"name":"Jens","pets":[{"name":"Barky"},{"name":"Snowy"}]}}`, detectDuplicates, `{"name":"Jens","pets":[{"name":"Barky"},{"name":"Snowy"}]}`, ``, nil))
+	t.Run("detect duplicate root key", run(`{"data":{"name":"Jens","name":"Sergiy"}}`, detectDuplicates, `{"name":"Jens","name":"Sergiy"}`, `{"message":"duplicate key 'name' in upstream response"}`, nil))
+	t.Run("detect duplicate nested key", run(`{"data":{"user":{"pets":[{"name":"Barky"},{"name":"Snowy","name":"Rex"}]}}}`, detectDuplicates, `{"user":{"pets":[{"name":"Barky"},{"name":"Snowy","name":"Rex"}]}}`, `{"message":"duplicate key 'name' in upstream response at path 'user.pets.1'"}`, nil))
+	t.Run("detect duplicate key next to upstream errors", run(`{"errors":[{"message":"foo"}],"data":{"id":1,"id":2}}`, detectDuplicates, `{"id":1,"id":2}`, `{"message":"foo"},{"message":"duplicate key 'id' in upstream response"}`, nil))
+
+	customPaths := ProcessResponseConfig{ExtractGraphqlResponse: true, DataPath: []string{"result", "payload"}, ErrorsPath: []string{"failures"}}
+
+	t.Run("custom data and errors path", run(`{"failures":[{"message":"foo"}],"result":{"payload":{"name":"Jens"}}}`, customPaths, `{"name":"Jens"}`, `{"message":"foo"}`, nil))
+	t.Run("custom paths ignore the default keys", run(`{"errors":[{"message":"foo"}],"data":{"name":"Jens"}}`, customPaths, ``, ``, nil))
+	t.Run("custom data path only", run(`{"errors":[{"message":"foo"}],"result":{"name":"Jens"}}`, ProcessResponseConfig{ExtractGraphqlResponse: true, DataPath: []string{"result"}}, `{"name":"Jens"}`, `{"message":"foo"}`, nil))
+
+	defaultCode := ProcessResponseConfig{ExtractGraphqlResponse: true, DefaultErrorCode: "UPSTREAM_ERROR"}
+
+	t.Run("default error code keeps an existing code", run(`{"errors":[{"message":"foo","extensions":{"code":"NOT_FOUND"}}],"data":null}`, defaultCode, `null`, `{"message":"foo","extensions":{"code":"NOT_FOUND"}}`, nil))
+	t.Run("default error code without extensions", run(`{"errors":[{"message":"foo"}],"data":null}`, defaultCode, `null`, `{"message":"foo","extensions":{"code":"UPSTREAM_ERROR"}}`, nil))
+	t.Run("default error code with extensions but no code", run(`{"errors":[{"message":"foo","extensions":{"retryable":true}}],"data":null}`, defaultCode, `null`, `{"message":"foo","extensions":{"code":"UPSTREAM_ERROR","retryable":true}}`, nil))
+	t.Run("default error code with empty extensions", run(`{"errors":[{"message":"foo","extensions":{ }}],"data":null}`, defaultCode, `null`, `{"message":"foo","extensions":{"code":"UPSTREAM_ERROR"}}`, nil))
+	t.Run("no default error code", run(`{"errors":[{"message":"foo"}],"data":null}`, graphql, `null`, `{"message":"foo"}`, nil))
+}
+
+func TestResolver_ExtractFetchResponse_Decompression(t *testing.T) {
+	gzipped := func(data string) string {
+		buf := &bytes.Buffer{}
+		writer := gzip.NewWriter(buf)
+		_, _ = writer.Write([]byte(data))
+		_ = writer.Close()
+		return buf.String()
+	}
+	deflated := func(data string) string {
+		buf := &bytes.Buffer{}
+		writer := zlib.NewWriter(buf)
+		_, _ = writer.Write([]byte(data))
+		_ = writer.Close()
+		return buf.String()
+	}
+
+	extract := func(r *Resolver, ctx *Context, fetch *SingleFetch, responseData string) (*BufPair, error) {
+		buf := NewBufPair()
+		err := r.extractFetchResponse(ctx, fetch, []byte(responseData), buf)
+		return buf, err
+	}
+
+	run := func(responseData string, cfg ProcessResponseConfig, expectedData, expectedErrors string) func(t *testing.T) {
+		return func(t *testing.T) {
+			buf, err := extract(New(context.Background()), NewContext(context.Background()), &SingleFetch{ProcessResponseConfig: cfg}, responseData)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedData, buf.Data.String())
+			assert.Equal(t, expectedErrors, buf.Errors.String())
 		}
+	}
 
-		err := resolver.ResolveGraphQLSubscription(&ctx, plan, out)
+	gzipGraphql := ProcessResponseConfig{ExtractGraphqlResponse: true, ContentEncoding: ContentEncodingGzip}
+	deflateGraphql := ProcessResponseConfig{ExtractGraphqlResponse: true, ContentEncoding: ContentEncodingDeflate}
+
+	t.Run("gzip", run(gzipped(`{"errors":[{"message":"foo"}],"data":{"name":"Jens"}}`), gzipGraphql, `{"name":"Jens"}`, `{"message":"foo"}`))
+	t.Run("gzip without graphql extraction", run(gzipped(`{"name":"Jens"}`), ProcessResponseConfig{ContentEncoding: ContentEncodingGzip}, `{"name":"Jens"}`, ``))
+	t.Run("gzip with plain response", run(`{"errors":[{"message":"foo"}],"data":{"name":"Jens"}}`, gzipGraphql, `{"name":"Jens"}`, `{"message":"foo"}`))
+	t.Run("deflate", run(deflated(`{"errors":[{"message":"foo"}],"data":{"name":"Jens"}}`), deflateGraphql, `{"name":"Jens"}`, `{"message":"foo"}`))
+	t.Run("deflate with plain response", run(`{"errors":[{"message":"foo"}],"data":{"name":"Jens"}}`, deflateGraphql, `{"name":"Jens"}`, `{"message":"foo"}`))
+	t.Run("unknown encoding", run(`{"data":{"name":"Jens"}}`, ProcessResponseConfig{ExtractGraphqlResponse: true, ContentEncoding: "br"}, `{"name":"Jens"}`, ``))
+
+	t.Run("batch response", func(t *testing.T) {
+		fetch := &SingleFetch{BatchBufferIds: []int{1, 2}, ProcessResponseConfig: gzipGraphql}
+		buf, err := extract(New(context.Background()), NewContext(context.Background()), fetch, gzipped(`[{"data":{"name":"Jens"}},{"data":{"name":"Sergiy"}}]`))
 		assert.NoError(t, err)
-		assert.Equal(t, 1, len(out.flushed))
-		assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}]},{"message":"Validation error occurred","locations":[{"line":1,"column":1}],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}],"data":null}`, out.flushed[0])
+		assert.Equal(t, `[{"data":{"name":"Jens"}},{"data":{"name":"Sergiy"}}]`, buf.Data.String())
 	})
 
-	t.Run("should successfully get result from upstream", func(t *testing.T) {
-		c, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	t.Run("response schema validates the decompressed response", func(t *testing.T) {
+		schema, err := ParseJSONSchema([]byte(`{"type":"object","required":["data"]}`))
+		assert.NoError(t, err)
+		ctx := NewContext(context.Background())
+		ctx.FeatureFlags = FeatureFlagValidateResponseSchema
+		fetch := &SingleFetch{ResponseSchema: schema, ProcessResponseConfig: gzipGraphql}
+		buf, err := extract(New(context.Background()), ctx, fetch, gzipped(`{"data":{"name":"Jens"}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":"Jens"}`, buf.Data.String())
+		assert.Equal(t, ``, buf.Errors.String())
+	})
 
-		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
-			return fmt.Sprintf(`{"data":{"counter":%d}}`, count), true
-		})
+	t.Run("corrupt gzip", func(t *testing.T) {
+		compressed := gzipped(`{"data":{"name":"Jens"}}`)
+		buf, err := extract(New(context.Background()), NewContext(context.Background()), &SingleFetch{ProcessResponseConfig: gzipGraphql}, compressed[:len(compressed)/2])
+		assert.True(t, errors.Is(err, errDecompressResponse))
+		assert.Equal(t, 0, buf.Data.Len())
+	})
 
-		resolver, plan, out := setup(c, fakeStream)
-		ctx := Context{
-			Context: c,
-		}
+	t.Run("decompressed response exceeds the limit", func(t *testing.T) {
+		r := New(context.Background())
+		r.MaxDecompressedResponseBytes = 64
+		bomb := gzipped(`{"data":{"name":"` + strings.Repeat("a", 1024) + `"}}`)
+		buf, err := extract(r, NewContext(context.Background()), &SingleFetch{ProcessResponseConfig: gzipGraphql}, bomb)
+		assert.True(t, errors.Is(err, errDecompressResponse))
+		assert.EqualError(t, err, "unable to decompress upstream response: decompressed response exceeds 64 bytes")
+		assert.Equal(t, 0, buf.Data.Len())
 
-		err := resolver.ResolveGraphQLSubscription(&ctx, plan, out)
+		buf, err = extract(r, NewContext(context.Background()), &SingleFetch{ProcessResponseConfig: gzipGraphql}, gzipped(`{"data":{"name":"Jens"}}`))
 		assert.NoError(t, err)
-		assert.Equal(t, 3, len(out.flushed))
-		assert.Equal(t, `{"data":{"counter":0}}`, out.flushed[0])
-		assert.Equal(t, `{"data":{"counter":1}}`, out.flushed[1])
-		assert.Equal(t, `{"data":{"counter":2}}`, out.flushed[2])
+		assert.Equal(t, `{"name":"Jens"}`, buf.Data.String())
 	})
 }
 
-func BenchmarkResolver_ResolveNode(b *testing.B) {
+func TestResolver_PreExtractedData(t *testing.T) {
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fields: []*Field{
+				{
+					Name: []byte("user"),
+					Value: &Object{
+						Path: []string{"user"},
+						Fields: []*Field{
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	resolve := func(ctx *Context, data string) string {
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, response, []byte(data), buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
 
-	c, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	extracted := resolve(NewContext(context.Background()), `{"data":{"user":{"name":"Jens"}}}`)
+	assert.Equal(t, `{"data":{"user":{"name":"Jens"}}}`, extracted)
 
-	resolver := New(c)
+	ctx := NewContext(context.Background())
+	ctx.SetPreExtractedData(true)
+	assert.Equal(t, extracted, resolve(ctx, `{"user":{"name":"Jens"}}`))
 
-	serviceOneDS := FakeDataSource(`{"serviceOne":{"fieldOne":"fieldOneValue"},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}`)
-	serviceTwoDS := FakeDataSource(`{"serviceTwo":{"fieldTwo":"fieldTwoValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"}}`)
-	nestedServiceOneDS := FakeDataSource(`{"serviceOne":{"fieldOne":"fieldOneValue"}}`)
+	// without the flag, the data isn't found within the envelope
+	assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user"]}],"data":null}`,
+		resolve(NewContext(context.Background()), `{"user":{"name":"Jens"}}`))
+}
 
-	plan := &GraphQLResponse{
+func TestResolver_FieldResponseName(t *testing.T) {
+	response := &GraphQLResponse{
 		Data: &Object{
-			Fetch: &ParallelFetch{
-				Fetches: []*SingleFetch{
-					{
-						BufferId: 0,
-						Input:    `{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":$$1$$,"firstArg":$$0$$}}}`,
-						InputTemplate: InputTemplate{
-							Segments: []TemplateSegment{
-								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`{"url":"https://service.one","body":{"query":"query($firstArg: String, $thirdArg: Int){serviceOne(serviceOneArg: $firstArg){fieldOne} anotherServiceOne(anotherServiceOneArg: $thirdArg){fieldOne} reusingServiceOne(reusingServiceOneArg: $firstArg){fieldOne}}","variables":{"thirdArg":`),
-								},
-								{
-									SegmentType:        VariableSegmentType,
-									VariableSource:     VariableSourceContext,
-									VariableSourcePath: []string{"thirdArg"},
-								},
-								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`,"firstArg":`),
-								},
-								{
-									SegmentType:        VariableSegmentType,
-									VariableSource:     VariableSourceContext,
-									VariableSourcePath: []string{"firstArg"},
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"user":{"id":1},"product":{"id":2}}`),
+			},
+			Fields: []*Field{
+				{
+					HasBuffer:    true,
+					BufferID:     0,
+					Name:         []byte("user"),
+					ResponseName: []byte("accounts_user"),
+					Value: &Object{
+						Path: []string{"user"},
+						Fields: []*Field{
+							{
+								Name:         []byte("id"),
+								ResponseName: []byte("accounts_id"),
+								Value: &Integer{
+									Path: []string{"id"},
 								},
-								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`}}}`),
+							},
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
 								},
 							},
 						},
-						DataSource: serviceOneDS,
-						Variables: NewVariables(
-							&ContextVariable{
-								Path: []string{"firstArg"},
-							},
-							&ContextVariable{
-								Path: []string{"thirdArg"},
+					},
+				},
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("product"),
+					Value: &Object{
+						Path: []string{"product"},
+						Fields: []*Field{
+							{
+								Name: []byte("id"),
+								Value: &Integer{
+									Path: []string{"id"},
+								},
 							},
-						),
+						},
 					},
+				},
+			},
+		},
+	}
+
+	ctx := NewContext(context.Background())
+	ctx.SetCollectAllErrors(true)
+	buf := &bytes.Buffer{}
+	err := New(context.Background()).ResolveGraphQLResponse(ctx, response, nil, buf)
+	assert.NoError(t, err)
+	// the path of errors uses the Name of the fields
+	assert.Equal(t, `{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["user","name"]}],`+
+		`"data":{"accounts_user":{"accounts_id":1,"name":null},"product":{"id":2}}}`, buf.String())
+}
+
+func TestResolver_StrictTypeNameConditions(t *testing.T) {
+	response := func(data string, petNullable bool) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(data),
+				},
+				Fields: []*Field{
 					{
-						BufferId: 1,
-						Input:    `{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":$$1$$,"secondArg":$$0$$}}}`,
-						InputTemplate: InputTemplate{
-							Segments: []TemplateSegment{
-								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`{"url":"https://service.two","body":{"query":"query($secondArg: Boolean, $fourthArg: Float){serviceTwo(serviceTwoArg: $secondArg){fieldTwo} secondServiceTwo(secondServiceTwoArg: $fourthArg){fieldTwo}}","variables":{"fourthArg":`),
-								},
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("pet"),
+						Value: &Object{
+							Path:     []string{"pet"},
+							Nullable: petNullable,
+							Fields: []*Field{
 								{
-									SegmentType:        VariableSegmentType,
-									VariableSource:     VariableSourceContext,
-									VariableSourcePath: []string{"fourthArg"},
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
 								},
 								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`,"secondArg":`),
+									Name:       []byte("woof"),
+									OnTypeName: []byte("Dog"),
+									Value: &String{
+										Path: []string{"woof"},
+									},
 								},
+							},
+						},
+					},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("owner"),
+						Value: &String{
+							Path: []string{"owner"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	resolve := func(t *testing.T, strict bool, data string, petNullable bool) string {
+		resolver := New(context.Background())
+		resolver.StrictTypeNameConditions = strict
+		buf := &bytes.Buffer{}
+		err := resolver.ResolveGraphQLResponse(NewContext(context.Background()), response(data, petNullable), nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("missing __typename resolves partially by default", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"pet":{"name":"Rex"},"owner":"Jens"}}`,
+			resolve(t, false, `{"pet":{"name":"Rex","woof":"Woof"},"owner":"Jens"}`, true))
+	})
+
+	t.Run("missing __typename nulls a nullable object", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[{"message":"__typename is missing for fields conditioned on the type","path":["pet"]}],"data":{"pet":null,"owner":"Jens"}}`,
+			resolve(t, true, `{"pet":{"name":"Rex","woof":"Woof"},"owner":"Jens"}`, true))
+	})
+
+	t.Run("missing __typename bubbles up from a non-nullable object", func(t *testing.T) {
+		assert.Equal(t, `{"errors":[{"message":"__typename is missing for fields conditioned on the type","path":["pet"]}],"data":null}`,
+			resolve(t, true, `{"pet":{"name":"Rex","woof":"Woof"},"owner":"Jens"}`, false))
+	})
+
+	t.Run("present __typename", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"pet":{"name":"Rex","woof":"Woof"},"owner":"Jens"}}`,
+			resolve(t, true, `{"pet":{"__typename":"Dog","name":"Rex","woof":"Woof"},"owner":"Jens"}`, false))
+		assert.Equal(t, `{"data":{"pet":{"name":"Tom"},"owner":"Jens"}}`,
+			resolve(t, true, `{"pet":{"__typename":"Cat","name":"Tom"},"owner":"Jens"}`, false))
+	})
+}
+
+func TestResolver_NullBubbling(t *testing.T) {
+	// hero has a non-null name and a list of friends with a non-null name, the second friend's name is null
+	response := func(heroNullable, friendsNullable, friendNullable, async bool) *GraphQLResponse {
+		return &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: FakeDataSource(`{"hero":{"name":"Luke","friends":[{"name":"Han"},{"name":null}]},"droid":{"name":"R2-D2"}}`),
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("hero"),
+						Value: &Object{
+							Path:     []string{"hero"},
+							Nullable: heroNullable,
+							Fields: []*Field{
 								{
-									SegmentType:        VariableSegmentType,
-									VariableSource:     VariableSourceContext,
-									VariableSourcePath: []string{"secondArg"},
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
 								},
 								{
-									SegmentType: StaticSegmentType,
-									Data:        []byte(`}}}`),
+									Name: []byte("friends"),
+									Value: &Array{
+										Path:                []string{"friends"},
+										Nullable:            friendsNullable,
+										ResolveAsynchronous: async,
+										Item: &Object{
+											Nullable: friendNullable,
+											Fields: []*Field{
+												{
+													Name: []byte("name"),
+													Value: &String{
+														Path: []string{"name"},
+													},
+												},
+											},
+										},
+									},
 								},
 							},
 						},
-						DataSource: serviceTwoDS,
-						Variables: NewVariables(
-							&ContextVariable{
-								Path: []string{"secondArg"},
-							},
-							&ContextVariable{
-								Path: []string{"fourthArg"},
-							},
-						),
 					},
-				},
-			},
-			Fields: []*Field{
-				{
-					BufferID:  0,
-					HasBuffer: true,
-					Name:      []byte("serviceOne"),
-					Value: &Object{
-						Path: []string{"serviceOne"},
-						Fields: []*Field{
-							{
-								Name: []byte("fieldOne"),
-								Value: &String{
-									Path: []string{"fieldOne"},
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("droid"),
+						Value: &Object{
+							Path:     []string{"droid"},
+							Nullable: true,
+							Fields: []*Field{
+								{
+									Name: []byte("name"),
+									Value: &String{
+										Path: []string{"name"},
+									},
 								},
 							},
 						},
 					},
 				},
+			},
+		}
+	}
+
+	run := func(response *GraphQLResponse, expected string) func(t *testing.T) {
+		return func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := New(context.Background()).ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, buf.String())
+		}
+	}
+
+	for _, async := range []bool{false, true} {
+		name := "synchronous"
+		if async {
+			name = "asynchronous"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Run("nullable list item is null, siblings are kept", run(response(true, true, true, async),
+				`{"data":{"hero":{"name":"Luke","friends":[{"name":"Han"},null]},"droid":{"name":"R2-D2"}}}`))
+			t.Run("nullable list is null, parent fields are kept", run(response(true, true, false, async),
+				`{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["hero","friends",1]}],`+
+					`"data":{"hero":{"name":"Luke","friends":null},"droid":{"name":"R2-D2"}}}`))
+			t.Run("nullable parent is null, sibling fields of the parent are kept", run(response(true, false, false, async),
+				`{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["hero","friends",1]}],`+
+					`"data":{"hero":null,"droid":{"name":"R2-D2"}}}`))
+			t.Run("no nullable parent, data is null", run(response(false, false, false, async),
+				`{"errors":[{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["hero","friends",1]},`+
+					`{"message":"unable to resolve","locations":[{"line":0,"column":0}],"path":["hero"]}],"data":null}`))
+		})
+	}
+}
+
+func TestResolver_FetchErrorsInResponse(t *testing.T) {
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &SingleFetch{
+				BufferId:   0,
+				DataSource: FakeDataSource(`{"users":[{"id":1},{"id":2}]}`),
+			},
+			Fields: []*Field{
 				{
-					BufferID:  1,
 					HasBuffer: true,
-					Name:      []byte("serviceTwo"),
-					Value: &Object{
-						Path: []string{"serviceTwo"},
-						Fetch: &SingleFetch{
-							BufferId: 2,
-							Input:    `{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`,
-							InputTemplate: InputTemplate{
-								Segments: []TemplateSegment{
-									{
-										SegmentType: StaticSegmentType,
-										Data:        []byte(`{"url":"https://service.one","body":{"query":"{serviceOne {fieldOne}}"}}`),
+					BufferID:  0,
+					Name:      []byte("users"),
+					Value: &Array{
+						Path: []string{"users"},
+						Item: &Object{
+							Fetch: &SingleFetch{
+								BufferId:   1,
+								DataSource: &failingDataSource{output: `{"name":`, err: errors.New(`Post "http://localhost:4001": connection refused`)},
+								InputTemplate: InputTemplate{
+									Segments: []TemplateSegment{
+										{
+											SegmentType:        VariableSegmentType,
+											VariableSource:     VariableSourceObject,
+											VariableSourcePath: []string{"id"},
+										},
 									},
 								},
 							},
-							DataSource: nestedServiceOneDS,
-							Variables:  Variables{},
-						},
-						Fields: []*Field{
-							{
-								Name: []byte("fieldTwo"),
-								Value: &String{
-									Path: []string{"fieldTwo"},
+							Fields: []*Field{
+								{
+									Name: []byte("id"),
+									Value: &Integer{
+										Path: []string{"id"},
+									},
 								},
-							},
-							{
-								BufferID:  2,
-								HasBuffer: true,
-								Name:      []byte("serviceOneResponse"),
-								Value: &Object{
-									Path: []string{"serviceOne"},
-									Fields: []*Field{
-										{
-											Name: []byte("fieldOne"),
-											Value: &String{
-												Path: []string{"fieldOne"},
-											},
-										},
+								{
+									HasBuffer: true,
+									BufferID:  1,
+									Name:      []byte("name"),
+									Value: &String{
+										Path:     []string{"name"},
+										Nullable: true,
 									},
 								},
 							},
 						},
 					},
 				},
-				{
-					BufferID:  0,
-					HasBuffer: true,
-					Name:      []byte("anotherServiceOne"),
-					Value: &Object{
-						Path: []string{"anotherServiceOne"},
-						Fields: []*Field{
-							{
-								Name: []byte("fieldOne"),
-								Value: &String{
-									Path: []string{"fieldOne"},
-								},
-							},
+			},
+		},
+	}
+
+	t.Run("load errors fail the response by default", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+		assert.EqualError(t, err, `Post "http://localhost:4001": connection refused`)
+	})
+
+	for _, singleFlight := range []bool{false, true} {
+		t.Run(fmt.Sprintf("single flight %t", singleFlight), func(t *testing.T) {
+			resolver := New(context.Background())
+			resolver.EnableSingleFlightLoader = singleFlight
+			ctx := NewContext(context.Background())
+			ctx.SetFetchErrorsInResponse(true)
+			buf := &bytes.Buffer{}
+			err := resolver.ResolveGraphQLResponse(ctx, response, nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"errors":[`+
+				`{"message":"Post \"http://localhost:4001\": connection refused","path":["users",0]},`+
+				`{"message":"Post \"http://localhost:4001\": connection refused","path":["users",1]}],`+
+				`"data":{"users":[{"id":1,"name":null},{"id":2,"name":null}]}}`, buf.String())
+		})
+	}
+
+	t.Run("canceled context fails the response", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		cancel()
+		ctx := NewContext(c)
+		ctx.SetFetchErrorsInResponse(true)
+		buf := &bytes.Buffer{}
+		err := New(context.Background()).ResolveGraphQLResponse(ctx, &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: &failingDataSource{err: context.Canceled},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("name"),
+						Value: &String{
+							Path:     []string{"name"},
+							Nullable: true,
 						},
 					},
 				},
-				{
-					BufferID:  1,
-					HasBuffer: true,
-					Name:      []byte("secondServiceTwo"),
-					Value: &Object{
-						Path: []string{"secondServiceTwo"},
-						Fields: []*Field{
-							{
-								Name: []byte("fieldTwo"),
-								Value: &String{
-									Path: []string{"fieldTwo"},
-								},
-							},
+			},
+		}, nil, buf)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestResolver_ResponsePathsPerFetch(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &ParallelFetch{
+				Fetches: []*SingleFetch{
+					{
+						BufferId:             0,
+						DataSource:           FakeDataSource(`{"data":{"name":"Jens"}}`),
+						DataSourceIdentifier: []byte("graphql"),
+						ProcessResponseConfig: ProcessResponseConfig{
+							ExtractGraphqlResponse: true,
+						},
+					},
+					{
+						BufferId:             1,
+						DataSource:           FakeDataSource(`{"result":{"city":"Berlin"},"problems":[{"message":"stale address"}]}`),
+						DataSourceIdentifier: []byte("rest"),
+						ProcessResponseConfig: ProcessResponseConfig{
+							ExtractGraphqlResponse: true,
+							DataPath:               []string{"result"},
+							ErrorsPath:             []string{"problems"},
 						},
 					},
 				},
+			},
+			Fields: []*Field{
 				{
+					HasBuffer: true,
 					BufferID:  0,
+					Name:      []byte("name"),
+					Value: &String{
+						Path: []string{"name"},
+					},
+				},
+				{
 					HasBuffer: true,
-					Name:      []byte("reusingServiceOne"),
-					Value: &Object{
-						Path: []string{"reusingServiceOne"},
-						Fields: []*Field{
-							{
-								Name: []byte("fieldOne"),
-								Value: &String{
-									Path: []string{"fieldOne"},
-								},
-							},
-						},
+					BufferID:  1,
+					Name:      []byte("city"),
+					Value: &String{
+						Path: []string{"city"},
 					},
 				},
 			},
 		},
 	}
 
-	var err error
-	expected := []byte(`{"data":{"serviceOne":{"fieldOne":"fieldOneValue"},"serviceTwo":{"fieldTwo":"fieldTwoValue","serviceOneResponse":{"fieldOne":"fieldOneValue"}},"anotherServiceOne":{"fieldOne":"anotherFieldOneValue"},"secondServiceTwo":{"fieldTwo":"secondFieldTwoValue"},"reusingServiceOne":{"fieldOne":"reUsingFieldOneValue"}}}`)
-
-	pool := sync.Pool{
-		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 0, 1024))
-		},
-	}
-
-	variables := []byte(`{"firstArg":"firstArgValue","thirdArg":123,"secondArg": true, "fourthArg": 12.34}`)
-
-	ctxPool := sync.Pool{
-		New: func() interface{} {
-			return NewContext(context.Background())
-		},
-	}
+	buf := &bytes.Buffer{}
+	err := New(c).ResolveGraphQLResponse(&Context{Context: c}, response, nil, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"errors":[{"message":"stale address"}],"data":{"name":"Jens","city":"Berlin"}}`, buf.String())
+}
 
-	runBench := func(b *testing.B) {
-		b.ReportAllocs()
-		b.SetBytes(int64(len(expected)))
-		b.ResetTimer()
+func TestResolver_BatchResponse(t *testing.T) {
+	run := func(upstreamResponse string, expectedOutput string) func(t *testing.T) {
+		return func(t *testing.T) {
+			c, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := New(c)
 
-		b.RunParallel(func(pb *testing.PB) {
-			for pb.Next() {
-				// _ = resolver.ResolveGraphQLResponse(ctx, plan, nil, ioutil.Discard)
-				ctx := ctxPool.Get().(*Context)
-				ctx.Variables = variables
-				buf := pool.Get().(*bytes.Buffer)
-				err = resolver.ResolveGraphQLResponse(ctx, plan, nil, buf)
-				if err != nil {
-					b.Fatal(err)
-				}
-				if !bytes.Equal(expected, buf.Bytes()) {
-					b.Fatalf("want:\n%s\ngot:\n%s\n", string(expected), buf.String())
+			user := func(bufferID int, name string) *Field {
+				return &Field{
+					HasBuffer: true,
+					BufferID:  bufferID,
+					Name:      []byte(name),
+					Value: &Object{
+						Nullable: true,
+						Path:     []string{"user"},
+						Fields: []*Field{
+							{
+								Name: []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+						},
+					},
 				}
+			}
 
-				buf.Reset()
-				pool.Put(buf)
-
-				ctx.Free()
-				ctxPool.Put(ctx)
+			response := &GraphQLResponse{
+				Data: &Object{
+					Fetch: &SingleFetch{
+						BufferId:       0,
+						BatchBufferIds: []int{1, 2},
+						DataSource:     FakeDataSource(upstreamResponse),
+						ProcessResponseConfig: ProcessResponseConfig{
+							ExtractGraphqlResponse: true,
+						},
+					},
+					Fields: []*Field{
+						user(1, "first"),
+						user(2, "second"),
+					},
+				},
 			}
-		})
+
+			buf := &bytes.Buffer{}
+			err := r.ResolveGraphQLResponse(&Context{Context: context.Background()}, response, nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedOutput, buf.String())
+		}
 	}
 
-	b.Run("singleflight enabled (latency 0)", func(b *testing.B) {
-		serviceOneDS.artificialLatency = 0
-		serviceTwoDS.artificialLatency = 0
-		nestedServiceOneDS.artificialLatency = 0
-		resolver.EnableSingleFlightLoader = true
-		runBench(b)
-	})
+	t.Run("scatter responses by index", run(
+		`[{"data":{"user":{"name":"Jens"}}},{"data":{"user":{"name":"Sergiy"}}}]`,
+		`{"data":{"first":{"name":"Jens"},"second":{"name":"Sergiy"}}}`,
+	))
+	t.Run("errors of a single response", run(
+		`[{"data":{"user":{"name":"Jens"}}},{"errors":[{"message":"user not found"}],"data":{"user":null}}]`,
+		`{"errors":[{"message":"user not found"}],"data":{"first":{"name":"Jens"},"second":null}}`,
+	))
+	t.Run("fewer responses than expected", run(
+		`[{"data":{"user":{"name":"Jens"}}}]`,
+		`{"errors":[{"message":"batch response contains 1 results, expected 2"}],"data":{"first":null,"second":null}}`,
+	))
+	t.Run("more responses than expected", run(
+		`[{"data":{"user":{"name":"Jens"}}},{"data":{"user":{"name":"Sergiy"}}},{"data":{"user":{"name":"Stefan"}}}]`,
+		`{"errors":[{"message":"batch response contains 3 results, expected 2"}],"data":{"first":null,"second":null}}`,
+	))
+	t.Run("response is not an array", run(
+		`{"data":{"user":{"name":"Jens"}}}`,
+		`{"errors":[{"message":"batch response is not an array"}],"data":{"first":null,"second":null}}`,
+	))
 }
 
 type hookContextPathMatcher struct {
@@ -2098,6 +5879,18 @@ func (h hookContextPathMatcher) String() string {
 	return fmt.Sprintf("is equal to %s", h.path)
 }
 
+type hookContextFallbackMatcher struct {
+	fallback bool
+}
+
+func (h hookContextFallbackMatcher) Matches(x interface{}) bool {
+	return x.(HookContext).Fallback == h.fallback
+}
+
+func (h hookContextFallbackMatcher) String() string {
+	return fmt.Sprintf("has fallback %t", h.fallback)
+}
+
 func TestInputTemplate_Render(t *testing.T) {
 
 	runTest := func(variables string, sourcePath []string, renderAsGraphQLVariable bool, expected string) {
@@ -2160,4 +5953,512 @@ func TestInputTemplate_Render(t *testing.T) {
 	t.Run("json object as graphql object with object array", func(t *testing.T) {
 		runTest(`{"foo":[{"bar":"baz"},{"bar":"bat"}]}`, []string{"foo"}, true, `[{bar:\"baz\"},{bar:\"bat\"}]`)
 	})
+	t.Run("context variable with default value", func(t *testing.T) {
+		render := func(variables string, defaultValue []byte, renderAsGraphQLValue bool) (string, error) {
+			variable := &ContextVariable{
+				Path:                 []string{"foo"},
+				RenderAsGraphQLValue: renderAsGraphQLValue,
+				DefaultValue:         defaultValue,
+			}
+			template := InputTemplate{
+				Segments: []TemplateSegment{variable.TemplateSegment()},
+			}
+			ctx := &Context{
+				Variables: []byte(variables),
+			}
+			buf := fastbuffer.New()
+			err := template.Render(ctx, nil, buf)
+			return buf.String(), err
+		}
+
+		t.Run("present value", func(t *testing.T) {
+			out, err := render(`{"foo":"bar"}`, []byte(`"baz"`), true)
+			assert.NoError(t, err)
+			assert.Equal(t, `\"bar\"`, out)
+		})
+		t.Run("missing value with default", func(t *testing.T) {
+			out, err := render(`{"bar":"baz"}`, []byte(`"baz"`), true)
+			assert.NoError(t, err)
+			assert.Equal(t, `\"baz\"`, out)
+		})
+		t.Run("missing value with object default", func(t *testing.T) {
+			out, err := render(`{}`, []byte(`{"limit":10}`), false)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"limit":10}`, out)
+		})
+		t.Run("missing value without default", func(t *testing.T) {
+			_, err := render(`{"bar":"baz"}`, nil, true)
+			assert.Error(t, err)
+		})
+	})
+	t.Run("context variable as json string", func(t *testing.T) {
+		run := func(variables string, expectedOutput string, expectedValue interface{}) func(t *testing.T) {
+			return func(t *testing.T) {
+				variable := &ContextVariable{
+					Path:               []string{"foo"},
+					RenderAsJSONString: true,
+				}
+				template := InputTemplate{
+					Segments: []TemplateSegment{
+						{
+							SegmentType: StaticSegmentType,
+							Data:        []byte(`{"foo":`),
+						},
+						variable.TemplateSegment(),
+						{
+							SegmentType: StaticSegmentType,
+							Data:        []byte(`}`),
+						},
+					},
+				}
+				ctx := &Context{
+					Variables: []byte(variables),
+				}
+				buf := fastbuffer.New()
+				err := template.Render(ctx, nil, buf)
+				assert.NoError(t, err)
+				assert.Equal(t, expectedOutput, buf.String())
+
+				var out map[string]interface{}
+				assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+				assert.Equal(t, expectedValue, out["foo"])
+			}
+		}
+
+		t.Run("plain string", run(`{"foo":"bar"}`, `{"foo":"bar"}`, "bar"))
+		t.Run("embedded quotes", run(`{"foo":"say \"hello\""}`, `{"foo":"say \"hello\""}`, `say "hello"`))
+		t.Run("backslashes", run(`{"foo":"C:\\temp\\"}`, `{"foo":"C:\\temp\\"}`, `C:\temp\`))
+		t.Run("newlines and tabs", run(`{"foo":"a\nb\tc"}`, `{"foo":"a\nb\tc"}`, "a\nb\tc"))
+		t.Run("unicode escapes", run(`{"foo":"caf\u00e9 \u0001"}`, `{"foo":"café \u0001"}`, "café \u0001"))
+		t.Run("non string value", run(`{"foo":{"bar":1}}`, `{"foo":{"bar":1}}`, map[string]interface{}{"bar": float64(1)}))
+	})
+}
+
+func TestInputTemplate_RenderHeaderVariable(t *testing.T) {
+	runTest := func(values []string, renderAsArray bool, expected string) func(t *testing.T) {
+		return func(t *testing.T) {
+			variable := &HeaderVariable{
+				Path:          []string{"X-Custom"},
+				RenderAsArray: renderAsArray,
+			}
+			template := InputTemplate{
+				Segments: []TemplateSegment{variable.TemplateSegment()},
+			}
+			header := http.Header{}
+			for i := range values {
+				header.Add("X-Custom", values[i])
+			}
+			ctx := &Context{
+				Request: Request{
+					Header: header,
+				},
+			}
+			buf := fastbuffer.New()
+			err := template.Render(ctx, nil, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, buf.String())
+		}
+	}
+
+	t.Run("no value", runTest(nil, false, ``))
+	t.Run("single value", runTest([]string{"a"}, false, `a`))
+	t.Run("multiple values", runTest([]string{"a", "b"}, false, `a,b`))
+	t.Run("no value as array", runTest(nil, true, `[]`))
+	t.Run("single value as array", runTest([]string{"a"}, true, `["a"]`))
+	t.Run("multiple values as array", runTest([]string{"a", "b"}, true, `["a","b"]`))
+	t.Run("values with special characters as array", runTest([]string{`for="_gazonk"`, "a\\b", "c,d"}, true, `["for=\"_gazonk\"","a\\b","c,d"]`))
+}
+
+func TestInputTemplate_RenderTraceVariable(t *testing.T) {
+	template := InputTemplate{
+		Segments: []TemplateSegment{
+			{
+				SegmentType: StaticSegmentType,
+				Data:        []byte(`{"header":{"X-Trace-Id":["`),
+			},
+			(&TraceVariable{}).TemplateSegment(),
+			{
+				SegmentType: StaticSegmentType,
+				Data:        []byte(`"]}}`),
+			},
+		},
+	}
+
+	t.Run("render the trace id of the context", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template.Render(&Context{TraceID: []byte("4bf92f3577b34da6")}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"header":{"X-Trace-Id":["4bf92f3577b34da6"]}}`, buf.String())
+	})
+
+	t.Run("escape the trace id", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template.Render(&Context{TraceID: []byte(`abc"]},"url":"https://evil.example\`)}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"header":{"X-Trace-Id":["abc\"]},\"url\":\"https://evil.example\\"]}}`, buf.String())
+		assert.True(t, json.Valid(buf.Bytes()))
+	})
+
+	t.Run("render an empty value without trace id", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template.Render(&Context{}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"header":{"X-Trace-Id":[""]}}`, buf.String())
+	})
+
+	t.Run("render the trace id as parameter", func(t *testing.T) {
+		parameterized := InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte("INSERT INTO audit (trace_id) VALUES ("),
+				},
+				(&TraceVariable{}).TemplateSegment(),
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(")"),
+				},
+			},
+		}
+		buf := fastbuffer.New()
+		args, err := parameterized.RenderParameterized(&Context{TraceID: []byte("4bf92f3577b34da6")}, nil, PlaceholderStyleDollar, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "INSERT INTO audit (trace_id) VALUES ($1)", buf.String())
+		assert.Equal(t, []interface{}{"4bf92f3577b34da6"}, args)
+	})
+
+	t.Run("trace variables are equal", func(t *testing.T) {
+		assert.True(t, (&TraceVariable{}).Equals(&TraceVariable{}))
+		assert.False(t, (&TraceVariable{}).Equals(&HeaderVariable{Path: []string{"X-Trace-Id"}}))
+	})
+}
+
+func TestInputTemplate_RenderURLEncoding(t *testing.T) {
+	template := func(variable Variable) *InputTemplate {
+		return &InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`https://example.com/users/`),
+				},
+				variable.TemplateSegment(),
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`?verbose=true`),
+				},
+			},
+		}
+	}
+	const name = `{"name":"Jens Neuse/Admin & Co. \u00fcber + 😀"}`
+	const encoded = `https://example.com/users/Jens%20Neuse%2FAdmin%20%26%20Co.%20%C3%BCber%20%2B%20%F0%9F%98%80?verbose=true`
+
+	t.Run("context variable", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template(&ContextVariable{Path: []string{"name"}, Encoding: EncodingURLComponent}).Render(&Context{Variables: []byte(name)}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, encoded, buf.String())
+	})
+
+	t.Run("object variable", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template(&ObjectVariable{Path: []string{"name"}, Encoding: EncodingURLComponent}).Render(&Context{}, []byte(name), buf)
+		assert.NoError(t, err)
+		assert.Equal(t, encoded, buf.String())
+	})
+
+	t.Run("non string value", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template(&ContextVariable{Path: []string{"id"}, Encoding: EncodingURLComponent}).Render(&Context{Variables: []byte(`{"id":123}`)}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `https://example.com/users/123?verbose=true`, buf.String())
+	})
+
+	t.Run("not encoded by default", func(t *testing.T) {
+		buf := fastbuffer.New()
+		err := template(&ContextVariable{Path: []string{"name"}}).Render(&Context{Variables: []byte(`{"name":"Jens Neuse/Admin"}`)}, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `https://example.com/users/Jens Neuse/Admin?verbose=true`, buf.String())
+	})
+}
+
+func TestInputTemplate_RenderBase64Encoding(t *testing.T) {
+	render := func(variables string, variable *ContextVariable) string {
+		variable.Path = []string{"cursor"}
+		variable.Encoding = EncodingBase64
+		template := InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`{"after":`),
+				},
+				variable.TemplateSegment(),
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`}`),
+				},
+			},
+		}
+		buf := fastbuffer.New()
+		err := template.Render(&Context{Variables: []byte(variables)}, nil, buf)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("empty value", func(t *testing.T) {
+		assert.Equal(t, `{"after":""}`, render(`{"cursor":""}`, &ContextVariable{RenderAsJSONString: true}))
+	})
+	t.Run("ascii value", func(t *testing.T) {
+		assert.Equal(t, `{"after":"dXNlcjox"}`, render(`{"cursor":"user:1"}`, &ContextVariable{RenderAsJSONString: true}))
+	})
+	t.Run("non-ascii value", func(t *testing.T) {
+		assert.Equal(t, `{"after":"w7xiZXIg8J+YgA=="}`, render(`{"cursor":"\u00fcber 😀"}`, &ContextVariable{RenderAsJSONString: true}))
+	})
+	t.Run("unquoted", func(t *testing.T) {
+		assert.Equal(t, `{"after":dXNlcjox}`, render(`{"cursor":"user:1"}`, &ContextVariable{}))
+	})
+	t.Run("graphql value", func(t *testing.T) {
+		assert.Equal(t, `{"after":\"dXNlcjox\"}`, render(`{"cursor":"user:1"}`, &ContextVariable{RenderAsGraphQLValue: true}))
+	})
+	t.Run("non string value", func(t *testing.T) {
+		assert.Equal(t, `{"after":"MTIz"}`, render(`{"cursor":123}`, &ContextVariable{RenderAsJSONString: true}))
+	})
+}
+
+func TestInputTemplate_RenderGraphQLEnum(t *testing.T) {
+	render := func(variables string) (string, error) {
+		variable := &ContextVariable{Path: []string{"position"}, RenderAsGraphQLEnum: true}
+		template := InputTemplate{
+			Segments: []TemplateSegment{
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`{"query":"{pets(position:`),
+				},
+				variable.TemplateSegment(),
+				{
+					SegmentType: StaticSegmentType,
+					Data:        []byte(`){name}}"}`),
+				},
+			},
+		}
+		buf := fastbuffer.New()
+		err := template.Render(&Context{Variables: []byte(variables)}, nil, buf)
+		return buf.String(), err
+	}
+	run := func(variables, expected string) func(t *testing.T) {
+		return func(t *testing.T) {
+			out, err := render(variables)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, out)
+		}
+	}
+	fail := func(variables string) func(t *testing.T) {
+		return func(t *testing.T) {
+			_, err := render(variables)
+			assert.True(t, errors.Is(err, errInvalidEnumValue))
+		}
+	}
+
+	t.Run("enum value", run(`{"position":"SIT"}`, `{"query":"{pets(position:SIT){name}}"}`))
+	t.Run("list of enum values", run(`{"position":["SIT","STAND_UP"]}`, `{"query":"{pets(position:[SIT,STAND_UP]){name}}"}`))
+	t.Run("empty list", run(`{"position":[]}`, `{"query":"{pets(position:[]){name}}"}`))
+	t.Run("null", run(`{"position":null}`, `{"query":"{pets(position:null){name}}"}`))
+	t.Run("null in list", run(`{"position":["SIT",null]}`, `{"query":"{pets(position:[SIT,null]){name}}"}`))
+	t.Run("invalid name", fail(`{"position":"SIT){secret}"}`))
+	t.Run("reserved name", fail(`{"position":"true"}`))
+	t.Run("leading digit", fail(`{"position":"1SIT"}`))
+	t.Run("number", fail(`{"position":1}`))
+	t.Run("invalid name in list", fail(`{"position":["SIT","a b"]}`))
+
+	t.Run("equals", func(t *testing.T) {
+		enum := &ContextVariable{Path: []string{"position"}, RenderAsGraphQLEnum: true}
+		assert.True(t, enum.Equals(&ContextVariable{Path: []string{"position"}, RenderAsGraphQLEnum: true}))
+		assert.False(t, enum.Equals(&ContextVariable{Path: []string{"position"}}))
+	})
+}
+
+func TestInputTemplate_RenderVariableBranches(t *testing.T) {
+	static := func(data string) TemplateSegment {
+		return TemplateSegment{
+			SegmentType: StaticSegmentType,
+			Data:        []byte(data),
+		}
+	}
+	template := func(defaultValue []byte) InputTemplate {
+		name := (&ContextVariable{Path: []string{"name"}, DefaultValue: defaultValue, RenderAsJSONString: true}).TemplateSegment()
+		branch := name
+		branch.Branches = &VariableBranches{
+			Null:  []TemplateSegment{static(`,"name":null`)},
+			Value: []TemplateSegment{static(`,"name":`), name},
+		}
+		return InputTemplate{
+			Segments: []TemplateSegment{
+				static(`{"id":1`),
+				branch,
+				static(`}`),
+			},
+		}
+	}
+	render := func(template InputTemplate, variables string) (string, error) {
+		buf := fastbuffer.New()
+		err := template.Render(&Context{Variables: []byte(variables)}, nil, buf)
+		return buf.String(), err
+	}
+
+	t.Run("value", func(t *testing.T) {
+		out, err := render(template(nil), `{"name":"Jens"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1,"name":"Jens"}`, out)
+	})
+
+	t.Run("explicit null", func(t *testing.T) {
+		out, err := render(template(nil), `{"name":null}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1,"name":null}`, out)
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		out, err := render(template(nil), `{}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1}`, out)
+
+		out, err = render(template(nil), ``)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1}`, out)
+	})
+
+	t.Run("absent with default value", func(t *testing.T) {
+		out, err := render(template([]byte(`"Anonymous"`)), `{}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1,"name":"Anonymous"}`, out)
+	})
+
+	t.Run("explicit null doesn't use the default value", func(t *testing.T) {
+		out, err := render(template([]byte(`"Anonymous"`)), `{"name":null}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1,"name":null}`, out)
+	})
+
+	t.Run("not supported for parameterized queries", func(t *testing.T) {
+		parameterized := template(nil)
+		_, err := parameterized.RenderParameterized(&Context{Variables: []byte(`{}`)}, nil, PlaceholderStyleDollar, fastbuffer.New())
+		assert.Error(t, err)
+	})
+}
+
+func TestInputTemplate_RenderParameterized(t *testing.T) {
+	template := InputTemplate{
+		Segments: []TemplateSegment{
+			{
+				SegmentType: StaticSegmentType,
+				Data:        []byte("SELECT * FROM users WHERE name = "),
+			},
+			{
+				SegmentType:        VariableSegmentType,
+				VariableSource:     VariableSourceContext,
+				VariableSourcePath: []string{"name"},
+			},
+			{
+				SegmentType: StaticSegmentType,
+				Data:        []byte(" AND age > "),
+			},
+			{
+				SegmentType:        VariableSegmentType,
+				VariableSource:     VariableSourceContext,
+				VariableSourcePath: []string{"age"},
+			},
+			{
+				SegmentType: StaticSegmentType,
+				Data:        []byte(" AND team_id = "),
+			},
+			{
+				SegmentType:        VariableSegmentType,
+				VariableSource:     VariableSourceObject,
+				VariableSourcePath: []string{"team", "id"},
+			},
+		},
+	}
+
+	render := func(t *testing.T, variables string, style PlaceholderStyle) (string, []interface{}) {
+		ctx := &Context{
+			Variables: []byte(variables),
+		}
+		buf := fastbuffer.New()
+		args, err := template.RenderParameterized(ctx, []byte(`{"team":{"id":7}}`), style, buf)
+		assert.NoError(t, err)
+		return buf.String(), args
+	}
+
+	t.Run("dollar placeholders", func(t *testing.T) {
+		query, args := render(t, `{"name":"Jens","age":30}`, PlaceholderStyleDollar)
+		assert.Equal(t, "SELECT * FROM users WHERE name = $1 AND age > $2 AND team_id = $3", query)
+		assert.Equal(t, []interface{}{"Jens", int64(30), int64(7)}, args)
+	})
+	t.Run("question mark placeholders", func(t *testing.T) {
+		query, args := render(t, `{"name":"Jens","age":30}`, PlaceholderStyleQuestionMark)
+		assert.Equal(t, "SELECT * FROM users WHERE name = ? AND age > ? AND team_id = ?", query)
+		assert.Equal(t, []interface{}{"Jens", int64(30), int64(7)}, args)
+	})
+	t.Run("values are never inlined", func(t *testing.T) {
+		query, args := render(t, `{"name":"'; DROP TABLE users; --","age":30}`, PlaceholderStyleDollar)
+		assert.Equal(t, "SELECT * FROM users WHERE name = $1 AND age > $2 AND team_id = $3", query)
+		assert.Equal(t, []interface{}{"'; DROP TABLE users; --", int64(30), int64(7)}, args)
+	})
+	t.Run("value types", func(t *testing.T) {
+		_, args := render(t, `{"name":"Line\nbreak \"quoted\"","age":30.5}`, PlaceholderStyleDollar)
+		assert.Equal(t, []interface{}{"Line\nbreak \"quoted\"", 30.5, int64(7)}, args)
+
+		_, args = render(t, `{"name":null,"age":true}`, PlaceholderStyleDollar)
+		assert.Equal(t, []interface{}{nil, true, int64(7)}, args)
+
+		_, args = render(t, `{"name":{"first":"Jens"},"age":[1,2]}`, PlaceholderStyleDollar)
+		assert.Equal(t, []interface{}{`{"first":"Jens"}`, `[1,2]`, int64(7)}, args)
+	})
+	t.Run("header values", func(t *testing.T) {
+		headerTemplate := InputTemplate{
+			Segments: []TemplateSegment{
+				(&HeaderVariable{Path: []string{"X-Tenant"}}).TemplateSegment(),
+				(&HeaderVariable{Path: []string{"X-Tenant"}, RenderAsArray: true}).TemplateSegment(),
+			},
+		}
+		ctx := &Context{
+			Request: Request{
+				Header: http.Header{"X-Tenant": []string{"a", "b"}},
+			},
+		}
+		buf := fastbuffer.New()
+		args, err := headerTemplate.RenderParameterized(ctx, nil, PlaceholderStyleQuestionMark, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "??", buf.String())
+		assert.Equal(t, []interface{}{"a,b", `["a","b"]`}, args)
+	})
+	t.Run("missing variable", func(t *testing.T) {
+		buf := fastbuffer.New()
+		_, err := template.RenderParameterized(&Context{Variables: []byte(`{}`)}, nil, PlaceholderStyleDollar, buf)
+		assert.Error(t, err)
+	})
+	t.Run("unknown placeholder style", func(t *testing.T) {
+		buf := fastbuffer.New()
+		_, err := template.RenderParameterized(&Context{Variables: []byte(`{"name":"Jens","age":30}`)}, []byte(`{"team":{"id":7}}`), PlaceholderStyle(0), buf)
+		assert.Error(t, err)
+	})
+}
+
+func TestContext_ResolveOptions(t *testing.T) {
+	ctx := NewContext(context.Background())
+	ctx.SetErrorsOnly(true)
+	ctx.SetCollectAllErrors(true)
+	ctx.SetFetchErrorsInResponse(true)
+	ctx.SetPreExtractedData(true)
+	ctx.SetMaxPreparedInputBytes(1024)
+	ctx.SetTracing(true)
+	ctx.resetPreparedInputBytes()
+	ctx.resetTruncatedArrays()
+	ctx.resetTracing(newFakeClock())
+
+	clone := ctx.Clone()
+	assert.Equal(t, ctx.resolveOptions, clone.resolveOptions)
+
+	ctx.Free()
+	assert.Equal(t, resolveOptions{}, ctx.resolveOptions)
+	assert.True(t, clone.errorsOnly)
 }
@@ -0,0 +1,103 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	sseDataPrefix       = []byte("data: ")
+	sseEventTerminator  = []byte("\n")
+	sseKeepAliveComment = []byte(": keep-alive\n\n")
+)
+
+// SSEWriter frames the output of ResolveGraphQLSubscription as Server-Sent Events (text/event-stream)
+// Everything written between two flushes is sent as one event, each line of the payload gets prefixed with "data: "
+// and the event is terminated with an empty line.
+type SSEWriter struct {
+	writer FlushWriter
+	clock  Clock
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	err    error
+}
+
+func NewSSEWriter(writer FlushWriter) *SSEWriter {
+	return &SSEWriter{
+		writer: writer,
+		clock:  realClock{},
+	}
+}
+
+// SetClock replaces the Clock used for the interval of StartKeepAlive
+func (s *SSEWriter) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// Write buffers the payload of the current event, it returns the error of a previous failed write to the underlying writer
+func (s *SSEWriter) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.buf.Write(p)
+}
+
+// Flush writes the buffered payload as one event and flushes the underlying writer, empty payloads are skipped
+func (s *SSEWriter) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() == 0 || s.err != nil {
+		return
+	}
+
+	event := make([]byte, 0, s.buf.Len()+len(sseDataPrefix)+2)
+	for _, line := range bytes.Split(bytes.TrimSuffix(s.buf.Bytes(), sseEventTerminator), sseEventTerminator) {
+		event = append(event, sseDataPrefix...)
+		event = append(event, line...)
+		event = append(event, sseEventTerminator...)
+	}
+	event = append(event, sseEventTerminator...)
+	s.buf.Reset()
+
+	s.writeAndFlush(event)
+}
+
+// StartKeepAlive periodically sends a ": keep-alive" comment, which is ignored by EventSource clients but keeps idle connections open
+// The keep-alive stops when ctx is done.
+func (s *SSEWriter) StartKeepAlive(ctx context.Context, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				_ = s.WriteHeartbeat()
+			}
+		}
+	}()
+}
+
+// WriteHeartbeat sends a ": keep-alive" comment, it's used for the heartbeats of ResolveGraphQLSubscription
+func (s *SSEWriter) WriteHeartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.writeAndFlush(sseKeepAliveComment)
+	return s.err
+}
+
+func (s *SSEWriter) writeAndFlush(p []byte) {
+	if _, err := s.writer.Write(p); err != nil {
+		s.err = err
+		return
+	}
+	s.writer.Flush()
+}
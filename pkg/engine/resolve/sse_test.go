@@ -0,0 +1,151 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEWriter(t *testing.T) {
+	t.Run("should frame subscription frames as events", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fakeStream := FakeStream(cancel, func(count int) (message string, ok bool) {
+			return fmt.Sprintf(`{"data":{"counter":%d}}`, count), count < 1
+		})
+
+		plan := &GraphQLSubscription{
+			Trigger: GraphQLSubscriptionTrigger{
+				Source: fakeStream,
+			},
+			Response: &GraphQLResponse{
+				Data: &Object{
+					Fields: []*Field{
+						{
+							Name: []byte("counter"),
+							Value: &Integer{
+								Path: []string{"counter"},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		out := &TestFlushWriter{
+			buf: bytes.Buffer{},
+		}
+
+		err := New(c).ResolveGraphQLSubscription(&Context{Context: c}, plan, NewSSEWriter(out))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"data: {\"data\":{\"counter\":0}}\n\n",
+			"data: {\"data\":{\"counter\":1}}\n\n",
+		}, out.flushed)
+	})
+
+	t.Run("should prefix each line of a multi line payload", func(t *testing.T) {
+		out := &TestFlushWriter{}
+		writer := NewSSEWriter(out)
+
+		_, err := writer.Write([]byte("{\n\"data\":null\n}\n"))
+		assert.NoError(t, err)
+		writer.Flush()
+
+		assert.Equal(t, []string{"data: {\ndata: \"data\":null\ndata: }\n\n"}, out.flushed)
+	})
+
+	t.Run("should not send empty events", func(t *testing.T) {
+		out := &TestFlushWriter{}
+		writer := NewSSEWriter(out)
+
+		writer.Flush()
+
+		assert.Len(t, out.flushed, 0)
+	})
+
+	t.Run("should return the error of a failed write", func(t *testing.T) {
+		writer := NewSSEWriter(&failingFlushWriter{})
+
+		_, err := writer.Write([]byte(`{"data":null}`))
+		assert.NoError(t, err)
+		writer.Flush()
+
+		_, err = writer.Write([]byte(`{"data":null}`))
+		assert.Equal(t, errWriteFailed, err)
+	})
+
+	t.Run("should send keep-alive comments until the context is done", func(t *testing.T) {
+		out := &lockedFlushWriter{}
+		writer := NewSSEWriter(out)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		writer.StartKeepAlive(ctx, time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			return strings.HasPrefix(out.String(), ": keep-alive\n\n")
+		}, time.Second, time.Millisecond)
+		cancel()
+	})
+
+	t.Run("should send a keep-alive comment each interval of the clock", func(t *testing.T) {
+		out := &lockedFlushWriter{}
+		writer := NewSSEWriter(out)
+		clock := newFakeClock()
+		writer.SetClock(clock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		writer.StartKeepAlive(ctx, time.Second)
+
+		clock.Advance(time.Millisecond * 999)
+		assert.Equal(t, "", out.String())
+
+		clock.Advance(time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return out.String() == ": keep-alive\n\n"
+		}, time.Second, time.Millisecond)
+
+		clock.Advance(time.Second)
+		assert.Eventually(t, func() bool {
+			return out.String() == ": keep-alive\n\n: keep-alive\n\n"
+		}, time.Second, time.Millisecond)
+	})
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingFlushWriter struct{}
+
+func (f *failingFlushWriter) Write(p []byte) (n int, err error) {
+	return 0, errWriteFailed
+}
+
+func (f *failingFlushWriter) Flush() {}
+
+type lockedFlushWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *lockedFlushWriter) Write(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *lockedFlushWriter) Flush() {}
+
+func (l *lockedFlushWriter) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
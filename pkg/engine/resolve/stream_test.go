@@ -32,9 +32,9 @@ func TestArrayStream(t *testing.T) {
 						Name:      []byte("users"),
 						Value: &Array{
 							Stream: Stream{
-								Enabled:          true,
-								InitialBatchSize: 0,
-								PatchIndex:       0,
+								Enabled:      true,
+								InitialCount: 0,
+								PatchIndex:   0,
 							},
 						},
 					},
@@ -64,7 +64,7 @@ func TestArrayStream(t *testing.T) {
 		},
 	}
 
-	c,cancel := context.WithCancel(context.Background())
+	c, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	resolver := New(c)
@@ -112,9 +112,9 @@ func TestArrayStream_InitialBatch_1(t *testing.T) {
 						Name:      []byte("users"),
 						Value: &Array{
 							Stream: Stream{
-								Enabled:          true,
-								InitialBatchSize: 1,
-								PatchIndex:       0,
+								Enabled:      true,
+								InitialCount: 1,
+								PatchIndex:   0,
 							},
 							Item: &Object{
 								Fields: []*Field{
@@ -160,7 +160,7 @@ func TestArrayStream_InitialBatch_1(t *testing.T) {
 		},
 	}
 
-	c,cancel := context.WithCancel(context.Background())
+	c, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	resolver := New(c)
@@ -204,9 +204,9 @@ func TestArrayStream_InitialBatch_2(t *testing.T) {
 						Name:      []byte("users"),
 						Value: &Array{
 							Stream: Stream{
-								Enabled:          true,
-								InitialBatchSize: 2,
-								PatchIndex:       0,
+								Enabled:      true,
+								InitialCount: 2,
+								PatchIndex:   0,
 							},
 							Item: &Object{
 								Fields: []*Field{
@@ -252,7 +252,95 @@ func TestArrayStream_InitialBatch_2(t *testing.T) {
 		},
 	}
 
-	c,cancel := context.WithCancel(context.Background())
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resolver := New(c)
+
+	ctx := NewContext(context.Background())
+
+	writer := &TestFlushWriter{}
+
+	err := resolver.ResolveGraphQLStreamingResponse(ctx, res, nil, writer)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(writer.flushed))
+
+	expected, err := ioutil.ReadFile("./testdata/stream_5.json")
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), writer.flushed[0])
+}
+
+func TestArrayStream_InitialCountExceedsListLength(t *testing.T) {
+
+	controller := gomock.NewController(t)
+
+	userService := fakeService(t, controller, "user", "./testdata/users.json",
+		"")
+
+	res := &GraphQLStreamingResponse{
+		InitialResponse: &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					DataSource: userService,
+					BufferId:   0,
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("users"),
+						Value: &Array{
+							Stream: Stream{
+								Enabled:      true,
+								InitialCount: 5,
+								PatchIndex:   0,
+							},
+							Item: &Object{
+								Fields: []*Field{
+									{
+										Name: []byte("id"),
+										Value: &Integer{
+											Path: []string{"id"},
+										},
+									},
+									{
+										Name: []byte("name"),
+										Value: &String{
+											Path: []string{"name"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Patches: []*GraphQLResponsePatch{
+			{
+				Operation: literal.ADD,
+				Value: &Object{
+					Fields: []*Field{
+						{
+							Name: []byte("id"),
+							Value: &Integer{
+								Path: []string{"id"},
+							},
+						},
+						{
+							Name: []byte("name"),
+							Value: &String{
+								Path: []string{"name"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	resolver := New(c)
@@ -296,9 +384,9 @@ func TestStreamAndDefer(t *testing.T) {
 						Name:      []byte("users"),
 						Value: &Array{
 							Stream: Stream{
-								Enabled:          true,
-								InitialBatchSize: 0,
-								PatchIndex:       0,
+								Enabled:      true,
+								InitialCount: 0,
+								PatchIndex:   0,
 							},
 						},
 					},
@@ -371,7 +459,7 @@ func TestStreamAndDefer(t *testing.T) {
 		},
 	}
 
-	c,cancel := context.WithCancel(context.Background())
+	c, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	resolver := New(c)
@@ -0,0 +1,171 @@
+package resolve
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cespare/xxhash"
+)
+
+// MultiplexingSubscriptionDataSource shares one upstream subscription between all subscribers with the same input
+// The upstream is started by the first subscriber and canceled once the contexts of all subscribers are done,
+// canceling a single subscriber doesn't affect the others.
+// Frames are sent to the subscribers one after another, so a slow subscriber delays the frames of the others,
+// SubscriptionBufferModeDropOldest decouples slow clients from the upstream.
+// Errors of an ErrorReportingSubscriptionDataSource are sent to all subscribers.
+type MultiplexingSubscriptionDataSource struct {
+	source  SubscriptionDataSource
+	mu      sync.Mutex
+	streams map[uint64]*multiplexedStream
+}
+
+type multiplexedStream struct {
+	cancel      context.CancelFunc
+	subscribers map[*multiplexSubscriber]struct{}
+}
+
+type multiplexSubscriber struct {
+	ctx  context.Context
+	next chan<- []byte
+	errs chan<- error
+}
+
+func NewMultiplexingSubscriptionDataSource(source SubscriptionDataSource) *MultiplexingSubscriptionDataSource {
+	return &MultiplexingSubscriptionDataSource{
+		source:  source,
+		streams: map[uint64]*multiplexedStream{},
+	}
+}
+
+func (m *MultiplexingSubscriptionDataSource) Start(ctx context.Context, input []byte, next chan<- []byte) error {
+	return m.StartWithErrors(ctx, input, next, nil)
+}
+
+func (m *MultiplexingSubscriptionDataSource) StartWithErrors(ctx context.Context, input []byte, next chan<- []byte, errs chan<- error) error {
+	streamID := xxhash.Sum64(input)
+	subscriber := &multiplexSubscriber{
+		ctx:  ctx,
+		next: next,
+		errs: errs,
+	}
+
+	m.mu.Lock()
+	stream, ok := m.streams[streamID]
+	if !ok {
+		var err error
+		stream, err = m.startStream(streamID, input)
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+	}
+	stream.subscribers[subscriber] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.unsubscribe(streamID, stream, subscriber)
+	}()
+	return nil
+}
+
+// ActiveStreams returns the number of upstream subscriptions
+func (m *MultiplexingSubscriptionDataSource) ActiveStreams() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.streams)
+}
+
+// startStream must be called with mu locked
+func (m *MultiplexingSubscriptionDataSource) startStream(streamID uint64, input []byte) (*multiplexedStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	next := make(chan []byte)
+	errs := make(chan error, 1)
+
+	var err error
+	if source, ok := m.source.(ErrorReportingSubscriptionDataSource); ok {
+		err = source.StartWithErrors(ctx, input, next, errs)
+	} else {
+		err = m.source.Start(ctx, input, next)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stream := &multiplexedStream{
+		cancel:      cancel,
+		subscribers: map[*multiplexSubscriber]struct{}{},
+	}
+	m.streams[streamID] = stream
+	go m.fanOut(ctx, streamID, stream, next, errs)
+	return stream, nil
+}
+
+func (m *MultiplexingSubscriptionDataSource) fanOut(ctx context.Context, streamID uint64, stream *multiplexedStream, next <-chan []byte, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			for _, subscriber := range m.endStream(streamID, stream) {
+				if subscriber.errs != nil {
+					select {
+					case subscriber.errs <- err:
+					default:
+					}
+				}
+			}
+			return
+		case data, ok := <-next:
+			if !ok {
+				for _, subscriber := range m.endStream(streamID, stream) {
+					close(subscriber.next)
+				}
+				return
+			}
+			for _, subscriber := range m.subscribers(stream) {
+				select {
+				case subscriber.next <- data:
+				case <-subscriber.ctx.Done():
+				}
+			}
+		}
+	}
+}
+
+func (m *MultiplexingSubscriptionDataSource) subscribers(stream *multiplexedStream) []*multiplexSubscriber {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscribers := make([]*multiplexSubscriber, 0, len(stream.subscribers))
+	for subscriber := range stream.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers
+}
+
+// endStream removes the stream after the upstream ended and returns its subscribers
+func (m *MultiplexingSubscriptionDataSource) endStream(streamID uint64, stream *multiplexedStream) []*multiplexSubscriber {
+	subscribers := m.subscribers(stream)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams[streamID] == stream {
+		delete(m.streams, streamID)
+	}
+	stream.subscribers = map[*multiplexSubscriber]struct{}{}
+	stream.cancel()
+	return subscribers
+}
+
+func (m *MultiplexingSubscriptionDataSource) unsubscribe(streamID uint64, stream *multiplexedStream, subscriber *multiplexSubscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(stream.subscribers, subscriber)
+	if len(stream.subscribers) != 0 {
+		return
+	}
+	if m.streams[streamID] == stream {
+		delete(m.streams, streamID)
+	}
+	stream.cancel()
+}
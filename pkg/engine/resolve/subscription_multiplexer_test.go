@@ -0,0 +1,160 @@
+package resolve
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type upstreamSubscriptionDataSource struct {
+	mu     sync.Mutex
+	starts int
+	ctx    context.Context
+	next   chan<- []byte
+}
+
+func (u *upstreamSubscriptionDataSource) Start(ctx context.Context, input []byte, next chan<- []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.starts++
+	u.ctx = ctx
+	u.next = next
+	return nil
+}
+
+func (u *upstreamSubscriptionDataSource) upstream() (context.Context, chan<- []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.ctx, u.next
+}
+
+func TestMultiplexingSubscriptionDataSource(t *testing.T) {
+	receive := func(t *testing.T, next chan []byte) string {
+		select {
+		case data := <-next:
+			return string(data)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for frame")
+			return ""
+		}
+	}
+
+	t.Run("should share one upstream between two subscribers", func(t *testing.T) {
+		source := &upstreamSubscriptionDataSource{}
+		multiplexer := NewMultiplexingSubscriptionDataSource(source)
+
+		first, cancelFirst := context.WithCancel(context.Background())
+		defer cancelFirst()
+		second, cancelSecond := context.WithCancel(context.Background())
+		defer cancelSecond()
+
+		firstNext := make(chan []byte, 2)
+		secondNext := make(chan []byte, 2)
+		assert.NoError(t, multiplexer.Start(first, []byte(`{"query":"subscription{counter}"}`), firstNext))
+		assert.NoError(t, multiplexer.Start(second, []byte(`{"query":"subscription{counter}"}`), secondNext))
+		assert.Equal(t, 1, source.starts)
+		assert.Equal(t, 1, multiplexer.ActiveStreams())
+
+		_, upstreamNext := source.upstream()
+		go func() {
+			upstreamNext <- []byte(`{"data":{"counter":1}}`)
+		}()
+		assert.Equal(t, `{"data":{"counter":1}}`, receive(t, firstNext))
+		assert.Equal(t, `{"data":{"counter":1}}`, receive(t, secondNext))
+	})
+
+	t.Run("should start one upstream per input", func(t *testing.T) {
+		source := &upstreamSubscriptionDataSource{}
+		multiplexer := NewMultiplexingSubscriptionDataSource(source)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NoError(t, multiplexer.Start(ctx, []byte(`{"query":"subscription{counter}"}`), make(chan []byte)))
+		assert.NoError(t, multiplexer.Start(ctx, []byte(`{"query":"subscription{remainingJedis}"}`), make(chan []byte)))
+		assert.Equal(t, 2, source.starts)
+		assert.Equal(t, 2, multiplexer.ActiveStreams())
+	})
+
+	t.Run("should keep the upstream running when one subscriber cancels", func(t *testing.T) {
+		source := &upstreamSubscriptionDataSource{}
+		multiplexer := NewMultiplexingSubscriptionDataSource(source)
+
+		first, cancelFirst := context.WithCancel(context.Background())
+		second, cancelSecond := context.WithCancel(context.Background())
+		defer cancelSecond()
+
+		firstNext := make(chan []byte, 2)
+		secondNext := make(chan []byte, 2)
+		assert.NoError(t, multiplexer.Start(first, []byte(`{"query":"subscription{counter}"}`), firstNext))
+		assert.NoError(t, multiplexer.Start(second, []byte(`{"query":"subscription{counter}"}`), secondNext))
+
+		upstreamCtx, upstreamNext := source.upstream()
+		cancelFirst()
+
+		go func() {
+			upstreamNext <- []byte(`{"data":{"counter":1}}`)
+			upstreamNext <- []byte(`{"data":{"counter":2}}`)
+		}()
+		assert.Equal(t, `{"data":{"counter":1}}`, receive(t, secondNext))
+		assert.Equal(t, `{"data":{"counter":2}}`, receive(t, secondNext))
+		assert.NoError(t, upstreamCtx.Err())
+		assert.Equal(t, 1, multiplexer.ActiveStreams())
+	})
+
+	t.Run("should tear down the upstream when the last subscriber cancels", func(t *testing.T) {
+		source := &upstreamSubscriptionDataSource{}
+		multiplexer := NewMultiplexingSubscriptionDataSource(source)
+
+		first, cancelFirst := context.WithCancel(context.Background())
+		second, cancelSecond := context.WithCancel(context.Background())
+
+		assert.NoError(t, multiplexer.Start(first, []byte(`{"query":"subscription{counter}"}`), make(chan []byte)))
+		assert.NoError(t, multiplexer.Start(second, []byte(`{"query":"subscription{counter}"}`), make(chan []byte)))
+
+		upstreamCtx, _ := source.upstream()
+		cancelFirst()
+		cancelSecond()
+
+		assert.Eventually(t, func() bool {
+			return upstreamCtx.Err() != nil
+		}, time.Second, time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return multiplexer.ActiveStreams() == 0
+		}, time.Second, time.Millisecond)
+
+		third, cancelThird := context.WithCancel(context.Background())
+		defer cancelThird()
+		assert.NoError(t, multiplexer.Start(third, []byte(`{"query":"subscription{counter}"}`), make(chan []byte)))
+		assert.Equal(t, 2, source.starts)
+	})
+
+	t.Run("should close all subscribers when the upstream completes", func(t *testing.T) {
+		source := &upstreamSubscriptionDataSource{}
+		multiplexer := NewMultiplexingSubscriptionDataSource(source)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		firstNext := make(chan []byte, 2)
+		secondNext := make(chan []byte, 2)
+		assert.NoError(t, multiplexer.Start(ctx, []byte(`{"query":"subscription{counter}"}`), firstNext))
+		assert.NoError(t, multiplexer.Start(ctx, []byte(`{"query":"subscription{counter}"}`), secondNext))
+
+		_, upstreamNext := source.upstream()
+		close(upstreamNext)
+
+		for _, next := range []chan []byte{firstNext, secondNext} {
+			select {
+			case _, ok := <-next:
+				assert.False(t, ok)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for close")
+			}
+		}
+		assert.Equal(t, 0, multiplexer.ActiveStreams())
+	})
+}
@@ -0,0 +1,108 @@
+package resolve
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var literalTracing = []byte("tracing")
+
+// tracing records the timings of the fetches of a response in the Apollo Tracing format, see Context.SetTracing
+type tracing struct {
+	mu        sync.Mutex
+	clock     Clock
+	startTime time.Time
+	resolvers bytes.Buffer
+}
+
+func newTracing(clock Clock) *tracing {
+	return &tracing{
+		clock:     clock,
+		startTime: clock.Now(),
+	}
+}
+
+// SetTracing enables the Apollo Tracing extension, the start offset and duration of each fetch is added to the "tracing" extension
+// of the response. Fetches are traced as resolver entries of their TraceField, subscriptions aren't traced.
+func (c *Context) SetTracing(enabled bool) {
+	c.tracingEnabled = enabled
+}
+
+func (c *Context) resetTracing(clock Clock) {
+	c.tracing = nil
+	if c.tracingEnabled {
+		c.tracing = newTracing(clock)
+	}
+}
+
+// TraceField describes the root field resolved by a SingleFetch, it's the resolver entry of the fetch in the Apollo Tracing extension
+type TraceField struct {
+	// ResponseName is the alias or the name of the field, it's appended to the path of the object the fetch is attached to
+	ResponseName string
+	ParentType   string
+	FieldName    string
+	// ReturnType is the printed type of the field, e.g. "[User!]"
+	ReturnType string
+}
+
+// addFetchTrace records a fetch which was loaded from start to end as resolver entry of its TraceField
+// Fetches without a TraceField aren't traced.
+func (c *Context) addFetchTrace(fetch *SingleFetch, start, end time.Time) {
+	if c.tracing == nil || fetch.TraceField == nil {
+		return
+	}
+	c.tracing.mu.Lock()
+	defer c.tracing.mu.Unlock()
+	resolvers := &c.tracing.resolvers
+	if resolvers.Len() != 0 {
+		resolvers.Write(comma)
+	}
+	resolvers.WriteString(`{"path":`)
+	c.writeTracePath(resolvers, fetch.TraceField.ResponseName)
+	resolvers.WriteString(`,"parentType":`)
+	resolvers.WriteString(strconv.Quote(fetch.TraceField.ParentType))
+	resolvers.WriteString(`,"fieldName":`)
+	resolvers.WriteString(strconv.Quote(fetch.TraceField.FieldName))
+	resolvers.WriteString(`,"returnType":`)
+	resolvers.WriteString(strconv.Quote(fetch.TraceField.ReturnType))
+	resolvers.WriteString(`,"startOffset":`)
+	resolvers.WriteString(strconv.FormatInt(start.Sub(c.tracing.startTime).Nanoseconds(), 10))
+	resolvers.WriteString(`,"duration":`)
+	resolvers.WriteString(strconv.FormatInt(end.Sub(start).Nanoseconds(), 10))
+	resolvers.Write(rBrace)
+}
+
+// writeTracePath writes the current path with the response name of the traced field appended
+// The path elements of the Context are shared by parallel fetches, so the field isn't added as path element.
+func (c *Context) writeTracePath(buf *bytes.Buffer, responseName string) {
+	start := buf.Len()
+	c.writeErrorPath(buf)
+	buf.Truncate(buf.Len() - len(rBrack))
+	if buf.Len()-start > len(lBrack) {
+		buf.Write(comma)
+	}
+	buf.WriteString(strconv.Quote(responseName))
+	buf.Write(rBrack)
+}
+
+// writeTo writes the "tracing" extension, the response ends when it's written
+func (t *tracing) writeTo(buf *bytes.Buffer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	endTime := t.clock.Now()
+	buf.Write(quote)
+	buf.Write(literalTracing)
+	buf.Write(quote)
+	buf.Write(colon)
+	buf.WriteString(`{"version":1,"startTime":"`)
+	buf.WriteString(t.startTime.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`","endTime":"`)
+	buf.WriteString(endTime.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`","duration":`)
+	buf.WriteString(strconv.FormatInt(endTime.Sub(t.startTime).Nanoseconds(), 10))
+	buf.WriteString(`,"execution":{"resolvers":[`)
+	buf.Write(t.resolvers.Bytes())
+	buf.WriteString(`]}}`)
+}
@@ -0,0 +1,112 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// advancingDataSource advances the clock by latency on each load to simulate a slow upstream
+type advancingDataSource struct {
+	clock   *fakeClock
+	latency time.Duration
+	data    string
+}
+
+func (a *advancingDataSource) Load(ctx context.Context, input []byte, w io.Writer) error {
+	a.clock.Advance(a.latency)
+	_, err := w.Write([]byte(a.data))
+	return err
+}
+
+func TestResolver_Tracing(t *testing.T) {
+	clock := newFakeClock()
+	response := &GraphQLResponse{
+		Data: &Object{
+			Fetch: &SingleFetch{
+				BufferId:             0,
+				DataSource:           &advancingDataSource{clock: clock, latency: time.Millisecond * 10, data: `{"user":{"id":1}}`},
+				DataSourceIdentifier: []byte("users"),
+				TraceField:           &TraceField{ResponseName: "user", ParentType: "Query", FieldName: "user", ReturnType: "User"},
+			},
+			Fields: []*Field{
+				{
+					HasBuffer: true,
+					BufferID:  0,
+					Name:      []byte("user"),
+					Value: &Object{
+						Path: []string{"user"},
+						Fetch: &SingleFetch{
+							BufferId:             1,
+							DataSource:           &advancingDataSource{clock: clock, latency: time.Millisecond * 20, data: `{"name":"Jens"}`},
+							DataSourceIdentifier: []byte("names"),
+							TraceField:           &TraceField{ResponseName: "name", ParentType: "User", FieldName: "name", ReturnType: "String!"},
+						},
+						Fields: []*Field{
+							{
+								HasBuffer: true,
+								BufferID:  1,
+								Name:      []byte("name"),
+								Value: &String{
+									Path: []string{"name"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	resolver := New(context.Background())
+	resolver.SetClock(clock)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := resolver.ResolveGraphQLResponse(NewContext(context.Background()), response, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"user":{"name":"Jens"}}}`, buf.String())
+	})
+
+	t.Run("two fetches", func(t *testing.T) {
+		ctx := NewContext(context.Background())
+		ctx.SetTracing(true)
+		buf := &bytes.Buffer{}
+		err := resolver.ResolveGraphQLResponse(ctx, response, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"user":{"name":"Jens"}},"extensions":{"tracing":{"version":1,"startTime":"2021-01-01T00:00:00.03Z","endTime":"2021-01-01T00:00:00.06Z","duration":30000000,"execution":{"resolvers":[`+
+			`{"path":["user"],"parentType":"Query","fieldName":"user","returnType":"User","startOffset":0,"duration":10000000},`+
+			`{"path":["user","name"],"parentType":"User","fieldName":"name","returnType":"String!","startOffset":10000000,"duration":20000000}]}}}}`, buf.String())
+	})
+
+	t.Run("fetches without trace field", func(t *testing.T) {
+		untraced := &GraphQLResponse{
+			Data: &Object{
+				Fetch: &SingleFetch{
+					BufferId:   0,
+					DataSource: &advancingDataSource{clock: clock, latency: time.Millisecond * 10, data: `{"id":1}`},
+				},
+				Fields: []*Field{
+					{
+						HasBuffer: true,
+						BufferID:  0,
+						Name:      []byte("id"),
+						Value: &Integer{
+							Path: []string{"id"},
+						},
+					},
+				},
+			},
+		}
+		ctx := NewContext(context.Background())
+		ctx.SetTracing(true)
+		buf := &bytes.Buffer{}
+		err := resolver.ResolveGraphQLResponse(ctx, untraced, nil, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"data":{"id":1},"extensions":{"tracing":{"version":1,"startTime":"2021-01-01T00:00:00.06Z","endTime":"2021-01-01T00:00:00.07Z","duration":10000000,"execution":{"resolvers":[]}}}}`, buf.String())
+	})
+}
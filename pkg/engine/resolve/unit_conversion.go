@@ -0,0 +1,139 @@
+package resolve
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
+)
+
+// RoundingMode defines how a converted value is rounded to the digits supported by the field
+type RoundingMode int
+
+const (
+	// RoundingModeHalfAwayFromZero rounds ties away from zero, e.g. 2.5 to 3 and -2.5 to -3
+	RoundingModeHalfAwayFromZero RoundingMode = iota
+	// RoundingModeHalfEven rounds ties to the nearest even digit, e.g. 2.5 to 2 and 3.5 to 4
+	RoundingModeHalfEven
+	// RoundingModeTowardZero truncates the digits, e.g. 2.7 to 2 and -2.7 to -2
+	RoundingModeTowardZero
+	// RoundingModeFloor rounds towards negative infinity, e.g. 2.7 to 2 and -2.7 to -3
+	RoundingModeFloor
+	// RoundingModeCeiling rounds towards positive infinity, e.g. 2.2 to 3 and -2.2 to -2
+	RoundingModeCeiling
+)
+
+// UnitConversion converts a numeric upstream value into the unit of the client, e.g. cents to dollars with a Divisor of 100
+// The value is multiplied with Multiplier and divided by Divisor using exact decimal arithmetic.
+// Integer values are rounded to a whole number and must fit into an int64,
+// Float values are only rounded if the Float has a Precision and must fit into a float64.
+// Values which are out of range resolve to null or violate the non-null constraint.
+type UnitConversion struct {
+	// Multiplier is applied to the upstream value, 0 is treated as 1
+	Multiplier float64
+	// Divisor divides the multiplied value, 0 is treated as 1
+	Divisor float64
+	// Rounding is the RoundingMode used for digits which can't be represented by the field
+	Rounding RoundingMode
+}
+
+// convertInteger returns the converted JSON number rounded to a whole number
+func (u *UnitConversion) convertInteger(value []byte) ([]byte, error) {
+	converted, err := u.convert(value)
+	if err != nil {
+		return nil, err
+	}
+	rounded := roundRat(converted, 0, u.Rounding)
+	if !rounded.IsInt64() {
+		return nil, errNumberOutOfRange
+	}
+	return rounded.Append(nil, 10), nil
+}
+
+// convertFloat returns the converted JSON number as decimal number without exponent,
+// rounded to at most precision digits after the decimal point if precision is greater than 0
+func (u *UnitConversion) convertFloat(value []byte, precision int) ([]byte, error) {
+	converted, err := u.convert(value)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := converted.Float64()
+	if math.IsInf(f, 0) {
+		return nil, errNumberOutOfRange
+	}
+	if precision <= 0 {
+		return strconv.AppendFloat(nil, f, 'f', -1, 64), nil
+	}
+	return formatDecimal(roundRat(converted, precision, u.Rounding), precision), nil
+}
+
+func (u *UnitConversion) convert(value []byte) (*big.Rat, error) {
+	// values exceeding the range of float64 return ErrRange, this also limits the exponent parsed by big.Rat
+	if _, err := strconv.ParseFloat(unsafebytes.BytesToString(value), 64); err != nil {
+		return nil, err
+	}
+	converted, ok := new(big.Rat).SetString(string(value))
+	if !ok {
+		return nil, errNumberOutOfRange
+	}
+	if u.Multiplier != 0 {
+		converted.Mul(converted, decimalRat(u.Multiplier))
+	}
+	if u.Divisor != 0 {
+		converted.Quo(converted, decimalRat(u.Divisor))
+	}
+	return converted, nil
+}
+
+// decimalRat returns the shortest decimal representation of f, so that e.g. 0.3048 is not converted with its binary approximation
+func decimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r
+}
+
+// roundRat returns value * 10^precision rounded to a whole number
+func roundRat(value *big.Rat, precision int, mode RoundingMode) *big.Int {
+	numerator := new(big.Int).Mul(value.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil))
+	quotient, remainder := new(big.Int).QuoRem(numerator, value.Denom(), new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	// compare the remainder with half of the denominator to detect ties
+	doubleRemainder := remainder.Abs(remainder).Lsh(remainder, 1)
+	half := doubleRemainder.Cmp(value.Denom())
+
+	var awayFromZero bool
+	switch mode {
+	case RoundingModeHalfEven:
+		awayFromZero = half > 0 || (half == 0 && quotient.Bit(0) == 1)
+	case RoundingModeTowardZero:
+		awayFromZero = false
+	case RoundingModeFloor:
+		awayFromZero = numerator.Sign() < 0
+	case RoundingModeCeiling:
+		awayFromZero = numerator.Sign() > 0
+	default:
+		awayFromZero = half >= 0
+	}
+	if awayFromZero {
+		quotient.Add(quotient, big.NewInt(int64(numerator.Sign())))
+	}
+	return quotient
+}
+
+// formatDecimal formats value / 10^precision without trailing zeros
+func formatDecimal(value *big.Int, precision int) []byte {
+	digits := new(big.Int).Abs(value).String()
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	digits = digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+	digits = strings.TrimSuffix(strings.TrimRight(digits, "0"), ".")
+	if value.Sign() < 0 {
+		return append([]byte("-"), digits...)
+	}
+	return []byte(digits)
+}
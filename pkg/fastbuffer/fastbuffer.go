@@ -40,6 +40,11 @@ func (f *FastBuffer) Len() int {
 	return len(f.b)
 }
 
+// Cap returns the capacity of the underlying slice, it doesn't shrink on Reset
+func (f *FastBuffer) Cap() int {
+	return cap(f.b)
+}
+
 func (f *FastBuffer) UnsafeString() string {
 	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&f.b))
 	stringHeader := reflect.StringHeader{Data: sliceHeader.Data, Len: sliceHeader.Len}
@@ -2,6 +2,7 @@ package graphql
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
@@ -20,10 +21,12 @@ type Errors interface {
 type RequestErrors []RequestError
 
 func RequestErrorsFromError(err error) RequestErrors {
-	if errors, ok := err.(RequestErrors); ok {
-		return errors
+	var requestErrors RequestErrors
+	if errors.As(err, &requestErrors) {
+		return requestErrors
 	}
-	if report, ok := err.(operationreport.Report); ok {
+	var report operationreport.Report
+	if errors.As(err, &report) {
 		if len(report.ExternalErrors) == 0 {
 			return RequestErrors{
 				{
@@ -31,9 +34,8 @@ func RequestErrorsFromError(err error) RequestErrors {
 				},
 			}
 		}
-		var errors RequestErrors
 		for _, externalError := range report.ExternalErrors {
-			errors = append(errors, RequestError{
+			requestErrors = append(requestErrors, RequestError{
 				Message:   externalError.Message,
 				Locations: externalError.Locations,
 				Path: ErrorPath{
@@ -41,7 +43,7 @@ func RequestErrorsFromError(err error) RequestErrors {
 				},
 			})
 		}
-		return errors
+		return requestErrors
 	}
 	return RequestErrors{
 		{
@@ -111,19 +113,22 @@ func (o RequestErrors) ErrorByIndex(i int) error {
 }
 
 type RequestError struct {
-	Message   string                   `json:"message"`
-	Locations []graphqlerrors.Location `json:"locations,omitempty"`
-	Path      ErrorPath                `json:"path"`
+	Message    string                   `json:"message"`
+	Locations  []graphqlerrors.Location `json:"locations,omitempty"`
+	Path       ErrorPath                `json:"path"`
+	Extensions json.RawMessage          `json:"extensions,omitempty"`
 }
 
 func (o RequestError) MarshalJSON() ([]byte, error) {
 	if o.Path.Len() == 0 {
 		return json.Marshal(struct {
-			Message   string                   `json:"message"`
-			Locations []graphqlerrors.Location `json:"locations,omitempty"`
+			Message    string                   `json:"message"`
+			Locations  []graphqlerrors.Location `json:"locations,omitempty"`
+			Extensions json.RawMessage          `json:"extensions,omitempty"`
 		}{
-			Message:   o.Message,
-			Locations: o.Locations,
+			Message:    o.Message,
+			Locations:  o.Locations,
+			Extensions: o.Extensions,
 		})
 	}
 	path, err := o.Path.MarshalJSON()
@@ -131,13 +136,15 @@ func (o RequestError) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 	return json.Marshal(struct {
-		Message   string                   `json:"message"`
-		Locations []graphqlerrors.Location `json:"locations,omitempty"`
-		Path      json.RawMessage          `json:"path"`
+		Message    string                   `json:"message"`
+		Locations  []graphqlerrors.Location `json:"locations,omitempty"`
+		Path       json.RawMessage          `json:"path"`
+		Extensions json.RawMessage          `json:"extensions,omitempty"`
 	}{
-		Message:   o.Message,
-		Locations: o.Locations,
-		Path:      path,
+		Message:    o.Message,
+		Locations:  o.Locations,
+		Path:       path,
+		Extensions: o.Extensions,
 	})
 }
 
@@ -145,6 +152,75 @@ func (o RequestError) Error() string {
 	return fmt.Sprintf("%s, locations: %+v, path: %s", o.Message, o.Locations, o.Path.String())
 }
 
+// ErrorCategory tells whether an error returned by ExecutionEngineV2.Execute is caused by the request or is an internal error,
+// e.g. to respond with status code 400 or 500
+type ErrorCategory int
+
+const (
+	// ErrorCategoryRequest is an error of the request, e.g. a malformed or invalid operation
+	ErrorCategoryRequest ErrorCategory = iota
+	// ErrorCategoryInternal is an error of the engine, e.g. a data source failing to plan a valid operation
+	ErrorCategoryInternal
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case ErrorCategoryRequest:
+		return "request"
+	case ErrorCategoryInternal:
+		return "internal"
+	default:
+		return "unknown"
+	}
+}
+
+type executionError struct {
+	Category ErrorCategory
+	// Err is the underlying error, e.g. RequestErrors or an operationreport.Report
+	Err error
+}
+
+func (e *executionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *executionError) Unwrap() error {
+	return e.Err
+}
+
+// NormalizationError is returned by ExecutionEngineV2.Execute if the operation can't be parsed or normalized
+type NormalizationError struct {
+	executionError
+}
+
+// ValidationError is returned by ExecutionEngineV2.Execute if the operation isn't valid for the schema
+type ValidationError struct {
+	executionError
+}
+
+// PlanningError is returned by ExecutionEngineV2.Execute if the execution plan of a valid operation can't be created
+type PlanningError struct {
+	executionError
+}
+
+func newNormalizationError(category ErrorCategory, err error) *NormalizationError {
+	return &NormalizationError{executionError{Category: category, Err: err}}
+}
+
+func newValidationError(category ErrorCategory, err error) *ValidationError {
+	return &ValidationError{executionError{Category: category, Err: err}}
+}
+
+// newPlanningError categorizes the report as a request error if the planner only rejected the operation,
+// e.g. because of an invalid directive argument
+func newPlanningError(report operationreport.Report) *PlanningError {
+	category := ErrorCategoryInternal
+	if len(report.InternalErrors) == 0 {
+		category = ErrorCategoryRequest
+	}
+	return &PlanningError{executionError{Category: category, Err: report}}
+}
+
 type SchemaValidationErrors []SchemaValidationError
 
 func schemaValidationErrorsFromOperationReport(report operationreport.Report) (errors SchemaValidationErrors) {
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/jensneuse/abstractlogger"
@@ -20,10 +22,28 @@ import (
 	"github.com/jensneuse/graphql-go-tools/pkg/postprocess"
 )
 
+const defaultExecutionPlanCacheSize = 1024
+
+// responseWithErrorsPrefix is the start of resolved responses with errors, the errors are written before the data
+var responseWithErrorsPrefix = []byte(`{"errors":`)
+
 type EngineV2Configuration struct {
 	schema                   *Schema
 	plannerConfig            plan.Configuration
 	websocketBeforeStartHook WebsocketBeforeStartHook
+	executionPlanCacheSize   int
+	executionMetricsHook     ExecutionMetricsHook
+	planCacheObserver        func(hit bool)
+	persistedQueryStore      PersistedQueryStore
+	maxQueryDepth            int
+	exemptIntrospection      bool
+	resolveContextFactory    ResolveContextFactory
+	maxComplexity            int
+	complexityCalculator     ComplexityCalculator
+	complexityObserver       func(result ComplexityResult)
+	responseTransform        resolve.ResponseTransformFunc
+	responseCache            ResponseCache
+	responseCacheTTL         time.Duration
 }
 
 func NewEngineV2Configuration(schema *Schema) EngineV2Configuration {
@@ -34,6 +54,8 @@ func NewEngineV2Configuration(schema *Schema) EngineV2Configuration {
 			DataSources:          []plan.DataSourceConfiguration{},
 			Fields:               plan.FieldConfigurations{},
 		},
+		executionPlanCacheSize: defaultExecutionPlanCacheSize,
+		complexityCalculator:   NewFieldCostComplexityCalculator(),
 	}
 }
 
@@ -53,14 +75,85 @@ func (e *EngineV2Configuration) SetFieldConfigurations(fieldConfigs plan.FieldCo
 	e.plannerConfig.Fields = fieldConfigs
 }
 
+// SetExecutionPlanCacheSize - sets the number of execution plans kept in the LRU cache (default: 1024)
+// A size of 0 disables caching, so every operation gets planned on each execution
+func (e *EngineV2Configuration) SetExecutionPlanCacheSize(size int) {
+	e.executionPlanCacheSize = size
+}
+
 // SetWebsocketBeforeStartHook - sets before start hook which will be called before processing any operation sent over websockets
 func (e *EngineV2Configuration) SetWebsocketBeforeStartHook(hook WebsocketBeforeStartHook) {
 	e.websocketBeforeStartHook = hook
 }
 
+// SetExecutionMetricsHook - sets a hook which will be called with the duration of each phase of an execution
+func (e *EngineV2Configuration) SetExecutionMetricsHook(hook ExecutionMetricsHook) {
+	e.executionMetricsHook = hook
+}
+
+// SetPlanCacheObserver - sets an observer which will be called once per execution, hit is true if the execution plan was cached
+func (e *EngineV2Configuration) SetPlanCacheObserver(observer func(hit bool)) {
+	e.planCacheObserver = observer
+}
+
+// SetPersistedQueryStore - enables Automatic Persisted Queries (APQ) using the store to look up and register queries by their hash
+func (e *EngineV2Configuration) SetPersistedQueryStore(store PersistedQueryStore) {
+	e.persistedQueryStore = store
+}
+
+// SetMaxQueryDepth - rejects operations with fields nested deeper than maxDepth before planning (default: 0, no limit)
+func (e *EngineV2Configuration) SetMaxQueryDepth(maxDepth int) {
+	e.maxQueryDepth = maxDepth
+}
+
+// SetExemptIntrospectionFromMaxQueryDepth - skips the query depth check for operations which only contain introspection root fields
+func (e *EngineV2Configuration) SetExemptIntrospectionFromMaxQueryDepth(exempt bool) {
+	e.exemptIntrospection = exempt
+}
+
+// SetTracingEnabled - plans the resolver entries of the Apollo Tracing extension, which is added to the response by WithTracing
+func (e *EngineV2Configuration) SetTracingEnabled(enabled bool) {
+	e.plannerConfig.EnableTracing = enabled
+}
+
+// SetResolveContextFactory - sets the factory for the resolve.Context of pooled executions, e.g. to set default hooks for all operations
+// The Context and Request of the resolve.Context are set on each execution, options passed to Execute are applied on top of the defaults
+func (e *EngineV2Configuration) SetResolveContextFactory(factory ResolveContextFactory) {
+	e.resolveContextFactory = factory
+}
+
+// SetMaxComplexity - rejects operations with a complexity above maxComplexity before planning (default: 0, no limit)
+func (e *EngineV2Configuration) SetMaxComplexity(maxComplexity int) {
+	e.maxComplexity = maxComplexity
+}
+
+// SetComplexityCalculator - sets the calculator for the complexity of operations (default: FieldCostComplexityCalculator)
+func (e *EngineV2Configuration) SetComplexityCalculator(calculator ComplexityCalculator) {
+	e.complexityCalculator = calculator
+}
+
+// SetComplexityObserver - sets an observer which will be called with the complexity of each executed operation
+func (e *EngineV2Configuration) SetComplexityObserver(observer func(result ComplexityResult)) {
+	e.complexityObserver = observer
+}
+
+// SetResponseTransform - sets a transform applied to the data of each response before it's written, e.g. to redact values (default: nil, disabled)
+func (e *EngineV2Configuration) SetResponseTransform(transform resolve.ResponseTransformFunc) {
+	e.responseTransform = transform
+}
+
+// SetResponseCache - caches the responses of query operations for ttl, keyed by the operation, its variables and the request headers (default: nil, disabled)
+// Cached responses are served without planning or resolving, responses with errors aren't cached.
+// Execution options which change the response, e.g. hooks or feature flags, must be combined with WithResponseCacheBypass.
+func (e *EngineV2Configuration) SetResponseCache(cache ResponseCache, ttl time.Duration) {
+	e.responseCache = cache
+	e.responseCacheTTL = ttl
+}
+
 type EngineResultWriter struct {
-	buf           *bytes.Buffer
-	flushCallback func(data []byte)
+	buf            *bytes.Buffer
+	flushCallback  func(data []byte)
+	flushThreshold int
 }
 
 func NewEngineResultWriter() EngineResultWriter {
@@ -79,8 +172,39 @@ func (e *EngineResultWriter) SetFlushCallback(flushCb func(data []byte)) {
 	e.flushCallback = flushCb
 }
 
+// SetFlushThreshold flushes the buffered bytes whenever threshold bytes are buffered, e.g. to stream a response with chunked HTTP
+// A large write is flushed in chunks of threshold bytes, the remaining bytes are buffered until the next write or Flush.
+// The chunks don't align with JSON values, so it's meant for streaming a single response rather than subscription frames.
+// If set to 0 (default) or if no flush callback is set, bytes are only flushed by Flush.
+func (e *EngineResultWriter) SetFlushThreshold(threshold int) {
+	e.flushThreshold = threshold
+}
+
 func (e *EngineResultWriter) Write(p []byte) (n int, err error) {
-	return e.buf.Write(p)
+	// without a callback flushing would drop the buffered bytes
+	if e.flushThreshold <= 0 || e.flushCallback == nil {
+		return e.buf.Write(p)
+	}
+	for len(p) != 0 {
+		// the buffer may already exceed the threshold, e.g. if it was set after writing or the buffer wasn't empty
+		if e.buf.Len() >= e.flushThreshold {
+			e.Flush()
+		}
+		chunk := p
+		if remaining := e.flushThreshold - e.buf.Len(); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		written, err := e.buf.Write(chunk)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[written:]
+		if e.buf.Len() >= e.flushThreshold {
+			e.Flush()
+		}
+	}
+	return n, nil
 }
 
 func (e *EngineResultWriter) Read(p []byte) (n int, err error) {
@@ -111,23 +235,52 @@ func (e *EngineResultWriter) Reset() {
 	e.buf.Reset()
 }
 
+// AsHTTPResponse returns the buffered bytes as body of a http.Response with the ContentLength of the body
+// The Content-Type defaults to application/json if headers don't contain one, the headers passed in aren't modified.
 func (e *EngineResultWriter) AsHTTPResponse(status int, headers http.Header) *http.Response {
+	if headers.Get("Content-Type") == "" {
+		headers = headers.Clone()
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		headers.Set("Content-Type", "application/json")
+	}
 	res := &http.Response{}
 	res.Body = ioutil.NopCloser(e.buf)
+	res.ContentLength = int64(e.buf.Len())
 	res.Header = headers
 	res.StatusCode = status
 	return res
 }
 
+// ResolveContextFactory creates the resolve.Context used by the ExecutionEngineV2
+// It's called again after each execution to reset the context, so it must return a new resolve.Context on each call.
+type ResolveContextFactory func() *resolve.Context
+
+func defaultResolveContextFactory() *resolve.Context {
+	return resolve.NewContext(context.Background())
+}
+
 type internalExecutionContext struct {
 	resolveContext *resolve.Context
-	postProcessor  *postprocess.Processor
+	// resolveContextFactory creates the state resolveContext is reset to after each execution
+	// A new context is created each time, so that slices and maps modified by an execution don't leak into the next one.
+	resolveContextFactory ResolveContextFactory
+	postProcessor         *postprocess.Processor
+	responseCache         responseCacheOptions
+	// skipValidation is set by WithSkipValidation for trusted operations
+	skipValidation bool
 }
 
 func newInternalExecutionContext() *internalExecutionContext {
+	return newInternalExecutionContextFromFactory(defaultResolveContextFactory)
+}
+
+func newInternalExecutionContextFromFactory(factory ResolveContextFactory) *internalExecutionContext {
 	return &internalExecutionContext{
-		resolveContext: resolve.NewContext(context.Background()),
-		postProcessor:  postprocess.DefaultProcessor(),
+		resolveContext:        factory(),
+		resolveContextFactory: factory,
+		postProcessor:         postprocess.DefaultProcessor(),
 	}
 }
 
@@ -151,6 +304,9 @@ func (e *internalExecutionContext) setVariables(variables []byte) {
 
 func (e *internalExecutionContext) reset() {
 	e.resolveContext.Free()
+	*e.resolveContext = *e.resolveContextFactory()
+	e.responseCache = responseCacheOptions{}
+	e.skipValidation = false
 }
 
 type ExecutionEngineV2 struct {
@@ -160,7 +316,38 @@ type ExecutionEngineV2 struct {
 	plannerMu                    sync.Mutex
 	resolver                     *resolve.Resolver
 	internalExecutionContextPool sync.Pool
-	executionPlanCache           *lru.Cache
+	schema                       *executionSchema
+	schemaMu                     sync.RWMutex
+}
+
+// executionSchema is the schema an execution is validated, planned and resolved with
+// It's never modified, ReloadSchema swaps it as a whole so that an execution which took it keeps a consistent view
+// of the schema, the planner configuration and the execution plans planned for them.
+type executionSchema struct {
+	schema             *Schema
+	plannerConfig      plan.Configuration
+	executionPlanCache *lru.Cache
+}
+
+func newExecutionSchema(schema *Schema, plannerConfig plan.Configuration, executionPlanCacheSize int) (*executionSchema, error) {
+	// the hash is computed lazily, compute it before the schema is shared by concurrent executions
+	if schema != nil {
+		if _, err := schema.Hash(); err != nil {
+			return nil, err
+		}
+	}
+	s := &executionSchema{
+		schema:        schema,
+		plannerConfig: plannerConfig,
+	}
+	if executionPlanCacheSize > 0 {
+		var err error
+		s.executionPlanCache, err = lru.New(executionPlanCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 type WebsocketBeforeStartHook interface {
@@ -181,45 +368,175 @@ func WithAfterFetchHook(hook resolve.AfterFetchHook) ExecutionOptionsV2 {
 	}
 }
 
+// WithTraceID sets the trace id rendered by the `{{ .request.traceId }}` template of data source configurations
+func WithTraceID(traceID []byte) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.TraceID = traceID
+	}
+}
+
+// WithFeatureFlags enables experimental resolver behaviors for a single execution
+func WithFeatureFlags(flags resolve.FeatureFlags) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.FeatureFlags = flags
+	}
+}
+
+func WithFetchCompleteHook(hook resolve.FetchCompleteHook) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetFetchCompleteHook(hook)
+	}
+}
+
+func WithFetchInputRewriteHook(hook resolve.FetchInputRewriteHook) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetFetchInputRewriteHook(hook)
+	}
+}
+
+// WithFieldCache serves the values of fields from the cache and skips the fetches of cached fields
+func WithFieldCache(cache resolve.FieldCache) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetFieldCache(cache)
+	}
+}
+
+// WithErrorsOnly executes all fetches of the operation but only writes errors, the data of the response is null
+func WithErrorsOnly() ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetErrorsOnly(true)
+	}
+}
+
+// WithCollectAllErrors is a debug-only mode which resolves all fields and collects the errors of all null values of non-nullable fields
+// instead of nulling the enclosing object, the response doesn't comply with the GraphQL specification
+func WithCollectAllErrors() ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetCollectAllErrors(true)
+	}
+}
+
+// WithMaxPreparedInputBytes fails the execution once the inputs prepared for all fetches, e.g. the upstream request bodies, exceed max bytes
+func WithMaxPreparedInputBytes(max int64) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetMaxPreparedInputBytes(max)
+	}
+}
+
+// WithTracing adds the fetch timings of the execution to the response in the Apollo Tracing format, see resolve.Context.SetTracing
+// Fetches are only added as resolver entries if tracing is enabled with EngineV2Configuration.SetTracingEnabled
+func WithTracing() ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.resolveContext.SetTracing(true)
+	}
+}
+
+// WithSkipValidation skips the validation of the operation against the schema, it's still normalized and planned
+// It's meant for trusted operations only, e.g. persisted queries validated when they were registered.
+// Unsafe for untrusted input: an invalid operation is planned and resolved as is, which can fail in unexpected ways.
+func WithSkipValidation() ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.skipValidation = true
+	}
+}
+
 func NewExecutionEngineV2(ctx context.Context, logger abstractlogger.Logger, engineConfig EngineV2Configuration) (*ExecutionEngineV2, error) {
-	executionPlanCache, err := lru.New(1024)
+	schema, err := newExecutionSchema(engineConfig.schema, engineConfig.plannerConfig, engineConfig.executionPlanCacheSize)
 	if err != nil {
 		return nil, err
 	}
+	resolveContextFactory := engineConfig.resolveContextFactory
+	if resolveContextFactory == nil {
+		resolveContextFactory = defaultResolveContextFactory
+	}
+	resolver := resolve.New(ctx)
+	resolver.SetResponseTransform(engineConfig.responseTransform)
 	return &ExecutionEngineV2{
 		logger:   logger,
 		config:   engineConfig,
 		planner:  plan.NewPlanner(ctx, engineConfig.plannerConfig),
-		resolver: resolve.New(ctx),
+		resolver: resolver,
 		internalExecutionContextPool: sync.Pool{
 			New: func() interface{} {
-				return newInternalExecutionContext()
+				return newInternalExecutionContextFromFactory(resolveContextFactory)
 			},
 		},
-		executionPlanCache: executionPlanCache,
+		schema: schema,
 	}, nil
 }
 
+// ReloadSchema swaps the schema and the planner configuration of the engine and flushes the execution plan cache.
+// Executions which already started keep using the previous schema, new executions use the reloaded schema.
+func (e *ExecutionEngineV2) ReloadSchema(schema *Schema, plannerConfig plan.Configuration) error {
+	if schema == nil {
+		return ErrNilSchema
+	}
+
+	reloaded, err := newExecutionSchema(schema, plannerConfig, e.config.executionPlanCacheSize)
+	if err != nil {
+		return err
+	}
+
+	e.schemaMu.Lock()
+	defer e.schemaMu.Unlock()
+
+	e.schema = reloaded
+	if e.config.responseCache != nil {
+		e.config.responseCache.Purge()
+	}
+	return nil
+}
+
+func (e *ExecutionEngineV2) currentSchema() *executionSchema {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+	return e.schema
+}
+
 func (e *ExecutionEngineV2) Execute(ctx context.Context, operation *Request, writer resolve.FlushWriter, options ...ExecutionOptionsV2) error {
+	if err := resolvePersistedQuery(ctx, e.config.persistedQueryStore, operation); err != nil {
+		return err
+	}
+
+	executionSchema := e.currentSchema()
+	schema := executionSchema.schema
+
+	if !operation.IsNormalized() {
+		start := time.Now()
+		report := operation.parseQueryOnce()
+		e.reportExecutionPhase(ctx, ExecutionPhaseParse, start, false)
+		if report.HasErrors() {
+			e.logError("parsing failed", operation.OperationName, report)
+			result, err := normalizationResultFromReport(report)
+			if err != nil {
+				return newNormalizationError(ErrorCategoryInternal, err)
+			}
+			return newNormalizationError(ErrorCategoryRequest, result.Errors)
+		}
+	}
+
+	if report := operation.validateOperationSelection(); report.HasErrors() {
+		e.logError("operation selection failed", operation.OperationName, report)
+		return RequestErrorsFromOperationReport(report)
+	}
+
 	if !operation.IsNormalized() {
-		result, err := operation.Normalize(e.config.schema)
+		start := time.Now()
+		result, err := operation.Normalize(schema)
+		e.reportExecutionPhase(ctx, ExecutionPhaseNormalize, start, false)
 		if err != nil {
-			return err
+			normalizationErr := newNormalizationError(ErrorCategoryInternal, err)
+			e.logError("normalization failed", operation.OperationName, normalizationErr)
+			return normalizationErr
 		}
 
 		if !result.Successful {
-			return result.Errors
+			normalizationErr := newNormalizationError(ErrorCategoryRequest, result.Errors)
+			e.logError("normalization failed", operation.OperationName, normalizationErr)
+			return normalizationErr
 		}
 	}
 
-	result, err := operation.ValidateForSchema(e.config.schema)
-	if err != nil {
-		return err
-	}
-	if !result.Valid {
-		return result.Errors
-	}
-
 	execContext := e.getExecutionCtx()
 	defer e.putExecutionCtx(execContext)
 
@@ -229,53 +546,204 @@ func (e *ExecutionEngineV2) Execute(ctx context.Context, operation *Request, wri
 		options[i](execContext)
 	}
 
+	if !execContext.skipValidation {
+		start := time.Now()
+		result, err := operation.ValidateForSchema(schema)
+		e.reportExecutionPhase(ctx, ExecutionPhaseValidate, start, false)
+		if err != nil {
+			validationErr := newValidationError(ErrorCategoryInternal, err)
+			e.logError("validation failed", operation.OperationName, validationErr)
+			return validationErr
+		}
+		if !result.Valid {
+			validationErr := newValidationError(ErrorCategoryRequest, result.Errors)
+			e.logError("validation failed", operation.OperationName, validationErr)
+			return validationErr
+		}
+	}
+
+	if err := e.validateQueryDepth(operation, schema); err != nil {
+		e.logError("query depth validation failed", operation.OperationName, err)
+		return err
+	}
+
+	if err := e.validateComplexity(operation, schema); err != nil {
+		e.logError("complexity validation failed", operation.OperationName, err)
+		return err
+	}
+
 	var report operationreport.Report
-	cachedPlan := e.getCachedPlan(execContext, &operation.document, &e.config.schema.document, operation.OperationName, &report)
+	start := time.Now()
+	planCacheKey, err := planCacheKey(&operation.document, schema, operation.OperationName)
+	if err != nil {
+		report.AddInternalError(err)
+		planningErr := newPlanningError(report)
+		e.logError("planning failed", operation.OperationName, planningErr)
+		return planningErr
+	}
+
+	responseCacheKey, cacheResponse := e.responseCacheKey(execContext, operation, schema)
+	if cacheResponse {
+		if response, ok := e.config.responseCache.Get(responseCacheKey); ok {
+			_, err = writer.Write(response)
+			return err
+		}
+	}
+
+	cachedPlan, planCached := e.getCachedPlan(execContext, planCacheKey, &operation.document, executionSchema, operation.OperationName, &report)
+	e.reportExecutionPhase(ctx, ExecutionPhasePlan, start, planCached)
 	if report.HasErrors() {
-		return report
+		planningErr := newPlanningError(report)
+		e.logError("planning failed", operation.OperationName, planningErr)
+		return planningErr
 	}
 
+	start = time.Now()
 	switch p := cachedPlan.(type) {
 	case *plan.SynchronousResponsePlan:
+		if cacheResponse {
+			err = e.resolveCachedResponse(execContext, p.Response, executionSchema, responseCacheKey, writer)
+			break
+		}
 		err = e.resolver.ResolveGraphQLResponse(execContext.resolveContext, p.Response, nil, writer)
 	case *plan.SubscriptionResponsePlan:
 		err = e.resolver.ResolveGraphQLSubscription(execContext.resolveContext, p.Response, writer)
 	default:
 		return errors.New("execution of operation is not possible")
 	}
+	e.reportExecutionPhase(ctx, ExecutionPhaseResolve, start, false)
+	if err != nil {
+		e.logError("resolving failed", operation.OperationName, err)
+	}
 
 	return err
 }
 
-func (e *ExecutionEngineV2) getCachedPlan(ctx *internalExecutionContext, operation, definition *ast.Document, operationName string, report *operationreport.Report) plan.Plan {
+// resolveCachedResponse resolves the response into a buffer and stores it in the response cache unless it contains errors
+// Responses of executions which started before a schema reload aren't stored as the reload purged the cache.
+func (e *ExecutionEngineV2) resolveCachedResponse(ctx *internalExecutionContext, response *resolve.GraphQLResponse, schema *executionSchema, cacheKey ResponseCacheKey, writer io.Writer) error {
+	buf := &bytes.Buffer{}
+	if err := e.resolver.ResolveGraphQLResponse(ctx.resolveContext, response, nil, buf); err != nil {
+		return err
+	}
+	if ttl := e.responseCacheTTL(ctx); ttl > 0 && !bytes.HasPrefix(buf.Bytes(), responseWithErrorsPrefix) && schema == e.currentSchema() {
+		e.config.responseCache.Set(cacheKey, buf.Bytes(), ttl)
+	}
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
 
+// planCacheKey is the hash of the printed operation
+func planCacheKey(operation *ast.Document, schema *Schema, operationName string) (uint64, error) {
 	hash := pool.Hash64.Get()
 	hash.Reset()
 	defer pool.Hash64.Put(hash)
-	err := astprinter.Print(operation, definition, hash)
-	if err != nil {
-		report.AddInternalError(err)
-		return nil
+	if err := astprinter.Print(operation, &schema.document, hash); err != nil {
+		return 0, err
 	}
-
-	cacheKey := hash.Sum64()
-
-	if cached, ok := e.executionPlanCache.Get(cacheKey); ok {
-		if p, ok := cached.(plan.Plan); ok {
-			return p
+	// a document with multiple operations is planned once per selected operation
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(operationName))
+	return hash.Sum64(), nil
+}
+
+func (e *ExecutionEngineV2) getCachedPlan(ctx *internalExecutionContext, cacheKey uint64, operation *ast.Document, schema *executionSchema, operationName string, report *operationreport.Report) (p plan.Plan, cached bool) {
+	if schema.executionPlanCache != nil {
+		if cached, ok := schema.executionPlanCache.Get(cacheKey); ok {
+			if p, ok := cached.(plan.Plan); ok {
+				e.observePlanCache(true)
+				return p, true
+			}
 		}
 	}
+	e.observePlanCache(false)
+	if e.logger != nil {
+		e.logger.Debug("graphql.ExecutionEngineV2.getCachedPlan()",
+			abstractlogger.String("message", "execution plan cache miss"),
+			abstractlogger.String("operationName", operationName),
+		)
+	}
 
 	e.plannerMu.Lock()
 	defer e.plannerMu.Unlock()
-	planResult := e.planner.Plan(operation, definition, operationName, report)
+	// the planner is shared by all schemas, it plans with the configuration of the schema the execution took
+	e.planner.SetConfig(schema.plannerConfig)
+	planResult := e.planner.Plan(operation, &schema.schema.document, operationName, report)
+	if report.HasErrors() {
+		return nil, false
+	}
+
+	p = ctx.postProcessor.Process(planResult)
+	// plans of operations which started before a schema reload end up in the cache of the previous schema
+	if schema.executionPlanCache != nil {
+		schema.executionPlanCache.Add(cacheKey, p)
+	}
+	return p, false
+}
+
+func (e *ExecutionEngineV2) validateQueryDepth(operation *Request, schema *Schema) error {
+	if e.config.maxQueryDepth <= 0 {
+		return nil
+	}
+	if e.config.exemptIntrospection && isIntrospectionOnly(&operation.document) {
+		return nil
+	}
+	var report operationreport.Report
+	validateQueryDepth(&operation.document, &schema.document, e.config.maxQueryDepth, &report)
 	if report.HasErrors() {
+		return RequestErrorsFromError(report)
+	}
+	return nil
+}
+
+func (e *ExecutionEngineV2) validateComplexity(operation *Request, schema *Schema) error {
+	if e.config.maxComplexity <= 0 && e.config.complexityObserver == nil {
 		return nil
 	}
+	result, err := e.config.complexityCalculator.Calculate(&operation.document, &schema.document)
+	if err != nil {
+		return err
+	}
+	if result.Errors != nil && result.Errors.Count() > 0 {
+		return result.Errors
+	}
+	if e.config.complexityObserver != nil {
+		e.config.complexityObserver(result)
+	}
+	if e.config.maxComplexity > 0 && result.Complexity > e.config.maxComplexity {
+		var report operationreport.Report
+		report.AddExternalError(operationreport.ErrQueryExceedsMaxComplexity(result.Complexity, e.config.maxComplexity))
+		return RequestErrorsFromError(report)
+	}
+	return nil
+}
 
-	p := ctx.postProcessor.Process(planResult)
-	e.executionPlanCache.Add(cacheKey, p)
-	return p
+func (e *ExecutionEngineV2) logError(message, operationName string, err error) {
+	if e.logger == nil {
+		return
+	}
+	e.logger.Error("graphql.ExecutionEngineV2.Execute()",
+		abstractlogger.String("message", message),
+		abstractlogger.String("operationName", operationName),
+		abstractlogger.Error(err),
+	)
+}
+
+func (e *ExecutionEngineV2) observePlanCache(hit bool) {
+	if e.config.planCacheObserver != nil {
+		e.config.planCacheObserver(hit)
+	}
+}
+
+func (e *ExecutionEngineV2) reportExecutionPhase(ctx context.Context, phase ExecutionPhase, start time.Time, planCached bool) {
+	if e.config.executionMetricsHook == nil {
+		return
+	}
+	e.config.executionMetricsHook.OnExecutionPhase(ctx, ExecutionPhaseMetrics{
+		Phase:      phase,
+		Duration:   time.Since(start),
+		PlanCached: planCached,
+	})
 }
 
 func (e *ExecutionEngineV2) GetWebsocketBeforeStartHook() WebsocketBeforeStartHook {
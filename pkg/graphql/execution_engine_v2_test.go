@@ -1,7 +1,10 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -24,6 +27,7 @@ import (
 	"github.com/jensneuse/graphql-go-tools/pkg/engine/datasource/staticdatasource"
 	"github.com/jensneuse/graphql-go-tools/pkg/engine/plan"
 	"github.com/jensneuse/graphql-go-tools/pkg/engine/resolve"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
 	"github.com/jensneuse/graphql-go-tools/pkg/starwars"
 )
 
@@ -37,6 +41,7 @@ func TestNewEngineV2Configuration(t *testing.T) {
 		engineConfig = NewEngineV2Configuration(schema)
 		assert.Len(t, engineConfig.plannerConfig.DataSources, 0)
 		assert.Len(t, engineConfig.plannerConfig.Fields, 0)
+		assert.Equal(t, defaultExecutionPlanCacheSize, engineConfig.executionPlanCacheSize)
 	})
 
 	t.Run("should successfully add a data source", func(t *testing.T) {
@@ -94,6 +99,41 @@ func TestEngineResponseWriter_AsHTTPResponse(t *testing.T) {
 	assert.Equal(t, http.StatusOK, response.StatusCode)
 	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
 	assert.Equal(t, `{"key": "value"}`, string(body))
+	assert.Equal(t, int64(len(body)), response.ContentLength)
+
+	t.Run("explicit content type is preserved", func(t *testing.T) {
+		rw := NewEngineResultWriter()
+		_, err := rw.Write([]byte(`{"data":{"hello":"world"}}`))
+		require.NoError(t, err)
+
+		headers := make(http.Header)
+		headers.Set("Content-Type", "application/graphql-response+json")
+		response := rw.AsHTTPResponse(http.StatusOK, headers)
+		body, err := ioutil.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, "application/graphql-response+json", response.Header.Get("Content-Type"))
+		assert.Equal(t, int64(26), response.ContentLength)
+		assert.Equal(t, int64(len(body)), response.ContentLength)
+	})
+
+	t.Run("default content type", func(t *testing.T) {
+		rw := NewEngineResultWriter()
+		_, err := rw.Write([]byte(`{"data":null}`))
+		require.NoError(t, err)
+
+		headers := make(http.Header)
+		headers.Set("X-Request-Id", "1")
+		response := rw.AsHTTPResponse(http.StatusOK, headers)
+		assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		assert.Equal(t, "1", response.Header.Get("X-Request-Id"))
+		assert.Equal(t, int64(13), response.ContentLength)
+		// the headers passed in aren't modified
+		assert.Equal(t, "", headers.Get("Content-Type"))
+
+		response = rw.AsHTTPResponse(http.StatusOK, nil)
+		assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	})
 }
 
 type ExecutionEngineV2TestCase struct {
@@ -101,6 +141,7 @@ type ExecutionEngineV2TestCase struct {
 	operation        func(t *testing.T) Request
 	dataSources      []plan.DataSourceConfiguration
 	fields           plan.FieldConfigurations
+	options          []ExecutionOptionsV2
 	expectedResponse string
 }
 
@@ -119,7 +160,7 @@ func TestExecutionEngineV2_Execute(t *testing.T) {
 			resultWriter := NewEngineResultWriter()
 			execCtx, execCtxCancel := context.WithCancel(context.Background())
 			defer execCtxCancel()
-			err = engine.Execute(execCtx, &operation, &resultWriter)
+			err = engine.Execute(execCtx, &operation, &resultWriter, testCase.options...)
 
 			assert.Equal(t, testCase.expectedResponse, resultWriter.String())
 
@@ -232,6 +273,38 @@ func TestExecutionEngineV2_Execute(t *testing.T) {
 		},
 	))
 
+	t.Run("execute with trace id injection", runWithoutError(
+		ExecutionEngineV2TestCase{
+			schema:    starwarsSchema(t),
+			operation: loadStarWarsQuery(starwars.FileSimpleHeroQuery, nil),
+			dataSources: []plan.DataSourceConfiguration{
+				{
+					RootNodes: []plan.TypeField{
+						{TypeName: "Query", FieldNames: []string{"hero"}},
+					},
+					Factory: &rest_datasource.Factory{
+						Client: testNetHttpClient(t, roundTripperTestCase{
+							expectedHost:     "example.com",
+							expectedPath:     "/trace-123",
+							expectedBody:     "",
+							sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+							sendStatusCode:   200,
+						}),
+					},
+					Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+						Fetch: rest_datasource.FetchConfiguration{
+							URL:    "https://example.com/{{ .request.traceId }}",
+							Method: "GET",
+						},
+					}),
+				},
+			},
+			fields:           []plan.FieldConfiguration{},
+			options:          []ExecutionOptionsV2{WithTraceID([]byte("trace-123"))},
+			expectedResponse: `{"data":{"hero":{"name":"Luke Skywalker"}}}`,
+		},
+	))
+
 	t.Run("execute simple hero operation with graphql data source", runWithoutError(
 		ExecutionEngineV2TestCase{
 			schema:    starwarsSchema(t),
@@ -630,6 +703,555 @@ func TestExecutionEngineV2_FederationAndSubscription_IntegrationTest(t *testing.
 	*/
 }
 
+func TestEngineResultWriter_FlushThreshold(t *testing.T) {
+	t.Run("flush on threshold", func(t *testing.T) {
+		var flushed []string
+		resultWriter := NewEngineResultWriter()
+		resultWriter.SetFlushCallback(func(data []byte) {
+			flushed = append(flushed, string(data))
+		})
+		resultWriter.SetFlushThreshold(4)
+
+		n, err := resultWriter.Write([]byte(`{"data":{"hello":"world"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, 26, n)
+		assert.Equal(t, []string{`{"da`, `ta":`, `{"he`, `llo"`, `:"wo`, `rld"`}, flushed)
+		assert.Equal(t, `}}`, resultWriter.String())
+
+		_, err = resultWriter.Write([]byte(`abc`))
+		require.NoError(t, err)
+		assert.Equal(t, `}}ab`, flushed[len(flushed)-1])
+
+		resultWriter.Flush()
+		assert.Equal(t, `c`, flushed[len(flushed)-1])
+		assert.Equal(t, 0, resultWriter.Len())
+	})
+
+	t.Run("flush a buffer exceeding the threshold before writing", func(t *testing.T) {
+		var flushed []string
+		resultWriter := NewEngineResultWriterFromBuffer(bytes.NewBufferString(`{"data":`))
+		resultWriter.SetFlushCallback(func(data []byte) {
+			flushed = append(flushed, string(data))
+		})
+		resultWriter.SetFlushThreshold(4)
+
+		_, err := resultWriter.Write([]byte(`null}`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{`{"data":`, `null`}, flushed)
+		assert.Equal(t, `}`, resultWriter.String())
+	})
+
+	t.Run("threshold set after writing", func(t *testing.T) {
+		var flushed []string
+		resultWriter := NewEngineResultWriter()
+		resultWriter.SetFlushCallback(func(data []byte) {
+			flushed = append(flushed, string(data))
+		})
+		_, err := resultWriter.Write([]byte(`{"data":`))
+		require.NoError(t, err)
+
+		resultWriter.SetFlushThreshold(4)
+		_, err = resultWriter.Write([]byte(`null}`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{`{"data":`, `null`}, flushed)
+		assert.Equal(t, `}`, resultWriter.String())
+	})
+
+	t.Run("threshold without flush callback keeps all bytes", func(t *testing.T) {
+		resultWriter := NewEngineResultWriter()
+		resultWriter.SetFlushThreshold(4)
+
+		_, err := resultWriter.Write([]byte(`{"data":{"hello":"world"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, resultWriter.String())
+	})
+
+	t.Run("buffer until Flush by default", func(t *testing.T) {
+		flushes := 0
+		resultWriter := NewEngineResultWriter()
+		resultWriter.SetFlushCallback(func(data []byte) {
+			flushes++
+		})
+
+		_, err := resultWriter.Write([]byte(`{"data":{"hello":"world"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, flushes)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, resultWriter.String())
+	})
+}
+
+func TestExecutionEngineV2_ExecutionPlanCache(t *testing.T) {
+	planTwice := func(t *testing.T, cacheSize int) (first, second plan.Plan) {
+		schema := starwarsSchema(t)
+		engineConf := NewEngineV2Configuration(schema)
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{TypeName: "Query", FieldNames: []string{"hero"}},
+				},
+				Factory: &rest_datasource.Factory{},
+				Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+					Fetch: rest_datasource.FetchConfiguration{
+						URL:    "https://example.com/",
+						Method: "GET",
+					},
+				}),
+			},
+		})
+		engineConf.SetExecutionPlanCacheSize(cacheSize)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+		require.NoError(t, err)
+
+		operation := loadStarWarsQuery(starwars.FileSimpleHeroQuery, nil)(t)
+		result, err := operation.Normalize(schema)
+		require.NoError(t, err)
+		require.True(t, result.Successful)
+
+		cacheKey, err := planCacheKey(&operation.document, schema, operation.OperationName)
+		require.NoError(t, err)
+
+		execContext := newInternalExecutionContext()
+		var report operationreport.Report
+		first, _ = engine.getCachedPlan(execContext, cacheKey, &operation.document, engine.currentSchema(), operation.OperationName, &report)
+		second, _ = engine.getCachedPlan(execContext, cacheKey, &operation.document, engine.currentSchema(), operation.OperationName, &report)
+		require.False(t, report.HasErrors())
+		require.NotNil(t, first)
+		require.NotNil(t, second)
+		return first, second
+	}
+
+	t.Run("should cache plans by default", func(t *testing.T) {
+		first, second := planTwice(t, defaultExecutionPlanCacheSize)
+		assert.Same(t, first, second)
+	})
+
+	t.Run("should re-plan on each execution if the cache size is 0", func(t *testing.T) {
+		first, second := planTwice(t, 0)
+		assert.NotSame(t, first, second)
+	})
+}
+
+func TestExecutionEngineV2_PlanCacheObserver(t *testing.T) {
+	schema := starwarsSchema(t)
+	engineConf := NewEngineV2Configuration(schema)
+	engineConf.SetDataSources([]plan.DataSourceConfiguration{
+		{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"hero"}},
+			},
+			Factory: &rest_datasource.Factory{
+				Client: testNetHttpClient(t, roundTripperTestCase{
+					expectedHost:     "example.com",
+					expectedPath:     "/",
+					expectedBody:     "",
+					sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+					sendStatusCode:   200,
+				}),
+			},
+			Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+				Fetch: rest_datasource.FetchConfiguration{
+					URL:    "https://example.com/",
+					Method: "GET",
+				},
+			}),
+		},
+	})
+
+	var observed []bool
+	hits, misses := 0, 0
+	engineConf.SetPlanCacheObserver(func(hit bool) {
+		observed = append(observed, hit)
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		operation := loadStarWarsQuery(starwars.FileSimpleHeroQuery, nil)(t)
+		resultWriter := NewEngineResultWriter()
+		err = engine.Execute(context.Background(), &operation, &resultWriter)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, misses)
+	assert.Equal(t, 1, hits)
+	assert.Equal(t, []bool{false, true}, observed)
+}
+
+func TestExecutionEngineV2_PlanCacheKeyOperationName(t *testing.T) {
+	schema := starwarsSchema(t)
+	engineConf := NewEngineV2Configuration(schema)
+	engineConf.SetDataSources([]plan.DataSourceConfiguration{
+		{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"hero"}},
+			},
+			Factory: &rest_datasource.Factory{
+				Client: testNetHttpClient(t, roundTripperTestCase{
+					expectedHost:     "example.com",
+					expectedPath:     "/",
+					expectedBody:     "",
+					sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+					sendStatusCode:   200,
+				}),
+			},
+			Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+				Fetch: rest_datasource.FetchConfiguration{
+					URL:    "https://example.com/",
+					Method: "GET",
+				},
+			}),
+		},
+	})
+
+	var observed []bool
+	engineConf.SetPlanCacheObserver(func(hit bool) {
+		observed = append(observed, hit)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+	require.NoError(t, err)
+
+	execute := func(operationName string) string {
+		operation := Request{
+			OperationName: operationName,
+			Query:         `query Name { hero { name } } query AliasedName { hero { heroName: name } }`,
+		}
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &operation, &resultWriter)
+		require.NoError(t, err)
+		return resultWriter.String()
+	}
+
+	assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, execute("Name"))
+	assert.Equal(t, `{"data":{"hero":{"heroName":"Luke Skywalker"}}}`, execute("AliasedName"))
+	assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, execute("Name"))
+	assert.Equal(t, `{"data":{"hero":{"heroName":"Luke Skywalker"}}}`, execute("AliasedName"))
+	assert.Equal(t, []bool{false, false, true, true}, observed)
+}
+
+type capturedLogEntry struct {
+	level  string
+	msg    string
+	fields []abstractlogger.Field
+}
+
+type capturingLogger struct {
+	abstractlogger.Noop
+	entries []capturedLogEntry
+}
+
+func (c *capturingLogger) Debug(msg string, fields ...abstractlogger.Field) {
+	c.entries = append(c.entries, capturedLogEntry{level: "debug", msg: msg, fields: fields})
+}
+
+func (c *capturingLogger) Error(msg string, fields ...abstractlogger.Field) {
+	c.entries = append(c.entries, capturedLogEntry{level: "error", msg: msg, fields: fields})
+}
+
+func TestExecutionEngineV2_Logging(t *testing.T) {
+	newEngine := func(t *testing.T, logger abstractlogger.Logger) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(starwarsSchema(t))
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{TypeName: "Query", FieldNames: []string{"hero"}},
+				},
+				Factory: &rest_datasource.Factory{
+					Client: testNetHttpClient(t, roundTripperTestCase{
+						expectedHost:     "example.com",
+						expectedPath:     "/",
+						expectedBody:     "",
+						sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+						sendStatusCode:   200,
+					}),
+				},
+				Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+					Fetch: rest_datasource.FetchConfiguration{
+						URL:    "https://example.com/",
+						Method: "GET",
+					},
+				}),
+			},
+		})
+		engine, err := NewExecutionEngineV2(context.Background(), logger, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	invalidOperation := func() *Request {
+		return &Request{
+			OperationName: "Invalid",
+			Query:         "query Invalid { hero { unknownField } }",
+		}
+	}
+
+	t.Run("should log plan cache misses at debug level", func(t *testing.T) {
+		logger := &capturingLogger{}
+		engine := newEngine(t, logger)
+
+		for i := 0; i < 2; i++ {
+			operation := &Request{
+				OperationName: "MyHero",
+				Query:         "query MyHero { hero { name } }",
+			}
+			resultWriter := NewEngineResultWriter()
+			require.NoError(t, engine.Execute(context.Background(), operation, &resultWriter))
+		}
+
+		require.Len(t, logger.entries, 1)
+		assert.Equal(t, "debug", logger.entries[0].level)
+		assert.Equal(t, "graphql.ExecutionEngineV2.getCachedPlan()", logger.entries[0].msg)
+		assert.Contains(t, logger.entries[0].fields, abstractlogger.String("operationName", "MyHero"))
+	})
+
+	t.Run("should log failed normalization at error level", func(t *testing.T) {
+		logger := &capturingLogger{}
+		engine := newEngine(t, logger)
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), invalidOperation(), &resultWriter)
+		require.Error(t, err)
+
+		require.Len(t, logger.entries, 1)
+		assert.Equal(t, "error", logger.entries[0].level)
+		assert.Equal(t, "graphql.ExecutionEngineV2.Execute()", logger.entries[0].msg)
+		assert.Contains(t, logger.entries[0].fields, abstractlogger.String("message", "normalization failed"))
+		assert.Contains(t, logger.entries[0].fields, abstractlogger.String("operationName", "Invalid"))
+		assert.Contains(t, logger.entries[0].fields, abstractlogger.Error(err))
+	})
+
+	t.Run("should not panic without logger", func(t *testing.T) {
+		engine := newEngine(t, nil)
+
+		resultWriter := NewEngineResultWriter()
+		assert.NotPanics(t, func() {
+			err := engine.Execute(context.Background(), invalidOperation(), &resultWriter)
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestExecutionEngineV2_ErrorCategories(t *testing.T) {
+	newEngine := func(t *testing.T, custom json.RawMessage) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(starwarsSchema(t))
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{TypeName: "Query", FieldNames: []string{"hero"}},
+				},
+				Factory: &rest_datasource.Factory{},
+				Custom:  custom,
+			},
+		})
+		engine, err := NewExecutionEngineV2(context.Background(), nil, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+	validConfig := rest_datasource.ConfigJSON(rest_datasource.Configuration{
+		Fetch: rest_datasource.FetchConfiguration{
+			URL:    "https://example.com/",
+			Method: "GET",
+		},
+	})
+
+	execute := func(engine *ExecutionEngineV2, query string) error {
+		resultWriter := NewEngineResultWriter()
+		return engine.Execute(context.Background(), &Request{Query: query}, &resultWriter)
+	}
+
+	t.Run("parsing fails with a NormalizationError", func(t *testing.T) {
+		err := execute(newEngine(t, validConfig), "query {")
+		var normalizationErr *NormalizationError
+		require.True(t, errors.As(err, &normalizationErr))
+		assert.Equal(t, ErrorCategoryRequest, normalizationErr.Category)
+		assert.Equal(t, "unexpected token - got: EOF want one of: [RBRACE IDENT SPREAD], locations: [{Line:0 Column:0}], path: []", err.Error())
+	})
+
+	t.Run("normalization fails with a NormalizationError", func(t *testing.T) {
+		err := execute(newEngine(t, validConfig), "{ hero { unknownField } }")
+		var normalizationErr *NormalizationError
+		require.True(t, errors.As(err, &normalizationErr))
+		assert.Equal(t, ErrorCategoryRequest, normalizationErr.Category)
+		assert.Equal(t, "field: unknownField not defined on type: Character, locations: [], path: [query,hero,unknownField]", err.Error())
+
+		var requestErrors RequestErrors
+		require.True(t, errors.As(err, &requestErrors))
+		assert.Len(t, requestErrors, 1)
+	})
+
+	t.Run("validation fails with a ValidationError", func(t *testing.T) {
+		err := execute(newEngine(t, validConfig), "{ hero(episode: 1) { name } }")
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, ErrorCategoryRequest, validationErr.Category)
+		assert.Equal(t, "argument: episode not defined on node: hero, locations: [], path: [query,hero]", err.Error())
+		assert.Equal(t, "argument: episode not defined on node: hero", RequestErrorsFromError(err)[0].Message)
+	})
+
+	t.Run("planning fails with a PlanningError", func(t *testing.T) {
+		err := execute(newEngine(t, json.RawMessage(`{`)), "{ hero { name } }")
+		var planningErr *PlanningError
+		require.True(t, errors.As(err, &planningErr))
+		assert.Equal(t, ErrorCategoryInternal, planningErr.Category)
+
+		var report operationreport.Report
+		require.True(t, errors.As(err, &report))
+		assert.True(t, report.HasErrors())
+		assert.Equal(t, report.Error(), err.Error())
+	})
+}
+
+type executionMetricsHook struct {
+	metrics []ExecutionPhaseMetrics
+}
+
+func (e *executionMetricsHook) OnExecutionPhase(ctx context.Context, metrics ExecutionPhaseMetrics) {
+	e.metrics = append(e.metrics, metrics)
+}
+
+func (e *executionMetricsHook) phases() (phases []string) {
+	for i := range e.metrics {
+		phases = append(phases, e.metrics[i].Phase.String())
+	}
+	return phases
+}
+
+func TestExecutionEngineV2_ExecutionMetrics(t *testing.T) {
+	schema := starwarsSchema(t)
+	engineConf := NewEngineV2Configuration(schema)
+	engineConf.SetDataSources([]plan.DataSourceConfiguration{
+		{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"hero"}},
+			},
+			Factory: &rest_datasource.Factory{
+				Client: testNetHttpClient(t, roundTripperTestCase{
+					expectedHost:     "example.com",
+					expectedPath:     "/",
+					expectedBody:     "",
+					sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+					sendStatusCode:   200,
+				}),
+			},
+			Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+				Fetch: rest_datasource.FetchConfiguration{
+					URL:    "https://example.com/",
+					Method: "GET",
+				},
+			}),
+		},
+	})
+	hook := &executionMetricsHook{}
+	engineConf.SetExecutionMetricsHook(hook)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+	require.NoError(t, err)
+
+	execute := func(t *testing.T) {
+		hook.metrics = nil
+		operation := loadStarWarsQuery(starwars.FileSimpleHeroQuery, nil)(t)
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &operation, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+	}
+
+	execute(t)
+	assert.Equal(t, []string{"parse", "normalize", "validate", "plan", "resolve"}, hook.phases())
+	assert.False(t, hook.metrics[3].PlanCached)
+
+	execute(t)
+	assert.Equal(t, []string{"parse", "normalize", "validate", "plan", "resolve"}, hook.phases())
+	assert.True(t, hook.metrics[3].PlanCached)
+}
+
+func TestExecutionEngineV2_SkipValidation(t *testing.T) {
+	schema := starwarsSchema(t)
+	engineConf := NewEngineV2Configuration(schema)
+	engineConf.SetDataSources([]plan.DataSourceConfiguration{
+		{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"hero"}},
+			},
+			Factory: &rest_datasource.Factory{
+				Client: testNetHttpClient(t, roundTripperTestCase{
+					expectedHost:     "example.com",
+					expectedPath:     "/",
+					expectedBody:     "",
+					sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+					sendStatusCode:   200,
+				}),
+			},
+			Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+				Fetch: rest_datasource.FetchConfiguration{
+					URL:    "https://example.com/",
+					Method: "GET",
+				},
+			}),
+		},
+	})
+	hook := &executionMetricsHook{}
+	engineConf.SetExecutionMetricsHook(hook)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+	require.NoError(t, err)
+
+	execute := func(query string, options ...ExecutionOptionsV2) (string, error) {
+		hook.metrics = nil
+		operation := Request{Query: query}
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &operation, &resultWriter, options...)
+		return resultWriter.String(), err
+	}
+
+	t.Run("validate by default", func(t *testing.T) {
+		_, err := execute(`{ hero @unknown { name } }`)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, []string{"parse", "normalize", "validate"}, hook.phases())
+
+		out, err := execute(`{ hero { name } }`)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, out)
+		assert.Equal(t, []string{"parse", "normalize", "validate", "plan", "resolve"}, hook.phases())
+	})
+
+	t.Run("skip validation", func(t *testing.T) {
+		out, err := execute(`{ hero { name } }`, WithSkipValidation())
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, out)
+		assert.Equal(t, []string{"parse", "normalize", "plan", "resolve"}, hook.phases())
+
+		// the unknown directive would be rejected by the validation
+		out, err = execute(`{ hero @unknown { name } }`, WithSkipValidation())
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, out)
+	})
+
+	t.Run("skip validation applies to a single execution", func(t *testing.T) {
+		_, err := execute(`{ hero @unknown { name } }`)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+	})
+}
+
 func testNetHttpClient(t *testing.T, testCase roundTripperTestCase) *http.Client {
 	defaultClient := httpclient.DefaultNetHttpClient
 	return &http.Client{
@@ -661,6 +1283,81 @@ func (a *afterFetchHook) OnError(ctx resolve.HookContext, output []byte, singleF
 	a.err += string(output)
 }
 
+func TestExecutionEngineV2_OperationSelection(t *testing.T) {
+	newEngine := func(t *testing.T) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(starwarsSchema(t))
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{TypeName: "Query", FieldNames: []string{"hero"}},
+				},
+				Factory: &rest_datasource.Factory{
+					Client: testNetHttpClient(t, roundTripperTestCase{
+						expectedHost:     "example.com",
+						expectedPath:     "/",
+						expectedBody:     "",
+						sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+						sendStatusCode:   200,
+					}),
+				},
+				Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+					Fetch: rest_datasource.FetchConfiguration{
+						URL:    "https://example.com/",
+						Method: "GET",
+					},
+				}),
+			},
+		})
+		engine, err := NewExecutionEngineV2(context.Background(), abstractlogger.NoopLogger, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	multiOperationQuery := "query HeroName { hero { name } } query HeroTypeName { hero { __typename } }"
+
+	t.Run("should execute a single anonymous operation", func(t *testing.T) {
+		resultWriter := NewEngineResultWriter()
+		err := newEngine(t).Execute(context.Background(), &Request{Query: "{ hero { name } }"}, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+	})
+
+	t.Run("should execute the operation selected by name", func(t *testing.T) {
+		resultWriter := NewEngineResultWriter()
+		operation := &Request{
+			OperationName: "HeroName",
+			Query:         multiOperationQuery,
+		}
+		err := newEngine(t).Execute(context.Background(), operation, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+	})
+
+	t.Run("should return an error if the selected operation does not exist", func(t *testing.T) {
+		resultWriter := NewEngineResultWriter()
+		operation := &Request{
+			OperationName: "Unknown",
+			Query:         multiOperationQuery,
+		}
+		err := newEngine(t).Execute(context.Background(), operation, &resultWriter)
+		requestErrors, ok := err.(RequestErrors)
+		require.True(t, ok)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, "cannot find an operation with name: Unknown", requestErrors[0].Message)
+		assert.Equal(t, "", resultWriter.String())
+	})
+
+	t.Run("should return an error if no operation is selected in a multi operation document", func(t *testing.T) {
+		resultWriter := NewEngineResultWriter()
+		err := newEngine(t).Execute(context.Background(), &Request{Query: multiOperationQuery}, &resultWriter)
+		requestErrors, ok := err.(RequestErrors)
+		require.True(t, ok)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, "operation name is required when providing multiple operations", requestErrors[0].Message)
+		assert.Equal(t, "", resultWriter.String())
+	})
+}
+
 func TestExecutionWithOptions(t *testing.T) {
 
 	closer := make(chan struct{})
@@ -718,6 +1415,246 @@ func TestExecutionWithOptions(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestExecutionEngineV2_ResolveContextFactory(t *testing.T) {
+	engineConf := NewEngineV2Configuration(starwarsSchema(t))
+	engineConf.SetDataSources([]plan.DataSourceConfiguration{
+		{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"hero"}},
+			},
+			Factory: &graphql_datasource.Factory{
+				HTTPClient: testNetHttpClient(t, roundTripperTestCase{
+					expectedHost:     "example.com",
+					expectedPath:     "/",
+					expectedBody:     "",
+					sendResponseBody: `{"data":{"hero":{"name":"Luke Skywalker"}}}`,
+					sendStatusCode:   200,
+				}),
+			},
+			Custom: graphql_datasource.ConfigJson(graphql_datasource.Configuration{
+				Fetch: graphql_datasource.FetchConfiguration{
+					URL:    "https://example.com/",
+					Method: "GET",
+				},
+			}),
+		},
+	})
+
+	defaultBefore := &beforeFetchHook{}
+	engineConf.SetResolveContextFactory(func() *resolve.Context {
+		ctx := resolve.NewContext(context.Background())
+		ctx.SetBeforeFetchHook(defaultBefore)
+		return ctx
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine, err := NewExecutionEngineV2(ctx, abstractlogger.Noop{}, engineConf)
+	require.NoError(t, err)
+
+	execute := func(t *testing.T, options ...ExecutionOptionsV2) {
+		operation := loadStarWarsQuery(starwars.FileSimpleHeroQuery, nil)(t)
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &operation, &resultWriter, options...)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+	}
+
+	fetchInput := `{"method":"GET","url":"https://example.com/","body":{"query":"{hero}"}}`
+
+	t.Run("default hook is used for each execution", func(t *testing.T) {
+		defaultBefore.input = ""
+		execute(t)
+		execute(t)
+		assert.Equal(t, fetchInput+fetchInput, defaultBefore.input)
+	})
+
+	t.Run("options override defaults for a single execution", func(t *testing.T) {
+		defaultBefore.input = ""
+		before := &beforeFetchHook{}
+		execute(t, WithBeforeFetchHook(before))
+		assert.Equal(t, fetchInput, before.input)
+		assert.Equal(t, "", defaultBefore.input)
+
+		execute(t)
+		assert.Equal(t, fetchInput, defaultBefore.input)
+	})
+
+	t.Run("modified defaults don't leak into the next execution", func(t *testing.T) {
+		execContext := newInternalExecutionContextFromFactory(func() *resolve.Context {
+			ctx := resolve.NewContext(context.Background())
+			ctx.TraceID = []byte("trace")
+			ctx.Request.Header = http.Header{"X-Default": []string{"default"}}
+			return ctx
+		})
+		execContext.resolveContext.TraceID[0] = 'X'
+		execContext.resolveContext.Request.Header.Set("X-Execution", "execution")
+		execContext.reset()
+
+		assert.Equal(t, "trace", string(execContext.resolveContext.TraceID))
+		assert.Equal(t, http.Header{"X-Default": []string{"default"}}, execContext.resolveContext.Request.Header)
+	})
+}
+
+func TestExecutionEngineV2_ReloadSchema(t *testing.T) {
+	helloSchema := func(t *testing.T, definition string) *Schema {
+		schema, err := NewSchemaFromString(definition)
+		require.NoError(t, err)
+		return schema
+	}
+
+	helloPlannerConfig := func(fieldName, data string) plan.Configuration {
+		return plan.Configuration{
+			DataSources: []plan.DataSourceConfiguration{
+				{
+					RootNodes: []plan.TypeField{
+						{TypeName: "Query", FieldNames: []string{fieldName}},
+					},
+					Factory: &staticdatasource.Factory{},
+					Custom: staticdatasource.ConfigJSON(staticdatasource.Configuration{
+						Data: data,
+					}),
+				},
+			},
+			Fields: plan.FieldConfigurations{
+				{
+					TypeName:              "Query",
+					FieldName:             fieldName,
+					DisableDefaultMapping: true,
+				},
+			},
+		}
+	}
+
+	newEngine := func(t *testing.T) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(helloSchema(t, `type Query { hello: String }`))
+		engineConf.plannerConfig = helloPlannerConfig("hello", "world")
+		engine, err := NewExecutionEngineV2(context.Background(), abstractlogger.NoopLogger, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	execute := func(engine *ExecutionEngineV2, query string) (string, error) {
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: query}, &resultWriter)
+		return resultWriter.String(), err
+	}
+
+	t.Run("should use the reloaded schema and planner configuration", func(t *testing.T) {
+		engine := newEngine(t)
+
+		result, err := execute(engine, "{ hello }")
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, result)
+
+		err = engine.ReloadSchema(helloSchema(t, `type Query { goodbye: String }`), helloPlannerConfig("goodbye", "moon"))
+		require.NoError(t, err)
+
+		result, err = execute(engine, "{ goodbye }")
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"goodbye":"moon"}}`, result)
+
+		_, err = execute(engine, "{ hello }")
+		assert.Error(t, err)
+	})
+
+	t.Run("should flush the execution plan cache", func(t *testing.T) {
+		engine := newEngine(t)
+
+		result, err := execute(engine, "{ hello }")
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, result)
+		assert.Equal(t, 1, engine.currentSchema().executionPlanCache.Len())
+
+		err = engine.ReloadSchema(helloSchema(t, `type Query { hello: String }`), helloPlannerConfig("hello", "reloaded"))
+		require.NoError(t, err)
+		assert.Equal(t, 0, engine.currentSchema().executionPlanCache.Len())
+
+		result, err = execute(engine, "{ hello }")
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"reloaded"}}`, result)
+	})
+
+	t.Run("should plan with the schema the execution started with", func(t *testing.T) {
+		engine := newEngine(t)
+		previous := engine.currentSchema()
+
+		err := engine.ReloadSchema(helloSchema(t, `type Query { goodbye: String }`), helloPlannerConfig("goodbye", "moon"))
+		require.NoError(t, err)
+
+		operation := &Request{Query: "{ hello }"}
+		result, err := operation.Normalize(previous.schema)
+		require.NoError(t, err)
+		require.True(t, result.Successful)
+
+		execContext := engine.getExecutionCtx()
+		defer engine.putExecutionCtx(execContext)
+		var report operationreport.Report
+		cachedPlan, _ := engine.getCachedPlan(execContext, 1, &operation.document, previous, "", &report)
+		require.False(t, report.HasErrors(), report.Error())
+
+		resultWriter := NewEngineResultWriter()
+		execContext.prepare(context.Background(), nil, resolve.Request{})
+		err = engine.resolver.ResolveGraphQLResponse(execContext.resolveContext, cachedPlan.(*plan.SynchronousResponsePlan).Response, nil, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, resultWriter.String())
+		assert.Equal(t, 1, previous.executionPlanCache.Len())
+		assert.Equal(t, 0, engine.currentSchema().executionPlanCache.Len())
+	})
+
+	t.Run("should return an error for a nil schema", func(t *testing.T) {
+		engine := newEngine(t)
+
+		err := engine.ReloadSchema(nil, helloPlannerConfig("hello", "world"))
+		assert.Equal(t, ErrNilSchema, err)
+
+		result, err := execute(engine, "{ hello }")
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, result)
+	})
+
+	t.Run("should reload the schema while executing concurrently", func(t *testing.T) {
+		engine := newEngine(t)
+
+		done := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					result, err := execute(engine, "{ hello }")
+					if !assert.NoError(t, err) {
+						return
+					}
+					if result != `{"data":{"hello":"world"}}` && result != `{"data":{"hello":"reloaded"}}` {
+						assert.Fail(t, "unexpected result", result)
+						return
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < 50; i++ {
+			data := "world"
+			if i%2 == 0 {
+				data = "reloaded"
+			}
+			err := engine.ReloadSchema(helloSchema(t, `type Query { hello: String }`), helloPlannerConfig("hello", data))
+			require.NoError(t, err)
+			time.Sleep(time.Millisecond)
+		}
+
+		close(done)
+		wg.Wait()
+	})
+}
+
 func BenchmarkExecutionEngineV2(b *testing.B) {
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -0,0 +1,46 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+type ExecutionPhase int
+
+const (
+	ExecutionPhaseParse ExecutionPhase = iota + 1
+	ExecutionPhaseNormalize
+	ExecutionPhaseValidate
+	ExecutionPhasePlan
+	ExecutionPhaseResolve
+)
+
+func (p ExecutionPhase) String() string {
+	switch p {
+	case ExecutionPhaseParse:
+		return "parse"
+	case ExecutionPhaseNormalize:
+		return "normalize"
+	case ExecutionPhaseValidate:
+		return "validate"
+	case ExecutionPhasePlan:
+		return "plan"
+	case ExecutionPhaseResolve:
+		return "resolve"
+	default:
+		return "unknown"
+	}
+}
+
+type ExecutionPhaseMetrics struct {
+	Phase    ExecutionPhase
+	Duration time.Duration
+	// PlanCached is true if the execution plan was taken from the plan cache, it's only set for ExecutionPhasePlan
+	PlanCached bool
+}
+
+// ExecutionMetricsHook gets called by ExecutionEngineV2.Execute each time a phase of the execution is finished.
+// Phases which are skipped, e.g. parsing and normalization of an already normalized operation, are not reported.
+type ExecutionMetricsHook interface {
+	OnExecutionPhase(ctx context.Context, metrics ExecutionPhaseMetrics)
+}
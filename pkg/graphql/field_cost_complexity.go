@@ -0,0 +1,175 @@
+package graphql
+
+import (
+	"math"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+)
+
+var DefaultListSizeArguments = []string{"first", "last"}
+
+// FieldCostComplexityCalculator calculates the complexity of a normalized operation by assigning a cost to each field.
+//
+// The scoring rules are:
+//   - every field costs 1, this includes scalar fields and __typename
+//   - the cost of the selection set of a field is multiplied by the value of its list size argument, e.g. `first: 10`
+//   - arguments which were extracted into variables during normalization are looked up in the variables of the operation
+//   - if a field has no list size argument or the value is not a positive integer, the multiplier is 1
+//   - multipliers of nested fields are multiplied, e.g. `users(first: 10) { friends(first: 5) { name } }` costs 1 + 10 * (1 + 5 * 1) = 61
+//   - fields of inline fragments count as fields of the enclosing selection set
+//
+// The complexity saturates at math.MaxInt32 to avoid overflows for very large multipliers.
+type FieldCostComplexityCalculator struct {
+	// ListSizeArguments are the names of the arguments used as multiplier, the first argument present on a field is used
+	ListSizeArguments []string
+}
+
+func NewFieldCostComplexityCalculator() FieldCostComplexityCalculator {
+	return FieldCostComplexityCalculator{
+		ListSizeArguments: DefaultListSizeArguments,
+	}
+}
+
+// Calculate returns the field cost as Complexity, the number of fields as NodeCount and the maximum field nesting as Depth
+func (f FieldCostComplexityCalculator) Calculate(operation, definition *ast.Document) (ComplexityResult, error) {
+	walker := astvisitor.NewWalker(48)
+	visitor := fieldCostVisitor{
+		Walker:            &walker,
+		operation:         operation,
+		definition:        definition,
+		listSizeArguments: f.ListSizeArguments,
+		multipliers:       make([]int, 0, 8),
+	}
+	walker.RegisterEnterFieldVisitor(&visitor)
+	walker.RegisterLeaveFieldVisitor(&visitor)
+	walker.RegisterEnterFragmentDefinitionVisitor(&visitor)
+
+	report := operationreport.Report{}
+	walker.Walk(operation, definition, &report)
+
+	result := ComplexityResult{
+		NodeCount:    visitor.nodeCount,
+		Complexity:   visitor.complexity,
+		Depth:        visitor.depth,
+		PerRootField: visitor.rootFields,
+	}
+	if !report.HasErrors() {
+		return result, nil
+	}
+
+	result.Errors = RequestErrorsFromOperationReport(report)
+	var err error
+	if len(report.InternalErrors) > 0 {
+		err = report.InternalErrors[0]
+	}
+	return result, err
+}
+
+type fieldCostVisitor struct {
+	*astvisitor.Walker
+	operation         *ast.Document
+	definition        *ast.Document
+	listSizeArguments []string
+	// multipliers contains the accumulated multiplier for each enclosing field with a selection set
+	multipliers []int
+	nodeCount   int
+	complexity  int
+	depth       int
+	rootFields  []FieldComplexityResult
+}
+
+func (f *fieldCostVisitor) EnterField(ref int) {
+	multiplier := 1
+	if len(f.multipliers) != 0 {
+		multiplier = f.multipliers[len(f.multipliers)-1]
+	}
+	depth := len(f.multipliers) + 1
+
+	f.nodeCount++
+	f.complexity = saturatingAdd(f.complexity, multiplier)
+	if depth > f.depth {
+		f.depth = depth
+	}
+
+	if depth == 1 {
+		fieldName := f.operation.FieldNameString(ref)
+		alias := f.operation.FieldAliasOrNameString(ref)
+		if alias == fieldName {
+			alias = ""
+		}
+		f.rootFields = append(f.rootFields, FieldComplexityResult{
+			TypeName:  f.EnclosingTypeDefinition.NameString(f.definition),
+			FieldName: fieldName,
+			Alias:     alias,
+		})
+	}
+	root := &f.rootFields[len(f.rootFields)-1]
+	root.NodeCount++
+	root.Complexity = saturatingAdd(root.Complexity, multiplier)
+	if depth > root.Depth {
+		root.Depth = depth
+	}
+
+	if f.operation.FieldHasSelections(ref) {
+		f.multipliers = append(f.multipliers, saturatingMultiply(multiplier, f.listSize(ref)))
+	}
+}
+
+func (f *fieldCostVisitor) EnterFragmentDefinition(ref int) {
+	f.SkipNode()
+}
+
+func (f *fieldCostVisitor) LeaveField(ref int) {
+	if f.operation.FieldHasSelections(ref) {
+		f.multipliers = f.multipliers[:len(f.multipliers)-1]
+	}
+}
+
+func (f *fieldCostVisitor) listSize(field int) int {
+	for _, name := range f.listSizeArguments {
+		ref, exists := f.operation.FieldArgument(field, []byte(name))
+		if !exists {
+			continue
+		}
+		value := f.operation.ArgumentValue(ref)
+		var size int64
+		switch value.Kind {
+		case ast.ValueKindInteger:
+			size = f.operation.IntValueAsInt(value.Ref)
+		case ast.ValueKindVariable:
+			variableValue, err := jsonparser.GetInt(f.operation.Input.Variables, f.operation.VariableValueNameString(value.Ref))
+			if err != nil {
+				return 1
+			}
+			size = variableValue
+		default:
+			return 1
+		}
+		if size <= 0 {
+			return 1
+		}
+		if size > math.MaxInt32 {
+			return math.MaxInt32
+		}
+		return int(size)
+	}
+	return 1
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+func saturatingMultiply(a, b int) int {
+	if a != 0 && b > math.MaxInt32/a {
+		return math.MaxInt32
+	}
+	return a * b
+}
@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fieldCostSchema = `
+schema { query: Query }
+type Query {
+	user(id: ID): User
+	users(first: Int, last: Int): [User]
+}
+type User {
+	id: ID
+	name: String
+	friends(first: Int): [User]
+}`
+
+func TestFieldCostComplexityCalculator_Calculate(t *testing.T) {
+	run := func(query string, variables string, expectedComplexity, expectedNodeCount, expectedDepth int) func(t *testing.T) {
+		return func(t *testing.T) {
+			schema, err := NewSchemaFromString(fieldCostSchema)
+			require.NoError(t, err)
+
+			request := Request{Query: query, Variables: []byte(variables)}
+			normalizationResult, err := request.Normalize(schema)
+			require.NoError(t, err)
+			require.True(t, normalizationResult.Successful)
+
+			result, err := NewFieldCostComplexityCalculator().Calculate(&request.document, &schema.document)
+			require.NoError(t, err)
+			assert.Equal(t, expectedComplexity, result.Complexity)
+			assert.Equal(t, expectedNodeCount, result.NodeCount)
+			assert.Equal(t, expectedDepth, result.Depth)
+		}
+	}
+
+	t.Run("simple query", run(`{ user(id: "1") { id name } }`, ``, 3, 3, 2))
+	t.Run("list multiplied by first", run(`{ users(first: 10) { id name } }`, ``, 21, 3, 2))
+	t.Run("list multiplied by last", run(`{ users(last: 3) { id } }`, ``, 4, 2, 2))
+	t.Run("list without size argument", run(`{ users { id } }`, ``, 2, 2, 2))
+	t.Run("nested multipliers", run(`{ users(first: 10) { friends(first: 5) { name } } }`, ``, 61, 3, 3))
+	t.Run("multiplier from variable", run(`query Users($n: Int) { users(first: $n) { id } }`, `{"n":20}`, 21, 2, 2))
+	t.Run("negative multiplier", run(`{ users(first: -5) { id } }`, ``, 2, 2, 2))
+
+	t.Run("per root field", func(t *testing.T) {
+		schema, err := NewSchemaFromString(fieldCostSchema)
+		require.NoError(t, err)
+
+		request := Request{Query: `{ user(id: "1") { name } all: users(first: 2) { name } }`}
+		_, err = request.Normalize(schema)
+		require.NoError(t, err)
+
+		result, err := NewFieldCostComplexityCalculator().Calculate(&request.document, &schema.document)
+		require.NoError(t, err)
+		assert.Equal(t, []FieldComplexityResult{
+			{TypeName: "Query", FieldName: "user", NodeCount: 2, Complexity: 2, Depth: 2},
+			{TypeName: "Query", FieldName: "users", Alias: "all", NodeCount: 2, Complexity: 3, Depth: 2},
+		}, result.PerRootField)
+		assert.Equal(t, 5, result.Complexity)
+	})
+}
+
+func TestExecutionEngineV2_MaxComplexity(t *testing.T) {
+	newEngine := func(t *testing.T, maxComplexity int, observer func(result ComplexityResult)) *ExecutionEngineV2 {
+		schema, err := NewSchemaFromString(fieldCostSchema)
+		require.NoError(t, err)
+		engineConf := NewEngineV2Configuration(schema)
+		engineConf.SetMaxComplexity(maxComplexity)
+		engineConf.SetComplexityObserver(observer)
+		engine, err := NewExecutionEngineV2(context.Background(), abstractlogger.Noop{}, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	t.Run("reject operation above the budget", func(t *testing.T) {
+		var observed ComplexityResult
+		engine := newEngine(t, 20, func(result ComplexityResult) {
+			observed = result
+		})
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: `{ users(first: 10) { id name } }`}, &resultWriter)
+		require.Error(t, err)
+		requestErrors, ok := err.(RequestErrors)
+		require.True(t, ok)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, "query complexity of 21 exceeds the maximum complexity of 20", requestErrors[0].Message)
+		assert.Equal(t, 21, observed.Complexity)
+		assert.Equal(t, 0, resultWriter.Len())
+	})
+
+	t.Run("observe without budget", func(t *testing.T) {
+		var observed ComplexityResult
+		engine := newEngine(t, 0, func(result ComplexityResult) {
+			observed = result
+		})
+
+		resultWriter := NewEngineResultWriter()
+		_ = engine.Execute(context.Background(), &Request{Query: `{ users(first: 10) { id name } }`}, &resultWriter)
+		assert.Equal(t, 21, observed.Complexity)
+	})
+}
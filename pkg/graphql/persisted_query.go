@@ -0,0 +1,114 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+var (
+	ErrPersistedQueryNotFound = RequestErrors{
+		{
+			Message:    "PersistedQueryNotFound",
+			Extensions: json.RawMessage(`{"code":"PERSISTED_QUERY_NOT_FOUND"}`),
+		},
+	}
+	ErrPersistedQueryNotSupported = RequestErrors{
+		{
+			Message:    "PersistedQueryNotSupported",
+			Extensions: json.RawMessage(`{"code":"PERSISTED_QUERY_NOT_SUPPORTED"}`),
+		},
+	}
+	ErrPersistedQueryHashMismatch = RequestErrors{
+		{
+			Message: "provided sha does not match query",
+		},
+	}
+)
+
+// PersistedQueryStore stores the queries of Automatic Persisted Queries (APQ) by their sha256 hash
+type PersistedQueryStore interface {
+	Get(ctx context.Context, hash string) (query string, found bool, err error)
+	Set(ctx context.Context, hash string, query string) error
+}
+
+type lruPersistedQueryStore struct {
+	cache *lru.Cache
+}
+
+// NewLRUPersistedQueryStore returns an in memory PersistedQueryStore which keeps the last recently used queries
+func NewLRUPersistedQueryStore(size int) (PersistedQueryStore, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &lruPersistedQueryStore{cache: cache}, nil
+}
+
+func (l *lruPersistedQueryStore) Get(_ context.Context, hash string) (query string, found bool, err error) {
+	cached, ok := l.cache.Get(hash)
+	if !ok {
+		return "", false, nil
+	}
+	return cached.(string), true, nil
+}
+
+func (l *lruPersistedQueryStore) Set(_ context.Context, hash string, query string) error {
+	l.cache.Add(hash, query)
+	return nil
+}
+
+type persistedQueryExtensions struct {
+	PersistedQuery *struct {
+		Version    int    `json:"version"`
+		Sha256Hash string `json:"sha256Hash"`
+	} `json:"persistedQuery"`
+}
+
+// persistedQueryHash returns the sha256 hash of the persisted query extension of the request
+func (r *Request) persistedQueryHash() (hash string, ok bool) {
+	if len(r.Extensions) == 0 {
+		return "", false
+	}
+	var extensions persistedQueryExtensions
+	if err := json.Unmarshal(r.Extensions, &extensions); err != nil || extensions.PersistedQuery == nil {
+		return "", false
+	}
+	return extensions.PersistedQuery.Sha256Hash, extensions.PersistedQuery.Sha256Hash != ""
+}
+
+// resolvePersistedQuery sets the query of an APQ request from the store
+// if the request contains the query, it gets registered in the store after the hash is verified
+func resolvePersistedQuery(ctx context.Context, store PersistedQueryStore, operation *Request) error {
+	hash, ok := operation.persistedQueryHash()
+	if !ok {
+		return nil
+	}
+	if store == nil {
+		if operation.Query != "" {
+			return nil
+		}
+		return ErrPersistedQueryNotSupported
+	}
+
+	if operation.Query == "" {
+		query, found, err := store.Get(ctx, hash)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrPersistedQueryNotFound
+		}
+		operation.Query = query
+		return nil
+	}
+
+	queryHash := sha256.Sum256([]byte(operation.Query))
+	if hex.EncodeToString(queryHash[:]) != hash {
+		return ErrPersistedQueryHashMismatch
+	}
+	return store.Set(ctx, hash, operation.Query)
+}
@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/engine/datasource/rest_datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/engine/plan"
+)
+
+func TestExecutionEngineV2_PersistedQueries(t *testing.T) {
+	query := "query MyHero { hero { name } }"
+	queryHash := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(queryHash[:])
+	extensions := []byte(fmt.Sprintf(`{"persistedQuery":{"version":1,"sha256Hash":"%s"}}`, hash))
+
+	newEngine := func(t *testing.T, store PersistedQueryStore) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(starwarsSchema(t))
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			{
+				RootNodes: []plan.TypeField{
+					{TypeName: "Query", FieldNames: []string{"hero"}},
+				},
+				Factory: &rest_datasource.Factory{
+					Client: testNetHttpClient(t, roundTripperTestCase{
+						expectedHost:     "example.com",
+						expectedPath:     "/",
+						expectedBody:     "",
+						sendResponseBody: `{"hero": {"name": "Luke Skywalker"}}`,
+						sendStatusCode:   200,
+					}),
+				},
+				Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+					Fetch: rest_datasource.FetchConfiguration{
+						URL:    "https://example.com/",
+						Method: "GET",
+					},
+				}),
+			},
+		})
+		if store != nil {
+			engineConf.SetPersistedQueryStore(store)
+		}
+		engine, err := NewExecutionEngineV2(context.Background(), abstractlogger.Noop{}, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	newStore := func(t *testing.T) PersistedQueryStore {
+		store, err := NewLRUPersistedQueryStore(8)
+		require.NoError(t, err)
+		return store
+	}
+
+	errorResponse := func(t *testing.T, err error) string {
+		requestErrors, ok := err.(RequestErrors)
+		require.True(t, ok)
+		buf := &bytes.Buffer{}
+		_, writeErr := requestErrors.WriteResponse(buf)
+		require.NoError(t, writeErr)
+		return buf.String()
+	}
+
+	t.Run("unknown hash", func(t *testing.T) {
+		engine := newEngine(t, newStore(t))
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Extensions: extensions}, &resultWriter)
+		assert.Equal(t, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`, errorResponse(t, err))
+	})
+
+	t.Run("register then execute by hash", func(t *testing.T) {
+		engine := newEngine(t, newStore(t))
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: query, Extensions: extensions}, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+
+		resultWriter = NewEngineResultWriter()
+		err = engine.Execute(context.Background(), &Request{Extensions: extensions}, &resultWriter)
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"hero":{"name":"Luke Skywalker"}}}`, resultWriter.String())
+	})
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		store := newStore(t)
+		engine := newEngine(t, store)
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: "query Other { hero { name } }", Extensions: extensions}, &resultWriter)
+		assert.Equal(t, `{"errors":[{"message":"provided sha does not match query"}]}`, errorResponse(t, err))
+		assert.Equal(t, 0, resultWriter.Len())
+
+		_, found, err := store.Get(context.Background(), hash)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("persisted queries not enabled", func(t *testing.T) {
+		engine := newEngine(t, nil)
+
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Extensions: extensions}, &resultWriter)
+		assert.Equal(t, `{"errors":[{"message":"PersistedQueryNotSupported","extensions":{"code":"PERSISTED_QUERY_NOT_SUPPORTED"}}]}`, errorResponse(t, err))
+	})
+}
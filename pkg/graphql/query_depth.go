@@ -0,0 +1,58 @@
+package graphql
+
+import (
+	"strings"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+)
+
+// validateQueryDepth reports an error if the operation contains fields nested deeper than maxDepth
+// root fields have a depth of 1, fields of inline fragments count towards the depth of the enclosing field
+func validateQueryDepth(operation, definition *ast.Document, maxDepth int, report *operationreport.Report) {
+	walker := astvisitor.NewWalker(48)
+	visitor := queryDepthVisitor{
+		Walker:   &walker,
+		maxDepth: maxDepth,
+	}
+	walker.RegisterEnterFieldVisitor(&visitor)
+	walker.Walk(operation, definition, report)
+}
+
+type queryDepthVisitor struct {
+	*astvisitor.Walker
+	maxDepth int
+}
+
+func (q *queryDepthVisitor) EnterField(ref int) {
+	depth := 1
+	for i := range q.Ancestors {
+		if q.Ancestors[i].Kind == ast.NodeKindField {
+			depth++
+		}
+	}
+	if depth > q.maxDepth {
+		q.StopWithExternalErr(operationreport.ErrQueryExceedsMaxDepth(q.maxDepth))
+	}
+}
+
+// isIntrospectionOnly returns true if all root fields of all operations are introspection fields, e.g. __schema or __type
+func isIntrospectionOnly(operation *ast.Document) bool {
+	for i := range operation.OperationDefinitions {
+		if !operation.OperationDefinitions[i].HasSelections {
+			continue
+		}
+		selectionSet := operation.OperationDefinitions[i].SelectionSet
+		for _, selectionRef := range operation.SelectionSets[selectionSet].SelectionRefs {
+			selection := operation.Selections[selectionRef]
+			if selection.Kind != ast.SelectionKindField {
+				return false
+			}
+			if !strings.HasPrefix(operation.FieldNameUnsafeString(selection.Ref), "__") {
+				return false
+			}
+		}
+	}
+	return true
+}
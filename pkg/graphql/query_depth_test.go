@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+)
+
+const queryWithDepth4 = `query Friends { hero { friends { ... on Human { friends { name } } } } }`
+
+func TestValidateQueryDepth(t *testing.T) {
+	run := func(query string, maxDepth int, expectedErr string) func(t *testing.T) {
+		return func(t *testing.T) {
+			schema := starwarsSchema(t)
+			request := Request{Query: query}
+			result, err := request.Normalize(schema)
+			require.NoError(t, err)
+			require.True(t, result.Successful)
+
+			var report operationreport.Report
+			validateQueryDepth(&request.document, &schema.document, maxDepth, &report)
+			if expectedErr == "" {
+				assert.False(t, report.HasErrors())
+				return
+			}
+			require.Len(t, report.ExternalErrors, 1)
+			assert.Equal(t, expectedErr, report.ExternalErrors[0].Message)
+		}
+	}
+
+	t.Run("under the limit", run(queryWithDepth4, 5, ""))
+	t.Run("at the limit", run(queryWithDepth4, 4, ""))
+	t.Run("one over the limit", run(queryWithDepth4, 3, "query exceeds maximum depth of 3"))
+	t.Run("root fields", run(`{ hero { name } droid(id: "2000") { name } }`, 2, ""))
+}
+
+func TestIsIntrospectionOnly(t *testing.T) {
+	run := func(query string, expected bool) func(t *testing.T) {
+		return func(t *testing.T) {
+			request := Request{Query: query}
+			report := request.parseQueryOnce()
+			require.False(t, report.HasErrors())
+			assert.Equal(t, expected, isIntrospectionOnly(&request.document))
+		}
+	}
+
+	t.Run("schema", run(`{ __schema { types { name } } }`, true))
+	t.Run("schema and type", run(`{ __schema { queryType { name } } __type(name: "Droid") { name } }`, true))
+	t.Run("named introspection query with regular field", run(`query IntrospectionQuery { __schema { types { name } } hero { name } }`, false))
+	t.Run("regular field", run(`{ hero { name } }`, false))
+}
+
+func TestExecutionEngineV2_MaxQueryDepth(t *testing.T) {
+	newEngine := func(t *testing.T, exemptIntrospection bool) *ExecutionEngineV2 {
+		engineConf := NewEngineV2Configuration(starwarsSchema(t))
+		engineConf.SetMaxQueryDepth(2)
+		engineConf.SetExemptIntrospectionFromMaxQueryDepth(exemptIntrospection)
+		engine, err := NewExecutionEngineV2(context.Background(), abstractlogger.Noop{}, engineConf)
+		require.NoError(t, err)
+		return engine
+	}
+
+	t.Run("reject too deep query", func(t *testing.T) {
+		engine := newEngine(t, false)
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: queryWithDepth4}, &resultWriter)
+		require.Error(t, err)
+
+		requestErrors, ok := err.(RequestErrors)
+		require.True(t, ok)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, "query exceeds maximum depth of 2", requestErrors[0].Message)
+		assert.Equal(t, 0, resultWriter.Len())
+	})
+
+	t.Run("reject too deep introspection query", func(t *testing.T) {
+		engine := newEngine(t, false)
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: `{ __schema { queryType { fields { name } } } }`}, &resultWriter)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "query exceeds maximum depth of 2")
+	})
+
+	t.Run("exempt introspection query", func(t *testing.T) {
+		engine := newEngine(t, true)
+		resultWriter := NewEngineResultWriter()
+		err := engine.Execute(context.Background(), &Request{Query: `{ __schema { queryType { fields { name } } } }`}, &resultWriter)
+		assert.NoError(t, err)
+	})
+}
+
+func BenchmarkValidateQueryDepth(b *testing.B) {
+	schema, err := NewSchemaFromString(`type Query { user: User } type User { name: String friends: [User] }`)
+	require.NoError(b, err)
+	request := Request{Query: `{ user { friends { friends { name } } } }`}
+	result, err := request.Normalize(schema)
+	require.NoError(b, err)
+	require.True(b, result.Successful)
+
+	var report operationreport.Report
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		validateQueryDepth(&request.document, &schema.document, 10, &report)
+		if report.HasErrors() {
+			b.Fatal(report)
+		}
+	}
+}
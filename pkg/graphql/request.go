@@ -37,6 +37,7 @@ type Request struct {
 	OperationName string          `json:"operationName"`
 	Variables     json.RawMessage `json:"variables"`
 	Query         string          `json:"query"`
+	Extensions    json.RawMessage `json:"extensions,omitempty"`
 
 	document     ast.Document
 	isParsed     bool
@@ -108,6 +109,33 @@ func (r *Request) parseQueryOnce() (report operationreport.Report) {
 	return report
 }
 
+// validateOperationSelection ensures the operation name of the request selects exactly one operation of the document
+func (r *Request) validateOperationSelection() (report operationreport.Report) {
+	operationCount := 0
+	for _, rootNode := range r.document.RootNodes {
+		if rootNode.Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		if r.OperationName != "" && r.document.OperationDefinitionNameString(rootNode.Ref) == r.OperationName {
+			return report
+		}
+		operationCount++
+	}
+
+	if operationCount == 0 {
+		// documents without operations get rejected during validation
+		return report
+	}
+	if r.OperationName != "" {
+		report.AddExternalError(operationreport.ErrOperationWithProvidedOperationNameNotFound(r.OperationName))
+		return report
+	}
+	if operationCount > 1 {
+		report.AddExternalError(operationreport.ErrRequiredOperationNameIsMissing())
+	}
+	return report
+}
+
 func (r *Request) IsIntrospectionQuery() (result bool, err error) {
 	report := r.parseQueryOnce()
 	if report.HasErrors() {
@@ -0,0 +1,171 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
+)
+
+// ResponseCacheKey is the SHA-256 digest of the operation, its variables and the request headers
+// The key is long enough to rule out collisions, so that a response is never served to a request it doesn't belong to,
+// e.g. of a user with another Authorization header.
+type ResponseCacheKey [sha256.Size]byte
+
+// ResponseCache stores the serialized responses of query operations, see EngineV2Configuration.SetResponseCache
+type ResponseCache interface {
+	Get(key ResponseCacheKey) (response []byte, found bool)
+	Set(key ResponseCacheKey, response []byte, ttl time.Duration)
+	// Purge removes all responses, it's called when the schema of the engine gets reloaded
+	Purge()
+}
+
+type lruResponseCache struct {
+	cache *lru.Cache
+	now   func() time.Time
+}
+
+type cachedResponse struct {
+	response  []byte
+	expiresAt time.Time
+}
+
+// NewLRUResponseCache returns an in memory ResponseCache which keeps the last recently used responses until they expire
+func NewLRUResponseCache(size int) (ResponseCache, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &lruResponseCache{cache: cache, now: time.Now}, nil
+}
+
+func (l *lruResponseCache) Get(key ResponseCacheKey) (response []byte, found bool) {
+	cached, ok := l.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := cached.(cachedResponse)
+	if !l.now().Before(entry.expiresAt) {
+		l.cache.Remove(key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (l *lruResponseCache) Set(key ResponseCacheKey, response []byte, ttl time.Duration) {
+	l.cache.Add(key, cachedResponse{response: response, expiresAt: l.now().Add(ttl)})
+}
+
+func (l *lruResponseCache) Purge() {
+	l.cache.Purge()
+}
+
+// responseCacheOptions are the per execution settings of the response cache
+type responseCacheOptions struct {
+	bypass    bool
+	hasMaxAge bool
+	maxAge    time.Duration
+}
+
+// WithResponseCacheBypass neither serves the response from the response cache nor stores it
+func WithResponseCacheBypass() ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.responseCache.bypass = true
+	}
+}
+
+// WithResponseCacheMaxAge overrides the TTL of the response cache for the response of a single execution,
+// a maxAge of 0 serves the response from the cache but doesn't store it
+func WithResponseCacheMaxAge(maxAge time.Duration) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		ctx.responseCache.hasMaxAge = true
+		ctx.responseCache.maxAge = maxAge
+	}
+}
+
+// WithCacheControl applies the directives of a Cache-Control request header to the response cache:
+// no-cache and no-store bypass the cache, max-age=N stores the response for N seconds
+func WithCacheControl(header string) ExecutionOptionsV2 {
+	return func(ctx *internalExecutionContext) {
+		for _, directive := range strings.Split(header, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-cache" || directive == "no-store":
+				ctx.responseCache.bypass = true
+			case strings.HasPrefix(directive, "max-age="):
+				seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil || seconds < 0 {
+					continue
+				}
+				ctx.responseCache.hasMaxAge = true
+				ctx.responseCache.maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+}
+
+// responseCacheKey digests the printed operation with the operation name, the variables and the request headers,
+// the headers are part of the key as they can be rendered into the inputs of fetches, e.g. an Authorization header.
+// ok is false if the response of the operation must not be cached
+func (e *ExecutionEngineV2) responseCacheKey(ctx *internalExecutionContext, operation *Request, schema *Schema) (key ResponseCacheKey, ok bool) {
+	if e.config.responseCache == nil || ctx.responseCache.bypass {
+		return key, false
+	}
+	operationType, err := operation.OperationType()
+	if err != nil || operationType != OperationTypeQuery {
+		return key, false
+	}
+
+	printed, err := astprinter.PrintString(&operation.document, &schema.document)
+	if err != nil {
+		return key, false
+	}
+	digest := sha256.New()
+	writeResponseCacheKeyPart(digest, printed)
+	writeResponseCacheKeyPart(digest, operation.OperationName)
+	writeResponseCacheKeyPart(digest, string(operation.Variables))
+	writeResponseCacheHeaders(digest, ctx.resolveContext.Request.Header)
+	digest.Sum(key[:0])
+	return key, true
+}
+
+// writeResponseCacheHeaders writes the headers sorted by their canonical name, so that the key doesn't depend on the order of the map
+func writeResponseCacheHeaders(digest hash.Hash, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeResponseCacheKeyPart(digest, http.CanonicalHeaderKey(name))
+		writeResponseCacheKeyPart(digest, strconv.Itoa(len(header[name])))
+		for _, value := range header[name] {
+			writeResponseCacheKeyPart(digest, value)
+		}
+	}
+}
+
+// writeResponseCacheKeyPart writes the part prefixed with its length, so that the boundaries of the parts are part of the key
+func writeResponseCacheKeyPart(digest hash.Hash, part string) {
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(part)))
+	_, _ = digest.Write(length[:])
+	_, _ = io.WriteString(digest, part)
+}
+
+// responseCacheTTL returns the TTL of the response of the execution, the max age of the execution overrides the configured TTL
+func (e *ExecutionEngineV2) responseCacheTTL(ctx *internalExecutionContext) time.Duration {
+	if ctx.responseCache.hasMaxAge {
+		return ctx.responseCache.maxAge
+	}
+	return e.config.responseCacheTTL
+}
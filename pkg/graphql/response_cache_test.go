@@ -0,0 +1,186 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/engine/datasource/rest_datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/engine/plan"
+	"github.com/jensneuse/graphql-go-tools/pkg/engine/resolve"
+)
+
+func TestExecutionEngineV2_ResponseCache(t *testing.T) {
+	const schemaDefinition = `
+		schema { query: Query mutation: Mutation }
+		type Query { hero(name: String): String }
+		type Mutation { renameHero(name: String): String }`
+	schema, err := NewSchemaFromString(schemaDefinition)
+	require.NoError(t, err)
+
+	setup := func(t *testing.T) (*ExecutionEngineV2, *lruResponseCache, *int64) {
+		var upstreamRequests int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&upstreamRequests, 1)
+			name := strings.TrimPrefix(r.URL.Path, "/")
+			if authorization := r.Header.Get("Authorization"); authorization != "" {
+				name += ":" + authorization
+			}
+			_, _ = fmt.Fprintf(w, `{"name":"%s"}`, name)
+		}))
+		t.Cleanup(server.Close)
+
+		dataSource := func(typeName, fieldName string) plan.DataSourceConfiguration {
+			return plan.DataSourceConfiguration{
+				RootNodes: []plan.TypeField{
+					{TypeName: typeName, FieldNames: []string{fieldName}},
+				},
+				Factory: &rest_datasource.Factory{Client: server.Client()},
+				Custom: rest_datasource.ConfigJSON(rest_datasource.Configuration{
+					Fetch: rest_datasource.FetchConfiguration{
+						URL:    server.URL + "/{{ .arguments.name }}",
+						Method: "GET",
+						Header: http.Header{
+							"Authorization": []string{"{{ .request.headers.Authorization }}"},
+						},
+					},
+				}),
+			}
+		}
+
+		cache, err := NewLRUResponseCache(16)
+		require.NoError(t, err)
+
+		engineConf := NewEngineV2Configuration(schema)
+		engineConf.SetDataSources([]plan.DataSourceConfiguration{
+			dataSource("Query", "hero"),
+			dataSource("Mutation", "renameHero"),
+		})
+		engineConf.SetFieldConfigurations(plan.FieldConfigurations{
+			{TypeName: "Query", FieldName: "hero", Path: []string{"name"}},
+			{TypeName: "Mutation", FieldName: "renameHero", Path: []string{"name"}},
+		})
+		engineConf.SetResponseCache(cache, time.Minute)
+		engine, err := NewExecutionEngineV2(context.Background(), nil, engineConf)
+		require.NoError(t, err)
+		return engine, cache.(*lruResponseCache), &upstreamRequests
+	}
+
+	executeWithHeader := func(t *testing.T, engine *ExecutionEngineV2, query, name string, header http.Header, options ...ExecutionOptionsV2) string {
+		resultWriter := NewEngineResultWriter()
+		operation := &Request{Query: query, Variables: stringify(map[string]interface{}{"name": name})}
+		operation.SetHeader(header)
+		require.NoError(t, engine.Execute(context.Background(), operation, &resultWriter, options...))
+		return resultWriter.String()
+	}
+
+	execute := func(t *testing.T, engine *ExecutionEngineV2, query, name string, options ...ExecutionOptionsV2) string {
+		return executeWithHeader(t, engine, query, name, nil, options...)
+	}
+
+	const heroQuery = `query Hero($name: String) { hero(name: $name) }`
+
+	t.Run("hit", func(t *testing.T) {
+		engine, _, upstreamRequests := setup(t)
+		assert.Equal(t, `{"data":{"hero":"luke"}}`, execute(t, engine, heroQuery, "luke"))
+		assert.Equal(t, `{"data":{"hero":"luke"}}`, execute(t, engine, heroQuery, "luke"))
+		assert.Equal(t, int64(1), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("miss on different variables", func(t *testing.T) {
+		engine, _, upstreamRequests := setup(t)
+		assert.Equal(t, `{"data":{"hero":"luke"}}`, execute(t, engine, heroQuery, "luke"))
+		assert.Equal(t, `{"data":{"hero":"leia"}}`, execute(t, engine, heroQuery, "leia"))
+		assert.Equal(t, int64(2), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("miss on different headers", func(t *testing.T) {
+		engine, _, upstreamRequests := setup(t)
+		alice := http.Header{"Authorization": []string{"alice"}}
+		bob := http.Header{"Authorization": []string{"bob"}}
+		assert.Equal(t, `{"data":{"hero":"luke:alice"}}`, executeWithHeader(t, engine, heroQuery, "luke", alice))
+		assert.Equal(t, `{"data":{"hero":"luke:bob"}}`, executeWithHeader(t, engine, heroQuery, "luke", bob))
+		assert.Equal(t, `{"data":{"hero":"luke:alice"}}`, executeWithHeader(t, engine, heroQuery, "luke", alice))
+		assert.Equal(t, int64(2), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("boundaries of header values are part of the key", func(t *testing.T) {
+		engine, _, _ := setup(t)
+		key := func(header http.Header) ResponseCacheKey {
+			operation := &Request{Query: heroQuery}
+			report := operation.parseQueryOnce()
+			require.False(t, report.HasErrors())
+			execContext := newInternalExecutionContext()
+			execContext.resolveContext.Request.Header = header
+			key, ok := engine.responseCacheKey(execContext, operation, schema)
+			require.True(t, ok)
+			return key
+		}
+		assert.Equal(t, key(http.Header{"Authorization": {"alice"}}), key(http.Header{"Authorization": {"alice"}}))
+		assert.NotEqual(t, key(http.Header{"Authorization": {"alice", "bob"}}), key(http.Header{"Authorization": {"alice\x00bob"}}))
+		assert.NotEqual(t, key(http.Header{"Authorization": {"alice"}, "X-Bob": nil}), key(http.Header{"Authorization": {"alice", "X-Bob"}}))
+	})
+
+	t.Run("responses of executions which started before a schema reload aren't stored", func(t *testing.T) {
+		engine, cache, _ := setup(t)
+		reloaded, err := NewSchemaFromString(schemaDefinition)
+		require.NoError(t, err)
+		execute(t, engine, heroQuery, "luke", WithBeforeFetchHook(reloadSchemaHook{engine: engine, schema: reloaded}))
+		assert.Equal(t, 0, cache.cache.Len())
+	})
+
+	t.Run("mutations bypass the cache", func(t *testing.T) {
+		engine, _, upstreamRequests := setup(t)
+		const renameMutation = `mutation Rename($name: String) { renameHero(name: $name) }`
+		assert.Equal(t, `{"data":{"renameHero":"han"}}`, execute(t, engine, renameMutation, "han"))
+		assert.Equal(t, `{"data":{"renameHero":"han"}}`, execute(t, engine, renameMutation, "han"))
+		assert.Equal(t, int64(2), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("expired responses are resolved again", func(t *testing.T) {
+		engine, cache, upstreamRequests := setup(t)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		execute(t, engine, heroQuery, "luke")
+		now = now.Add(time.Minute)
+		execute(t, engine, heroQuery, "luke")
+		assert.Equal(t, int64(2), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("explicit bypass", func(t *testing.T) {
+		engine, _, upstreamRequests := setup(t)
+		execute(t, engine, heroQuery, "luke")
+		execute(t, engine, heroQuery, "luke", WithResponseCacheBypass())
+		execute(t, engine, heroQuery, "luke", WithCacheControl("no-cache"))
+		assert.Equal(t, int64(3), atomic.LoadInt64(upstreamRequests))
+	})
+
+	t.Run("max-age overrides the ttl", func(t *testing.T) {
+		engine, cache, upstreamRequests := setup(t)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		execute(t, engine, heroQuery, "luke", WithCacheControl("public, max-age=5"))
+		now = now.Add(time.Second * 5)
+		execute(t, engine, heroQuery, "luke", WithResponseCacheMaxAge(0))
+		execute(t, engine, heroQuery, "luke")
+		assert.Equal(t, int64(3), atomic.LoadInt64(upstreamRequests))
+	})
+}
+
+// reloadSchemaHook reloads the schema of the engine while the execution is resolving
+type reloadSchemaHook struct {
+	engine *ExecutionEngineV2
+	schema *Schema
+}
+
+func (h reloadSchemaHook) OnBeforeFetch(ctx resolve.HookContext, input []byte) {
+	_ = h.engine.ReloadSchema(h.schema, h.engine.config.plannerConfig)
+}
@@ -218,3 +218,18 @@ func ErrEnumValueNameMustBeUnique(enumName, enumValueName ast.ByteSlice) (err Ex
 	err.Message = fmt.Sprintf("enum value '%s.%s' can only be defined once", enumName, enumValueName)
 	return err
 }
+
+func ErrStreamInitialCountMustBeNonNegative(initialCount int) (err ExternalError) {
+	err.Message = fmt.Sprintf("initialCount of @stream must be a non-negative integer, got: %d", initialCount)
+	return err
+}
+
+func ErrQueryExceedsMaxDepth(maxDepth int) (err ExternalError) {
+	err.Message = fmt.Sprintf("query exceeds maximum depth of %d", maxDepth)
+	return err
+}
+
+func ErrQueryExceedsMaxComplexity(complexity, maxComplexity int) (err ExternalError) {
+	err.Message = fmt.Sprintf("query complexity of %d exceeds the maximum complexity of %d", complexity, maxComplexity)
+	return err
+}
@@ -23,6 +23,8 @@ func (p *ProcessDefer) Process(pre plan.Plan) plan.Plan {
 		return p.synchronousResponse(in)
 	case *plan.StreamingResponsePlan:
 		return p.processStreamingResponsePlan(in)
+	case *plan.SubscriptionResponsePlan:
+		return p.subscriptionResponse(in)
 	default:
 		return pre
 	}
@@ -52,6 +54,28 @@ func (p *ProcessDefer) synchronousResponse(pre *plan.SynchronousResponsePlan) pl
 	return pre
 }
 
+func (p *ProcessDefer) subscriptionResponse(pre *plan.SubscriptionResponsePlan) plan.Plan {
+	if pre.Response.StreamingResponse != nil {
+		p.processStreamingResponsePlan(&plan.StreamingResponsePlan{
+			FlushInterval: pre.FlushInterval,
+			Response:      pre.Response.StreamingResponse,
+		})
+		return pre
+	}
+	p.out = &plan.StreamingResponsePlan{
+		FlushInterval: pre.FlushInterval,
+		Response: &resolve.GraphQLStreamingResponse{
+			InitialResponse: pre.Response.Response,
+			FlushInterval:   pre.FlushInterval,
+		},
+	}
+	p.traverseNode(p.out.Response.InitialResponse.Data)
+	if p.updated {
+		pre.Response.StreamingResponse = p.out.Response
+	}
+	return pre
+}
+
 func (p *ProcessDefer) traverseNode(node resolve.Node) {
 
 	switch n := node.(type) {
@@ -29,7 +29,7 @@ func TestDefaultProcessor_Process(t *testing.T) {
 						BufferID:  0,
 						Name:      []byte("users"),
 						Stream: &resolve.StreamField{
-							InitialBatchSize: 0,
+							InitialCount: 0,
 						},
 						Value: &resolve.Array{
 							Item: &resolve.Object{
@@ -111,9 +111,9 @@ func TestDefaultProcessor_Process(t *testing.T) {
 							Name:      []byte("users"),
 							Value: &resolve.Array{
 								Stream: resolve.Stream{
-									Enabled:          true,
-									InitialBatchSize: 0,
-									PatchIndex:       1,
+									Enabled:      true,
+									InitialCount: 0,
+									PatchIndex:   1,
 								},
 							},
 						},
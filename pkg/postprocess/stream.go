@@ -21,6 +21,8 @@ func (p *ProcessStream) Process(pre plan.Plan) plan.Plan {
 		return p.processSynchronousPlan(in)
 	case *plan.StreamingResponsePlan:
 		return p.processStreamingResponsePlan(in)
+	case *plan.SubscriptionResponsePlan:
+		return p.processSubscriptionPlan(in)
 	default:
 		return pre
 	}
@@ -50,6 +52,28 @@ func (p *ProcessStream) processSynchronousPlan(in *plan.SynchronousResponsePlan)
 	return in
 }
 
+func (p *ProcessStream) processSubscriptionPlan(in *plan.SubscriptionResponsePlan) plan.Plan {
+	if in.Response.StreamingResponse != nil {
+		p.processStreamingResponsePlan(&plan.StreamingResponsePlan{
+			FlushInterval: in.FlushInterval,
+			Response:      in.Response.StreamingResponse,
+		})
+		return in
+	}
+	p.out = &plan.StreamingResponsePlan{
+		FlushInterval: in.FlushInterval,
+		Response: &resolve.GraphQLStreamingResponse{
+			InitialResponse: in.Response.Response,
+			FlushInterval:   in.FlushInterval,
+		},
+	}
+	p.traverseNode(in.Response.Response.Data)
+	if p.didUpdate {
+		in.Response.StreamingResponse = p.out.Response
+	}
+	return in
+}
+
 func (p *ProcessStream) traverseNode(node resolve.Node) {
 	switch n := node.(type) {
 	case *resolve.Object:
@@ -58,7 +82,8 @@ func (p *ProcessStream) traverseNode(node resolve.Node) {
 				switch array := n.Fields[i].Value.(type) {
 				case *resolve.Array:
 					array.Stream.Enabled = true
-					array.Stream.InitialBatchSize = n.Fields[i].Stream.InitialBatchSize
+					array.Stream.InitialCount = initialCount(n.Fields[i].Stream.InitialCount, n.Fields[i].Stream.InitialBatchSize)
+					array.Stream.InitialBatchSize = 0
 					n.Fields[i].Stream = nil
 				}
 			}
@@ -67,11 +92,13 @@ func (p *ProcessStream) traverseNode(node resolve.Node) {
 	case *resolve.Array:
 		if n.Stream.Enabled {
 			p.didUpdate = true
+			n.Stream.InitialCount = initialCount(n.Stream.InitialCount, n.Stream.InitialBatchSize)
+			n.Stream.InitialBatchSize = 0
 			patch := &resolve.GraphQLResponsePatch{
 				Value:     n.Item,
 				Operation: literal.ADD,
 			}
-			if n.Stream.InitialBatchSize == 0 {
+			if n.Stream.InitialCount == 0 {
 				n.Item = nil
 			}
 			p.out.Response.Patches = append(p.out.Response.Patches, patch)
@@ -84,3 +111,12 @@ func (p *ProcessStream) traverseNode(node resolve.Node) {
 		p.traverseNode(n.Item)
 	}
 }
+
+// initialCount reconciles the deprecated InitialBatchSize of plans built by hand with the InitialCount, so that the resolver
+// only reads InitialCount. The planner only sets InitialCount, an explicit initialCount of 0 is never overridden.
+func initialCount(initialCount, initialBatchSize int) int {
+	if initialCount == 0 {
+		return initialBatchSize
+	}
+	return initialCount
+}
@@ -29,7 +29,7 @@ func TestProcessStream_Process(t *testing.T) {
 						BufferID:  0,
 						Name:      []byte("users"),
 						Stream: &resolve.StreamField{
-							InitialBatchSize: 0,
+							InitialCount: 0,
 						},
 						Value: &resolve.Array{
 							Item: &resolve.Object{
@@ -108,9 +108,9 @@ func TestProcessStream_Process(t *testing.T) {
 							Name:      []byte("users"),
 							Value: &resolve.Array{
 								Stream: resolve.Stream{
-									Enabled:          true,
-									InitialBatchSize: 0,
-									PatchIndex:       0,
+									Enabled:      true,
+									InitialCount: 0,
+									PatchIndex:   0,
 								},
 							},
 						},
@@ -203,7 +203,7 @@ func TestProcessStream_Process_BatchSize_1(t *testing.T) {
 						BufferID:  0,
 						Name:      []byte("users"),
 						Stream: &resolve.StreamField{
-							InitialBatchSize: 1,
+							InitialCount: 1,
 						},
 						Value: &resolve.Array{
 							Item: &resolve.Object{
@@ -282,8 +282,8 @@ func TestProcessStream_Process_BatchSize_1(t *testing.T) {
 							Name:      []byte("users"),
 							Value: &resolve.Array{
 								Stream: resolve.Stream{
-									Enabled:          true,
-									InitialBatchSize: 1,
+									Enabled:      true,
+									InitialCount: 1,
 								},
 								Item: &resolve.Object{
 									Fetch: &resolve.SingleFetch{
@@ -409,3 +409,113 @@ func TestProcessStream_Process_BatchSize_1(t *testing.T) {
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestProcessStream_Process_Subscription(t *testing.T) {
+	original := &plan.SubscriptionResponsePlan{
+		FlushInterval: 10,
+		Response: &resolve.GraphQLSubscription{
+			Response: &resolve.GraphQLResponse{
+				Data: &resolve.Object{
+					Fields: []*resolve.Field{
+						{
+							Name: []byte("users"),
+							Stream: &resolve.StreamField{
+								InitialCount: 1,
+							},
+							Value: &resolve.Array{
+								Path: []string{"users"},
+								Item: &resolve.Object{
+									Fields: []*resolve.Field{
+										{
+											Name: []byte("id"),
+											Value: &resolve.Integer{
+												Path: []string{"id"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	proc := &ProcessStream{}
+	actual := proc.Process(original).(*plan.SubscriptionResponsePlan)
+
+	assert.Equal(t, original, actual)
+	assert.NotNil(t, actual.Response.StreamingResponse)
+	assert.Equal(t, int64(10), actual.Response.StreamingResponse.FlushInterval)
+	assert.Equal(t, actual.Response.Response, actual.Response.StreamingResponse.InitialResponse)
+	assert.Len(t, actual.Response.StreamingResponse.Patches, 1)
+	assert.Equal(t, literal.ADD, actual.Response.StreamingResponse.Patches[0].Operation)
+}
+
+func TestProcessStream_Process_SubscriptionWithoutStream(t *testing.T) {
+	original := &plan.SubscriptionResponsePlan{
+		Response: &resolve.GraphQLSubscription{
+			Response: &resolve.GraphQLResponse{
+				Data: &resolve.Object{
+					Fields: []*resolve.Field{
+						{
+							Name: []byte("id"),
+							Value: &resolve.Integer{
+								Path: []string{"id"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	proc := &ProcessStream{}
+	actual := proc.Process(original).(*plan.SubscriptionResponsePlan)
+
+	assert.Nil(t, actual.Response.StreamingResponse)
+}
+
+func TestProcessStream_Process_DeprecatedInitialBatchSize(t *testing.T) {
+	process := func(field *resolve.StreamField, stream resolve.Stream) resolve.Stream {
+		array := &resolve.Array{
+			Path:   []string{"users"},
+			Stream: stream,
+			Item: &resolve.String{
+				Path: []string{"name"},
+			},
+		}
+		original := &plan.SynchronousResponsePlan{
+			Response: &resolve.GraphQLResponse{
+				Data: &resolve.Object{
+					Fields: []*resolve.Field{
+						{
+							Name:   []byte("users"),
+							Stream: field,
+							Value:  array,
+						},
+					},
+				},
+			},
+		}
+		proc := &ProcessStream{}
+		proc.Process(original)
+		return array.Stream
+	}
+
+	t.Run("stream field", func(t *testing.T) {
+		stream := process(&resolve.StreamField{InitialBatchSize: 2}, resolve.Stream{})
+		assert.Equal(t, 2, stream.InitialCount)
+		assert.Equal(t, 0, stream.InitialBatchSize)
+	})
+	t.Run("stream of an array built by hand", func(t *testing.T) {
+		stream := process(nil, resolve.Stream{Enabled: true, InitialBatchSize: 2})
+		assert.Equal(t, 2, stream.InitialCount)
+		assert.Equal(t, 0, stream.InitialBatchSize)
+	})
+	t.Run("initial count takes precedence", func(t *testing.T) {
+		stream := process(&resolve.StreamField{InitialCount: 1, InitialBatchSize: 2}, resolve.Stream{})
+		assert.Equal(t, 1, stream.InitialCount)
+	})
+}